@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	ScopeCredit   = "credit"
+	ScopeWithdraw = "withdraw"
+	ScopeTransfer = "transfer"
+	ScopeRead     = "read"
+	ScopeAdmin    = "admin"
+)
+
+type Client struct {
+	Name   string
+	Scopes map[string]bool
+}
+
+type clientKey struct{}
+
+func (c *Client) HasScope(scope string) bool {
+	return c.Scopes[scope]
+}
+
+// HashKey возвращает sha256 от ключа в hex, в таком виде ключи хранятся в конфиге
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func WithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func ClientFromContext(ctx context.Context) *Client {
+	client, _ := ctx.Value(clientKey{}).(*Client)
+	return client
+}
+
+// ClientName возвращает имя вызывающего клиента или пустую строку для внутренних вызовов
+func ClientName(ctx context.Context) string {
+	if client := ClientFromContext(ctx); client != nil {
+		return client.Name
+	}
+
+	return ""
+}
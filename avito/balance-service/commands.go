@@ -0,0 +1,112 @@
+package main
+
+import (
+	"avito/auth"
+	"avito/logger"
+	"avito/service"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"os"
+)
+
+// имя клиента, от которого служебные команды записывают изменения
+const commandClient = "balance-service-cli"
+
+// runCommand выполняет служебную команду и возвращает код завершения процесса
+func runCommand(ctx context.Context, serviceAPI service.ServiceAPI, name string, args []string) int {
+	ctx = auth.WithClient(ctx, &auth.Client{Name: commandClient})
+	// stdout занят результатом команды
+	logger.SetOutput(os.Stderr)
+
+	switch name {
+	case "reconcile":
+		return runReconcile(ctx, serviceAPI, args)
+	case "verify-ledger":
+		return runVerifyLedger(ctx, serviceAPI, args)
+	case "archive":
+		return runArchive(ctx, serviceAPI)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %s, available commands: reconcile, verify-ledger, archive\n", name)
+		return 2
+	}
+}
+
+// runReconcile печатает отчет сверки в JSON. Код 1 - остались неисправленные расхождения
+func runReconcile(ctx context.Context, serviceAPI service.ServiceAPI, args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "adjust mismatched balances to the sum of their postings")
+	reason := flags.String("reason", "", "audit reason for adjustments, required with -fix")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err, _ := serviceAPI.GetLedgerService().ReconcileRequest(ctx, *fix, *reason)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Reconciliation failed: %v\n", err)
+		return 2
+	}
+
+	printJSON(report)
+
+	for _, mismatch := range report.Mismatches {
+		if !mismatch.Corrected {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// runVerifyLedger печатает результат проверки цепочек хешей в JSON. Код 1 - найдены разрывы
+func runVerifyLedger(ctx context.Context, serviceAPI service.ServiceAPI, args []string) int {
+	flags := flag.NewFlagSet("verify-ledger", flag.ContinueOnError)
+	user := flags.String("user", "", "verify only the chain of this account")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var userID *uuid.UUID
+	if *user != "" {
+		id, err := uuid.Parse(*user)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Incorrect value of user: %v\n", err)
+			return 2
+		}
+		userID = &id
+	}
+
+	result, err, _ := serviceAPI.GetLedgerService().VerifyLedgerRequest(ctx, userID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		return 2
+	}
+
+	printJSON(result)
+
+	if !result.Valid {
+		return 1
+	}
+
+	return 0
+}
+
+// runArchive создает секции и выгружает месяцы старше срока хранения, печатает отчет в JSON
+func runArchive(ctx context.Context, serviceAPI service.ServiceAPI) int {
+	report, err, _ := serviceAPI.GetArchiveService().ArchiveRequest(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Archiving failed: %v\n", err)
+		return 2
+	}
+
+	printJSON(report)
+	return 0
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -2,49 +2,97 @@ package main
 
 import (
 	"avito/handlers"
+	"avito/logger"
 	"avito/storage"
+	"avito/storage/sqlite"
 	"avito/service"
 	"context"
 	"fmt"
-	"github.com/gorilla/mux"
-	"log"
+	"golang.org/x/xerrors"
 	"net/http"
+	"os"
 	"avito/config"
 	"avito/db"
 )
 
 func main() {
+	log := logger.New("main")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	applicationConfig, err := config.ParseConfig()
 	if err != nil {
-		log.Fatalf("Cannot parse config: %+v", err)
+		log.Fatalf(ctx, "Cannot parse config: %+v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	pgConn, err := db.NewConnectToPG(&applicationConfig.DB, ctx)
+	level, err := logger.ParseLevel(applicationConfig.LogLevel)
 	if err != nil {
-		log.Fatalf("Cannot connect to DB, reason: %v", err)
+		log.Fatalf(ctx, "Cannot parse config: %v", err)
+	}
+	logger.SetLevel(level)
+
+	storageAPI, err := newStorageAPI(ctx, &applicationConfig.DB)
+	if err != nil {
+		log.Fatalf(ctx, "Cannot connect to DB, reason: %v", err)
+	}
+
+	serviceAPI := service.NewServiceAPI(storageAPI, applicationConfig)
+	if err := serviceAPI.GetSystemAccountService().EnsureSystemAccounts(ctx); err != nil {
+		log.Fatalf(ctx, "Cannot create system accounts, reason: %v", err)
+	}
+
+	// служебные команды выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(ctx, serviceAPI, os.Args[1], os.Args[2:]))
 	}
 
-	storageAPI := storage.NewStorageAPI(pgConn, ctx)
-	serviceAPI := service.NewServiceAPI(storageAPI)
-
-	a := handlers.NewHandlers(serviceAPI)
-
-	r := mux.NewRouter()
-	// зачисление денежных средств
-	r.HandleFunc("/balance/credit", a.CreditFundsHandler)
-	// списание денежных средств
-	r.HandleFunc("/balance/withdraw", a.WithdrawFundsHandler)
-	// перевод денежных средств другому пользователю
-	r.HandleFunc("/balance/transfer", a.TransferFundsHandler)
-	// получение текущего баланса
-	r.HandleFunc("/balance/get", a.GetBalanceHandler)
-	// получение
-	r.HandleFunc("/balance/transactions", a.GetTransactionsHandler)
-	http.Handle("/", r)
-
-	fmt.Println("Server is listening...")
+	serviceAPI.GetWebhookService().ResumeDeliveries(ctx)
+	go serviceAPI.GetScheduleService().Start(ctx)
+	go serviceAPI.GetEscrowService().Start(ctx)
+	go serviceAPI.GetInvoiceService().Start(ctx)
+	go serviceAPI.GetMoneyRequestService().Start(ctx)
+	go serviceAPI.GetArchiveService().Start(ctx)
+
+	http.Handle("/", handlers.NewRouter(serviceAPI, applicationConfig))
+
+	log.Infof(ctx, "Server is listening on port %d", applicationConfig.HTTPPort)
 	http.ListenAndServe(fmt.Sprintf(":%d", applicationConfig.HTTPPort), nil)
 }
+
+// newStorageAPI подключается к хранилищу, выбранному параметром db_driver
+func newStorageAPI(ctx context.Context, dbConfig *config.DBConfig) (storage.StorageAPI, error) {
+	switch dbConfig.Driver {
+	case "", config.DriverPostgres:
+		pgConn, err := db.NewConnectToPG(dbConfig, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if dbConfig.ReplicaDSN == "" {
+			return storage.NewStorageAPI(pgConn, ctx), nil
+		}
+
+		// до первой успешной проверки отставания чтения выполняются в основной базе
+		replicaConn, err := db.NewLazyConnectToPGDSN(dbConfig.ReplicaDSN, ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("Cannot connect to replica: %v", err)
+		}
+		replica := db.NewReplica(replicaConn, dbConfig.ReplicaMaxLag)
+		go replica.Monitor(ctx, dbConfig.ReplicaLagCheckInterval)
+
+		return storage.NewStorageAPIWithReplica(pgConn, replica, ctx), nil
+	case config.DriverSQLite:
+		if dbConfig.ReplicaDSN != "" {
+			return nil, xerrors.Errorf("db_replica_dsn is supported only by postgres")
+		}
+
+		sqliteDB, err := sqlite.Open(dbConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return sqlite.NewStorageAPI(sqliteDB, ctx), nil
+	default:
+		return nil, xerrors.Errorf("unknown db_driver %q", dbConfig.Driver)
+	}
+}
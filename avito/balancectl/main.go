@@ -0,0 +1,315 @@
+// balancectl - утилита администратора для работы с балансами через API сервиса
+package main
+
+import (
+	"avito/client"
+	"avito/dto"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: balancectl [flags] <command> [command flags]
+
+Commands:
+  balance    -user ID [-currency CUR]                     show balance
+  history    -user ID [-limit N] [-offset N]              show transactions
+  status     -user ID                                     show account status and its history
+  credit     -user ID -amount SUM -reason CODE [-comment] credit funds
+  withdraw   -user ID -amount SUM -reason CODE [-comment] withdraw funds
+  adjust     -user ID -amount SUM -reason CODE [-comment] set balance to SUM
+  freeze     -user ID -reason TEXT                        forbid debits from the account
+  unfreeze   -user ID -reason TEXT                        make the account active again
+
+Reason codes: %s
+
+Flags:
+`
+
+type command struct {
+	client *client.Client
+	output string
+}
+
+func main() {
+	flags := flag.NewFlagSet("balancectl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, strings.Join(dto.AdminReasons, ", "))
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", envOrDefault("BALANCE_API_URL", "http://localhost:9000"), "service address, env BALANCE_API_URL")
+	key := flags.String("key", os.Getenv("BALANCE_API_KEY"), "API key with admin scope, env BALANCE_API_KEY")
+	output := flags.String("output", "table", "output format: table or json")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of the command")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 || (*output != "table" && *output != "json") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	// администратору нужен баланс основной базы, в том числе сразу после его изменения
+	ctx = client.WithStrongConsistency(ctx)
+
+	c := &command{client: client.NewClient(client.Config{BaseURL: *addr, APIKey: *key}), output: *output}
+	os.Exit(c.run(ctx, flags.Arg(0), flags.Args()[1:]))
+}
+
+// run выполняет команду и возвращает код завершения: 1 - ошибка API, 2 - неверные аргументы
+func (c *command) run(ctx context.Context, name string, args []string) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	user := flags.String("user", "", "user id")
+
+	var err error
+	switch name {
+	case "balance":
+		currency := flags.String("currency", "", "convert balance to this currency")
+		if userID, ok := parseArgs(flags, args, user); ok {
+			err = c.balance(ctx, userID, *currency)
+		} else {
+			return 2
+		}
+	case "history":
+		limit := flags.Int("limit", 20, "number of transactions")
+		offset := flags.Int("offset", 0, "number of transactions to skip")
+		if userID, ok := parseArgs(flags, args, user); ok {
+			err = c.history(ctx, userID, client.Page{Limit: *limit, Offset: *offset})
+		} else {
+			return 2
+		}
+	case "status":
+		if userID, ok := parseArgs(flags, args, user); ok {
+			err = c.status(ctx, userID)
+		} else {
+			return 2
+		}
+	case "credit", "withdraw", "adjust":
+		amount := flags.String("amount", "", "amount, e.g. 100.50; for adjust - the resulting balance")
+		reason := flags.String("reason", "", "reason code: "+strings.Join(dto.AdminReasons, ", "))
+		comment := flags.String("comment", "", "free text added to the reason")
+		userID, ok := parseArgs(flags, args, user)
+		if !ok {
+			return 2
+		}
+		sum, parseErr := parseMoney(*amount)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "Incorrect value of amount: %v\n", parseErr)
+			return 2
+		}
+		if *reason == "" {
+			fmt.Fprintf(os.Stderr, "-reason is required\n")
+			return 2
+		}
+		err = c.operation(ctx, name, dto.AdminOperationRequest{UserId: userID, Sum: sum, Reason: *reason, Comment: *comment})
+	case "freeze", "unfreeze":
+		reason := flags.String("reason", "", "reason of the status change")
+		userID, ok := parseArgs(flags, args, user)
+		if !ok {
+			return 2
+		}
+		if *reason == "" {
+			fmt.Fprintf(os.Stderr, "-reason is required\n")
+			return 2
+		}
+		status := dto.AccountFrozenDebits
+		if name == "unfreeze" {
+			status = dto.AccountActive
+		}
+		err = c.setStatus(ctx, dto.SetAccountStatusRequest{UserId: userID, Status: status, Reason: *reason})
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %s, run balancectl -help for the list of commands\n", name)
+		return 2
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
+
+func (c *command) balance(ctx context.Context, userID uuid.UUID, currency string) error {
+	balance, err := c.client.GetBalance(ctx, userID, currency)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return printJSON(map[string]interface{}{"user_id": userID, "amount": balance, "currency": currency})
+	}
+
+	w := newTable("USER", "BALANCE")
+	fmt.Fprintf(w, "%v\t%s\n", userID, formatMoney(balance))
+	return w.Flush()
+}
+
+func (c *command) history(ctx context.Context, userID uuid.UUID, page client.Page) error {
+	history, err := c.client.GetTransactionHistory(ctx, userID, page)
+	if err != nil {
+		return err
+	}
+
+	// вывод движений не меняется, архивные месяцы печатаются в stderr
+	for _, archived := range history.Archived {
+		fmt.Fprintf(os.Stderr, "%d transactions from %s to %s are archived in %s\n", archived.Postings, archived.From, archived.To, archived.File)
+	}
+
+	if c.output == "json" {
+		return printJSON(history.Transactions)
+	}
+
+	w := newTable("CREATED AT", "OPERATION", "CHANGE", "CLIENT", "COMMENT", "ID")
+	for _, t := range history.Transactions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\n", t.CreatedAt, t.Operation, formatMoney(t.ChangeBalance), t.Client, t.Comment, t.Id)
+	}
+	return w.Flush()
+}
+
+func (c *command) status(ctx context.Context, userID uuid.UUID) error {
+	account, err := c.client.GetAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "json" {
+		return printJSON(account)
+	}
+
+	w := newTable("USER", "STATUS", "REASON")
+	fmt.Fprintf(w, "%v\t%s\t%s\n", account.UserId, account.Status, account.Reason)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(account.History) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w = newTable("CHANGED AT", "OLD STATUS", "NEW STATUS", "REASON", "CLIENT")
+	for _, change := range account.History {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", change.CreatedAt, change.OldStatus, change.NewStatus, change.Reason, change.Client)
+	}
+	return w.Flush()
+}
+
+// operation выполняет административную операцию и печатает баланс после нее
+func (c *command) operation(ctx context.Context, name string, request dto.AdminOperationRequest) error {
+	var err error
+	switch name {
+	case "credit":
+		err = c.client.AdminCredit(ctx, request)
+	case "withdraw":
+		err = c.client.AdminWithdraw(ctx, request)
+	case "adjust":
+		err = c.client.AdjustBalance(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.balance(ctx, request.UserId, "")
+}
+
+func (c *command) setStatus(ctx context.Context, request dto.SetAccountStatusRequest) error {
+	if err := c.client.SetAccountStatus(ctx, request); err != nil {
+		return err
+	}
+
+	return c.status(ctx, request.UserId)
+}
+
+func parseArgs(flags *flag.FlagSet, args []string, user *string) (uuid.UUID, bool) {
+	if err := flags.Parse(args); err != nil {
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(*user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Incorrect value of user: %v\n", err)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// parseMoney разбирает неотрицательную сумму в рублях с копейками: 100, 100.5, 100.05
+func parseMoney(value string) (*dto.Money, error) {
+	parts := strings.SplitN(value, ".", 2)
+	intPart, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || intPart < 0 {
+		return nil, xerrors.Errorf("incorrect amount %q", value)
+	}
+
+	var fracPart int64
+	if len(parts) == 2 {
+		frac := parts[1]
+		if len(frac) == 0 || len(frac) > 2 {
+			return nil, xerrors.Errorf("incorrect amount %q", value)
+		}
+		if len(frac) == 1 {
+			frac += "0"
+		}
+		fracPart, err = strconv.ParseInt(frac, 10, 64)
+		if err != nil || fracPart < 0 {
+			return nil, xerrors.Errorf("incorrect amount %q", value)
+		}
+	}
+
+	return &dto.Money{IntPart: intPart, FracPart: fracPart}, nil
+}
+
+func formatMoney(money *dto.Money) string {
+	if money == nil {
+		return ""
+	}
+
+	sum := money.IntPart*100 + money.FracPart
+	sign := ""
+	if sum < 0 {
+		sign, sum = "-", -sum
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, sum/100, sum%100)
+}
+
+func newTable(columns ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return w
+}
+
+func printJSON(value interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func fail(err error) int {
+	var apiError *client.Error
+	if xerrors.As(err, &apiError) && apiError.RequestID != "" {
+		fmt.Fprintf(os.Stderr, "Error: %v (request id %s)\n", err, apiError.RequestID)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+
+	return 1
+}
+
+func envOrDefault(name string, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return value
+}
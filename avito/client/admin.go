@@ -0,0 +1,180 @@
+package client
+
+import (
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) AdminCredit(ctx context.Context, request dto.AdminOperationRequest) error {
+	return c.post(ctx, "/admin/balance/credit", nil, request, nil)
+}
+
+func (c *Client) AdminWithdraw(ctx context.Context, request dto.AdminOperationRequest) error {
+	return c.post(ctx, "/admin/balance/withdraw", nil, request, nil)
+}
+
+// AdjustBalance устанавливает баланс пользователя равным request.Sum
+func (c *Client) AdjustBalance(ctx context.Context, request dto.AdminOperationRequest) error {
+	return c.post(ctx, "/admin/balance/adjust", nil, request, nil)
+}
+
+func (c *Client) RegisterWebhook(ctx context.Context, request dto.WebhookRequest) (*dto.Webhook, error) {
+	var result dto.Webhook
+	if err := c.post(ctx, "/admin/webhooks/create", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetWebhooks(ctx context.Context) ([]dto.Webhook, error) {
+	var result dto.GetWebhooksResponse
+	if err := c.get(ctx, "/admin/webhooks/list", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Webhooks, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.post(ctx, "/admin/webhooks/delete", url.Values{"id": {id.String()}}, nil, nil)
+}
+
+// GetWebhookDeliveries возвращает доставки событий; nil webhookID и пустой status не ограничивают выборку
+func (c *Client) GetWebhookDeliveries(ctx context.Context, webhookID *uuid.UUID, status string, page Page) ([]dto.WebhookDelivery, error) {
+	query := page.apply(nil)
+	if webhookID != nil {
+		query.Set("webhook_id", webhookID.String())
+	}
+	if status != "" {
+		query.Set("status", status)
+	}
+
+	var result dto.GetWebhookDeliveriesResponse
+	if err := c.get(ctx, "/admin/webhooks/deliveries", query, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Deliveries, nil
+}
+
+func (c *Client) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) error {
+	return c.post(ctx, "/admin/webhooks/replay", url.Values{"id": {id.String()}}, nil, nil)
+}
+
+func (c *Client) GetUserLimits(ctx context.Context, userID uuid.UUID) (*dto.UserLimits, error) {
+	var result dto.UserLimits
+	if err := c.get(ctx, "/admin/limits/get", url.Values{"user_id": {userID.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) SetUserLimits(ctx context.Context, limits dto.UserLimits) error {
+	return c.post(ctx, "/admin/limits/set", nil, limits, nil)
+}
+
+func (c *Client) ResetUserLimits(ctx context.Context, userID uuid.UUID) error {
+	return c.post(ctx, "/admin/limits/reset", url.Values{"user_id": {userID.String()}}, nil, nil)
+}
+
+func (c *Client) GetAccountStatus(ctx context.Context, userID uuid.UUID) (*dto.GetAccountStatusResponse, error) {
+	var result dto.GetAccountStatusResponse
+	if err := c.get(ctx, "/admin/accounts/status", url.Values{"user_id": {userID.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) SetAccountStatus(ctx context.Context, request dto.SetAccountStatusRequest) error {
+	return c.post(ctx, "/admin/accounts/set-status", nil, request, nil)
+}
+
+func (c *Client) SetCreditLimit(ctx context.Context, request dto.SetCreditLimitRequest) error {
+	return c.post(ctx, "/admin/accounts/set-credit-limit", nil, request, nil)
+}
+
+func (c *Client) GetOverdraftAccounts(ctx context.Context, page Page) ([]dto.OverdraftAccount, error) {
+	var result dto.GetOverdraftAccountsResponse
+	if err := c.get(ctx, "/admin/accounts/overdraft", page.apply(nil), &result); err != nil {
+		return nil, err
+	}
+
+	return result.Accounts, nil
+}
+
+func (c *Client) GetSystemAccounts(ctx context.Context) (*dto.SystemAccountsReport, error) {
+	var result dto.SystemAccountsReport
+	if err := c.get(ctx, "/admin/system-accounts", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetJournalEntry(ctx context.Context, id uuid.UUID) (*dto.JournalEntry, error) {
+	var result dto.JournalEntry
+	if err := c.get(ctx, "/admin/ledger/entry", url.Values{"id": {id.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Reconcile сверяет балансы с суммами движений, при fix исправляет расхождения с указанной причиной
+func (c *Client) Reconcile(ctx context.Context, fix bool, reason string) (*dto.ReconcileReport, error) {
+	query := url.Values{"fix": {strconv.FormatBool(fix)}}
+	if reason != "" {
+		query.Set("reason", reason)
+	}
+
+	var result dto.ReconcileReport
+	if err := c.post(ctx, "/admin/ledger/reconcile", query, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// VerifyLedger проверяет цепочки хешей движений всех счетов или только userID
+func (c *Client) VerifyLedger(ctx context.Context, userID *uuid.UUID) (*dto.LedgerVerification, error) {
+	query := url.Values{}
+	if userID != nil {
+		query.Set("user_id", userID.String())
+	}
+
+	var result dto.LedgerVerification
+	if err := c.get(ctx, "/admin/ledger/verify", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetAuditLog(ctx context.Context, filter dto.AuditFilter, page Page) ([]dto.AuditEntry, error) {
+	query := page.apply(nil)
+	if filter.UserId != nil {
+		query.Set("user_id", filter.UserId.String())
+	}
+	if filter.Client != "" {
+		query.Set("client", filter.Client)
+	}
+	if filter.From != "" {
+		query.Set("from", filter.From)
+	}
+	if filter.To != "" {
+		query.Set("to", filter.To)
+	}
+
+	var result dto.GetAuditLogResponse
+	if err := c.get(ctx, "/admin/audit", query, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Records, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"avito/dto"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"net/url"
+)
+
+func (c *Client) Credit(ctx context.Context, request dto.OperationRequest) error {
+	return c.post(ctx, "/balance/credit", nil, request, nil)
+}
+
+func (c *Client) Withdraw(ctx context.Context, request dto.OperationRequest) error {
+	return c.post(ctx, "/balance/withdraw", nil, request, nil)
+}
+
+func (c *Client) Transfer(ctx context.Context, request dto.TransferFundsRequest) error {
+	return c.post(ctx, "/balance/transfer", nil, request, nil)
+}
+
+func (c *Client) QuoteTransfer(ctx context.Context, request dto.TransferFundsRequest) (*dto.TransferQuote, error) {
+	var result dto.TransferQuote
+	if err := c.post(ctx, "/balance/transfer/quote", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetBalance возвращает баланс пользователя, при непустом currency - в этой валюте
+func (c *Client) GetBalance(ctx context.Context, userID uuid.UUID, currency string) (*dto.Money, error) {
+	query := url.Values{"user_id": {userID.String()}}
+	if currency != "" {
+		query.Set("currency", currency)
+	}
+	applyConsistency(ctx, query)
+
+	// сервис отвечает строкой вида "Balance: <int_part>.<frac_part>"
+	var response string
+	if err := c.get(ctx, "/balance/get", query, &response); err != nil {
+		return nil, err
+	}
+
+	var result dto.Money
+	if _, err := fmt.Sscanf(response, "Balance: %d.%d", &result.IntPart, &result.FracPart); err != nil {
+		return nil, xerrors.Errorf("Unexpected balance response %q: %w", response, err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetTransactions(ctx context.Context, userID uuid.UUID, page Page) ([]dto.Transaction, error) {
+	result, err := c.GetTransactionHistory(ctx, userID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Transactions, nil
+}
+
+// GetTransactionHistory возвращает страницу движений вместе с месяцами, выгруженными в архив
+func (c *Client) GetTransactionHistory(ctx context.Context, userID uuid.UUID, page Page) (*dto.GetTransactionsResponse, error) {
+	query := applyConsistency(ctx, page.apply(url.Values{"user_id": {userID.String()}}))
+
+	var result dto.GetTransactionsResponse
+	if err := c.get(ctx, "/balance/transactions", query, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// applyConsistency добавляет к запросу чтения параметр consistency, если он задан в ctx
+func applyConsistency(ctx context.Context, query url.Values) url.Values {
+	if strong, _ := ctx.Value(strongConsistencyContextKey).(bool); strong {
+		query.Set("consistency", dto.ConsistencyStrong)
+	}
+
+	return query
+}
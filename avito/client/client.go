@@ -0,0 +1,279 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyHeader         = "X-API-Key"
+	requestIDHeader      = "X-Request-ID"
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+type Config struct {
+	// адрес сервиса, например http://localhost:8080
+	BaseURL string
+	APIKey  string
+	// число попыток запроса, включая первую; 0 - DefaultConfig().MaxAttempts
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// таймаут одной попытки
+	Timeout time.Duration
+	// если не задан, используется http.Client с Timeout
+	HTTPClient *http.Client
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Client - клиент HTTP API сервиса баланса. Безопасен для использования из нескольких горутин
+type Client struct {
+	conf Config
+	http *http.Client
+}
+
+func NewClient(conf Config) *Client {
+	defaults := DefaultConfig()
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaults.MaxAttempts
+	}
+	if conf.InitialBackoff <= 0 {
+		conf.InitialBackoff = defaults.InitialBackoff
+	}
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = defaults.MaxBackoff
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaults.Timeout
+	}
+	conf.BaseURL = strings.TrimRight(conf.BaseURL, "/")
+
+	httpClient := conf.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: conf.Timeout}
+	}
+
+	return &Client{conf: conf, http: httpClient}
+}
+
+type contextKey int
+
+const (
+	idempotencyKeyContextKey contextKey = iota
+	strongConsistencyContextKey
+)
+
+// WithIdempotencyKey задает ключ идемпотентности для запросов с этим контекстом.
+// Без него каждый вызов метода клиента получает новый ключ, общий для всех его попыток
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey, key)
+}
+
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyContextKey).(string); ok && key != "" {
+		return key
+	}
+
+	return uuid.New().String()
+}
+
+// WithStrongConsistency требует, чтобы запросы баланса и истории с этим контекстом читали
+// основную базу сервиса, а не реплику или кэш, например сразу после изменения баланса
+func WithStrongConsistency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongConsistencyContextKey, true)
+}
+
+// Page - параметры постраничной выборки, нулевой Limit означает значение по умолчанию сервиса
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+func (p Page) apply(query url.Values) url.Values {
+	if query == nil {
+		query = url.Values{}
+	}
+	if p.Limit > 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Offset > 0 {
+		query.Set("offset", strconv.Itoa(p.Offset))
+	}
+
+	return query
+}
+
+// get выполняет читающий запрос: его можно безопасно повторить после любой временной ошибки
+func (c *Client) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	return c.do(ctx, http.MethodGet, path, query, nil, result)
+}
+
+// post выполняет изменяющий запрос. Он повторяется только если сервис его гарантированно
+// не обработал: соединение не установлено или запрос отклонен ограничением частоты
+func (c *Client) post(ctx context.Context, path string, query url.Values, body interface{}, result interface{}) error {
+	return c.do(ctx, http.MethodPost, path, query, body, result)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return xerrors.Errorf("Cannot encode request body: %w", err)
+		}
+	}
+
+	target := c.conf.BaseURL + path
+	if len(query) != 0 {
+		target += "?" + query.Encode()
+	}
+
+	key := ""
+	if method != http.MethodGet {
+		key = idempotencyKey(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.attempt(ctx, method, target, key, payload, result)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.conf.MaxAttempts || !retryable(method, err) {
+			return err
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt выполняет одну попытку запроса и возвращает задержку из Retry-After, если она есть
+func (c *Client) attempt(ctx context.Context, method string, target string, key string, payload []byte, result interface{}) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+	if err != nil {
+		return 0, xerrors.Errorf("Cannot create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.conf.APIKey != "" {
+		req.Header.Set(apiKeyHeader, c.conf.APIKey)
+	}
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+		return 0, &transportError{err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, &transportError{err: err, sent: true}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return retryAfter, newError(resp, data)
+	}
+
+	if result == nil {
+		return 0, nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return 0, xerrors.Errorf("Cannot decode response of %s: %w", req.URL.Path, err)
+	}
+
+	return 0, nil
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.conf.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= c.conf.MaxBackoff {
+			return c.conf.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// transportError - ошибка, при которой ответ сервиса не получен.
+// sent означает, что запрос мог дойти до сервиса
+type transportError struct {
+	err  error
+	sent bool
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// notSent сообщает, что соединение с сервисом не было установлено и запрос точно не обработан
+func (e *transportError) notSent() bool {
+	if e.sent {
+		return false
+	}
+
+	var opError *net.OpError
+	return xerrors.As(e.err, &opError) && opError.Op == "dial"
+}
+
+func retryable(method string, err error) bool {
+	var apiError *Error
+	if xerrors.As(err, &apiError) {
+		if apiError.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+		return method == http.MethodGet && apiError.Temporary()
+	}
+
+	var transport *transportError
+	if xerrors.As(err, &transport) {
+		return method == http.MethodGet || transport.notSent()
+	}
+
+	return false
+}
@@ -0,0 +1,342 @@
+package client
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/handlers"
+	"avito/logger"
+	"avito/service"
+	"avito/storage/memory"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// recorder отвечает заранее заданными ответами и запоминает заголовки полученных запросов
+type recorder struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	requests  []*http.Request
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	respond := rec.responses[len(rec.responses)-1]
+	if len(rec.requests) < len(rec.responses) {
+		respond = rec.responses[len(rec.requests)]
+	}
+	rec.requests = append(rec.requests, r)
+	respond(w)
+}
+
+func (rec *recorder) attempts() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.requests)
+}
+
+func respond(status int, body interface{}) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func newTestClient(t *testing.T, responses ...func(w http.ResponseWriter)) (*Client, *recorder, func()) {
+	rec := &recorder{responses: responses}
+	server := httptest.NewServer(rec)
+	c := NewClient(Config{BaseURL: server.URL, APIKey: "key", MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	return c, rec, server.Close
+}
+
+func TestRetriesReadOnServerError(t *testing.T) {
+	unavailable := respond(http.StatusServiceUnavailable, dto.ErrorResponse{Error: "Unavailable"})
+	c, rec, stop := newTestClient(t, unavailable, unavailable, respond(http.StatusOK, "Balance: 10.5"))
+	defer stop()
+
+	userID := uuid.New()
+	balance, err := c.GetBalance(context.Background(), userID, "")
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if *balance != (dto.Money{IntPart: 10, FracPart: 5}) || rec.attempts() != 3 {
+		t.Fatalf("unexpected balance %v after %d attempts", balance, rec.attempts())
+	}
+
+	r := rec.requests[0]
+	if r.Method != http.MethodGet || r.URL.Query().Get("user_id") != userID.String() || r.Header.Get(apiKeyHeader) != "key" {
+		t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+	}
+}
+
+func TestDoesNotRetryMutationOnServerError(t *testing.T) {
+	c, rec, stop := newTestClient(t, respond(http.StatusInternalServerError, dto.ErrorResponse{Error: "System error. Contact support", RequestId: "req-1"}))
+	defer stop()
+
+	err := c.Credit(context.Background(), dto.OperationRequest{UserId: uuid.New(), Sum: &dto.Money{IntPart: 1}})
+	if !xerrors.Is(err, ErrInternal) || rec.attempts() != 1 {
+		t.Fatalf("expected single failed attempt, got %v after %d attempts", err, rec.attempts())
+	}
+
+	var apiError *Error
+	if !xerrors.As(err, &apiError) || apiError.RequestID != "req-1" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestRetriesRateLimitedMutationWithSameKey(t *testing.T) {
+	limited := respond(http.StatusTooManyRequests, dto.ErrorResponse{Error: "Too many requests"})
+	c, rec, stop := newTestClient(t, limited, respond(http.StatusOK, "OK"), respond(http.StatusOK, "OK"))
+	defer stop()
+
+	request := dto.TransferFundsRequest{IdSender: uuid.New(), IdReceiver: uuid.New(), Sum: &dto.Money{IntPart: 1}}
+	if err := c.Transfer(context.Background(), request); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if rec.attempts() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", rec.attempts())
+	}
+
+	first, second := rec.requests[0].Header.Get(idempotencyKeyHeader), rec.requests[1].Header.Get(idempotencyKeyHeader)
+	if first == "" || first != second {
+		t.Fatalf("retry must reuse idempotency key: %q, %q", first, second)
+	}
+
+	ctx := WithIdempotencyKey(context.Background(), "payment-42")
+	if err := c.Transfer(ctx, request); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if key := rec.requests[2].Header.Get(idempotencyKeyHeader); key != "payment-42" {
+		t.Fatalf("unexpected idempotency key %q", key)
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransportErrors(t *testing.T) {
+	var attempts int
+	fail := func(op string) *http.Client {
+		attempts = 0
+		return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, &net.OpError{Op: op, Net: "tcp", Err: xerrors.New("connection refused")}
+		})}
+	}
+
+	// соединение не установлено - изменяющий запрос можно повторить
+	c := NewClient(Config{BaseURL: "http://balance", MaxAttempts: 3, InitialBackoff: time.Millisecond, HTTPClient: fail("dial")})
+	if err := c.Withdraw(context.Background(), dto.OperationRequest{UserId: uuid.New(), Sum: &dto.Money{IntPart: 1}}); err == nil || attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d: %v", attempts, err)
+	}
+
+	// ошибка после отправки - сервис мог выполнить списание
+	c = NewClient(Config{BaseURL: "http://balance", MaxAttempts: 3, InitialBackoff: time.Millisecond, HTTPClient: fail("read")})
+	if err := c.Withdraw(context.Background(), dto.OperationRequest{UserId: uuid.New(), Sum: &dto.Money{IntPart: 1}}); err == nil || attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d: %v", attempts, err)
+	}
+	attempts = 0
+	if _, err := c.GetTransactions(context.Background(), uuid.New(), Page{}); err == nil || attempts != 3 {
+		t.Fatalf("expected 3 attempts of read, got %d: %v", attempts, err)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	c, _, stop := newTestClient(t,
+		respond(http.StatusBadRequest, dto.ErrorResponse{Error: "Insufficient funds", Code: dto.ErrCodeInsufficientFunds}),
+		respond(http.StatusForbidden, dto.ErrorResponse{Error: "Operation is not permitted for this client"}),
+		func(w http.ResponseWriter) { http.NotFound(w, nil) },
+	)
+	defer stop()
+
+	ctx := context.Background()
+	err := c.Withdraw(ctx, dto.OperationRequest{UserId: uuid.New(), Sum: &dto.Money{IntPart: 1}})
+	if !xerrors.Is(err, ErrInsufficientFunds) || !xerrors.Is(err, ErrBadRequest) || xerrors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := c.GetSystemAccounts(ctx); !xerrors.Is(err, ErrForbidden) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = c.GetEscrow(ctx, "deal")
+	var apiError *Error
+	if !xerrors.Is(err, ErrNotFound) || !xerrors.As(err, &apiError) || apiError.Message != "404 page not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStrongConsistency(t *testing.T) {
+	c, rec, stop := newTestClient(t, respond(http.StatusOK, "Balance: 1.0"), respond(http.StatusOK, "Balance: 1.0"), respond(http.StatusOK, dto.GetTransactionsResponse{}))
+	defer stop()
+
+	ctx := context.Background()
+	if _, err := c.GetBalance(ctx, uuid.New(), ""); err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if _, err := c.GetBalance(WithStrongConsistency(ctx), uuid.New(), ""); err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if _, err := c.GetTransactions(WithStrongConsistency(ctx), uuid.New(), Page{}); err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+
+	for i, expected := range []string{"", "strong", "strong"} {
+		if consistency := rec.requests[i].URL.Query().Get("consistency"); consistency != expected {
+			t.Fatalf("request %d: expected consistency %q, got %q", i, expected, consistency)
+		}
+	}
+}
+
+func TestGetTransactionHistory(t *testing.T) {
+	archived := dto.ArchivedRange{From: "2023-01-01T00:00:00Z", To: "2023-02-01T00:00:00Z", Postings: 3, File: "transaction_2023_01_5f1c2a9b.ndjson.gz"}
+	c, rec, stop := newTestClient(t, respond(http.StatusOK, dto.GetTransactionsResponse{Transactions: []dto.Transaction{}, Archived: []dto.ArchivedRange{archived}}))
+	defer stop()
+
+	userID := uuid.New()
+	history, err := c.GetTransactionHistory(context.Background(), userID, Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetTransactionHistory: %v", err)
+	}
+	if len(history.Archived) != 1 || history.Archived[0] != archived {
+		t.Fatalf("unexpected archived ranges %+v", history.Archived)
+	}
+	if query := rec.requests[0].URL.Query(); query.Get("user_id") != userID.String() || query.Get("limit") != "10" {
+		t.Fatalf("unexpected query %v", query)
+	}
+}
+
+func TestContextCancelsBackoff(t *testing.T) {
+	limited := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "30")
+		respond(http.StatusTooManyRequests, dto.ErrorResponse{Error: "Too many requests"})(w)
+	}
+	c, rec, stop := newTestClient(t, limited)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := c.GetTransactions(ctx, uuid.New(), Page{Limit: 10})
+	if err != context.DeadlineExceeded || time.Since(started) > 5*time.Second || rec.attempts() != 1 {
+		t.Fatalf("expected deadline after first attempt, got %v after %d attempts", err, rec.attempts())
+	}
+	if limit := rec.requests[0].URL.Query().Get("limit"); limit != "10" {
+		t.Fatalf("unexpected limit %q", limit)
+	}
+}
+
+const adminKey = "admin-key"
+
+// newService запускает обработчики сервиса поверх хранилища в памяти
+func newService(t *testing.T) (*Client, func()) {
+	conf := &config.ApplicationConfig{
+		SystemAccounts: config.SystemAccountsConfig{
+			Revenue:    "00000000-0000-0000-0000-000000000001",
+			Promotions: "00000000-0000-0000-0000-000000000002",
+			Clearing:   "00000000-0000-0000-0000-000000000003",
+			Escrow:     "00000000-0000-0000-0000-000000000004",
+		},
+	}
+	serviceAPI := service.NewServiceAPI(memory.NewStorageAPI(), conf)
+	if err := serviceAPI.GetSystemAccountService().EnsureSystemAccounts(context.Background()); err != nil {
+		t.Fatalf("EnsureSystemAccounts: %v", err)
+	}
+
+	clients := []config.APIClientConfig{
+		{Name: "admin", KeyHash: auth.HashKey(adminKey), Scopes: []string{auth.ScopeCredit, auth.ScopeWithdraw, auth.ScopeTransfer, auth.ScopeRead, auth.ScopeAdmin}},
+	}
+
+	a := handlers.NewHandlers(serviceAPI)
+	r := mux.NewRouter()
+	r.Use(handlers.NewRequestIDMiddleware())
+	r.Use(handlers.NewAuthMiddleware(clients))
+	r.HandleFunc("/balance/credit", handlers.RequireScope(auth.ScopeCredit, a.CreditFundsHandler))
+	r.HandleFunc("/balance/withdraw", handlers.RequireScope(auth.ScopeWithdraw, a.WithdrawFundsHandler))
+	r.HandleFunc("/balance/transfer", handlers.RequireScope(auth.ScopeTransfer, a.TransferFundsHandler))
+	r.HandleFunc("/balance/get", handlers.RequireScope(auth.ScopeRead, a.GetBalanceHandler))
+	r.HandleFunc("/balance/transactions", handlers.RequireScope(auth.ScopeRead, a.GetTransactionsHandler))
+	r.HandleFunc("/admin/accounts/status", handlers.RequireScope(auth.ScopeAdmin, a.GetAccountStatusHandler))
+	r.HandleFunc("/admin/accounts/set-status", handlers.RequireScope(auth.ScopeAdmin, a.SetAccountStatusHandler))
+	r.HandleFunc("/admin/system-accounts", handlers.RequireScope(auth.ScopeAdmin, a.GetSystemAccountsHandler))
+
+	server := httptest.NewServer(r)
+	return NewClient(Config{BaseURL: server.URL, APIKey: adminKey}), server.Close
+}
+
+func TestAgainstService(t *testing.T) {
+	c, stop := newService(t)
+	defer stop()
+
+	ctx := context.Background()
+	sender, receiver := uuid.New(), uuid.New()
+	if err := c.Credit(ctx, dto.OperationRequest{UserId: sender, Sum: &dto.Money{IntPart: 100}}); err != nil {
+		t.Fatalf("Credit: %v", err)
+	}
+	if err := c.Transfer(ctx, dto.TransferFundsRequest{IdSender: sender, IdReceiver: receiver, Sum: &dto.Money{IntPart: 30, FracPart: 25}}); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+
+	balance, err := c.GetBalance(ctx, sender, "")
+	if err != nil || *balance != (dto.Money{IntPart: 69, FracPart: 75}) {
+		t.Fatalf("unexpected balance %v: %v", balance, err)
+	}
+
+	balance, err = c.GetBalance(WithStrongConsistency(ctx), sender, "")
+	if err != nil || *balance != (dto.Money{IntPart: 69, FracPart: 75}) {
+		t.Fatalf("unexpected strong balance %v: %v", balance, err)
+	}
+
+	transactions, err := c.GetTransactions(ctx, sender, Page{Limit: 1})
+	if err != nil || len(transactions) != 1 {
+		t.Fatalf("unexpected transactions %v: %v", transactions, err)
+	}
+
+	err = c.Withdraw(ctx, dto.OperationRequest{UserId: receiver, Sum: &dto.Money{IntPart: 31}})
+	var apiError *Error
+	if !xerrors.Is(err, ErrInsufficientFunds) || !xerrors.As(err, &apiError) || apiError.RequestID == "" {
+		t.Fatalf("expected insufficient funds with request id, got %#v", err)
+	}
+
+	if err := c.SetAccountStatus(ctx, dto.SetAccountStatusRequest{UserId: receiver, Status: dto.AccountFrozenDebits, Reason: "fraud check"}); err != nil {
+		t.Fatalf("SetAccountStatus: %v", err)
+	}
+	err = c.Withdraw(ctx, dto.OperationRequest{UserId: receiver, Sum: &dto.Money{IntPart: 1}})
+	if !xerrors.Is(err, ErrAccountFrozen) {
+		t.Fatalf("expected frozen account, got %v", err)
+	}
+
+	report, err := c.GetSystemAccounts(ctx)
+	if err != nil || !report.Balanced {
+		t.Fatalf("unexpected system accounts %+v: %v", report, err)
+	}
+
+	unauthorized := NewClient(Config{BaseURL: c.conf.BaseURL, APIKey: "wrong"})
+	if _, err := unauthorized.GetBalance(ctx, sender, ""); !xerrors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected unauthorized, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error - ответ сервиса с кодом статуса не из 2xx
+type Error struct {
+	StatusCode int
+	// код ошибки сервиса (dto.ErrCode*), если он есть
+	Code      string
+	Message   string
+	RequestID string
+}
+
+// ошибки для сравнения через xerrors.Is: с кодом сравниваются по коду, без него - по статусу
+var (
+	ErrInsufficientFunds = &Error{Code: dto.ErrCodeInsufficientFunds}
+	ErrLimitExceeded     = &Error{Code: dto.ErrCodeLimitExceeded}
+	ErrAccountFrozen     = &Error{Code: dto.ErrCodeAccountFrozen}
+	ErrAccountBlocked    = &Error{Code: dto.ErrCodeAccountBlocked}
+	ErrAccountClosed     = &Error{Code: dto.ErrCodeAccountClosed}
+	ErrInvalidState      = &Error{Code: dto.ErrCodeInvalidState}
+	ErrBadRequest        = &Error{StatusCode: http.StatusBadRequest}
+	ErrUnauthorized      = &Error{StatusCode: http.StatusUnauthorized}
+	ErrForbidden         = &Error{StatusCode: http.StatusForbidden}
+	ErrNotFound          = &Error{StatusCode: http.StatusNotFound}
+	ErrRateLimited       = &Error{StatusCode: http.StatusTooManyRequests}
+	ErrInternal          = &Error{StatusCode: http.StatusInternalServerError}
+)
+
+func newError(resp *http.Response, body []byte) *Error {
+	result := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(requestIDHeader)}
+
+	var response dto.ErrorResponse
+	if err := json.Unmarshal(body, &response); err == nil && response.Error != "" {
+		result.Code = response.Code
+		result.Message = response.Error
+		if response.RequestId != "" {
+			result.RequestID = response.RequestId
+		}
+		return result
+	}
+
+	// ответ не от обработчиков сервиса, например 404 маршрутизатора или прокси
+	result.Message = strings.TrimSpace(string(body))
+	if result.Message == "" {
+		result.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return result
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.Code != "" {
+		return e.Code == t.Code
+	}
+
+	return t.StatusCode != 0 && e.StatusCode == t.StatusCode
+}
+
+// Temporary сообщает, что запрос может выполниться при повторе
+func (e *Error) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
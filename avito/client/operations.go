@@ -0,0 +1,141 @@
+package client
+
+import (
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+	"net/url"
+)
+
+func (c *Client) CreateScheduledOperation(ctx context.Context, request dto.ScheduledOperationRequest) (*dto.ScheduledOperation, error) {
+	var result dto.ScheduledOperation
+	if err := c.post(ctx, "/scheduled/create", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetScheduledOperation(ctx context.Context, id uuid.UUID) (*dto.ScheduledOperation, error) {
+	var result dto.ScheduledOperation
+	if err := c.get(ctx, "/scheduled/get", url.Values{"id": {id.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetScheduledOperations(ctx context.Context, userID uuid.UUID, page Page) ([]dto.ScheduledOperation, error) {
+	var result dto.GetScheduledOperationsResponse
+	if err := c.get(ctx, "/scheduled/list", page.apply(url.Values{"user_id": {userID.String()}}), &result); err != nil {
+		return nil, err
+	}
+
+	return result.Operations, nil
+}
+
+func (c *Client) CancelScheduledOperation(ctx context.Context, id uuid.UUID) error {
+	return c.post(ctx, "/scheduled/cancel", url.Values{"id": {id.String()}}, nil, nil)
+}
+
+func (c *Client) FundEscrow(ctx context.Context, request dto.FundEscrowRequest) (*dto.Escrow, error) {
+	var result dto.Escrow
+	if err := c.post(ctx, "/escrow/fund", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) ReleaseEscrow(ctx context.Context, request dto.EscrowActionRequest) error {
+	return c.post(ctx, "/escrow/release", nil, request, nil)
+}
+
+func (c *Client) CancelEscrow(ctx context.Context, request dto.EscrowActionRequest) error {
+	return c.post(ctx, "/escrow/cancel", nil, request, nil)
+}
+
+func (c *Client) GetEscrow(ctx context.Context, dealID string) (*dto.Escrow, error) {
+	var result dto.Escrow
+	if err := c.get(ctx, "/escrow/get", url.Values{"deal_id": {dealID}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) CreateInvoice(ctx context.Context, request dto.CreateInvoiceRequest) (*dto.Invoice, error) {
+	var result dto.Invoice
+	if err := c.post(ctx, "/invoices/create", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) PayInvoice(ctx context.Context, request dto.InvoiceActionRequest) error {
+	return c.post(ctx, "/invoices/pay", nil, request, nil)
+}
+
+func (c *Client) CancelInvoice(ctx context.Context, request dto.InvoiceActionRequest) error {
+	return c.post(ctx, "/invoices/cancel", nil, request, nil)
+}
+
+func (c *Client) GetInvoice(ctx context.Context, id uuid.UUID) (*dto.Invoice, error) {
+	var result dto.Invoice
+	if err := c.get(ctx, "/invoices/get", url.Values{"id": {id.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetInvoices возвращает счета плательщика, пустой status не ограничивает выборку
+func (c *Client) GetInvoices(ctx context.Context, payerID uuid.UUID, status string, page Page) ([]dto.Invoice, error) {
+	query := page.apply(url.Values{"payer_id": {payerID.String()}})
+	if status != "" {
+		query.Set("status", status)
+	}
+
+	var result dto.GetInvoicesResponse
+	if err := c.get(ctx, "/invoices/list", query, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Invoices, nil
+}
+
+func (c *Client) CreateMoneyRequest(ctx context.Context, request dto.CreateMoneyRequest) (*dto.MoneyRequest, error) {
+	var result dto.MoneyRequest
+	if err := c.post(ctx, "/money-requests/create", nil, request, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) AcceptMoneyRequest(ctx context.Context, request dto.MoneyRequestActionRequest) error {
+	return c.post(ctx, "/money-requests/accept", nil, request, nil)
+}
+
+func (c *Client) DeclineMoneyRequest(ctx context.Context, request dto.MoneyRequestActionRequest) error {
+	return c.post(ctx, "/money-requests/decline", nil, request, nil)
+}
+
+func (c *Client) GetMoneyRequest(ctx context.Context, id uuid.UUID) (*dto.MoneyRequest, error) {
+	var result dto.MoneyRequest
+	if err := c.get(ctx, "/money-requests/get", url.Values{"id": {id.String()}}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetPendingMoneyRequests(ctx context.Context, userID uuid.UUID, page Page) ([]dto.MoneyRequest, error) {
+	var result dto.GetMoneyRequestsResponse
+	if err := c.get(ctx, "/money-requests/pending", page.apply(url.Values{"user_id": {userID.String()}}), &result); err != nil {
+		return nil, err
+	}
+
+	return result.Requests, nil
+}
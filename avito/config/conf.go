@@ -5,21 +5,171 @@ import (
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 const confPath = "config/parameters.yaml"
 
+// хранилища, которые можно выбрать параметром db_driver
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type DBConfig struct {
+	// postgres (по умолчанию) или sqlite
+	Driver   string `yaml:"db_driver"`
+	// путь к файлу базы SQLite
+	Path     string `yaml:"db_path"`
 	User     string `yaml:"db_user"`
 	Password string `yaml:"db_password"`
 	Host     string `yaml:"db_host"`
 	Port     uint16 `yaml:"db_port"`
 	DBName   string `yaml:"db_name"`
+	// строка подключения к реплике Postgres для чтения баланса и истории, пусто - без реплики
+	ReplicaDSN string `yaml:"db_replica_dsn"`
+	// допустимое отставание реплики, при большем чтения выполняются в основной базе
+	ReplicaMaxLag time.Duration `yaml:"db_replica_max_lag"`
+	// период проверки отставания реплики
+	ReplicaLagCheckInterval time.Duration `yaml:"db_replica_lag_check_interval"`
+}
+
+type WebhookConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout"`
+	// порог в копейках, ниже которого отправляется событие low_balance
+	LowBalanceThreshold int64 `yaml:"low_balance_threshold"`
+}
+
+type APIClientConfig struct {
+	Name string `yaml:"name"`
+	// sha256 от API-ключа в hex
+	KeyHash string   `yaml:"key_hash"`
+	Scopes  []string `yaml:"scopes"`
+}
+
+type AuthConfig struct {
+	Clients []APIClientConfig `yaml:"clients"`
+}
+
+// нулевой rate отключает соответствующее ограничение
+type RouteRateLimitConfig struct {
+	// запросов в секунду
+	ClientRate  float64 `yaml:"client_rate"`
+	ClientBurst int     `yaml:"client_burst"`
+	UserRate    float64 `yaml:"user_rate"`
+	UserBurst   int     `yaml:"user_burst"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// ключ - путь маршрута, настройки "default" применяются к остальным маршрутам
+	Routes map[string]RouteRateLimitConfig `yaml:"routes"`
+}
+
+// лимиты по умолчанию в копейках, 0 - без ограничения
+type LimitsConfig struct {
+	WithdrawDaily      int64 `yaml:"withdraw_daily"`
+	WithdrawMonthly    int64 `yaml:"withdraw_monthly"`
+	TransferDaily      int64 `yaml:"transfer_daily"`
+	TransferMonthly    int64 `yaml:"transfer_monthly"`
+	SingleOperationMax int64 `yaml:"single_operation_max"`
+	TransfersPerDay    int   `yaml:"transfers_per_day"`
+}
+
+type SchedulerConfig struct {
+	// период проверки операций, которые пора выполнить
+	Interval time.Duration `yaml:"interval"`
+	// задержка перед повтором при нехватке средств
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	BatchSize     int           `yaml:"batch_size"`
+}
+
+type EscrowConfig struct {
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	// период проверки сделок с истекшим сроком
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+type InvoiceConfig struct {
+	// период проверки счетов с истекшим сроком оплаты
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+type MoneyRequestConfig struct {
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	// период проверки запросов с истекшим сроком
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+// FeeTier - правило комиссии для переводов суммой до UpTo копеек включительно (0 - без ограничения).
+// Комиссия равна Fixed + Percent% от суммы, но не меньше Min и не больше Max (0 - без ограничения)
+type FeeTier struct {
+	UpTo    int64   `yaml:"up_to"`
+	Percent float64 `yaml:"percent"`
+	Fixed   int64   `yaml:"fixed"`
+	Min     int64   `yaml:"min"`
+	Max     int64   `yaml:"max"`
+}
+
+type FeesConfig struct {
+	// правила по возрастанию up_to, применяется первое подходящее
+	Tiers []FeeTier `yaml:"tiers"`
+}
+
+// служебные счета платформы, хранятся в balance вместе со счетами пользователей
+type SystemAccountsConfig struct {
+	// доходы: списания за услуги и комиссии
+	Revenue string `yaml:"revenue"`
+	// расходы на промо-начисления
+	Promotions string `yaml:"promotions"`
+	// расчеты с внешними источниками пополнений
+	Clearing string `yaml:"clearing"`
+	// средства сделок до их завершения
+	Escrow string `yaml:"escrow"`
+}
+
+// кэш балансов в памяти процесса. Изменения, сделанные другими экземплярами сервиса,
+// становятся видны не позже чем через ttl
+type BalanceCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// максимальное число счетов в кэше, при превышении вытесняются давно не читавшиеся
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// секции "transaction" по месяцам и выгрузка месяцев старше срока хранения в файлы
+type ArchiveConfig struct {
+	// каталог файлов архива, пустой - движения не архивируются, секции создаются
+	Dir string `yaml:"dir"`
+	// число полных месяцев до текущего, движения которых остаются в базе
+	RetentionMonths int `yaml:"retention_months"`
+	// на сколько месяцев после текущего создаются секции
+	PartitionsAhead int `yaml:"partitions_ahead"`
+	// период создания секций и архивирования
+	CheckInterval time.Duration `yaml:"check_interval"`
 }
 
 type ApplicationConfig struct {
 	DB DBConfig `yaml:",inline"`
 	HTTPPort uint16 `yaml:"http_port"`
+	// минимальный уровень логов: debug, info, warn, error
+	LogLevel string `yaml:"log_level"`
+	Webhook WebhookConfig `yaml:"webhook"`
+	Auth AuthConfig `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Limits LimitsConfig `yaml:"limits"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Escrow EscrowConfig `yaml:"escrow"`
+	Invoice InvoiceConfig `yaml:"invoice"`
+	MoneyRequest MoneyRequestConfig `yaml:"money_request"`
+	Fees FeesConfig `yaml:"fees"`
+	SystemAccounts SystemAccountsConfig `yaml:"system_accounts"`
+	BalanceCache BalanceCacheConfig `yaml:"balance_cache"`
+	Archive ArchiveConfig `yaml:"archive"`
 }
 
 func ParseConfig() (*ApplicationConfig, error) {
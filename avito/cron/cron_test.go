@@ -0,0 +1,105 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	for _, test := range []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		// каждая минута, секунды отбрасываются
+		{"* * * * *", time.Date(2021, 3, 1, 10, 7, 30, 0, time.UTC), date(2021, 3, 1, 10, 8)},
+		// шаги
+		{"*/15 * * * *", date(2021, 3, 1, 10, 7), date(2021, 3, 1, 10, 15)},
+		{"*/15 * * * *", date(2021, 3, 1, 10, 45), date(2021, 3, 1, 11, 0)},
+		{"5/20 * * * *", date(2021, 3, 1, 10, 26), date(2021, 3, 1, 10, 45)},
+		// диапазоны и диапазоны с шагом
+		{"0 9-17 * * *", date(2021, 3, 1, 17, 0), date(2021, 3, 2, 9, 0)},
+		{"0 9-17/4 * * *", date(2021, 3, 1, 9, 0), date(2021, 3, 1, 13, 0)},
+		{"0 9-17/4 * * *", date(2021, 3, 1, 17, 0), date(2021, 3, 2, 9, 0)},
+		// списки
+		{"0,30 * * * *", date(2021, 3, 1, 10, 0), date(2021, 3, 1, 10, 30)},
+		{"0,30 * * * *", date(2021, 3, 1, 10, 30), date(2021, 3, 1, 11, 0)},
+		{"0 8,12-13 * * *", date(2021, 3, 1, 8, 0), date(2021, 3, 1, 12, 0)},
+		// 1 марта 2021 - понедельник
+		{"0 0 * * 1", date(2021, 3, 1, 0, 0), date(2021, 3, 8, 0, 0)},
+		{"0 0 * * 1-5", date(2021, 3, 5, 12, 0), date(2021, 3, 8, 0, 0)},
+		// ограничены и день месяца, и день недели - достаточно любого
+		{"0 0 15 * 1", date(2021, 3, 1, 0, 0), date(2021, 3, 8, 0, 0)},
+		{"0 0 15 * 1", date(2021, 3, 8, 0, 0), date(2021, 3, 15, 0, 0)},
+		{"0 0 9 * 1", date(2021, 3, 8, 0, 0), date(2021, 3, 9, 0, 0)},
+		// ограничен только день месяца
+		{"0 0 15 * *", date(2021, 3, 1, 0, 0), date(2021, 3, 15, 0, 0)},
+		// переход через месяц, в котором нет нужного дня
+		{"0 0 31 * *", date(2021, 4, 1, 0, 0), date(2021, 5, 31, 0, 0)},
+		{"0 12 29 2 *", date(2021, 3, 1, 0, 0), date(2024, 2, 29, 12, 0)},
+		{"0 0 1 */3 *", date(2021, 2, 10, 0, 0), date(2021, 4, 1, 0, 0)},
+		// переход через год
+		{"0 0 1 1 *", date(2021, 12, 31, 23, 59), date(2022, 1, 1, 0, 0)},
+		{"30 23 31 12 *", date(2021, 12, 31, 23, 30), date(2022, 12, 31, 23, 30)},
+		{"*/10 * * * *", date(2021, 12, 31, 23, 55), date(2022, 1, 1, 0, 0)},
+		// сокращения
+		{"@hourly", date(2021, 3, 1, 10, 0), date(2021, 3, 1, 11, 0)},
+		{"@daily", date(2021, 3, 1, 10, 0), date(2021, 3, 2, 0, 0)},
+		{"@weekly", date(2021, 3, 3, 10, 0), date(2021, 3, 7, 0, 0)},
+		{"@monthly", date(2021, 1, 31, 12, 0), date(2021, 2, 1, 0, 0)},
+		{" @daily ", date(2021, 3, 1, 10, 0), date(2021, 3, 2, 0, 0)},
+	} {
+		schedule, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.expr, err)
+		}
+
+		if next := schedule.Next(test.from); !next.Equal(test.expected) {
+			t.Fatalf("%q after %v: expected %v, got %v", test.expr, test.from, test.expected, next)
+		}
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if next := schedule.Next(date(2021, 1, 1, 0, 0)); !next.IsZero() {
+		t.Fatalf("expected zero time for impossible date, got %v", next)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@yearly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 7",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"0-70/5 * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
@@ -18,12 +18,28 @@ type ConnDB struct {
 }
 
 func NewConnectToPG(dbConfig *config.DBConfig, ctx context.Context) (*ConnDB, error) {
-	poolConfig, err := pgxpool.ParseConfig(fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName))
+	return NewConnectToPGDSN(fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.DBName), ctx)
+}
+
+// NewConnectToPGDSN подключается по строке подключения вида "user=... host=... dbname=..."
+func NewConnectToPGDSN(dsn string, ctx context.Context) (*ConnDB, error) {
+	return connect(dsn, false, ctx)
+}
+
+// NewLazyConnectToPGDSN создает пул без проверки подключения: соединения открываются
+// при первых запросах, поэтому недоступная база не мешает запуску сервиса
+func NewLazyConnectToPGDSN(dsn string, ctx context.Context) (*ConnDB, error) {
+	return connect(dsn, true, ctx)
+}
+
+func connect(dsn string, lazy bool, ctx context.Context) (*ConnDB, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, xerrors.Errorf("Cannot parse config", err)
 	}
 	poolConfig.ConnConfig.RuntimeParams["standard_conforming_strings"] = "on";
 	poolConfig.ConnConfig.PreferSimpleProtocol = true
+	poolConfig.LazyConnect = lazy
 
 	db, err := pgxpool.ConnectConfig(ctx, poolConfig)
 	if err != nil {
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"avito/logger"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// отставание реплики: 0, если она получила и применила весь WAL или не находится в режиме
+// восстановления (строка подключения указывает на основную базу), иначе время с последней
+// примененной транзакции. null - отставание неизвестно, реплика еще не применила ни одной транзакции
+const replicaLagQuery = `select case
+	when not pg_is_in_recovery() or pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0::float8
+	else extract(epoch from now() - pg_last_xact_replay_timestamp())::float8
+end;`
+
+// Replica - реплика основной базы для чтения и ее отставание, которое периодически проверяет Monitor
+type Replica struct {
+	conn   *ConnDB
+	maxLag time.Duration
+	// в наносекундах, -1 - реплика недоступна или еще не проверялась
+	lag int64
+	// проверялась ли реплика, используется только в Monitor
+	checked bool
+	log     *logger.Logger
+}
+
+// NewReplica создает реплику, чтения с которой допустимы при отставании не больше maxLag
+func NewReplica(conn *ConnDB, maxLag time.Duration) *Replica {
+	return &Replica{
+		conn:   conn,
+		maxLag: maxLag,
+		lag:    -1,
+		log:    logger.New("replica"),
+	}
+}
+
+func (r *Replica) Conn() *ConnDB {
+	return r.conn
+}
+
+// Lag возвращает последнее измеренное отставание, false - реплика недоступна
+func (r *Replica) Lag() (time.Duration, bool) {
+	lag := atomic.LoadInt64(&r.lag)
+	return time.Duration(lag), lag >= 0
+}
+
+// Available сообщает, что реплика доступна и отстает не больше допустимого
+func (r *Replica) Available() bool {
+	lag, ok := r.Lag()
+	return ok && lag <= r.maxLag
+}
+
+// Monitor проверяет отставание реплики каждые interval до отмены ctx. До первой проверки
+// реплика считается недоступной
+func (r *Replica) Monitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// проверка, не уложившаяся в период, считается неудачной
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		var seconds *float64
+		err := r.conn.DB.QueryRow(checkCtx, replicaLagQuery).Scan(&seconds)
+		cancel()
+		r.observe(ctx, seconds, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe сохраняет результат проверки и пишет в лог после первой проверки и при переключении
+// чтений между базами
+func (r *Replica) observe(ctx context.Context, seconds *float64, err error) {
+	wasAvailable := r.Available()
+
+	lag := int64(-1)
+	if err == nil && seconds != nil {
+		lag = int64(*seconds * float64(time.Second))
+	}
+	atomic.StoreInt64(&r.lag, lag)
+
+	available := r.Available()
+	checked := r.checked
+	r.checked = true
+	switch {
+	case checked && available == wasAvailable:
+	case available:
+		r.log.Infof(ctx, "Reads are routed to replica, lag %v", time.Duration(lag))
+	case err != nil:
+		r.log.Warnf(ctx, "Reads are routed to primary, cannot get replica lag, reason: %v", err)
+	case lag < 0:
+		r.log.Warnf(ctx, "Reads are routed to primary, replica lag is unknown")
+	default:
+		r.log.Warnf(ctx, "Reads are routed to primary, replica lag %v exceeds %v", time.Duration(lag), r.maxLag)
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"avito/logger"
+	"context"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+func TestReplicaAvailable(t *testing.T) {
+	ctx := context.Background()
+	replica := NewReplica(nil, time.Second)
+	if replica.Available() {
+		t.Fatalf("replica must be unavailable before the first check")
+	}
+
+	seconds := func(value float64) *float64 { return &value }
+	for _, check := range []struct {
+		seconds   *float64
+		err       error
+		available bool
+	}{
+		{seconds: seconds(0), available: true},
+		{seconds: seconds(0.5), available: true},
+		{seconds: seconds(1), available: true},
+		{seconds: seconds(1.5), available: false},
+		{seconds: seconds(0.2), available: true},
+		{seconds: nil, available: false},
+		{seconds: seconds(0), available: true},
+		{err: xerrors.New("connection refused"), available: false},
+	} {
+		replica.observe(ctx, check.seconds, check.err)
+		if replica.Available() != check.available {
+			t.Fatalf("after %v, %v expected available=%v", check.seconds, check.err, check.available)
+		}
+	}
+
+	if _, ok := replica.Lag(); ok {
+		t.Fatalf("lag of unavailable replica must be unknown")
+	}
+}
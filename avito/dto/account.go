@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	AccountActive       = "active"
+	AccountFrozenDebits = "frozen_debits"
+	AccountBlocked      = "blocked"
+	AccountClosed       = "closed"
+)
+
+type SetAccountStatusRequest struct {
+	UserId uuid.UUID `json:"user_id"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason"`
+}
+
+type AccountStatusChange struct {
+	Id        uuid.UUID `json:"id"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	Reason    string    `json:"reason"`
+	Client    string    `json:"client"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type GetAccountStatusResponse struct {
+	UserId  uuid.UUID             `json:"user_id"`
+	Status  string                `json:"status"`
+	Reason  string                `json:"reason"`
+	History []AccountStatusChange `json:"history"`
+}
+
+type SetCreditLimitRequest struct {
+	UserId      uuid.UUID `json:"user_id"`
+	CreditLimit *Money    `json:"credit_limit"`
+}
+
+type OverdraftAccount struct {
+	UserId      uuid.UUID `json:"user_id"`
+	Sum         *Money    `json:"amount"`
+	CreditLimit *Money    `json:"credit_limit"`
+}
+
+type GetOverdraftAccountsResponse struct {
+	Accounts []OverdraftAccount
+}
+
+func (r SetCreditLimitRequest) String() string {
+	return fmt.Sprintf("{User ID: %v, credit limit: %v}", r.UserId, r.CreditLimit)
+}
+
+func (r SetAccountStatusRequest) String() string {
+	return fmt.Sprintf("{User ID: %v, status: %s, reason: %s}", r.UserId, r.Status, r.Reason)
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// коды причин административных операций с балансом
+const (
+	AdminReasonCorrection   = "correction"
+	AdminReasonCompensation = "compensation"
+	AdminReasonChargeback   = "chargeback"
+	AdminReasonFraud        = "fraud"
+	AdminReasonMigration    = "migration"
+)
+
+var AdminReasons = []string{AdminReasonCorrection, AdminReasonCompensation, AdminReasonChargeback, AdminReasonFraud, AdminReasonMigration}
+
+// административное зачисление или списание amount, для корректировки amount - итоговый баланс.
+// reason - один из AdminReasons, comment дополняет его в комментарии к движениям
+type AdminOperationRequest struct {
+	UserId  uuid.UUID `json:"user_id"`
+	Sum     *Money    `json:"amount"`
+	Reason  string    `json:"reason"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+func (r AdminOperationRequest) String() string {
+	return fmt.Sprintf("{User ID: %v, sum: %v, reason: %s, comment: %s}", r.UserId, r.Sum, r.Reason, r.Comment)
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "github.com/google/uuid"
+
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// запись журнала аудита: кто, откуда и с каким результатом выполнил изменяющий запрос
+type AuditEntry struct {
+	Id             uuid.UUID   `json:"id"`
+	RequestId      string      `json:"request_id"`
+	Client         string      `json:"client"`
+	Method         string      `json:"method"`
+	Path           string      `json:"path"`
+	SourceIP       string      `json:"source_ip"`
+	PayloadHash    string      `json:"payload_hash"`
+	Status         int         `json:"status"`
+	Result         string      `json:"result"`
+	Error          *string     `json:"error,omitempty"`
+	UserIds        []uuid.UUID `json:"user_ids"`
+	TransactionIds []uuid.UUID `json:"transaction_ids"`
+	CreatedAt      string      `json:"created_at"`
+}
+
+// время в RFC3339, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	UserId *uuid.UUID
+	Client string
+	From   string
+	To     string
+}
+
+type GetAuditLogResponse struct {
+	Records []AuditEntry `json:"records"`
+}
@@ -5,9 +5,37 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	OperationCredit   = "credit"
+	OperationWithdraw = "withdraw"
+	OperationTransfer = "transfer"
+	OperationFee      = "fee"
+)
+
+// значения параметра consistency запросов баланса и истории
+const (
+	// чтение с реплики, если она настроена и не отстает, или из кэша (по умолчанию)
+	ConsistencyEventual = "eventual"
+	// чтение из основной базы
+	ConsistencyStrong = "strong"
+)
+
+// коды ошибок, по которым клиент может отличить причину отказа
+const (
+	ErrCodeInsufficientFunds = "insufficient_funds"
+	ErrCodeLimitExceeded = "limit_exceeded"
+	ErrCodeAccountFrozen = "account_frozen"
+	ErrCodeAccountBlocked = "account_blocked"
+	ErrCodeAccountClosed = "account_closed"
+	ErrCodeForbidden = "forbidden"
+	ErrCodeInvalidState = "invalid_state"
+)
+
 type OperationRequest struct {
 	UserId uuid.UUID `json:"user_id"`
 	Sum *Money `json:"amount"`
+	// только для зачисления: external (по умолчанию) или promotion
+	Source string `json:"source,omitempty"`
 }
 
 type TransferFundsRequest struct {
@@ -16,23 +44,109 @@ type TransferFundsRequest struct {
 	Sum *Money `json:"amount"`
 }
 
+// расчет комиссии перевода, total - сумма списания с отправителя
+type TransferQuote struct {
+	Sum *Money `json:"amount"`
+	Fee *Money `json:"fee"`
+	Total *Money `json:"total"`
+}
+
 type GetBalanceResponse struct {
 	Sum *Money `json:"amount"`
 }
 
 type GetTransactionsResponse struct {
 	Transactions []Transaction
+	// месяцы, движения счета за которые выгружены в архив и в Transactions не входят
+	Archived []ArchivedRange `json:"archived,omitempty"`
+}
+
+// месяц движений [From, To), выгруженный из базы в файл архива
+type ArchivedRange struct {
+	From string `json:"from"`
+	To string `json:"to"`
+	// движений в файле, в истории счета - движений счета
+	Postings int `json:"postings"`
+	File string `json:"file"`
+	ArchivedAt string `json:"archived_at"`
+}
+
+// результат создания секций и архивирования месяцев старше срока хранения
+type ArchiveReport struct {
+	Partitions []string `json:"partitions"`
+	Archived []ArchivedRange `json:"archived"`
 }
 
 type Transaction struct {
 	Id uuid.UUID `json:"id"`
+	// проводка, в которую входит движение
+	EntryId uuid.UUID `json:"entry_id"`
 	UserID uuid.UUID `json:"user_id"`
 	ChangeBalance *Money `json:"change_balance"`
+	Operation string `json:"operation"`
+	Client string `json:"client"`
+	Comment string `json:"comment"`
 	CreatedAt string `json:"created_at"`
 }
 
+// проводка двойной записи: движения по счетам, сумма которых равна нулю
+type JournalEntry struct {
+	Id uuid.UUID `json:"id"`
+	Operation string `json:"operation"`
+	Client string `json:"client"`
+	Comment string `json:"comment"`
+	CreatedAt string `json:"created_at"`
+	Postings []Transaction `json:"postings"`
+	// месяц проводки выгружен в архив: ее движения есть только в его файле, Postings неполны
+	Archived *ArchivedRange `json:"archived,omitempty"`
+}
+
+// расхождение баланса счета с суммой его движений
+type BalanceMismatch struct {
+	UserId uuid.UUID `json:"user_id"`
+	Balance *Money `json:"balance"`
+	PostingsSum *Money `json:"postings_sum"`
+	Difference *Money `json:"difference"`
+	Postings int `json:"postings"`
+	Corrected bool `json:"corrected"`
+}
+
+type ReconcileReport struct {
+	Accounts int `json:"accounts"`
+	Mismatches []BalanceMismatch `json:"mismatches"`
+	Fixed bool `json:"fixed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// причины разрыва цепочки хешей движений счета
+const (
+	ChainBreakSequence = "sequence_gap"
+	ChainBreakDuplicateSeq = "duplicate_seq"
+	ChainBreakPrevHash = "prev_hash_mismatch"
+	ChainBreakHash = "hash_mismatch"
+)
+
+// первое движение счета, на котором нарушена цепочка хешей
+type ChainBreak struct {
+	UserId uuid.UUID `json:"user_id"`
+	TransactionId uuid.UUID `json:"transaction_id"`
+	Seq int64 `json:"seq"`
+	Reason string `json:"reason"`
+	Expected string `json:"expected"`
+	Actual string `json:"actual"`
+}
+
+type LedgerVerification struct {
+	Accounts int `json:"accounts"`
+	Postings int `json:"postings"`
+	Valid bool `json:"valid"`
+	Breaks []ChainBreak `json:"breaks"`
+}
+
 type ErrorResponse struct {
 	Error string
+	Code string `json:",omitempty"`
+	RequestId string `json:",omitempty"`
 }
 
 type Money struct {
@@ -47,7 +161,7 @@ type CurrencyRates struct {
 }
 
 func (r OperationRequest) String() string {
-	return fmt.Sprintf("{User ID: %v, sum: %v}", r.UserId, r.Sum)
+	return fmt.Sprintf("{User ID: %v, sum: %v, source: %s}", r.UserId, r.Sum, r.Source)
 }
 
 func (r TransferFundsRequest) String() string {
@@ -63,11 +177,11 @@ func (r GetBalanceResponse) String() string {
 }
 
 func (r GetTransactionsResponse) String() string {
-	return fmt.Sprintf("Transactions: %v", r.Transactions)
+	return fmt.Sprintf("Transactions: %v, archived: %v", r.Transactions, r.Archived)
 }
 
 func (r Transaction) String() string {
-	return fmt.Sprintf("{ID: %v, user id: %v, change: %v, created at: %v}", r.Id, r.UserID, r.ChangeBalance, r.CreatedAt)
+	return fmt.Sprintf("{ID: %v, user id: %v, change: %v, operation: %s, client: %s, comment: %s, created at: %v}", r.Id, r.UserID, r.ChangeBalance, r.Operation, r.Client, r.Comment, r.CreatedAt)
 }
 
 func (r Money) String() string {
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	EscrowFunded    = "funded"
+	EscrowReleased  = "released"
+	EscrowCancelled = "cancelled"
+	EscrowExpired   = "expired"
+)
+
+// expires_at в формате RFC3339, по умолчанию escrow.default_ttl от момента создания
+type FundEscrowRequest struct {
+	DealId    string    `json:"deal_id"`
+	PayerId   uuid.UUID `json:"payer_id"`
+	PayeeId   uuid.UUID `json:"payee_id"`
+	Sum       *Money    `json:"amount"`
+	ExpiresAt string    `json:"expires_at,omitempty"`
+}
+
+type EscrowActionRequest struct {
+	DealId  string `json:"deal_id"`
+	Comment string `json:"comment"`
+}
+
+type Escrow struct {
+	Id        uuid.UUID     `json:"id"`
+	DealId    string        `json:"deal_id"`
+	PayerId   uuid.UUID     `json:"payer_id"`
+	PayeeId   uuid.UUID     `json:"payee_id"`
+	Sum       *Money        `json:"amount"`
+	Status    string        `json:"status"`
+	ExpiresAt string        `json:"expires_at"`
+	CreatedAt string        `json:"created_at"`
+	History   []EscrowEvent `json:"history"`
+}
+
+type EscrowEvent struct {
+	Status    string `json:"status"`
+	Comment   string `json:"comment"`
+	Client    string `json:"client"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (r FundEscrowRequest) String() string {
+	return fmt.Sprintf("{Deal ID: %s, payer ID: %v, payee ID: %v, sum: %v, expires at: %s}", r.DealId, r.PayerId, r.PayeeId, r.Sum, r.ExpiresAt)
+}
+
+func (r EscrowActionRequest) String() string {
+	return fmt.Sprintf("{Deal ID: %s, comment: %s}", r.DealId, r.Comment)
+}
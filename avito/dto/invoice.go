@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	InvoiceOpen      = "open"
+	InvoicePaid      = "paid"
+	InvoiceCancelled = "cancelled"
+	InvoiceExpired   = "expired"
+)
+
+type InvoiceItem struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	Price       *Money `json:"price"`
+}
+
+// сумма позиций, если они указаны, должна совпадать с amount. due_at в формате RFC3339
+type CreateInvoiceRequest struct {
+	PayerId     uuid.UUID     `json:"payer_id"`
+	Sum         *Money        `json:"amount"`
+	Description string        `json:"description"`
+	DueAt       string        `json:"due_at"`
+	Items       []InvoiceItem `json:"items"`
+}
+
+type InvoiceActionRequest struct {
+	InvoiceId uuid.UUID `json:"invoice_id"`
+}
+
+type Invoice struct {
+	Id          uuid.UUID     `json:"id"`
+	PayerId     uuid.UUID     `json:"payer_id"`
+	Sum         *Money        `json:"amount"`
+	Description string        `json:"description"`
+	DueAt       string        `json:"due_at"`
+	Status      string        `json:"status"`
+	Items       []InvoiceItem `json:"items"`
+	Client      string        `json:"client"`
+	PaidAt      *string       `json:"paid_at"`
+	CreatedAt   string        `json:"created_at"`
+}
+
+type GetInvoicesResponse struct {
+	Invoices []Invoice `json:"invoices"`
+}
+
+func (r CreateInvoiceRequest) String() string {
+	return fmt.Sprintf("{Payer ID: %v, sum: %v, description: %s, due at: %s, items: %d}", r.PayerId, r.Sum, r.Description, r.DueAt, len(r.Items))
+}
+
+func (r InvoiceActionRequest) String() string {
+	return fmt.Sprintf("{Invoice ID: %v}", r.InvoiceId)
+}
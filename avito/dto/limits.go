@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// в запросе на изменение отсутствующее поле означает лимит по умолчанию из конфига,
+// нулевое - отсутствие лимита; в ответе отсутствующее поле означает отсутствие лимита
+type UserLimits struct {
+	UserID             uuid.UUID `json:"user_id"`
+	WithdrawDaily      *Money    `json:"withdraw_daily,omitempty"`
+	WithdrawMonthly    *Money    `json:"withdraw_monthly,omitempty"`
+	TransferDaily      *Money    `json:"transfer_daily,omitempty"`
+	TransferMonthly    *Money    `json:"transfer_monthly,omitempty"`
+	SingleOperationMax *Money    `json:"single_operation_max,omitempty"`
+	TransfersPerDay    *int      `json:"transfers_per_day,omitempty"`
+}
+
+func (r UserLimits) String() string {
+	return fmt.Sprintf("{User ID: %v, withdraw daily: %v, withdraw monthly: %v, transfer daily: %v, transfer monthly: %v, single operation max: %v, transfers per day: %v}",
+		r.UserID, r.WithdrawDaily, r.WithdrawMonthly, r.TransferDaily, r.TransferMonthly, r.SingleOperationMax, r.TransfersPerDay)
+}
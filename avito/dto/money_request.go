@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	MoneyRequestPending  = "pending"
+	MoneyRequestAccepted = "accepted"
+	MoneyRequestDeclined = "declined"
+	MoneyRequestExpired  = "expired"
+)
+
+// requester_id запрашивает сумму у payer_id. expires_at в формате RFC3339,
+// по умолчанию money_request.default_ttl от момента создания
+type CreateMoneyRequest struct {
+	RequesterId uuid.UUID `json:"requester_id"`
+	PayerId     uuid.UUID `json:"payer_id"`
+	Sum         *Money    `json:"amount"`
+	Message     string    `json:"message"`
+	ExpiresAt   string    `json:"expires_at,omitempty"`
+}
+
+type MoneyRequestActionRequest struct {
+	RequestId uuid.UUID `json:"request_id"`
+}
+
+type MoneyRequest struct {
+	Id          uuid.UUID `json:"id"`
+	RequesterId uuid.UUID `json:"requester_id"`
+	PayerId     uuid.UUID `json:"payer_id"`
+	Sum         *Money    `json:"amount"`
+	Message     string    `json:"message"`
+	Status      string    `json:"status"`
+	ExpiresAt   string    `json:"expires_at"`
+	Client      string    `json:"client"`
+	CreatedAt   string    `json:"created_at"`
+}
+
+type GetMoneyRequestsResponse struct {
+	Requests []MoneyRequest `json:"requests"`
+}
+
+func (r CreateMoneyRequest) String() string {
+	return fmt.Sprintf("{Requester ID: %v, payer ID: %v, sum: %v, message: %s, expires at: %s}", r.RequesterId, r.PayerId, r.Sum, r.Message, r.ExpiresAt)
+}
+
+func (r MoneyRequestActionRequest) String() string {
+	return fmt.Sprintf("{Request ID: %v}", r.RequestId)
+}
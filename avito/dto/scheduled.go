@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduledActive    = "active"
+	ScheduledCompleted = "completed"
+	ScheduledCancelled = "cancelled"
+	ScheduledFailed    = "failed"
+)
+
+const (
+	OccurrenceSucceeded = "succeeded"
+	OccurrenceRetrying  = "retrying"
+	OccurrenceFailed    = "failed"
+)
+
+// разовая операция задается через run_at (RFC3339), повторяющаяся - через cron-выражение schedule
+type ScheduledOperationRequest struct {
+	Operation  string     `json:"operation"`
+	UserId     uuid.UUID  `json:"user_id"`
+	ReceiverId *uuid.UUID `json:"receiver_id,omitempty"`
+	Sum        *Money     `json:"amount"`
+	RunAt      string     `json:"run_at,omitempty"`
+	Schedule   string     `json:"schedule,omitempty"`
+}
+
+type ScheduledOperation struct {
+	Id          uuid.UUID             `json:"id"`
+	Operation   string                `json:"operation"`
+	UserId      uuid.UUID             `json:"user_id"`
+	ReceiverId  *uuid.UUID            `json:"receiver_id,omitempty"`
+	Sum         *Money                `json:"amount"`
+	Schedule    string                `json:"schedule,omitempty"`
+	NextRunAt   string                `json:"next_run_at"`
+	Status      string                `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	LastError   *string               `json:"last_error,omitempty"`
+	Client      string                `json:"client"`
+	CreatedAt   string                `json:"created_at"`
+	Occurrences []ScheduledOccurrence `json:"occurrences,omitempty"`
+}
+
+type ScheduledOccurrence struct {
+	ScheduledFor string  `json:"scheduled_for"`
+	Status       string  `json:"status"`
+	Attempts     int     `json:"attempts"`
+	LastError    *string `json:"last_error,omitempty"`
+	UpdatedAt    string  `json:"updated_at"`
+}
+
+type GetScheduledOperationsResponse struct {
+	Operations []ScheduledOperation
+}
+
+func (r ScheduledOperationRequest) String() string {
+	return fmt.Sprintf("{Operation: %s, user ID: %v, receiver ID: %v, sum: %v, run at: %s, schedule: %s}", r.Operation, r.UserId, r.ReceiverId, r.Sum, r.RunAt, r.Schedule)
+}
+
+func (r ScheduledOperation) String() string {
+	return fmt.Sprintf("{ID: %v, operation: %s, user ID: %v, sum: %v, next run at: %s, status: %s}", r.Id, r.Operation, r.UserId, r.Sum, r.NextRunAt, r.Status)
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "github.com/google/uuid"
+
+const (
+	SystemAccountRevenue    = "revenue"
+	SystemAccountPromotions = "promotions"
+	SystemAccountClearing   = "clearing"
+	SystemAccountEscrow     = "escrow"
+)
+
+// источники зачисления: внешнее пополнение или промо-начисление за счет платформы
+const (
+	CreditSourceExternal  = "external"
+	CreditSourcePromotion = "promotion"
+)
+
+type SystemAccount struct {
+	Name   string    `json:"name"`
+	UserId uuid.UUID `json:"user_id"`
+	Sum    *Money    `json:"amount"`
+}
+
+// сумма по всем счетам (total) при сбалансированном учете равна нулю
+type SystemAccountsReport struct {
+	Accounts   []SystemAccount `json:"accounts"`
+	UsersTotal *Money          `json:"users_total"`
+	Total      *Money          `json:"total"`
+	Balanced   bool            `json:"balanced"`
+}
@@ -0,0 +1,76 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+const (
+	EventCredit     = "credit"
+	EventWithdraw   = "withdraw"
+	EventTransfer   = "transfer"
+	EventLowBalance = "low_balance"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type Webhook struct {
+	Id        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []Webhook
+}
+
+// тело запроса, которое получает подписчик
+type WebhookEvent struct {
+	Id             uuid.UUID  `json:"id"`
+	Event          string     `json:"event"`
+	UserID         uuid.UUID  `json:"user_id"`
+	CounterpartyID *uuid.UUID `json:"counterparty_id,omitempty"`
+	Sum            *Money     `json:"amount,omitempty"`
+	Balance        *Money     `json:"balance,omitempty"`
+	CreatedAt      string     `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id           uuid.UUID `json:"id"`
+	WebhookID    uuid.UUID `json:"webhook_id"`
+	Event        string    `json:"event"`
+	Payload      string    `json:"payload"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode *int      `json:"response_code,omitempty"`
+	LastError    *string   `json:"last_error,omitempty"`
+	CreatedAt    string    `json:"created_at"`
+	UpdatedAt    string    `json:"updated_at"`
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery
+}
+
+func (r WebhookRequest) String() string {
+	return fmt.Sprintf("{URL: %s, events: %v}", r.URL, r.Events)
+}
+
+func (r Webhook) String() string {
+	return fmt.Sprintf("{ID: %v, URL: %s, events: %v, created at: %v}", r.Id, r.URL, r.Events, r.CreatedAt)
+}
+
+func (r WebhookDelivery) String() string {
+	return fmt.Sprintf("{ID: %v, webhook id: %v, event: %s, status: %s, attempts: %d}", r.Id, r.WebhookID, r.Event, r.Status, r.Attempts)
+}
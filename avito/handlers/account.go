@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) GetAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	account, err, isInternal := h.service.GetAccountService().GetAccountStatusRequest(r.Context(), userID)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getAccountStatusRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, account, w)
+}
+
+func (h *handlers) SetAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var setAccountStatusRequest dto.SetAccountStatusRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&setAccountStatusRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse setAccountStatusRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received setAccountStatusRequest: %v", setAccountStatusRequest)
+
+	err, isInternal := h.service.GetAccountService().SetAccountStatusRequest(r.Context(), setAccountStatusRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do setAccountStatusRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Status of account %v has been changed to %s", setAccountStatusRequest.UserId, setAccountStatusRequest.Status)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) SetCreditLimitHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var setCreditLimitRequest dto.SetCreditLimitRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&setCreditLimitRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse setCreditLimitRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received setCreditLimitRequest: %v", setCreditLimitRequest)
+
+	err, isInternal := h.service.GetAccountService().SetCreditLimitRequest(r.Context(), setCreditLimitRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do setCreditLimitRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Credit limit of account %v has been changed", setCreditLimitRequest.UserId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) GetOverdraftAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	accounts, err, isInternal := h.service.GetAccountService().GetOverdraftAccountsRequest(r.Context(), limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getOverdraftAccountsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetOverdraftAccountsResponse{Accounts: accounts}
+	sendResponse(http.StatusOK, response, w)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"avito/dto"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+func (h *handlers) AdminCreditHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleAdminOperation(w, r, "adminCreditRequest", h.service.GetAdminOperationService().CreditRequest)
+}
+
+func (h *handlers) AdminWithdrawHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleAdminOperation(w, r, "adminWithdrawRequest", h.service.GetAdminOperationService().WithdrawRequest)
+}
+
+func (h *handlers) AdjustBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	h.handleAdminOperation(w, r, "adjustBalanceRequest", h.service.GetAdminOperationService().AdjustRequest)
+}
+
+// handleAdminOperation разбирает dto.AdminOperationRequest и выполняет им операцию do
+func (h *handlers) handleAdminOperation(w http.ResponseWriter, r *http.Request, name string, do func(ctx context.Context, request dto.AdminOperationRequest) (error, bool)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var adminOperationRequest dto.AdminOperationRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&adminOperationRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse %s, reason: %v", name, err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received %s: %v", name, adminOperationRequest)
+
+	err, isInternal := do(r.Context(), adminOperationRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do %s, reason: %v", name, err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Admin operation %s for user %v has been successfully done", name, adminOperationRequest.UserId)
+	sendResponse(http.StatusOK, "OK", w)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"avito/dto"
+	"avito/logger"
+	"avito/service"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// auditResponseWriter запоминает код ответа и тело ответа с ошибкой
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.status >= 400 {
+		w.body.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Audit сохраняет в журнал аудита запись о каждом вызове изменяющего обработчика:
+// клиента, id запроса, адрес, хеш тела, результат и записанные операцией движения
+func (h *handlers) Audit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		payloadHash := sha256.Sum256(body)
+
+		request := service.AuditRequest{
+			RequestID:   logger.RequestID(r.Context()),
+			Method:      r.Method,
+			Path:        r.URL.RequestURI(),
+			SourceIP:    r.RemoteAddr,
+			PayloadHash: hex.EncodeToString(payloadHash[:]),
+		}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			request.SourceIP = host
+		}
+		if userID, err := uuid.Parse(requestUserID(r)); err == nil {
+			request.UserID = &userID
+		}
+
+		ctx, _ := service.WithAuditRecord(r.Context())
+		aw := &auditResponseWriter{ResponseWriter: w}
+		next(aw, r.WithContext(ctx))
+
+		request.Status = aw.status
+		if request.Status == 0 {
+			request.Status = http.StatusOK
+		}
+		var errorResponse dto.ErrorResponse
+		if json.Unmarshal(aw.body.Bytes(), &errorResponse) == nil {
+			request.Error = errorResponse.Error
+		}
+
+		h.service.GetAuditService().WriteAuditRequest(ctx, request)
+	}
+}
+
+func (h *handlers) GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	filter := dto.AuditFilter{
+		Client: r.URL.Query().Get("client"),
+		From:   r.URL.Query().Get("from"),
+		To:     r.URL.Query().Get("to"),
+	}
+	if u := r.URL.Query().Get("user_id"); u != "" {
+		userID, err := uuid.Parse(u)
+		if err != nil {
+			h.log.Warnf(r.Context(), "Error while parse value of user_id")
+			response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+			sendResponse(http.StatusBadRequest, response, w)
+			return
+		}
+		filter.UserId = &userID
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	records, err, isInternal := h.service.GetAuditService().GetAuditLogRequest(r.Context(), filter, limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getAuditLogRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetAuditLogResponse{Records: records}
+	sendResponse(http.StatusOK, response, w)
+}
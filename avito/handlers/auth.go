@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"net/http"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// NewAuthMiddleware определяет клиента по API-ключу из заголовка X-API-Key
+// (или Authorization: Bearer) и кладет его в контекст запроса
+func NewAuthMiddleware(clients []config.APIClientConfig) func(http.Handler) http.Handler {
+	log := logger.New("auth")
+
+	byHash := make(map[string]*auth.Client, len(clients))
+	for _, c := range clients {
+		client := &auth.Client{Name: c.Name, Scopes: make(map[string]bool)}
+		for _, scope := range c.Scopes {
+			client.Scopes[scope] = true
+		}
+		byHash[strings.ToLower(c.KeyHash)] = client
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(apiKeyHeader)
+			if key == "" {
+				key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+
+			client, ok := byHash[auth.HashKey(key)]
+			if key == "" || !ok {
+				log.Warnf(r.Context(), "Rejected request to %s from %s: invalid API key", r.URL.Path, r.RemoteAddr)
+				w.Header().Set("Content-Type", "application/json")
+				sendResponse(http.StatusUnauthorized, &dto.ErrorResponse{Error: "Invalid API key"}, w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClient(r.Context(), client)))
+		})
+	}
+}
+
+// RequireScope пропускает запрос только если у клиента есть нужный scope
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := auth.ClientFromContext(r.Context())
+		if client == nil || !client.HasScope(scope) {
+			w.Header().Set("Content-Type", "application/json")
+			sendResponse(http.StatusForbidden, &dto.ErrorResponse{Error: "Operation is not permitted for this client"}, w)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"net/http"
+)
+
+func (h *handlers) FundEscrowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var fundEscrowRequest dto.FundEscrowRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&fundEscrowRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse fundEscrowRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received fundEscrowRequest: %v", fundEscrowRequest)
+
+	escrow, err, isInternal := h.service.GetEscrowService().FundEscrowRequest(r.Context(), fundEscrowRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do fundEscrowRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Escrow for deal %s has been successfully funded", escrow.DealId)
+	sendResponse(http.StatusOK, escrow, w)
+}
+
+func (h *handlers) ReleaseEscrowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var escrowActionRequest dto.EscrowActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&escrowActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse releaseEscrowRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received releaseEscrowRequest: %v", escrowActionRequest)
+
+	err, isInternal := h.service.GetEscrowService().ReleaseEscrowRequest(r.Context(), escrowActionRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do releaseEscrowRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Escrow for deal %s has been successfully released", escrowActionRequest.DealId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) CancelEscrowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var escrowActionRequest dto.EscrowActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&escrowActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse cancelEscrowRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received cancelEscrowRequest: %v", escrowActionRequest)
+
+	err, isInternal := h.service.GetEscrowService().CancelEscrowRequest(r.Context(), escrowActionRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do cancelEscrowRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Escrow for deal %s has been successfully cancelled", escrowActionRequest.DealId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) GetEscrowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	dealID := r.URL.Query().Get("deal_id")
+	if dealID == "" {
+		h.log.Warnf(r.Context(), "Error while parse value of deal_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of deal_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	escrow, err, isInternal := h.service.GetEscrowService().GetEscrowRequest(r.Context(), dealID)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getEscrowRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, escrow, w)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"net/http"
+)
+
+func (h *handlers) QuoteTransferHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var quoteRequest dto.TransferFundsRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&quoteRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse quoteTransferRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received quoteTransferRequest: %v", quoteRequest)
+
+	quote, err, isInternal := h.service.GetFeeService().QuoteTransferRequest(r.Context(), quoteRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do quoteTransferRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, quote, w)
+}
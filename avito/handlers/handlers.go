@@ -2,14 +2,12 @@ package handlers
 
 import (
 	"avito/dto"
+	"avito/logger"
 	"avito/service"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
-	"log"
 	"net/http"
-	"os"
-	"strconv"
 )
 
 type Handlers interface {
@@ -18,17 +16,57 @@ type Handlers interface {
 	TransferFundsHandler(w http.ResponseWriter, r *http.Request)
 	GetBalanceHandler(w http.ResponseWriter, r *http.Request)
 	GetTransactionsHandler(w http.ResponseWriter, r *http.Request)
+	RegisterWebhookHandler(w http.ResponseWriter, r *http.Request)
+	GetWebhooksHandler(w http.ResponseWriter, r *http.Request)
+	DeleteWebhookHandler(w http.ResponseWriter, r *http.Request)
+	GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request)
+	ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request)
+	GetUserLimitsHandler(w http.ResponseWriter, r *http.Request)
+	SetUserLimitsHandler(w http.ResponseWriter, r *http.Request)
+	ResetUserLimitsHandler(w http.ResponseWriter, r *http.Request)
+	GetAccountStatusHandler(w http.ResponseWriter, r *http.Request)
+	SetAccountStatusHandler(w http.ResponseWriter, r *http.Request)
+	SetCreditLimitHandler(w http.ResponseWriter, r *http.Request)
+	GetOverdraftAccountsHandler(w http.ResponseWriter, r *http.Request)
+	CreateScheduledOperationHandler(w http.ResponseWriter, r *http.Request)
+	GetScheduledOperationHandler(w http.ResponseWriter, r *http.Request)
+	GetScheduledOperationsHandler(w http.ResponseWriter, r *http.Request)
+	CancelScheduledOperationHandler(w http.ResponseWriter, r *http.Request)
+	FundEscrowHandler(w http.ResponseWriter, r *http.Request)
+	ReleaseEscrowHandler(w http.ResponseWriter, r *http.Request)
+	CancelEscrowHandler(w http.ResponseWriter, r *http.Request)
+	GetEscrowHandler(w http.ResponseWriter, r *http.Request)
+	CreateInvoiceHandler(w http.ResponseWriter, r *http.Request)
+	PayInvoiceHandler(w http.ResponseWriter, r *http.Request)
+	CancelInvoiceHandler(w http.ResponseWriter, r *http.Request)
+	GetInvoiceHandler(w http.ResponseWriter, r *http.Request)
+	GetInvoicesHandler(w http.ResponseWriter, r *http.Request)
+	CreateMoneyRequestHandler(w http.ResponseWriter, r *http.Request)
+	AcceptMoneyRequestHandler(w http.ResponseWriter, r *http.Request)
+	DeclineMoneyRequestHandler(w http.ResponseWriter, r *http.Request)
+	GetMoneyRequestHandler(w http.ResponseWriter, r *http.Request)
+	GetPendingMoneyRequestsHandler(w http.ResponseWriter, r *http.Request)
+	QuoteTransferHandler(w http.ResponseWriter, r *http.Request)
+	GetSystemAccountsHandler(w http.ResponseWriter, r *http.Request)
+	GetJournalEntryHandler(w http.ResponseWriter, r *http.Request)
+	ReconcileHandler(w http.ResponseWriter, r *http.Request)
+	VerifyLedgerHandler(w http.ResponseWriter, r *http.Request)
+	GetAuditLogHandler(w http.ResponseWriter, r *http.Request)
+	AdminCreditHandler(w http.ResponseWriter, r *http.Request)
+	AdminWithdrawHandler(w http.ResponseWriter, r *http.Request)
+	AdjustBalanceHandler(w http.ResponseWriter, r *http.Request)
+	Audit(next http.HandlerFunc) http.HandlerFunc
 }
 
 type handlers struct {
 	service service.ServiceAPI
-	log *log.Logger
+	log *logger.Logger
 }
 
 func NewHandlers(api service.ServiceAPI) Handlers {
 	return &handlers{
 		service: api,
-		log: log.New(os.Stdout, "CONTROLLER: ", log.LstdFlags),
+		log: logger.New("controller"),
 	}
 }
 
@@ -41,23 +79,23 @@ func (h *handlers) CreditFundsHandler(w http.ResponseWriter, r *http.Request) {
 	err := dec.Decode(&creditFundsRequest)
 
 	if err != nil {
-		h.log.Printf("Error while parse creditFundsRequest, reason: %v", err)
+		h.log.Warnf(r.Context(), "Error while parse creditFundsRequest, reason: %v", err)
 		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
 	}
-	h.log.Printf("Received creditFundsRequest: %v", creditFundsRequest)
+	h.log.Debugf(r.Context(), "Received creditFundsRequest: %v", creditFundsRequest)
 
-	err, bool := h.service.GetBalanceService().CreditFundsRequest(creditFundsRequest)
+	err, bool := h.service.GetBalanceService().CreditFundsRequest(r.Context(), creditFundsRequest)
 	if err != nil {
-		h.log.Printf("Error while do creditFundsRequest, reason: %v", err)
-		response := &dto.ErrorResponse{Error: err.Error()}
-		sendResponse(getErrorStatus(bool), response, w)
+		h.log.Warnf(r.Context(), "Error while do creditFundsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, bool), response, w)
 		return
 	}
 
 	response := fmt.Sprintf("OK")
-	h.log.Printf("Funds have been successfully credited to the account of user with id %v", creditFundsRequest.UserId)
+	h.log.Infof(r.Context(), "Funds have been successfully credited to the account of user with id %v", creditFundsRequest.UserId)
 	sendResponse(http.StatusOK, response, w)
 }
 
@@ -70,23 +108,23 @@ func (h *handlers) WithdrawFundsHandler(w http.ResponseWriter, r *http.Request)
 	err := dec.Decode(&withdrawFundsRequest)
 
 	if err != nil {
-		h.log.Printf("Error while parse withdrawFundsRequest, reason: %v", err)
+		h.log.Warnf(r.Context(), "Error while parse withdrawFundsRequest, reason: %v", err)
 		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
 	}
-	h.log.Printf("Received withdrawFundsRequest: %v", withdrawFundsRequest)
+	h.log.Debugf(r.Context(), "Received withdrawFundsRequest: %v", withdrawFundsRequest)
 
-	err, bool := h.service.GetBalanceService().WithdrawFundsRequest(withdrawFundsRequest)
+	err, bool := h.service.GetBalanceService().WithdrawFundsRequest(r.Context(), withdrawFundsRequest)
 	if err != nil {
-		h.log.Printf("Error while do withdrawFundsRequest, reason: %v", err)
-		response := &dto.ErrorResponse{Error: err.Error()}
-		sendResponse(getErrorStatus(bool), response, w)
+		h.log.Warnf(r.Context(), "Error while do withdrawFundsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, bool), response, w)
 		return
 	}
 
 	response := fmt.Sprintf("OK")
-	h.log.Printf("Funds have been successfully withdraw from the account of user %v", withdrawFundsRequest.UserId)
+	h.log.Infof(r.Context(), "Funds have been successfully withdraw from the account of user %v", withdrawFundsRequest.UserId)
 	sendResponse(http.StatusOK, response, w)
 }
 
@@ -99,23 +137,23 @@ func (h *handlers) TransferFundsHandler(w http.ResponseWriter, r *http.Request)
 	err := dec.Decode(&transferFundsRequest)
 
 	if err != nil {
-		h.log.Printf("Error while parse transferFundsRequest, reason: %v", err)
+		h.log.Warnf(r.Context(), "Error while parse transferFundsRequest, reason: %v", err)
 		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
 	}
-	h.log.Printf("Received transferFundsRequest: %v", transferFundsRequest)
+	h.log.Debugf(r.Context(), "Received transferFundsRequest: %v", transferFundsRequest)
 
-	err, bool := h.service.GetBalanceService().TransferFundsRequest(transferFundsRequest)
+	err, bool := h.service.GetBalanceService().TransferFundsRequest(r.Context(), transferFundsRequest)
 	if err != nil {
-		h.log.Printf("Error while do transferFundsRequest, reason: %v", err)
-		response := &dto.ErrorResponse{Error: err.Error()}
-		sendResponse(getErrorStatus(bool), response, w)
+		h.log.Warnf(r.Context(), "Error while do transferFundsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, bool), response, w)
 		return
 	}
 
 	response := fmt.Sprintf("OK")
-	h.log.Printf("Funds have been successfully transfer from user %v to user %v", transferFundsRequest.IdSender, transferFundsRequest.IdReceiver)
+	h.log.Infof(r.Context(), "Funds have been successfully transfer from user %v to user %v", transferFundsRequest.IdSender, transferFundsRequest.IdReceiver)
 	sendResponse(http.StatusOK, response, w)
 
 }
@@ -124,7 +162,7 @@ func (h *handlers) GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 
 	uID := r.URL.Query().Get("user_id")
 	if uID == "" {
-		h.log.Printf("Error while parse value of user_id")
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
 		response := &dto.ErrorResponse{Error: "Unknown user_id"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
@@ -132,7 +170,7 @@ func (h *handlers) GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 
 	userID, err := uuid.Parse(uID)
 	if err != nil {
-		h.log.Printf("Error while convert userID from string to uuid.UUID")
+		h.log.Warnf(r.Context(), "Error while convert userID from string to uuid.UUID")
 		response := &dto.ErrorResponse{Error: "System error. Contact support"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
@@ -140,16 +178,24 @@ func (h *handlers) GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 
 	currency := r.URL.Query().Get("currency")
 
-	result, err, isInternal := h.service.GetBalanceService().GetBalanceRequest(userID, currency)
+	ctx, err := withConsistency(r)
 	if err != nil {
-		h.log.Printf("Error while do creditFundsRequest, reason: %v", err)
-		response := &dto.ErrorResponse{Error: err.Error()}
+		h.log.Warnf(r.Context(), "Error while parse consistency, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	result, err, isInternal := h.service.GetBalanceService().GetBalanceRequest(ctx, userID, currency)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do creditFundsRequest, reason: %v", err)
+		response := newErrorResponse(err)
 		sendResponse(getErrorStatus(isInternal), response, w)
 		return
 	}
 
 	response := &dto.GetBalanceResponse{Sum: result}
-	h.log.Printf("Send response: %v", response)
+	h.log.Debugf(r.Context(), "Send response: %v", response)
 	sendResponse(http.StatusOK, fmt.Sprintf("Balance: %d.%d", response.Sum.IntPart, response.Sum.FracPart), w)
 }
 
@@ -157,7 +203,7 @@ func (h *handlers) GetTransactionsHandler(w http.ResponseWriter, r *http.Request
 
 	uID := r.URL.Query().Get("user_id")
 	if uID == "" {
-		h.log.Printf("Error while parse value of user_id")
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
 		response := &dto.ErrorResponse{Error: "Unknown user_id"}
 		sendResponse(http.StatusBadRequest, response, w)
 		return
@@ -165,46 +211,45 @@ func (h *handlers) GetTransactionsHandler(w http.ResponseWriter, r *http.Request
 
 	userID, err := uuid.Parse(uID)
 	if err != nil {
-		h.log.Printf("Error while convert userID from string to uuid.UUID")
-		response := &dto.ErrorResponse{Error: "System error. Contact support"}
+		h.log.Warnf(r.Context(), "Error while convert userID from string to uuid.UUID")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
 		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	ctx, err := withConsistency(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse consistency, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	rows, err, isInternal := h.service.GetTransactionService().GetTransactionsRequest(ctx, userID, limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getTransactionsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
 	}
 
-	l := r.URL.Query().Get("limit")
-	var limit int
-	if len(l) == 0 {
-		limit = 100
-	} else {
-		limit, err = strconv.Atoi(l)
-		if err != nil {
-			h.log.Printf("Error while parse value of limit")
-			response := &dto.ErrorResponse{Error: "Incorrect value of limit"}
-			sendResponse(http.StatusBadRequest, response, w)
-		}
-	}
-
-	ofs := r.URL.Query().Get("offset")
-	var offset int
-	if len(ofs) == 0 {
-		offset = 0
-	} else {
-		offset, err = strconv.Atoi(ofs)
-		if err != nil {
-			h.log.Printf("Error while parse value of offset")
-			response := &dto.ErrorResponse{Error: "Incorrect value of offset"}
-			sendResponse(http.StatusBadRequest, response, w)
-		}
-	}
-
-	rows, err, isInternal := h.service.GetTransactionService().GetTransactionsRequest(userID, limit, offset)
+	archived, err, isInternal := h.service.GetTransactionService().GetArchivedRangesRequest(ctx, userID)
 	if err != nil {
-		h.log.Printf("Error while do getTransactionsRequest, reason: %v", err)
-		response := &dto.ErrorResponse{Error: err.Error()}
+		h.log.Warnf(r.Context(), "Error while do getArchivedRangesRequest, reason: %v", err)
+		response := newErrorResponse(err)
 		sendResponse(getErrorStatus(isInternal), response, w)
 		return
 	}
 
-	response := &dto.GetTransactionsResponse{Transactions: rows}
-	h.log.Printf("Send response: %v", response)
+	response := &dto.GetTransactionsResponse{Transactions: rows, Archived: archived}
+	h.log.Debugf(r.Context(), "Send response: %v", response)
 	sendResponse(http.StatusOK, response, w)
 }
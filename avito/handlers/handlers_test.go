@@ -0,0 +1,748 @@
+package handlers
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/service"
+	"avito/storage/memory"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+const (
+	adminKey  = "admin-key"
+	readerKey = "reader-key"
+)
+
+var revenueAccount = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// newTestRouter собирает маршруты balance-service поверх хранилища в памяти
+func newTestRouter(t *testing.T) http.Handler {
+	conf := &config.ApplicationConfig{
+		Auth: config.AuthConfig{Clients: []config.APIClientConfig{
+			{Name: "admin", KeyHash: auth.HashKey(adminKey), Scopes: []string{auth.ScopeCredit, auth.ScopeWithdraw, auth.ScopeTransfer, auth.ScopeRead, auth.ScopeAdmin}},
+			{Name: "reader", KeyHash: auth.HashKey(readerKey), Scopes: []string{auth.ScopeRead}},
+		}},
+		// ограничитель подключен, но не мешает тестам
+		RateLimit: config.RateLimitConfig{Enabled: true, Routes: map[string]config.RouteRateLimitConfig{
+			"default": {ClientRate: 1000, ClientBurst: 1000, UserRate: 1000, UserBurst: 1000},
+		}},
+		SystemAccounts: config.SystemAccountsConfig{
+			Revenue:    revenueAccount.String(),
+			Promotions: "00000000-0000-0000-0000-000000000002",
+			Clearing:   "00000000-0000-0000-0000-000000000003",
+			Escrow:     "00000000-0000-0000-0000-000000000004",
+		},
+		Limits: config.LimitsConfig{SingleOperationMax: 100000},
+	}
+	serviceAPI := service.NewServiceAPI(memory.NewStorageAPI(), conf)
+	if err := serviceAPI.GetSystemAccountService().EnsureSystemAccounts(context.Background()); err != nil {
+		t.Fatalf("EnsureSystemAccounts: %v", err)
+	}
+
+	return NewRouter(serviceAPI, conf)
+}
+
+func do(t *testing.T, router http.Handler, method string, target string, key string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("cannot marshal request body: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("cannot decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+func requireStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
+
+func requireErrorCode(t *testing.T, w *httptest.ResponseRecorder, status int, code string) dto.ErrorResponse {
+	t.Helper()
+	requireStatus(t, w, status)
+
+	var response dto.ErrorResponse
+	decode(t, w, &response)
+	if response.Code != code || response.Error == "" || response.RequestId != w.Header().Get(requestIDHeader) {
+		t.Fatalf("unexpected error response %+v", response)
+	}
+
+	return response
+}
+
+func requireBalance(t *testing.T, router http.Handler, userID uuid.UUID, expected string) {
+	t.Helper()
+	w := do(t, router, http.MethodGet, "/balance/get?user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+
+	var balance string
+	decode(t, w, &balance)
+	if balance != expected {
+		t.Fatalf("expected %q, got %q", expected, balance)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+
+	w := do(t, router, http.MethodGet, "/balance/get?user_id="+userID.String(), "", nil)
+	requireErrorCode(t, w, http.StatusUnauthorized, "")
+
+	w = do(t, router, http.MethodGet, "/balance/get?user_id="+userID.String(), "wrong-key", nil)
+	requireErrorCode(t, w, http.StatusUnauthorized, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/balance/get?user_id="+userID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+readerKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	requireErrorCode(t, rec, http.StatusBadRequest, "")
+
+	w = do(t, router, http.MethodPost, "/balance/credit", readerKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 1}})
+	requireErrorCode(t, w, http.StatusForbidden, "")
+}
+
+func TestRequestID(t *testing.T) {
+	router := newTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/balance/get?user_id="+uuid.New().String(), nil)
+	req.Header.Set(apiKeyHeader, readerKey)
+	req.Header.Set(requestIDHeader, "trace-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get(requestIDHeader) != "trace-42" {
+		t.Fatalf("expected request id trace-42, got %q", w.Header().Get(requestIDHeader))
+	}
+
+	var response dto.ErrorResponse
+	decode(t, w, &response)
+	if response.RequestId != "trace-42" {
+		t.Fatalf("unexpected error response %+v", response)
+	}
+
+	w = do(t, router, http.MethodGet, "/balance/get?user_id="+uuid.New().String(), readerKey, nil)
+	if _, err := uuid.Parse(w.Header().Get(requestIDHeader)); err != nil {
+		t.Fatalf("expected generated request id, got %q", w.Header().Get(requestIDHeader))
+	}
+}
+
+func TestBalanceOperations(t *testing.T) {
+	router := newTestRouter(t)
+	senderID := uuid.New()
+	receiverID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: senderID, Sum: &dto.Money{IntPart: 100, FracPart: 50}})
+	requireStatus(t, w, http.StatusOK)
+	var ok string
+	decode(t, w, &ok)
+	if ok != "OK" {
+		t.Fatalf("unexpected response %q", ok)
+	}
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: senderID, Sum: &dto.Money{IntPart: 10}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/balance/transfer", adminKey, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: &dto.Money{IntPart: 40, FracPart: 25}})
+	requireStatus(t, w, http.StatusOK)
+
+	requireBalance(t, router, senderID, "Balance: 50.25")
+	requireBalance(t, router, receiverID, "Balance: 40.25")
+
+	w = do(t, router, http.MethodGet, "/balance/transactions?user_id="+senderID.String()+"&limit=2", readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var transactions dto.GetTransactionsResponse
+	decode(t, w, &transactions)
+	if len(transactions.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %+v", transactions)
+	}
+	for _, transaction := range transactions.Transactions {
+		if transaction.UserID != senderID || transaction.Client != "admin" {
+			t.Fatalf("unexpected transaction %+v", transaction)
+		}
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/system-accounts", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var report dto.SystemAccountsReport
+	decode(t, w, &report)
+	if !report.Balanced || *report.UsersTotal != (dto.Money{IntPart: 90, FracPart: 50}) {
+		t.Fatalf("unexpected system accounts report %+v", report)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/ledger/verify", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var verification dto.LedgerVerification
+	decode(t, w, &verification)
+	if !verification.Valid {
+		t.Fatalf("unexpected verification %+v", verification)
+	}
+}
+
+func TestBalanceErrors(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, map[string]interface{}{"user_id": userID, "amount": dto.Money{IntPart: 1}, "unknown": true})
+	requireErrorCode(t, w, http.StatusBadRequest, "")
+
+	w = do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 10}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 11}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeInsufficientFunds)
+
+	w = do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: revenueAccount, Sum: &dto.Money{IntPart: 1}})
+	requireErrorCode(t, w, http.StatusForbidden, dto.ErrCodeForbidden)
+
+	w = do(t, router, http.MethodPost, "/admin/accounts/set-status", adminKey, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountFrozenDebits, Reason: "fraud check"})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 1}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeAccountFrozen)
+
+	// отрицательное зачисление - ошибка пользователя
+	w = do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: -100}})
+	response := requireErrorCode(t, w, http.StatusBadRequest, "")
+	if response.Error != "Sum must be positive" {
+		t.Fatalf("unexpected error response %+v", response)
+	}
+
+	w = do(t, router, http.MethodGet, "/balance/get?user_id="+uuid.New().String(), readerKey, nil)
+	response = requireErrorCode(t, w, http.StatusBadRequest, "")
+	if response.Error != "User does not exist" {
+		t.Fatalf("unexpected error response %+v", response)
+	}
+
+	requireBalance(t, router, userID, "Balance: 10.0")
+
+	w = do(t, router, http.MethodGet, "/balance/get?consistency=strong&user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodGet, "/balance/transactions?consistency=latest&user_id="+userID.String(), readerKey, nil)
+	response = requireErrorCode(t, w, http.StatusBadRequest, "")
+	if response.Error != "consistency must be eventual or strong" {
+		t.Fatalf("unexpected error response %+v", response)
+	}
+}
+
+func TestSingleOperationLimit(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 5000}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 1000, FracPart: 1}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeLimitExceeded)
+}
+
+func TestEscrowHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	payerID := uuid.New()
+	payeeID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: payerID, Sum: &dto.Money{IntPart: 100}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/escrow/fund", adminKey, dto.FundEscrowRequest{DealId: "deal-1", PayerId: payerID, PayeeId: payeeID, Sum: &dto.Money{IntPart: 60}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodPost, "/escrow/fund", adminKey, dto.FundEscrowRequest{DealId: "deal-1", PayerId: payerID, PayeeId: payeeID, Sum: &dto.Money{IntPart: 10}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeInvalidState)
+
+	w = do(t, router, http.MethodGet, "/escrow/get?deal_id=deal-1", readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var escrow dto.Escrow
+	decode(t, w, &escrow)
+	if escrow.Status != dto.EscrowFunded || escrow.PayerId != payerID || escrow.History[0].Client != "admin" {
+		t.Fatalf("unexpected escrow %+v", escrow)
+	}
+
+	requireBalance(t, router, payerID, "Balance: 40.0")
+}
+
+func TestAuditLog(t *testing.T) {
+	router := newTestRouter(t)
+	senderID := uuid.New()
+	receiverID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: senderID, Sum: &dto.Money{IntPart: 10}})
+	requireStatus(t, w, http.StatusOK)
+	w = do(t, router, http.MethodPost, "/balance/transfer", adminKey, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: &dto.Money{IntPart: 20}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeInsufficientFunds)
+	failedRequestID := w.Header().Get(requestIDHeader)
+
+	// чтение не попадает в журнал аудита, отказ по scope попадает
+	do(t, router, http.MethodGet, "/balance/get?user_id="+senderID.String(), readerKey, nil)
+	w = do(t, router, http.MethodPost, "/balance/withdraw", readerKey, dto.OperationRequest{UserId: senderID, Sum: &dto.Money{IntPart: 1}})
+	requireStatus(t, w, http.StatusForbidden)
+
+	w = do(t, router, http.MethodGet, "/admin/audit?user_id="+senderID.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var log dto.GetAuditLogResponse
+	decode(t, w, &log)
+	if len(log.Records) != 3 {
+		t.Fatalf("expected 3 audit records, got %+v", log.Records)
+	}
+
+	forbidden, failed, credited := log.Records[0], log.Records[1], log.Records[2]
+	if forbidden.Client != "reader" || forbidden.Status != http.StatusForbidden || forbidden.Result != dto.AuditResultFailure {
+		t.Fatalf("unexpected audit record %+v", forbidden)
+	}
+	if failed.RequestId != failedRequestID || failed.Path != "/balance/transfer" || failed.Error == nil || len(failed.TransactionIds) != 0 || len(failed.UserIds) != 1 {
+		t.Fatalf("unexpected audit record %+v", failed)
+	}
+	if credited.Client != "admin" || credited.Result != dto.AuditResultSuccess || len(credited.TransactionIds) != 2 || credited.PayloadHash == "" {
+		t.Fatalf("unexpected audit record %+v", credited)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/audit?client=reader", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	decode(t, w, &log)
+	if len(log.Records) != 1 {
+		t.Fatalf("expected 1 audit record, got %+v", log.Records)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/audit?user_id=bad", adminKey, nil)
+	requireErrorCode(t, w, http.StatusBadRequest, "")
+
+	w = do(t, router, http.MethodGet, "/admin/audit", readerKey, nil)
+	requireErrorCode(t, w, http.StatusForbidden, "")
+}
+
+// requireError проверяет статус и текст ошибки ответа
+func requireError(t *testing.T, w *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+	response := requireErrorCode(t, w, status, "")
+	if response.Error != message {
+		t.Fatalf("expected error %q, got %+v", message, response)
+	}
+}
+
+func credit(t *testing.T, router http.Handler, userID uuid.UUID, sum int64) {
+	t.Helper()
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: sum}})
+	requireStatus(t, w, http.StatusOK)
+}
+
+// некорректные параметры отклоняются до обращения к сервису: ответ содержит только ошибку
+func TestGetTransactionsValidation(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+	credit(t, router, userID, 10)
+
+	requireError(t, do(t, router, http.MethodGet, "/balance/transactions", readerKey, nil), http.StatusBadRequest, "Unknown user_id")
+	requireError(t, do(t, router, http.MethodGet, "/balance/transactions?user_id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodGet, "/balance/transactions?limit=ten&user_id="+userID.String(), readerKey, nil), http.StatusBadRequest, "Incorrect value of limit")
+	requireError(t, do(t, router, http.MethodGet, "/balance/transactions?limit=-1&user_id="+userID.String(), readerKey, nil), http.StatusBadRequest, "Incorrect value of limit")
+	requireError(t, do(t, router, http.MethodGet, "/balance/transactions?offset=x&user_id="+userID.String(), readerKey, nil), http.StatusBadRequest, "Incorrect value of offset")
+
+	w := do(t, router, http.MethodGet, "/balance/transactions?limit=1&offset=1&user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var transactions dto.GetTransactionsResponse
+	decode(t, w, &transactions)
+	if len(transactions.Transactions) != 0 {
+		t.Fatalf("expected empty page, got %+v", transactions)
+	}
+}
+
+func TestTransferQuote(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := do(t, router, http.MethodPost, "/balance/transfer/quote", readerKey, dto.TransferFundsRequest{IdSender: uuid.New(), IdReceiver: uuid.New(), Sum: &dto.Money{IntPart: 10}})
+	requireStatus(t, w, http.StatusOK)
+	var quote dto.TransferQuote
+	decode(t, w, &quote)
+	if *quote.Sum != (dto.Money{IntPart: 10}) || *quote.Total != (dto.Money{IntPart: 10}) {
+		t.Fatalf("unexpected quote %+v", quote)
+	}
+
+	requireError(t, do(t, router, http.MethodPost, "/balance/transfer/quote", readerKey, "amount"), http.StatusBadRequest, "Cannot parse request body")
+}
+
+func TestMetricsRequireAdmin(t *testing.T) {
+	router := newTestRouter(t)
+
+	requireStatus(t, do(t, router, http.MethodGet, "/metrics", "", nil), http.StatusUnauthorized)
+	requireStatus(t, do(t, router, http.MethodGet, "/metrics", readerKey, nil), http.StatusForbidden)
+	requireStatus(t, do(t, router, http.MethodGet, "/metrics", adminKey, nil), http.StatusOK)
+}
+
+func TestAccountHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+	credit(t, router, userID, 10)
+
+	w := do(t, router, http.MethodPost, "/admin/accounts/set-credit-limit", adminKey, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: &dto.Money{IntPart: 5}})
+	requireStatus(t, w, http.StatusOK)
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 12}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodGet, "/admin/accounts/overdraft?limit=10", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var overdraft dto.GetOverdraftAccountsResponse
+	decode(t, w, &overdraft)
+	if len(overdraft.Accounts) != 1 || overdraft.Accounts[0].UserId != userID || *overdraft.Accounts[0].Sum != (dto.Money{IntPart: -2}) {
+		t.Fatalf("unexpected overdraft accounts %+v", overdraft)
+	}
+
+	w = do(t, router, http.MethodPost, "/admin/accounts/set-status", adminKey, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountBlocked, Reason: "court order"})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodGet, "/admin/accounts/status?user_id="+userID.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var status dto.GetAccountStatusResponse
+	decode(t, w, &status)
+	if status.Status != dto.AccountBlocked || status.Reason != "court order" || len(status.History) != 1 || status.History[0].Client != "admin" {
+		t.Fatalf("unexpected account status %+v", status)
+	}
+
+	requireError(t, do(t, router, http.MethodGet, "/admin/accounts/status?user_id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodGet, "/admin/accounts/overdraft?offset=-5", adminKey, nil), http.StatusBadRequest, "Incorrect value of offset")
+	requireError(t, do(t, router, http.MethodPost, "/admin/accounts/set-credit-limit", adminKey, map[string]string{"user": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/admin/accounts/set-status", adminKey, []int{1}), http.StatusBadRequest, "Cannot parse request body")
+	requireStatus(t, do(t, router, http.MethodPost, "/admin/accounts/set-credit-limit", adminKey, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: &dto.Money{IntPart: -1}}), http.StatusBadRequest)
+	requireStatus(t, do(t, router, http.MethodGet, "/admin/accounts/status?user_id="+userID.String(), readerKey, nil), http.StatusForbidden)
+}
+
+func TestLimitHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+	credit(t, router, userID, 100)
+
+	w := do(t, router, http.MethodPost, "/admin/limits/set", adminKey, dto.UserLimits{UserID: userID, WithdrawDaily: &dto.Money{IntPart: 30}})
+	requireStatus(t, w, http.StatusOK)
+
+	w = do(t, router, http.MethodGet, "/admin/limits/get?user_id="+userID.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var limits dto.UserLimits
+	decode(t, w, &limits)
+	if limits.WithdrawDaily == nil || *limits.WithdrawDaily != (dto.Money{IntPart: 30}) || *limits.SingleOperationMax != (dto.Money{IntPart: 1000}) {
+		t.Fatalf("unexpected limits %+v", limits)
+	}
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 31}})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeLimitExceeded)
+
+	requireStatus(t, do(t, router, http.MethodPost, "/admin/limits/reset?user_id="+userID.String(), adminKey, nil), http.StatusOK)
+	requireError(t, do(t, router, http.MethodPost, "/admin/limits/reset?user_id="+userID.String(), adminKey, nil), http.StatusBadRequest, "User has no individual limits")
+
+	w = do(t, router, http.MethodPost, "/balance/withdraw", adminKey, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 31}})
+	requireStatus(t, w, http.StatusOK)
+
+	requireError(t, do(t, router, http.MethodGet, "/admin/limits/get?user_id=", adminKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodPost, "/admin/limits/reset?user_id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodPost, "/admin/limits/set", adminKey, map[string]int{"withdraw_yearly": 1}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/admin/limits/set", adminKey, dto.UserLimits{UserID: userID, TransferDaily: &dto.Money{IntPart: -1}}), http.StatusBadRequest, "Limit cannot be negative")
+}
+
+func TestAdminOperationHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+
+	w := do(t, router, http.MethodPost, "/admin/balance/credit", adminKey, dto.AdminOperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 50}, Reason: dto.AdminReasonCompensation})
+	requireStatus(t, w, http.StatusOK)
+	w = do(t, router, http.MethodPost, "/admin/balance/withdraw", adminKey, dto.AdminOperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 20}, Reason: dto.AdminReasonChargeback, Comment: "case 7"})
+	requireStatus(t, w, http.StatusOK)
+	requireBalance(t, router, userID, "Balance: 30.0")
+
+	w = do(t, router, http.MethodPost, "/admin/balance/adjust", adminKey, dto.AdminOperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 45}, Reason: dto.AdminReasonCorrection})
+	requireStatus(t, w, http.StatusOK)
+	requireBalance(t, router, userID, "Balance: 45.0")
+
+	w = do(t, router, http.MethodGet, "/balance/transactions?user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var transactions dto.GetTransactionsResponse
+	decode(t, w, &transactions)
+	if len(transactions.Transactions) != 3 || transactions.Transactions[1].Comment != "admin:chargeback: case 7" {
+		t.Fatalf("unexpected transactions %+v", transactions.Transactions)
+	}
+
+	requireError(t, do(t, router, http.MethodPost, "/admin/balance/credit", adminKey, dto.AdminOperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 1}, Reason: "gift"}), http.StatusBadRequest, "reason must be one of "+fmt.Sprint(dto.AdminReasons))
+	requireError(t, do(t, router, http.MethodPost, "/admin/balance/withdraw", adminKey, dto.AdminOperationRequest{UserId: userID, Sum: &dto.Money{IntPart: -1}, Reason: dto.AdminReasonFraud}), http.StatusBadRequest, "Sum must be positive")
+	requireError(t, do(t, router, http.MethodPost, "/admin/balance/adjust", adminKey, "adjust"), http.StatusBadRequest, "Cannot parse request body")
+	w = do(t, router, http.MethodPost, "/admin/balance/adjust", adminKey, dto.AdminOperationRequest{UserId: revenueAccount, Sum: &dto.Money{IntPart: 1}, Reason: dto.AdminReasonCorrection})
+	requireErrorCode(t, w, http.StatusForbidden, dto.ErrCodeForbidden)
+}
+
+func TestLedgerHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+	credit(t, router, userID, 25)
+
+	w := do(t, router, http.MethodGet, "/balance/transactions?user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var transactions dto.GetTransactionsResponse
+	decode(t, w, &transactions)
+
+	w = do(t, router, http.MethodGet, "/admin/ledger/entry?id="+transactions.Transactions[0].EntryId.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var entry dto.JournalEntry
+	decode(t, w, &entry)
+	if entry.Operation != dto.OperationCredit || len(entry.Postings) != 2 {
+		t.Fatalf("unexpected journal entry %+v", entry)
+	}
+
+	w = do(t, router, http.MethodPost, "/admin/ledger/reconcile?fix=false", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var report dto.ReconcileReport
+	decode(t, w, &report)
+	if report.Accounts == 0 || len(report.Mismatches) != 0 || report.Fixed {
+		t.Fatalf("unexpected reconcile report %+v", report)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/ledger/verify?user_id="+userID.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var verification dto.LedgerVerification
+	decode(t, w, &verification)
+	if !verification.Valid {
+		t.Fatalf("unexpected verification %+v", verification)
+	}
+
+	requireError(t, do(t, router, http.MethodGet, "/admin/ledger/entry?id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireStatus(t, do(t, router, http.MethodGet, "/admin/ledger/entry?id="+uuid.New().String(), adminKey, nil), http.StatusBadRequest)
+	requireError(t, do(t, router, http.MethodPost, "/admin/ledger/reconcile?fix=maybe", adminKey, nil), http.StatusBadRequest, "Incorrect value of fix")
+	requireError(t, do(t, router, http.MethodGet, "/admin/ledger/verify?user_id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := do(t, router, http.MethodPost, "/admin/webhooks/create", adminKey, dto.WebhookRequest{URL: "https://example.com/hook", Events: []string{dto.EventCredit, dto.EventCredit}})
+	requireStatus(t, w, http.StatusOK)
+	var webhook dto.Webhook
+	decode(t, w, &webhook)
+	if webhook.Secret == "" || len(webhook.Events) != 1 {
+		t.Fatalf("unexpected webhook %+v", webhook)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/webhooks/list", adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var webhooks dto.GetWebhooksResponse
+	decode(t, w, &webhooks)
+	if len(webhooks.Webhooks) != 1 || webhooks.Webhooks[0].Id != webhook.Id || webhooks.Webhooks[0].Secret != "" {
+		t.Fatalf("unexpected webhooks %+v", webhooks)
+	}
+
+	w = do(t, router, http.MethodGet, "/admin/webhooks/deliveries?webhook_id="+webhook.Id.String(), adminKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var deliveries dto.GetWebhookDeliveriesResponse
+	decode(t, w, &deliveries)
+	if len(deliveries.Deliveries) != 0 {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+
+	requireStatus(t, do(t, router, http.MethodPost, "/admin/webhooks/delete?id="+webhook.Id.String(), adminKey, nil), http.StatusOK)
+	requireStatus(t, do(t, router, http.MethodPost, "/admin/webhooks/delete?id="+webhook.Id.String(), adminKey, nil), http.StatusBadRequest)
+
+	requireError(t, do(t, router, http.MethodPost, "/admin/webhooks/create", adminKey, dto.WebhookRequest{URL: "ftp://example.com", Events: []string{dto.EventCredit}}), http.StatusBadRequest, "url must be an absolute http(s) URL")
+	requireError(t, do(t, router, http.MethodPost, "/admin/webhooks/create", adminKey, map[string]string{"endpoint": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodGet, "/admin/webhooks/deliveries?webhook_id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of webhook_id")
+	requireError(t, do(t, router, http.MethodGet, "/admin/webhooks/deliveries?limit=x", adminKey, nil), http.StatusBadRequest, "Incorrect value of limit")
+	requireError(t, do(t, router, http.MethodPost, "/admin/webhooks/delete?id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireError(t, do(t, router, http.MethodPost, "/admin/webhooks/replay?id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireStatus(t, do(t, router, http.MethodPost, "/admin/webhooks/replay?id="+uuid.New().String(), adminKey, nil), http.StatusBadRequest)
+}
+
+func TestScheduledHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	userID := uuid.New()
+	credit(t, router, userID, 100)
+	runAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	w := do(t, router, http.MethodPost, "/scheduled/create", adminKey, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: &dto.Money{IntPart: 10}, RunAt: runAt})
+	requireStatus(t, w, http.StatusOK)
+	var operation dto.ScheduledOperation
+	decode(t, w, &operation)
+	if operation.Status != dto.ScheduledActive || operation.Client != "admin" {
+		t.Fatalf("unexpected scheduled operation %+v", operation)
+	}
+
+	w = do(t, router, http.MethodGet, "/scheduled/get?id="+operation.Id.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	w = do(t, router, http.MethodGet, "/scheduled/list?user_id="+userID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var operations dto.GetScheduledOperationsResponse
+	decode(t, w, &operations)
+	if len(operations.Operations) != 1 || operations.Operations[0].Id != operation.Id {
+		t.Fatalf("unexpected scheduled operations %+v", operations)
+	}
+
+	// создание и отмена требуют scope операции
+	w = do(t, router, http.MethodPost, "/scheduled/create", readerKey, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: &dto.Money{IntPart: 10}, RunAt: runAt})
+	requireErrorCode(t, w, http.StatusForbidden, dto.ErrCodeForbidden)
+	w = do(t, router, http.MethodPost, "/scheduled/cancel?id="+operation.Id.String(), readerKey, nil)
+	requireErrorCode(t, w, http.StatusForbidden, dto.ErrCodeForbidden)
+
+	requireStatus(t, do(t, router, http.MethodPost, "/scheduled/cancel?id="+operation.Id.String(), adminKey, nil), http.StatusOK)
+	w = do(t, router, http.MethodGet, "/scheduled/get?id="+operation.Id.String(), readerKey, nil)
+	decode(t, w, &operation)
+	if operation.Status != dto.ScheduledCancelled {
+		t.Fatalf("expected cancelled operation, got %+v", operation)
+	}
+
+	requireError(t, do(t, router, http.MethodPost, "/scheduled/create", adminKey, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: &dto.Money{IntPart: 10}, Schedule: "61 * * * *"}), http.StatusBadRequest,
+		"Incorrect schedule: cron field \"61\": value out of range [0, 59]")
+	requireError(t, do(t, router, http.MethodPost, "/scheduled/create", adminKey, map[string]string{"op": "withdraw"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodGet, "/scheduled/get?id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireError(t, do(t, router, http.MethodGet, "/scheduled/list?user_id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodGet, "/scheduled/list?offset=x&user_id="+userID.String(), readerKey, nil), http.StatusBadRequest, "Incorrect value of offset")
+	requireError(t, do(t, router, http.MethodPost, "/scheduled/cancel?id=bad", adminKey, nil), http.StatusBadRequest, "Incorrect value of id")
+}
+
+func TestInvoiceHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	payerID := uuid.New()
+	credit(t, router, payerID, 100)
+	dueAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	create := func(sum int64) dto.Invoice {
+		t.Helper()
+		w := do(t, router, http.MethodPost, "/invoices/create", adminKey, dto.CreateInvoiceRequest{PayerId: payerID, Sum: &dto.Money{IntPart: sum}, Description: "vacancy", DueAt: dueAt})
+		requireStatus(t, w, http.StatusOK)
+		var invoice dto.Invoice
+		decode(t, w, &invoice)
+		return invoice
+	}
+	paid, cancelled := create(60), create(70)
+
+	requireStatus(t, do(t, router, http.MethodPost, "/invoices/pay", adminKey, dto.InvoiceActionRequest{InvoiceId: paid.Id}), http.StatusOK)
+	requireStatus(t, do(t, router, http.MethodPost, "/invoices/cancel", adminKey, dto.InvoiceActionRequest{InvoiceId: cancelled.Id}), http.StatusOK)
+	requireBalance(t, router, payerID, "Balance: 40.0")
+
+	w := do(t, router, http.MethodGet, "/invoices/get?id="+paid.Id.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var invoice dto.Invoice
+	decode(t, w, &invoice)
+	if invoice.Status != dto.InvoicePaid || invoice.PaidAt == nil {
+		t.Fatalf("unexpected invoice %+v", invoice)
+	}
+
+	w = do(t, router, http.MethodGet, "/invoices/list?status=cancelled&payer_id="+payerID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var invoices dto.GetInvoicesResponse
+	decode(t, w, &invoices)
+	if len(invoices.Invoices) != 1 || invoices.Invoices[0].Id != cancelled.Id {
+		t.Fatalf("unexpected invoices %+v", invoices)
+	}
+
+	requireError(t, do(t, router, http.MethodPost, "/invoices/pay", adminKey, dto.InvoiceActionRequest{InvoiceId: uuid.New()}), http.StatusBadRequest, "Invoice does not exist")
+	requireError(t, do(t, router, http.MethodPost, "/invoices/cancel", adminKey, dto.InvoiceActionRequest{InvoiceId: uuid.New()}), http.StatusBadRequest, "Invoice does not exist")
+	requireError(t, do(t, router, http.MethodPost, "/invoices/create", adminKey, dto.CreateInvoiceRequest{PayerId: payerID, Sum: &dto.Money{IntPart: 1}, DueAt: "tomorrow"}), http.StatusBadRequest, "due_at must be in RFC3339 format")
+	requireError(t, do(t, router, http.MethodPost, "/invoices/create", adminKey, []string{}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/invoices/pay", adminKey, map[string]string{"id": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/invoices/cancel", adminKey, map[string]string{"id": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodGet, "/invoices/get?id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireError(t, do(t, router, http.MethodGet, "/invoices/list?payer_id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of payer_id")
+	requireError(t, do(t, router, http.MethodGet, "/invoices/list?status=unknown&payer_id="+payerID.String(), readerKey, nil), http.StatusBadRequest, "Unknown invoice status unknown")
+}
+
+func TestMoneyRequestHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	requesterID, payerID := uuid.New(), uuid.New()
+	credit(t, router, requesterID, 1)
+	credit(t, router, payerID, 100)
+
+	create := func(sum int64) dto.MoneyRequest {
+		t.Helper()
+		w := do(t, router, http.MethodPost, "/money-requests/create", adminKey, dto.CreateMoneyRequest{RequesterId: requesterID, PayerId: payerID, Sum: &dto.Money{IntPart: sum}, Message: "lunch",
+			ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+		requireStatus(t, w, http.StatusOK)
+		var request dto.MoneyRequest
+		decode(t, w, &request)
+		return request
+	}
+	accepted, declined := create(30), create(40)
+
+	w := do(t, router, http.MethodGet, "/money-requests/pending?user_id="+payerID.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var pending dto.GetMoneyRequestsResponse
+	decode(t, w, &pending)
+	if len(pending.Requests) != 2 {
+		t.Fatalf("expected 2 pending requests, got %+v", pending)
+	}
+
+	requireStatus(t, do(t, router, http.MethodPost, "/money-requests/accept", adminKey, dto.MoneyRequestActionRequest{RequestId: accepted.Id}), http.StatusOK)
+	requireStatus(t, do(t, router, http.MethodPost, "/money-requests/decline", adminKey, dto.MoneyRequestActionRequest{RequestId: declined.Id}), http.StatusOK)
+	requireBalance(t, router, requesterID, "Balance: 31.0")
+	requireBalance(t, router, payerID, "Balance: 70.0")
+
+	w = do(t, router, http.MethodGet, "/money-requests/get?id="+declined.Id.String(), readerKey, nil)
+	requireStatus(t, w, http.StatusOK)
+	var request dto.MoneyRequest
+	decode(t, w, &request)
+	if request.Status != dto.MoneyRequestDeclined {
+		t.Fatalf("unexpected money request %+v", request)
+	}
+
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/accept", adminKey, dto.MoneyRequestActionRequest{RequestId: uuid.New()}), http.StatusBadRequest, "Money request does not exist")
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/decline", adminKey, dto.MoneyRequestActionRequest{RequestId: uuid.New()}), http.StatusBadRequest, "Money request does not exist")
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/create", adminKey, dto.CreateMoneyRequest{RequesterId: payerID, PayerId: payerID, Sum: &dto.Money{IntPart: 1}}), http.StatusBadRequest, "RequesterID and payerID cannot be equal")
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/create", adminKey, "request"), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/accept", adminKey, map[string]string{"id": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/money-requests/decline", adminKey, map[string]string{"id": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodGet, "/money-requests/get?id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of id")
+	requireError(t, do(t, router, http.MethodGet, "/money-requests/pending?user_id=bad", readerKey, nil), http.StatusBadRequest, "Incorrect value of user_id")
+	requireError(t, do(t, router, http.MethodGet, "/money-requests/pending?limit=x&user_id="+payerID.String(), readerKey, nil), http.StatusBadRequest, "Incorrect value of limit")
+}
+
+func TestEscrowSettlementHandlers(t *testing.T) {
+	router := newTestRouter(t)
+	payerID, payeeID := uuid.New(), uuid.New()
+	credit(t, router, payerID, 100)
+
+	for _, deal := range []string{"deal-released", "deal-cancelled"} {
+		w := do(t, router, http.MethodPost, "/escrow/fund", adminKey, dto.FundEscrowRequest{DealId: deal, PayerId: payerID, PayeeId: payeeID, Sum: &dto.Money{IntPart: 30}})
+		requireStatus(t, w, http.StatusOK)
+	}
+
+	requireStatus(t, do(t, router, http.MethodPost, "/escrow/release", adminKey, dto.EscrowActionRequest{DealId: "deal-released", Comment: "work accepted"}), http.StatusOK)
+	requireStatus(t, do(t, router, http.MethodPost, "/escrow/cancel", adminKey, dto.EscrowActionRequest{DealId: "deal-cancelled"}), http.StatusOK)
+	requireBalance(t, router, payerID, "Balance: 70.0")
+	requireBalance(t, router, payeeID, "Balance: 30.0")
+
+	w := do(t, router, http.MethodPost, "/escrow/release", adminKey, dto.EscrowActionRequest{DealId: "deal-cancelled"})
+	requireErrorCode(t, w, http.StatusBadRequest, dto.ErrCodeInvalidState)
+
+	requireError(t, do(t, router, http.MethodPost, "/escrow/release", adminKey, map[string]string{"deal": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodPost, "/escrow/cancel", adminKey, map[string]string{"deal": "x"}), http.StatusBadRequest, "Cannot parse request body")
+	requireError(t, do(t, router, http.MethodGet, "/escrow/get", readerKey, nil), http.StatusBadRequest, "Incorrect value of deal_id")
+	requireStatus(t, do(t, router, http.MethodPost, "/escrow/release", readerKey, dto.EscrowActionRequest{DealId: "deal-released"}), http.StatusForbidden)
+}
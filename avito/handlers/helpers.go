@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"avito/dto"
+	"avito/service"
+	"context"
 	"encoding/json"
+	"golang.org/x/xerrors"
 	"net/http"
+	"strconv"
 )
 
 func getErrorStatus(isInternal bool) int {
@@ -13,8 +18,70 @@ func getErrorStatus(isInternal bool) int {
 	return http.StatusInternalServerError
 }
 
+// getServiceErrorStatus дополнительно учитывает код ошибки сервиса
+func getServiceErrorStatus(err error, isInternal bool) int {
+	var serviceError *service.ServiceError
+	if xerrors.As(err, &serviceError) && serviceError.Code == dto.ErrCodeForbidden {
+		return http.StatusForbidden
+	}
+
+	return getErrorStatus(isInternal)
+}
+
+// newErrorResponse формирует ответ с текстом ошибки и ее кодом, если он есть
+func newErrorResponse(err error) *dto.ErrorResponse {
+	response := &dto.ErrorResponse{Error: err.Error()}
+
+	var serviceError *service.ServiceError
+	if xerrors.As(err, &serviceError) {
+		response.Code = serviceError.Code
+	}
+
+	return response
+}
+
 func sendResponse(httpStatus int, response interface{}, w http.ResponseWriter) {
+	// id запроса выставляет NewRequestIDMiddleware
+	if errorResponse, ok := response.(*dto.ErrorResponse); ok {
+		errorResponse.RequestId = w.Header().Get(requestIDHeader)
+	}
+
 	w.WriteHeader(httpStatus)
 	json.NewEncoder(w).Encode(response)
 }
 
+
+// parsePagination разбирает параметры limit и offset, по умолчанию limit=100, offset=0
+func parsePagination(r *http.Request) (int, int, error) {
+	limit, offset := 100, 0
+
+	if l := r.URL.Query().Get("limit"); len(l) != 0 {
+		value, err := strconv.Atoi(l)
+		if err != nil || value < 0 {
+			return 0, 0, xerrors.Errorf("Incorrect value of limit")
+		}
+		limit = value
+	}
+
+	if ofs := r.URL.Query().Get("offset"); len(ofs) != 0 {
+		value, err := strconv.Atoi(ofs)
+		if err != nil || value < 0 {
+			return 0, 0, xerrors.Errorf("Incorrect value of offset")
+		}
+		offset = value
+	}
+
+	return limit, offset, nil
+}
+
+// withConsistency разбирает параметр consistency запроса чтения: strong - чтение из основной базы
+func withConsistency(r *http.Request) (context.Context, error) {
+	switch r.URL.Query().Get("consistency") {
+	case "", dto.ConsistencyEventual:
+		return r.Context(), nil
+	case dto.ConsistencyStrong:
+		return service.WithStrongConsistency(r.Context()), nil
+	default:
+		return nil, xerrors.Errorf("consistency must be %s or %s", dto.ConsistencyEventual, dto.ConsistencyStrong)
+	}
+}
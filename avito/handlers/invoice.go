@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) CreateInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var createInvoiceRequest dto.CreateInvoiceRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&createInvoiceRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse createInvoiceRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received createInvoiceRequest: %v", createInvoiceRequest)
+
+	invoice, err, isInternal := h.service.GetInvoiceService().CreateInvoiceRequest(r.Context(), createInvoiceRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do createInvoiceRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Invoice %v has been successfully created", invoice.Id)
+	sendResponse(http.StatusOK, invoice, w)
+}
+
+func (h *handlers) PayInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var invoiceActionRequest dto.InvoiceActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&invoiceActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse payInvoiceRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received payInvoiceRequest: %v", invoiceActionRequest)
+
+	err, isInternal := h.service.GetInvoiceService().PayInvoiceRequest(r.Context(), invoiceActionRequest.InvoiceId)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do payInvoiceRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Invoice %v has been successfully paid", invoiceActionRequest.InvoiceId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) CancelInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var invoiceActionRequest dto.InvoiceActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&invoiceActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse cancelInvoiceRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received cancelInvoiceRequest: %v", invoiceActionRequest)
+
+	err, isInternal := h.service.GetInvoiceService().CancelInvoiceRequest(r.Context(), invoiceActionRequest.InvoiceId)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do cancelInvoiceRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Invoice %v has been successfully cancelled", invoiceActionRequest.InvoiceId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) GetInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	invoice, err, isInternal := h.service.GetInvoiceService().GetInvoiceRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getInvoiceRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, invoice, w)
+}
+
+func (h *handlers) GetInvoicesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	payerID, err := uuid.Parse(r.URL.Query().Get("payer_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of payer_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of payer_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	invoices, err, isInternal := h.service.GetInvoiceService().GetInvoicesRequest(r.Context(), payerID, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getInvoicesRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetInvoicesResponse{Invoices: invoices}
+	sendResponse(http.StatusOK, response, w)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+func (h *handlers) GetJournalEntryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	entry, err, isInternal := h.service.GetLedgerService().GetJournalEntryRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getJournalEntryRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, entry, w)
+}
+
+func (h *handlers) ReconcileHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	fix := false
+	if f := r.URL.Query().Get("fix"); f != "" {
+		value, err := strconv.ParseBool(f)
+		if err != nil {
+			h.log.Warnf(r.Context(), "Error while parse value of fix")
+			response := &dto.ErrorResponse{Error: "Incorrect value of fix"}
+			sendResponse(http.StatusBadRequest, response, w)
+			return
+		}
+		fix = value
+	}
+
+	report, err, isInternal := h.service.GetLedgerService().ReconcileRequest(r.Context(), fix, r.URL.Query().Get("reason"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do reconcileRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Reconciliation found %d mismatches in %d accounts", len(report.Mismatches), report.Accounts)
+	sendResponse(http.StatusOK, report, w)
+}
+
+func (h *handlers) VerifyLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var userID *uuid.UUID
+	if u := r.URL.Query().Get("user_id"); u != "" {
+		id, err := uuid.Parse(u)
+		if err != nil {
+			h.log.Warnf(r.Context(), "Error while parse value of user_id")
+			response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+			sendResponse(http.StatusBadRequest, response, w)
+			return
+		}
+		userID = &id
+	}
+
+	result, err, isInternal := h.service.GetLedgerService().VerifyLedgerRequest(r.Context(), userID)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do verifyLedgerRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, result, w)
+}
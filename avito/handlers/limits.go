@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) GetUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	limits, err, isInternal := h.service.GetLimitService().GetUserLimitsRequest(r.Context(), userID)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getUserLimitsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, limits, w)
+}
+
+func (h *handlers) SetUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var userLimits dto.UserLimits
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&userLimits)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse setUserLimitsRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received setUserLimitsRequest: %v", userLimits)
+
+	err, isInternal := h.service.GetLimitService().SetUserLimitsRequest(r.Context(), userLimits)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do setUserLimitsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Limits of user %v have been successfully updated", userLimits.UserID)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) ResetUserLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	err, isInternal := h.service.GetLimitService().ResetUserLimitsRequest(r.Context(), userID)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do resetUserLimitsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Limits of user %v have been reset to defaults", userID)
+	sendResponse(http.StatusOK, "OK", w)
+}
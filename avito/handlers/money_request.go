@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) CreateMoneyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var createMoneyRequest dto.CreateMoneyRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&createMoneyRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse createMoneyRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received createMoneyRequest: %v", createMoneyRequest)
+
+	request, err, isInternal := h.service.GetMoneyRequestService().CreateMoneyRequest(r.Context(), createMoneyRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do createMoneyRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Money request %v has been successfully created", request.Id)
+	sendResponse(http.StatusOK, request, w)
+}
+
+func (h *handlers) AcceptMoneyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var moneyRequestActionRequest dto.MoneyRequestActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&moneyRequestActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse acceptMoneyRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received acceptMoneyRequest: %v", moneyRequestActionRequest)
+
+	err, isInternal := h.service.GetMoneyRequestService().AcceptMoneyRequest(r.Context(), moneyRequestActionRequest.RequestId)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do acceptMoneyRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Money request %v has been successfully accepted", moneyRequestActionRequest.RequestId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) DeclineMoneyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var moneyRequestActionRequest dto.MoneyRequestActionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&moneyRequestActionRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse declineMoneyRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received declineMoneyRequest: %v", moneyRequestActionRequest)
+
+	err, isInternal := h.service.GetMoneyRequestService().DeclineMoneyRequest(r.Context(), moneyRequestActionRequest.RequestId)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do declineMoneyRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Money request %v has been successfully declined", moneyRequestActionRequest.RequestId)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) GetMoneyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	request, err, isInternal := h.service.GetMoneyRequestService().GetMoneyRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getMoneyRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, request, w)
+}
+
+func (h *handlers) GetPendingMoneyRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	requests, err, isInternal := h.service.GetMoneyRequestService().GetPendingMoneyRequests(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getPendingMoneyRequests, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetMoneyRequestsResponse{Requests: requests}
+	sendResponse(http.StatusOK, response, w)
+}
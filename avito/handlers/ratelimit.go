@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/metrics"
+	"avito/ratelimit"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var rateLimitRejected = metrics.NewCounterVec("rate_limit_rejected_total", "Requests rejected by rate limiter", "route", "limit", "client")
+
+type routeLimiters struct {
+	client *ratelimit.Limiter
+	user   *ratelimit.Limiter
+}
+
+type rateLimiter struct {
+	conf     config.RateLimitConfig
+	log      *logger.Logger
+	mu       sync.Mutex
+	limiters map[string]*routeLimiters
+}
+
+// NewRateLimitMiddleware ограничивает число запросов к каждому маршруту отдельно
+// для каждого клиента и для каждого user_id. Должен подключаться после NewAuthMiddleware
+func NewRateLimitMiddleware(conf config.RateLimitConfig) func(http.Handler) http.Handler {
+	rl := &rateLimiter{
+		conf:     conf,
+		log:      logger.New("rate-limit"),
+		limiters: make(map[string]*routeLimiters),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !conf.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			limiters := rl.get(route)
+
+			client := auth.ClientName(r.Context())
+			if client == "" {
+				client = r.RemoteAddr
+			}
+
+			if limiters.client != nil {
+				if ok, wait := limiters.client.Allow(client); !ok {
+					rl.reject(w, r, route, "client", client, wait)
+					return
+				}
+			}
+
+			if limiters.user != nil {
+				// корзина пользователя общая для всех клиентов, иначе N клиентов
+				// пропускали бы к одному пользователю в N раз больше запросов
+				if userID := requestUserID(r); userID != "" {
+					if ok, wait := limiters.user.Allow(userID); !ok {
+						rl.reject(w, r, route, "user", client, wait)
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *rateLimiter) get(route string) *routeLimiters {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if limiters, ok := rl.limiters[route]; ok {
+		return limiters
+	}
+
+	routeConf, ok := rl.conf.Routes[route]
+	if !ok {
+		routeConf = rl.conf.Routes["default"]
+	}
+
+	limiters := &routeLimiters{}
+	if routeConf.ClientRate > 0 {
+		limiters.client = ratelimit.NewLimiter(routeConf.ClientRate, routeConf.ClientBurst)
+	}
+	if routeConf.UserRate > 0 {
+		limiters.user = ratelimit.NewLimiter(routeConf.UserRate, routeConf.UserBurst)
+	}
+	rl.limiters[route] = limiters
+
+	return limiters
+}
+
+func (rl *rateLimiter) reject(w http.ResponseWriter, r *http.Request, route string, limit string, client string, wait time.Duration) {
+	rl.log.Warnf(r.Context(), "Rejected request to %s from client %s: %s limit exceeded", route, client, limit)
+	rateLimitRejected.Inc(route, limit, client)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+	sendResponse(http.StatusTooManyRequests, &dto.ErrorResponse{Error: "Too many requests"}, w)
+}
+
+// requestUserID возвращает пользователя, к которому относится запрос: user_id из query
+// или user_id/sender_id из тела запроса. Тело после чтения восстанавливается
+func requestUserID(r *http.Request) string {
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		return userID
+	}
+
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var fields struct {
+		UserID   string `json:"user_id"`
+		SenderID string `json:"sender_id"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+
+	if fields.UserID != "" {
+		return fields.UserID
+	}
+
+	return fields.SenderID
+}
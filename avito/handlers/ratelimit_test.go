@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"avito/auth"
+	"avito/config"
+	"github.com/gorilla/mux"
+	"net/http"
+	"testing"
+)
+
+// newRateLimitRouter возвращает маршрут с заданными ограничениями, отвечающий 200 на каждый пропущенный запрос
+func newRateLimitRouter(routes map[string]config.RouteRateLimitConfig) http.Handler {
+	clients := []config.APIClientConfig{
+		{Name: "admin", KeyHash: auth.HashKey(adminKey), Scopes: []string{auth.ScopeRead}},
+		{Name: "reader", KeyHash: auth.HashKey(readerKey), Scopes: []string{auth.ScopeRead}},
+	}
+
+	r := mux.NewRouter()
+	r.Use(NewRequestIDMiddleware())
+	r.Use(NewAuthMiddleware(clients))
+	r.Use(NewRateLimitMiddleware(config.RateLimitConfig{Enabled: true, Routes: routes}))
+	r.HandleFunc("/balance/credit", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return r
+}
+
+func TestRateLimitClient(t *testing.T) {
+	router := newRateLimitRouter(map[string]config.RouteRateLimitConfig{
+		"default": {ClientRate: 0.5, ClientBurst: 2},
+	})
+
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", adminKey, nil), http.StatusOK)
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", adminKey, nil), http.StatusOK)
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, nil)
+	requireStatus(t, w, http.StatusTooManyRequests)
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", retryAfter)
+	}
+
+	// лимит клиента не действует на других клиентов
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", readerKey, nil), http.StatusOK)
+}
+
+func TestRateLimitUser(t *testing.T) {
+	router := newRateLimitRouter(map[string]config.RouteRateLimitConfig{
+		"/balance/credit": {UserRate: 1, UserBurst: 1},
+	})
+	user := map[string]string{"user_id": "2b1f4b1e-2f4c-4d4b-9d4a-6f0f4c1e0a01"}
+	other := map[string]string{"user_id": "2b1f4b1e-2f4c-4d4b-9d4a-6f0f4c1e0a02"}
+
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", adminKey, user), http.StatusOK)
+
+	w := do(t, router, http.MethodPost, "/balance/credit", adminKey, user)
+	requireStatus(t, w, http.StatusTooManyRequests)
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "1" {
+		t.Fatalf("expected Retry-After 1, got %q", retryAfter)
+	}
+
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", adminKey, other), http.StatusOK)
+
+	// лимит пользователя общий для всех клиентов
+	w = do(t, router, http.MethodPost, "/balance/credit", readerKey, user)
+	requireStatus(t, w, http.StatusTooManyRequests)
+	requireStatus(t, do(t, router, http.MethodPost, "/balance/credit", readerKey, other), http.StatusTooManyRequests)
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(NewRateLimitMiddleware(config.RateLimitConfig{Enabled: false, Routes: map[string]config.RouteRateLimitConfig{
+		"default": {ClientRate: 0.001, ClientBurst: 1},
+	}}))
+	r.HandleFunc("/balance/get", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		requireStatus(t, do(t, r, http.MethodGet, "/balance/get", "", nil), http.StatusOK)
+	}
+}
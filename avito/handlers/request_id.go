@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"avito/logger"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// максимальная длина id запроса, принимаемого от клиента
+const maxRequestIDLength = 128
+
+// NewRequestIDMiddleware берет id запроса из заголовка X-Request-ID или генерирует новый,
+// кладет его в контекст для логов и возвращает в заголовке ответа. Должен подключаться первым
+func NewRequestIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(requestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.New().String()
+			}
+
+			w.Header().Set(requestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// validRequestID допускает только печатные ASCII-символы без пробелов, чтобы id клиента
+// нельзя было использовать для подделки строк лога или заголовков
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
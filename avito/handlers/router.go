@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/metrics"
+	"avito/service"
+	"github.com/gorilla/mux"
+)
+
+// NewRouter собирает маршруты сервиса. Middleware подключаются ко всем маршрутам, включая метрики
+func NewRouter(serviceAPI service.ServiceAPI, conf *config.ApplicationConfig) *mux.Router {
+	a := NewHandlers(serviceAPI)
+
+	r := mux.NewRouter()
+	// id запроса для логов и ответов, подключается до остальных middleware
+	r.Use(NewRequestIDMiddleware())
+	// все запросы требуют API-ключ клиента
+	r.Use(NewAuthMiddleware(conf.Auth.Clients))
+	// ограничение частоты запросов для клиентов и пользователей
+	r.Use(NewRateLimitMiddleware(conf.RateLimit))
+	// зачисление денежных средств
+	r.HandleFunc("/balance/credit", a.Audit(RequireScope(auth.ScopeCredit, a.CreditFundsHandler)))
+	// списание денежных средств
+	r.HandleFunc("/balance/withdraw", a.Audit(RequireScope(auth.ScopeWithdraw, a.WithdrawFundsHandler)))
+	// перевод денежных средств другому пользователю
+	r.HandleFunc("/balance/transfer", a.Audit(RequireScope(auth.ScopeTransfer, a.TransferFundsHandler)))
+	// расчет комиссии перевода
+	r.HandleFunc("/balance/transfer/quote", RequireScope(auth.ScopeRead, a.QuoteTransferHandler))
+	// получение текущего баланса
+	r.HandleFunc("/balance/get", RequireScope(auth.ScopeRead, a.GetBalanceHandler))
+	// получение
+	r.HandleFunc("/balance/transactions", RequireScope(auth.ScopeRead, a.GetTransactionsHandler))
+	// регистрация вебхука
+	r.HandleFunc("/admin/webhooks/create", a.Audit(RequireScope(auth.ScopeAdmin, a.RegisterWebhookHandler)))
+	// список вебхуков
+	r.HandleFunc("/admin/webhooks/list", RequireScope(auth.ScopeAdmin, a.GetWebhooksHandler))
+	// удаление вебхука
+	r.HandleFunc("/admin/webhooks/delete", a.Audit(RequireScope(auth.ScopeAdmin, a.DeleteWebhookHandler)))
+	// журнал доставок (status=dead - dead-letter список)
+	r.HandleFunc("/admin/webhooks/deliveries", RequireScope(auth.ScopeAdmin, a.GetWebhookDeliveriesHandler))
+	// повторная отправка доставки
+	r.HandleFunc("/admin/webhooks/replay", a.Audit(RequireScope(auth.ScopeAdmin, a.ReplayWebhookDeliveryHandler)))
+	// лимиты пользователя
+	r.HandleFunc("/admin/limits/get", RequireScope(auth.ScopeAdmin, a.GetUserLimitsHandler))
+	// установка индивидуальных лимитов
+	r.HandleFunc("/admin/limits/set", a.Audit(RequireScope(auth.ScopeAdmin, a.SetUserLimitsHandler)))
+	// сброс индивидуальных лимитов к значениям по умолчанию
+	r.HandleFunc("/admin/limits/reset", a.Audit(RequireScope(auth.ScopeAdmin, a.ResetUserLimitsHandler)))
+	// статус счета и история его изменений
+	r.HandleFunc("/admin/accounts/status", RequireScope(auth.ScopeAdmin, a.GetAccountStatusHandler))
+	// заморозка, блокировка, закрытие и восстановление счета
+	r.HandleFunc("/admin/accounts/set-status", a.Audit(RequireScope(auth.ScopeAdmin, a.SetAccountStatusHandler)))
+	// установка кредитного лимита (овердрафта)
+	r.HandleFunc("/admin/accounts/set-credit-limit", a.Audit(RequireScope(auth.ScopeAdmin, a.SetCreditLimitHandler)))
+	// счета с отрицательным балансом
+	r.HandleFunc("/admin/accounts/overdraft", RequireScope(auth.ScopeAdmin, a.GetOverdraftAccountsHandler))
+	// балансы служебных счетов и сверка суммы по всем счетам
+	r.HandleFunc("/admin/system-accounts", RequireScope(auth.ScopeAdmin, a.GetSystemAccountsHandler))
+	// проводка со всеми движениями по счетам
+	r.HandleFunc("/admin/ledger/entry", RequireScope(auth.ScopeAdmin, a.GetJournalEntryHandler))
+	// сверка балансов с суммами движений и корректировка расхождений
+	r.HandleFunc("/admin/ledger/reconcile", a.Audit(RequireScope(auth.ScopeAdmin, a.ReconcileHandler)))
+	// проверка цепочек хешей движений по счетам
+	r.HandleFunc("/admin/ledger/verify", RequireScope(auth.ScopeAdmin, a.VerifyLedgerHandler))
+	// журнал аудита изменяющих запросов
+	r.HandleFunc("/admin/audit", RequireScope(auth.ScopeAdmin, a.GetAuditLogHandler))
+	// административное зачисление, списание и корректировка баланса с кодом причины
+	r.HandleFunc("/admin/balance/credit", a.Audit(RequireScope(auth.ScopeAdmin, a.AdminCreditHandler)))
+	r.HandleFunc("/admin/balance/withdraw", a.Audit(RequireScope(auth.ScopeAdmin, a.AdminWithdrawHandler)))
+	r.HandleFunc("/admin/balance/adjust", a.Audit(RequireScope(auth.ScopeAdmin, a.AdjustBalanceHandler)))
+	// создание запланированного перевода или списания (scope проверяется по типу операции)
+	r.HandleFunc("/scheduled/create", a.Audit(a.CreateScheduledOperationHandler))
+	// запланированная операция и история ее выполнений
+	r.HandleFunc("/scheduled/get", RequireScope(auth.ScopeRead, a.GetScheduledOperationHandler))
+	// запланированные операции пользователя
+	r.HandleFunc("/scheduled/list", RequireScope(auth.ScopeRead, a.GetScheduledOperationsHandler))
+	// отмена запланированной операции (scope проверяется по типу операции)
+	r.HandleFunc("/scheduled/cancel", a.Audit(a.CancelScheduledOperationHandler))
+	// резервирование оплаты по сделке на счете сделок
+	r.HandleFunc("/escrow/fund", a.Audit(RequireScope(auth.ScopeTransfer, a.FundEscrowHandler)))
+	// перевод средств сделки получателю
+	r.HandleFunc("/escrow/release", a.Audit(RequireScope(auth.ScopeTransfer, a.ReleaseEscrowHandler)))
+	// возврат средств сделки плательщику
+	r.HandleFunc("/escrow/cancel", a.Audit(RequireScope(auth.ScopeTransfer, a.CancelEscrowHandler)))
+	// сделка и история ее статусов
+	r.HandleFunc("/escrow/get", RequireScope(auth.ScopeRead, a.GetEscrowHandler))
+	// выставление счета на оплату
+	r.HandleFunc("/invoices/create", a.Audit(RequireScope(auth.ScopeWithdraw, a.CreateInvoiceHandler)))
+	// оплата счета с баланса плательщика
+	r.HandleFunc("/invoices/pay", a.Audit(RequireScope(auth.ScopeWithdraw, a.PayInvoiceHandler)))
+	// отмена неоплаченного счета
+	r.HandleFunc("/invoices/cancel", a.Audit(RequireScope(auth.ScopeWithdraw, a.CancelInvoiceHandler)))
+	// счет с позициями
+	r.HandleFunc("/invoices/get", RequireScope(auth.ScopeRead, a.GetInvoiceHandler))
+	// счета плательщика
+	r.HandleFunc("/invoices/list", RequireScope(auth.ScopeRead, a.GetInvoicesHandler))
+	// запрос денег у другого пользователя
+	r.HandleFunc("/money-requests/create", a.Audit(RequireScope(auth.ScopeTransfer, a.CreateMoneyRequestHandler)))
+	// принятие запроса, выполняет перевод
+	r.HandleFunc("/money-requests/accept", a.Audit(RequireScope(auth.ScopeTransfer, a.AcceptMoneyRequestHandler)))
+	// отклонение запроса
+	r.HandleFunc("/money-requests/decline", a.Audit(RequireScope(auth.ScopeTransfer, a.DeclineMoneyRequestHandler)))
+	// запрос денег
+	r.HandleFunc("/money-requests/get", RequireScope(auth.ScopeRead, a.GetMoneyRequestHandler))
+	// ожидающие ответа запросы пользователя (входящие и исходящие)
+	r.HandleFunc("/money-requests/pending", RequireScope(auth.ScopeRead, a.GetPendingMoneyRequestsHandler))
+	// метрики сервиса, как и остальные маршруты, требуют API-ключ клиента
+	r.HandleFunc("/metrics", RequireScope(auth.ScopeAdmin, metrics.Handler().ServeHTTP))
+
+	return r
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) CreateScheduledOperationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var scheduledOperationRequest dto.ScheduledOperationRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&scheduledOperationRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse scheduledOperationRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received scheduledOperationRequest: %v", scheduledOperationRequest)
+
+	operation, err, isInternal := h.service.GetScheduleService().CreateScheduledOperationRequest(r.Context(), scheduledOperationRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do scheduledOperationRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Scheduled operation %v has been successfully created", operation.Id)
+	sendResponse(http.StatusOK, operation, w)
+}
+
+func (h *handlers) GetScheduledOperationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	operation, err, isInternal := h.service.GetScheduleService().GetScheduledOperationRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getScheduledOperationRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, operation, w)
+}
+
+func (h *handlers) GetScheduledOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of user_id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of user_id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	operations, err, isInternal := h.service.GetScheduleService().GetScheduledOperationsRequest(r.Context(), userID, limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getScheduledOperationsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetScheduledOperationsResponse{Operations: operations}
+	sendResponse(http.StatusOK, response, w)
+}
+
+func (h *handlers) CancelScheduledOperationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	err, isInternal := h.service.GetScheduleService().CancelScheduledOperationRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do cancelScheduledOperationRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getServiceErrorStatus(err, isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Scheduled operation %v has been cancelled", id)
+	sendResponse(http.StatusOK, "OK", w)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func (h *handlers) GetSystemAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	report, err, isInternal := h.service.GetSystemAccountService().GetSystemAccountsRequest(r.Context())
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getSystemAccountsRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	sendResponse(http.StatusOK, report, w)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (h *handlers) RegisterWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var webhookRequest dto.WebhookRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&webhookRequest)
+
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse webhookRequest, reason: %v", err)
+		response := &dto.ErrorResponse{Error: "Cannot parse request body"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+	h.log.Debugf(r.Context(), "Received webhookRequest: %v", webhookRequest)
+
+	webhook, err, isInternal := h.service.GetWebhookService().RegisterWebhookRequest(r.Context(), webhookRequest)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do webhookRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Webhook %v has been successfully registered", webhook.Id)
+	sendResponse(http.StatusOK, webhook, w)
+}
+
+func (h *handlers) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhooks, err, isInternal := h.service.GetWebhookService().GetWebhooksRequest(r.Context())
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getWebhooksRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetWebhooksResponse{Webhooks: webhooks}
+	sendResponse(http.StatusOK, response, w)
+}
+
+func (h *handlers) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	err, isInternal := h.service.GetWebhookService().DeleteWebhookRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do deleteWebhookRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Webhook %v has been successfully deleted", id)
+	sendResponse(http.StatusOK, "OK", w)
+}
+
+func (h *handlers) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var webhookID *uuid.UUID
+	if wID := r.URL.Query().Get("webhook_id"); wID != "" {
+		id, err := uuid.Parse(wID)
+		if err != nil {
+			h.log.Warnf(r.Context(), "Error while parse value of webhook_id")
+			response := &dto.ErrorResponse{Error: "Incorrect value of webhook_id"}
+			sendResponse(http.StatusBadRequest, response, w)
+			return
+		}
+		webhookID = &id
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse pagination, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	deliveries, err, isInternal := h.service.GetWebhookService().GetDeliveriesRequest(r.Context(), webhookID, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do getDeliveriesRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	response := &dto.GetWebhookDeliveriesResponse{Deliveries: deliveries}
+	sendResponse(http.StatusOK, response, w)
+}
+
+func (h *handlers) ReplayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while parse value of id")
+		response := &dto.ErrorResponse{Error: "Incorrect value of id"}
+		sendResponse(http.StatusBadRequest, response, w)
+		return
+	}
+
+	err, isInternal := h.service.GetWebhookService().ReplayDeliveryRequest(r.Context(), id)
+	if err != nil {
+		h.log.Warnf(r.Context(), "Error while do replayDeliveryRequest, reason: %v", err)
+		response := newErrorResponse(err)
+		sendResponse(getErrorStatus(isInternal), response, w)
+		return
+	}
+
+	h.log.Infof(r.Context(), "Delivery %v has been queued for replay", id)
+	sendResponse(http.StatusOK, "OK", w)
+}
@@ -0,0 +1,329 @@
+// loadgen - генератор нагрузки на запущенный сервис: выполняет смесь зачислений, списаний,
+// переводов и запросов баланса, печатает задержки и ошибки и проверяет инварианты балансов
+package main
+
+import (
+	"avito/client"
+	"avito/dto"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	opCredit   = "credit"
+	opWithdraw = "withdraw"
+	opTransfer = "transfer"
+	opGet      = "get"
+)
+
+var operations = []string{opCredit, opWithdraw, opTransfer, opGet}
+
+type options struct {
+	duration    time.Duration
+	concurrency int
+	rate        float64
+	users       int
+	initial     int64
+	amount      int64
+	mix         map[string]int
+	seed        int64
+	output      string
+}
+
+func main() {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	addr := flags.String("addr", envOrDefault("BALANCE_API_URL", "http://localhost:9000"), "service address, env BALANCE_API_URL")
+	key := flags.String("key", os.Getenv("BALANCE_API_KEY"), "API key with credit, withdraw, transfer and read scopes, env BALANCE_API_KEY; admin scope enables ledger checks")
+	duration := flags.Duration("duration", 30*time.Second, "duration of the run")
+	concurrency := flags.Int("concurrency", 16, "number of concurrent workers")
+	rate := flags.Float64("rate", 0, "total requests per second, 0 - as fast as possible")
+	users := flags.Int("users", 100, "number of accounts the load is spread over")
+	initial := flags.String("initial", "1000", "initial balance credited to every account before the run")
+	amount := flags.String("amount", "1", "sum of every credit, withdraw and transfer")
+	mix := flags.String("mix", "credit=2,withdraw=2,transfer=4,get=2", "relative weights of operations")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of a single request")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed of the random operation sequence")
+	output := flags.String("output", "table", "report format: table or json")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	opts := options{duration: *duration, concurrency: *concurrency, rate: *rate, users: *users, seed: *seed, output: *output}
+	var err error
+	if opts.initial, err = parseMoney(*initial); err != nil {
+		fmt.Fprintf(os.Stderr, "Incorrect value of initial: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.amount, err = parseMoney(*amount); err != nil || opts.amount == 0 {
+		fmt.Fprintf(os.Stderr, "Incorrect value of amount: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.mix, err = parseMix(*mix); err != nil {
+		fmt.Fprintf(os.Stderr, "Incorrect value of mix: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.concurrency < 1 || opts.users < 2 || (opts.output != "table" && opts.output != "json") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// повтор скрыл бы ошибки и исказил задержки, поэтому каждый запрос выполняется один раз
+	c := client.NewClient(client.Config{BaseURL: *addr, APIKey: *key, MaxAttempts: 1, Timeout: *timeout})
+	// проверкам после прогона повторы нужны, чтобы дождаться восстановления ограничения частоты
+	checker := client.NewClient(client.Config{BaseURL: *addr, APIKey: *key, MaxAttempts: 10, MaxBackoff: 5 * time.Second, Timeout: *timeout})
+
+	report, err := run(context.Background(), c, checker, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load test failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.output == "json" {
+		printJSON(report)
+	} else {
+		printTable(report)
+	}
+
+	if !report.Invariants.OK {
+		os.Exit(1)
+	}
+}
+
+// runner выполняет операции и ведет ожидаемые балансы счетов
+type runner struct {
+	client  *client.Client
+	checker *client.Client
+	opts    options
+	users   []uuid.UUID
+	// сумма списания с отправителя перевода с учетом комиссии
+	transferTotal int64
+
+	mu       sync.Mutex
+	expected map[uuid.UUID]int64
+	// счета, результат операции с которыми неизвестен: ошибка сети или 5xx
+	uncertain map[uuid.UUID]bool
+}
+
+func run(ctx context.Context, c *client.Client, checker *client.Client, opts options) (*report, error) {
+	r := &runner{client: c, checker: checker, opts: opts, expected: make(map[uuid.UUID]int64), uncertain: make(map[uuid.UUID]bool)}
+
+	for i := 0; i < opts.users; i++ {
+		userID := uuid.New()
+		if opts.initial > 0 {
+			if err := checker.Credit(ctx, dto.OperationRequest{UserId: userID, Sum: toMoney(opts.initial)}); err != nil {
+				return nil, xerrors.Errorf("Cannot credit initial balance: %w", err)
+			}
+		}
+		r.users = append(r.users, userID)
+		r.expected[userID] = opts.initial
+	}
+
+	quote, err := checker.QuoteTransfer(ctx, dto.TransferFundsRequest{IdSender: r.users[0], IdReceiver: r.users[1], Sum: toMoney(opts.amount)})
+	if err != nil {
+		return nil, xerrors.Errorf("Cannot quote transfer: %w", err)
+	}
+	r.transferTotal = toKopecks(quote.Total)
+
+	stats := newStats()
+	var tokens <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	started := time.Now()
+	deadline := started.Add(opts.duration)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func(rnd *rand.Rand) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-time.After(time.Until(deadline)):
+						return
+					}
+				}
+
+				op := r.pick(rnd)
+				begin := time.Now()
+				err := r.do(ctx, rnd, op)
+				stats.add(op, time.Since(begin), err)
+			}
+		}(rand.New(rand.NewSource(opts.seed + int64(i))))
+	}
+	wg.Wait()
+
+	result := stats.report(time.Since(started))
+	result.Invariants = r.checkInvariants(ctx)
+
+	return result, nil
+}
+
+func (r *runner) pick(rnd *rand.Rand) string {
+	total := 0
+	for _, weight := range r.opts.mix {
+		total += weight
+	}
+
+	n := rnd.Intn(total)
+	for _, op := range operations {
+		if n < r.opts.mix[op] {
+			return op
+		}
+		n -= r.opts.mix[op]
+	}
+
+	return opGet
+}
+
+func (r *runner) do(ctx context.Context, rnd *rand.Rand, op string) error {
+	userID := r.users[rnd.Intn(len(r.users))]
+	sum := toMoney(r.opts.amount)
+
+	var err error
+	switch op {
+	case opCredit:
+		err = r.client.Credit(ctx, dto.OperationRequest{UserId: userID, Sum: sum})
+		r.apply(err, map[uuid.UUID]int64{userID: r.opts.amount})
+	case opWithdraw:
+		err = r.client.Withdraw(ctx, dto.OperationRequest{UserId: userID, Sum: sum})
+		r.apply(err, map[uuid.UUID]int64{userID: -r.opts.amount})
+	case opTransfer:
+		receiverID := r.users[rnd.Intn(len(r.users))]
+		for receiverID == userID {
+			receiverID = r.users[rnd.Intn(len(r.users))]
+		}
+		err = r.client.Transfer(ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: receiverID, Sum: sum})
+		r.apply(err, map[uuid.UUID]int64{userID: -r.transferTotal, receiverID: r.opts.amount})
+	case opGet:
+		_, err = r.client.GetBalance(ctx, userID, "")
+	}
+
+	return err
+}
+
+// apply учитывает изменения балансов выполненной операции. Отказ с кодом 4xx баланс не меняет,
+// после ошибки сети или 5xx исход операции неизвестен
+func (r *runner) apply(err error, changes map[uuid.UUID]int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var apiError *client.Error
+	for userID, change := range changes {
+		switch {
+		case err == nil:
+			r.expected[userID] += change
+		case xerrors.As(err, &apiError) && apiError.StatusCode < 500:
+		default:
+			r.uncertain[userID] = true
+		}
+	}
+}
+
+// parseMix разбирает веса операций вида credit=2,withdraw=2,transfer=4,get=2
+func parseMix(value string) (map[string]int, error) {
+	result := make(map[string]int)
+	total := 0
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, xerrors.Errorf("expected operation=weight, got %q", part)
+		}
+
+		weight, err := strconv.Atoi(kv[1])
+		if err != nil || weight < 0 {
+			return nil, xerrors.Errorf("incorrect weight %q", part)
+		}
+
+		known := false
+		for _, op := range operations {
+			known = known || op == kv[0]
+		}
+		if !known {
+			return nil, xerrors.Errorf("unknown operation %q, expected one of %v", kv[0], operations)
+		}
+
+		result[kv[0]] = weight
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, xerrors.Errorf("at least one operation must have positive weight")
+	}
+
+	return result, nil
+}
+
+// parseMoney разбирает неотрицательную сумму в рублях с копейками и возвращает ее в копейках
+func parseMoney(value string) (int64, error) {
+	parts := strings.SplitN(value, ".", 2)
+	intPart, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || intPart < 0 {
+		return 0, xerrors.Errorf("incorrect amount %q", value)
+	}
+
+	var fracPart int64
+	if len(parts) == 2 {
+		frac := parts[1]
+		if len(frac) == 0 || len(frac) > 2 {
+			return 0, xerrors.Errorf("incorrect amount %q", value)
+		}
+		if len(frac) == 1 {
+			frac += "0"
+		}
+		if fracPart, err = strconv.ParseInt(frac, 10, 64); err != nil || fracPart < 0 {
+			return 0, xerrors.Errorf("incorrect amount %q", value)
+		}
+	}
+
+	return intPart*100 + fracPart, nil
+}
+
+func toMoney(sum int64) *dto.Money {
+	return &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+}
+
+func toKopecks(money *dto.Money) int64 {
+	return money.IntPart*100 + money.FracPart
+}
+
+func formatKopecks(sum int64) string {
+	sign := ""
+	if sum < 0 {
+		sign, sum = "-", -sum
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, sum/100, sum%100)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func envOrDefault(name string, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return value
+}
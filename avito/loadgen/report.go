@@ -0,0 +1,228 @@
+package main
+
+import (
+	"avito/client"
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/xerrors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type report struct {
+	Duration   time.Duration     `json:"duration"`
+	Requests   int               `json:"requests"`
+	RPS        float64           `json:"rps"`
+	Operations []operationReport `json:"operations"`
+	Invariants invariantReport   `json:"invariants"`
+}
+
+// задержки в миллисекундах
+type operationReport struct {
+	Operation string         `json:"operation"`
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	RPS       float64        `json:"rps"`
+	P50       float64        `json:"p50_ms"`
+	P90       float64        `json:"p90_ms"`
+	P95       float64        `json:"p95_ms"`
+	P99       float64        `json:"p99_ms"`
+	Max       float64        `json:"max_ms"`
+	ErrorKind map[string]int `json:"error_kinds,omitempty"`
+}
+
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]map[string]int
+}
+
+func newStats() *stats {
+	return &stats{latencies: make(map[string][]time.Duration), errors: make(map[string]map[string]int)}
+}
+
+func (s *stats) add(op string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latencies[op] = append(s.latencies[op], latency)
+	if err == nil {
+		return
+	}
+
+	if s.errors[op] == nil {
+		s.errors[op] = make(map[string]int)
+	}
+	s.errors[op][errorKind(err)]++
+}
+
+// errorKind - код ошибки сервиса, код статуса ответа без кода ошибки или network
+func errorKind(err error) string {
+	var apiError *client.Error
+	if !xerrors.As(err, &apiError) {
+		return "network"
+	}
+	if apiError.Code != "" {
+		return apiError.Code
+	}
+
+	return strconv.Itoa(apiError.StatusCode)
+}
+
+func (s *stats) report(elapsed time.Duration) *report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &report{Duration: elapsed, Operations: make([]operationReport, 0)}
+	for _, op := range operations {
+		latencies := s.latencies[op]
+		if len(latencies) == 0 {
+			continue
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		errors := 0
+		for _, count := range s.errors[op] {
+			errors += count
+		}
+
+		result.Operations = append(result.Operations, operationReport{
+			Operation: op,
+			Requests:  len(latencies),
+			Errors:    errors,
+			RPS:       float64(len(latencies)) / elapsed.Seconds(),
+			P50:       percentile(latencies, 0.50),
+			P90:       percentile(latencies, 0.90),
+			P95:       percentile(latencies, 0.95),
+			P99:       percentile(latencies, 0.99),
+			Max:       milliseconds(latencies[len(latencies)-1]),
+			ErrorKind: s.errors[op],
+		})
+		result.Requests += len(latencies)
+	}
+	result.RPS = float64(result.Requests) / elapsed.Seconds()
+
+	return result
+}
+
+// percentile возвращает значение, не меньше которого доля p отсортированных задержек
+func percentile(sorted []time.Duration, p float64) float64 {
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	return milliseconds(sorted[index])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type balanceMismatch struct {
+	UserId   string `json:"user_id"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+type invariantReport struct {
+	OK bool `json:"ok"`
+	// счета, баланс которых сравнивался с ожидаемым
+	CheckedAccounts int `json:"checked_accounts"`
+	// счета, исход операции с которыми неизвестен: их баланс не сравнивается
+	UncertainAccounts int               `json:"uncertain_accounts"`
+	Mismatches        []balanceMismatch `json:"mismatches"`
+	Violations        []string          `json:"violations"`
+	// проверки, которые не удалось выполнить, например без scope admin
+	Skipped []string `json:"skipped"`
+}
+
+// checkInvariants сверяет балансы счетов с ожидаемыми по результатам операций и,
+// при наличии scope admin, проверяет сбалансированность учета и совпадение балансов с движениями
+func (r *runner) checkInvariants(ctx context.Context) invariantReport {
+	result := invariantReport{Mismatches: make([]balanceMismatch, 0), Violations: make([]string, 0), Skipped: make([]string, 0)}
+	// балансы с реплики или из кэша могут не содержать последних операций
+	ctx = client.WithStrongConsistency(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, userID := range r.users {
+		balance, err := r.checker.GetBalance(ctx, userID, "")
+		if err != nil {
+			result.Violations = append(result.Violations, fmt.Sprintf("cannot get balance of %v: %v", userID, err))
+			continue
+		}
+
+		actual := toKopecks(balance)
+		if actual < 0 {
+			result.Violations = append(result.Violations, fmt.Sprintf("negative balance %s of %v", formatKopecks(actual), userID))
+		}
+
+		if r.uncertain[userID] {
+			result.UncertainAccounts++
+			continue
+		}
+		result.CheckedAccounts++
+		if expected := r.expected[userID]; actual != expected {
+			result.Mismatches = append(result.Mismatches, balanceMismatch{UserId: userID.String(), Expected: formatKopecks(expected), Actual: formatKopecks(actual)})
+		}
+	}
+
+	if system, err := r.checker.GetSystemAccounts(ctx); err != nil {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("system accounts: %v", err))
+	} else if !system.Balanced {
+		result.Violations = append(result.Violations, fmt.Sprintf("ledger is not balanced, total %v", system.Total))
+	}
+
+	if reconcile, err := r.checker.Reconcile(ctx, false, ""); err != nil {
+		result.Skipped = append(result.Skipped, fmt.Sprintf("reconciliation: %v", err))
+	} else if len(reconcile.Mismatches) != 0 {
+		result.Violations = append(result.Violations, fmt.Sprintf("%d balances do not match sums of their postings", len(reconcile.Mismatches)))
+	}
+
+	result.OK = len(result.Mismatches) == 0 && len(result.Violations) == 0
+	return result
+}
+
+func printTable(result *report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Duration %v, %d requests, %.1f rps\n\n", result.Duration.Round(time.Millisecond), result.Requests, result.RPS)
+
+	fmt.Fprintln(w, "OPERATION\tREQUESTS\tERRORS\tRPS\tP50 MS\tP90 MS\tP95 MS\tP99 MS\tMAX MS")
+	for _, op := range result.Operations {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\n", op.Operation, op.Requests, op.Errors, op.RPS, op.P50, op.P90, op.P95, op.P99, op.Max)
+	}
+
+	fmt.Fprintln(w, "\nOPERATION\tERROR\tCOUNT")
+	for _, op := range result.Operations {
+		for _, kind := range sortedKeys(op.ErrorKind) {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", op.Operation, kind, op.ErrorKind[kind])
+		}
+	}
+	w.Flush()
+
+	invariants := result.Invariants
+	fmt.Printf("\nInvariants: ok=%v, checked %d accounts, %d with unknown outcome\n", invariants.OK, invariants.CheckedAccounts, invariants.UncertainAccounts)
+	for _, mismatch := range invariants.Mismatches {
+		fmt.Printf("  balance of %s is %s, expected %s\n", mismatch.UserId, mismatch.Actual, mismatch.Expected)
+	}
+	for _, violation := range invariants.Violations {
+		fmt.Printf("  %s\n", violation)
+	}
+	for _, skipped := range invariants.Skipped {
+		fmt.Printf("  skipped %s\n", skipped)
+	}
+}
+
+func printJSON(value interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(value)
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"avito/auth"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// ParseLevel разбирает уровень из конфига, пустая строка - info
+func ParseLevel(value string) (Level, error) {
+	if value == "" {
+		return LevelInfo, nil
+	}
+
+	for level, name := range levelNames {
+		if strings.EqualFold(value, name) {
+			return level, nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", value)
+}
+
+// общий вывод и минимальный уровень для логгеров всех компонентов
+var (
+	mu       sync.Mutex
+	out      io.Writer = os.Stdout
+	minLevel           = LevelInfo
+)
+
+func SetLevel(level Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel = level
+}
+
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID возвращает id запроса или пустую строку для фоновых операций
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Logger пишет записи в JSON по одной в строке. Id запроса и клиент берутся из контекста
+type Logger struct {
+	component string
+}
+
+func New(component string) *Logger {
+	return &Logger{component: component}
+}
+
+type record struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component"`
+	RequestID string `json:"request_id,omitempty"`
+	Client    string `json:"client,omitempty"`
+	Message   string `json:"msg"`
+}
+
+func (l *Logger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	l.write(ctx, LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(ctx context.Context, format string, args ...interface{}) {
+	l.write(ctx, LevelInfo, format, args...)
+}
+
+func (l *Logger) Warnf(ctx context.Context, format string, args ...interface{}) {
+	l.write(ctx, LevelWarn, format, args...)
+}
+
+func (l *Logger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.write(ctx, LevelError, format, args...)
+}
+
+// Fatalf пишет запись с уровнем error и завершает процесс
+func (l *Logger) Fatalf(ctx context.Context, format string, args ...interface{}) {
+	l.write(ctx, LevelError, format, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) write(ctx context.Context, level Level, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if level < minLevel {
+		return
+	}
+
+	line, err := json.Marshal(record{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     levelNames[level],
+		Component: l.component,
+		RequestID: RequestID(ctx),
+		Client:    auth.ClientName(ctx),
+		Message:   fmt.Sprintf(format, args...),
+	})
+	if err != nil {
+		return
+	}
+
+	out.Write(append(line, '\n'))
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestLimiter возвращает ограничитель с управляемым временем
+func newTestLimiter(rate float64, burst int) (*Limiter, *time.Time) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(rate, burst)
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestAllowExhaustsBurst(t *testing.T) {
+	l, _ := newTestLimiter(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, wait := l.Allow("client"); !ok || wait != 0 {
+			t.Fatalf("request %d: expected to be allowed, got %v, %v", i, ok, wait)
+		}
+	}
+
+	ok, wait := l.Allow("client")
+	if ok || wait != time.Second {
+		t.Fatalf("expected rejection with 1s wait, got %v, %v", ok, wait)
+	}
+
+	// корзины разных ключей независимы
+	if ok, _ := l.Allow("other"); !ok {
+		t.Fatalf("expected other key to be allowed")
+	}
+}
+
+func TestAllowRefills(t *testing.T) {
+	l, now := newTestLimiter(2, 2)
+
+	l.Allow("client")
+	l.Allow("client")
+	if ok, wait := l.Allow("client"); ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected rejection with 500ms wait, got %v, %v", ok, wait)
+	}
+
+	// за 250ms набирается половина токена, до следующего - еще 250ms
+	*now = now.Add(250 * time.Millisecond)
+	if ok, wait := l.Allow("client"); ok || wait != 250*time.Millisecond {
+		t.Fatalf("expected rejection with 250ms wait, got %v, %v", ok, wait)
+	}
+
+	*now = now.Add(250 * time.Millisecond)
+	if ok, _ := l.Allow("client"); !ok {
+		t.Fatalf("expected request to be allowed after refill")
+	}
+
+	// корзина не наполняется больше burst
+	*now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("client"); !ok {
+			t.Fatalf("request %d: expected to be allowed", i)
+		}
+	}
+	if ok, _ := l.Allow("client"); ok {
+		t.Fatalf("expected burst to be limited to 2")
+	}
+}
+
+func TestSweepRemovesFullBuckets(t *testing.T) {
+	l, now := newTestLimiter(1, 1)
+
+	l.Allow("idle")
+	*now = now.Add(sweepInterval)
+	l.Allow("active")
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Fatalf("expected refilled bucket to be removed")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Fatalf("expected active bucket to stay")
+	}
+}
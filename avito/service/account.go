@@ -0,0 +1,204 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type AccountServiceAPI interface {
+	GetAccountStatusRequest(ctx context.Context, userID uuid.UUID) (*dto.GetAccountStatusResponse, error, bool)
+	SetAccountStatusRequest(ctx context.Context, setAccountStatusRequest dto.SetAccountStatusRequest) (error, bool)
+	SetCreditLimitRequest(ctx context.Context, setCreditLimitRequest dto.SetCreditLimitRequest) (error, bool)
+	GetOverdraftAccountsRequest(ctx context.Context, limit int, offset int) ([]dto.OverdraftAccount, error, bool)
+}
+
+type accountService struct {
+	storage storage.StorageAPI
+	log     *logger.Logger
+}
+
+func NewAccountServiceAPI(api storage.StorageAPI) AccountServiceAPI {
+	return &accountService{
+		storage: api,
+		log:     logger.New("account-service"),
+	}
+}
+
+func (a *accountService) GetAccountStatusRequest(ctx context.Context, userID uuid.UUID) (*dto.GetAccountStatusResponse, error, bool) {
+	account, err := a.storage.GetAccountStorage().GetAccount(userID)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("User does not exist"), false
+	}
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get account from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return account, nil, false
+}
+
+func (a *accountService) SetAccountStatusRequest(ctx context.Context, setAccountStatusRequest dto.SetAccountStatusRequest) (error, bool) {
+	a.log.Infof(ctx, "Trying to change account status %v", setAccountStatusRequest)
+
+	switch setAccountStatusRequest.Status {
+	case dto.AccountActive, dto.AccountFrozenDebits, dto.AccountBlocked, dto.AccountClosed:
+	default:
+		return xerrors.Errorf("Unknown account status %q", setAccountStatusRequest.Status), false
+	}
+
+	if setAccountStatusRequest.Reason == "" {
+		return xerrors.Errorf("reason cannot be empty"), false
+	}
+
+	tx, err := a.storage.GetTransaction(ctx)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	balance, err := a.storage.GetBalanceStorage().LockBalance(tx, setAccountStatusRequest.UserId)
+	if err == storage.ErrNoRows {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("User does not exist"), false
+	}
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	status, err := a.storage.GetAccountStorage().GetAccountStatus(tx, setAccountStatusRequest.UserId)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get account status from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if status == dto.AccountClosed {
+		tx.Rollback(ctx)
+		return newServiceError(dto.ErrCodeAccountClosed, "Account is closed"), false
+	}
+
+	if status == setAccountStatusRequest.Status {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("Account already has status %s", status), false
+	}
+
+	if setAccountStatusRequest.Status == dto.AccountClosed && balance != 0 {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("Account with non-zero balance cannot be closed"), false
+	}
+
+	err = a.storage.GetAccountStorage().SetAccountStatus(tx, setAccountStatusRequest.UserId, setAccountStatusRequest.Status, setAccountStatusRequest.Reason)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while set account status in DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = a.storage.GetAccountStorage().WriteStatusChange(tx, setAccountStatusRequest.UserId, status, setAccountStatusRequest.Status, setAccountStatusRequest.Reason, auth.ClientName(ctx))
+	if err != nil {
+		a.log.Errorf(ctx, "Error while write status change in DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return nil, false
+}
+
+func (a *accountService) SetCreditLimitRequest(ctx context.Context, setCreditLimitRequest dto.SetCreditLimitRequest) (error, bool) {
+	a.log.Infof(ctx, "Trying to set credit limit %v", setCreditLimitRequest)
+
+	if setCreditLimitRequest.CreditLimit == nil {
+		return xerrors.Errorf("credit_limit cannot be empty"), false
+	}
+
+	if setCreditLimitRequest.CreditLimit.FracPart < 0 || setCreditLimitRequest.CreditLimit.FracPart > 99 {
+		return xerrors.Errorf("frac_part must be between 0 and 99"), false
+	}
+
+	creditLimit := setCreditLimitRequest.CreditLimit.IntPart*100 + setCreditLimitRequest.CreditLimit.FracPart
+	if creditLimit < 0 {
+		return xerrors.Errorf("Credit limit cannot be negative"), false
+	}
+
+	tx, err := a.storage.GetTransaction(ctx)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	balance, err := a.storage.GetBalanceStorage().LockBalance(tx, setCreditLimitRequest.UserId)
+	if err == storage.ErrNoRows {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("User does not exist"), false
+	}
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if balance < -creditLimit {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("Credit limit cannot be less than current overdraft"), false
+	}
+
+	err = a.storage.GetBalanceStorage().SetCreditLimit(tx, setCreditLimitRequest.UserId, creditLimit)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while set credit limit in DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return nil, false
+}
+
+func (a *accountService) GetOverdraftAccountsRequest(ctx context.Context, limit int, offset int) ([]dto.OverdraftAccount, error, bool) {
+	accounts, err := a.storage.GetBalanceStorage().GetOverdraftAccounts(limit, offset)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get overdraft accounts from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return accounts, nil, false
+}
+
+// checkCanCredit разрешает зачисление на активный счет и на счет с замороженными списаниями
+func checkCanCredit(status string) error {
+	switch status {
+	case dto.AccountBlocked:
+		return newServiceError(dto.ErrCodeAccountBlocked, "Account is blocked")
+	case dto.AccountClosed:
+		return newServiceError(dto.ErrCodeAccountClosed, "Account is closed")
+	}
+
+	return nil
+}
+
+// checkCanDebit разрешает списание только с активного счета
+func checkCanDebit(status string) error {
+	if status == dto.AccountFrozenDebits {
+		return newServiceError(dto.ErrCodeAccountFrozen, "Debits from account are frozen")
+	}
+
+	return checkCanCredit(status)
+}
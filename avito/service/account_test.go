@@ -0,0 +1,154 @@
+package service
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func (e *testEnv) setStatus(userID uuid.UUID, status string) {
+	e.t.Helper()
+	err, isInternal := e.service.GetAccountService().SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: status, Reason: "test"})
+	requireNoError(e.t, err, isInternal)
+}
+
+func TestFrozenAccount(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	otherID := e.newUser(0)
+	e.setStatus(userID, dto.AccountFrozenDebits)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountFrozen)
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountFrozen)
+
+	// зачисления на замороженный счет разрешены
+	e.credit(userID, 100)
+	e.requireBalance(userID, 1100)
+	e.requireBalance(otherID, 0)
+}
+
+// отрицательные суммы не обходят заморозку: ни зачисление, ни перевод к замороженному счету
+// не могут списать с него средства
+func TestFrozenAccountNegativeSums(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	otherID := e.newUser(1000)
+	e.setStatus(userID, dto.AccountFrozenDebits)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(-6000)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: otherID, IdReceiver: userID, Sum: money(-3000)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	e.requireBalance(userID, 1000)
+	e.requireBalance(otherID, 1000)
+	e.requireBalanced()
+}
+
+func TestBlockedAccount(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	senderID := e.newUser(1000)
+	e.setStatus(userID, dto.AccountBlocked)
+
+	err, isInternal := e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountBlocked)
+
+	err, isInternal = e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: userID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountBlocked)
+
+	e.requireBalance(userID, 1000)
+	e.requireBalance(senderID, 1000)
+
+	e.setStatus(userID, dto.AccountActive)
+	e.credit(userID, 100)
+	e.requireBalance(userID, 1100)
+}
+
+func TestCloseAccount(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+	accountService := e.service.GetAccountService()
+
+	err, isInternal := accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountClosed, Reason: "test"})
+	requireUserError(t, err, isInternal, "Account with non-zero balance cannot be closed")
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+	e.setStatus(userID, dto.AccountClosed)
+
+	err, isInternal = e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountClosed)
+
+	err, isInternal = accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountActive, Reason: "test"})
+	requireCode(t, err, isInternal, dto.ErrCodeAccountClosed)
+}
+
+func TestSetAccountStatusValidation(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+	accountService := e.service.GetAccountService()
+
+	err, isInternal := accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: "deleted", Reason: "test"})
+	requireUserError(t, err, isInternal, `Unknown account status "deleted"`)
+
+	err, isInternal = accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountBlocked})
+	requireUserError(t, err, isInternal, "reason cannot be empty")
+
+	err, isInternal = accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: userID, Status: dto.AccountActive, Reason: "test"})
+	requireUserError(t, err, isInternal, "Account already has status active")
+
+	err, isInternal = accountService.SetAccountStatusRequest(e.ctx, dto.SetAccountStatusRequest{UserId: uuid.New(), Status: dto.AccountBlocked, Reason: "test"})
+	requireUserError(t, err, isInternal, "User does not exist")
+}
+
+func TestAccountStatusHistory(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+	e.setStatus(userID, dto.AccountFrozenDebits)
+	e.setStatus(userID, dto.AccountActive)
+
+	account, err, isInternal := e.service.GetAccountService().GetAccountStatusRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+	if account.Status != dto.AccountActive || len(account.History) != 2 {
+		t.Fatalf("unexpected account %+v", account)
+	}
+
+	transitions := map[string]string{}
+	for _, change := range account.History {
+		transitions[change.OldStatus] = change.NewStatus
+	}
+	if transitions[dto.AccountActive] != dto.AccountFrozenDebits || transitions[dto.AccountFrozenDebits] != dto.AccountActive {
+		t.Fatalf("unexpected history %+v", account.History)
+	}
+
+	_, err, isInternal = e.service.GetAccountService().GetAccountStatusRequest(e.ctx, uuid.New())
+	requireUserError(t, err, isInternal, "User does not exist")
+}
+
+func TestSetCreditLimit(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+	accountService := e.service.GetAccountService()
+
+	err, isInternal := accountService.SetCreditLimitRequest(e.ctx, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: money(-100)})
+	requireUserError(t, err, isInternal, "Credit limit cannot be negative")
+
+	err, isInternal = accountService.SetCreditLimitRequest(e.ctx, dto.SetCreditLimitRequest{UserId: uuid.New(), CreditLimit: money(100)})
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	err, isInternal = accountService.SetCreditLimitRequest(e.ctx, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: money(300)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(300)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = accountService.SetCreditLimitRequest(e.ctx, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: money(100)})
+	requireUserError(t, err, isInternal, "Credit limit cannot be less than current overdraft")
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"golang.org/x/xerrors"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type AdminOperationServiceAPI interface {
+	// CreditRequest зачисляет средства пользователю с указанием причины
+	CreditRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool)
+	// WithdrawRequest списывает средства пользователя с указанием причины, без учета его лимитов
+	WithdrawRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool)
+	// AdjustRequest устанавливает баланс пользователя равным amount одной проводкой
+	// на разницу со счетом clearing
+	AdjustRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool)
+}
+
+type adminOperationKey struct{}
+
+// withAdminOperation отмечает операцию, выполняемую администратором: к ней не применяются лимиты пользователя
+func withAdminOperation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminOperationKey{}, true)
+}
+
+func isAdminOperation(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminOperationKey{}).(bool)
+	return admin
+}
+
+type adminOperationService struct {
+	storage  storage.StorageAPI
+	balance  BalanceServiceAPI
+	webhooks WebhookServiceAPI
+	system   SystemAccounts
+	cache    BalanceCache
+	log      *logger.Logger
+}
+
+func NewAdminOperationServiceAPI(api storage.StorageAPI, balance BalanceServiceAPI, webhooks WebhookServiceAPI, system SystemAccounts, cache BalanceCache) AdminOperationServiceAPI {
+	return &adminOperationService{
+		storage:  api,
+		balance:  balance,
+		webhooks: webhooks,
+		system:   system,
+		cache:    cache,
+		log:      logger.New("admin-operation-service"),
+	}
+}
+
+func (a *adminOperationService) CreditRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool) {
+	a.log.Infof(ctx, "Trying to credit user by admin %v", request)
+
+	if err := validateAdminOperation(request); err != nil {
+		return err, false
+	}
+
+	return a.balance.CreditFundsRequest(withAdminComment(ctx, request), dto.OperationRequest{UserId: request.UserId, Sum: request.Sum})
+}
+
+func (a *adminOperationService) WithdrawRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool) {
+	a.log.Infof(ctx, "Trying to withdraw from user by admin %v", request)
+
+	if err := validateAdminOperation(request); err != nil {
+		return err, false
+	}
+
+	return a.balance.WithdrawFundsRequest(withAdminComment(ctx, request), dto.OperationRequest{UserId: request.UserId, Sum: request.Sum})
+}
+
+func (a *adminOperationService) AdjustRequest(ctx context.Context, request dto.AdminOperationRequest) (error, bool) {
+	a.log.Infof(ctx, "Trying to adjust balance of user by admin %v", request)
+
+	if err := validateAdminOperation(request); err != nil {
+		return err, false
+	}
+
+	if request.Sum.IntPart < 0 {
+		return xerrors.Errorf("Target balance cannot be negative"), false
+	}
+
+	if a.system.Contains(request.UserId) {
+		return newServiceError(dto.ErrCodeForbidden, "Operation is not permitted for system account"), false
+	}
+
+	tx, err := a.storage.GetTransaction(ctx)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	balance, err := a.storage.GetBalanceStorage().LockBalance(tx, request.UserId)
+	if err == storage.ErrNoRows {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("User does not exist"), false
+	}
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	diff := request.Sum.IntPart*100 + request.Sum.FracPart - balance
+	if diff == 0 {
+		tx.Rollback(ctx)
+		return nil, false
+	}
+
+	// корректировка двигает средства между счетом пользователя и clearing, как внешнее зачисление или его возврат
+	operation, event := dto.OperationCredit, dto.EventCredit
+	if diff < 0 {
+		operation, event = dto.OperationWithdraw, dto.EventWithdraw
+	}
+	entry := storage.JournalEntry{
+		Operation: operation,
+		Client:    auth.ClientName(ctx),
+		Comment:   adminComment(request),
+		Postings: []storage.Posting{
+			{UserID: request.UserId, Sum: diff, Operation: operation},
+			{UserID: a.system.Clearing, Sum: -diff, Operation: operation},
+		},
+	}
+
+	if err := a.system.checkEntry(entry); err != nil {
+		a.log.Errorf(ctx, "Error while check journal entry, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	_, postingIDs, err := a.storage.GetLedgerStorage().PostEntry(tx, entry)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while post journal entry in DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = tx.Commit(ctx)
+	a.cache.Invalidate(postingUsers(entry)...)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+	recordPostings(ctx, entry, postingIDs)
+
+	a.log.Infof(ctx, "Balance of user %v has been adjusted by %d", request.UserId, diff)
+
+	abs := diff
+	if abs < 0 {
+		abs = -abs
+	}
+	go a.webhooks.Notify(ctx, dto.WebhookEvent{Event: event, UserID: request.UserId, Sum: &dto.Money{IntPart: abs / 100, FracPart: abs % 100}})
+
+	return nil, false
+}
+
+func validateAdminOperation(request dto.AdminOperationRequest) error {
+	if request.Sum == nil {
+		return xerrors.Errorf("amount cannot be empty")
+	}
+
+	if request.Sum.FracPart < 0 || request.Sum.FracPart > 99 {
+		return xerrors.Errorf("frac_part must be between 0 and 99")
+	}
+
+	for _, reason := range dto.AdminReasons {
+		if request.Reason == reason {
+			return nil
+		}
+	}
+
+	return xerrors.Errorf("reason must be one of %v", dto.AdminReasons)
+}
+
+// adminComment - комментарий к движениям административной операции: admin:<reason>[: <comment>]
+func adminComment(request dto.AdminOperationRequest) string {
+	if request.Comment == "" {
+		return "admin:" + request.Reason
+	}
+
+	return "admin:" + request.Reason + ": " + request.Comment
+}
+
+func withAdminComment(ctx context.Context, request dto.AdminOperationRequest) context.Context {
+	return withTransactionComment(withAdminOperation(ctx), adminComment(request))
+}
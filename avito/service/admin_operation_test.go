@@ -0,0 +1,70 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"testing"
+)
+
+func TestAdminOperations(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.SingleOperationMax = 500
+	})
+	userID := e.newUser(1000)
+	adminService := e.service.GetAdminOperationService()
+
+	err, isInternal := adminService.CreditRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(100)})
+	requireUserError(t, err, isInternal, "reason must be one of [correction compensation chargeback fraud migration]")
+
+	err, isInternal = adminService.CreditRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(250), Reason: dto.AdminReasonCompensation, Comment: "ticket 42"})
+	requireNoError(t, err, isInternal)
+	e.requireBalance(userID, 1250)
+
+	// лимиты пользователя не ограничивают административное списание
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(800)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+	err, isInternal = adminService.WithdrawRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(800), Reason: dto.AdminReasonChargeback})
+	requireNoError(t, err, isInternal)
+	e.requireBalance(userID, 450)
+
+	err, isInternal = adminService.WithdrawRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(451), Reason: dto.AdminReasonChargeback})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	comments := map[string]bool{}
+	for _, transaction := range transactions {
+		comments[transaction.Comment] = true
+	}
+	if !comments["admin:compensation: ticket 42"] || !comments["admin:chargeback"] {
+		t.Fatalf("unexpected comments %v", comments)
+	}
+	e.requireBalanced()
+}
+
+func TestAdjustBalance(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	adminService := e.service.GetAdminOperationService()
+
+	for _, target := range []int64{1550, 20, 20, 0} {
+		err, isInternal := adminService.AdjustRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(target), Reason: dto.AdminReasonCorrection})
+		requireNoError(t, err, isInternal)
+		e.requireBalance(userID, target)
+	}
+
+	// баланс, равный цели, не меняется и не создает движений
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(transactions) != 4 {
+		t.Fatalf("expected 4 transactions, got %d", len(transactions))
+	}
+
+	err, isInternal = adminService.AdjustRequest(e.ctx, dto.AdminOperationRequest{UserId: e.system.Clearing, Sum: money(0), Reason: dto.AdminReasonCorrection})
+	requireCode(t, err, isInternal, dto.ErrCodeForbidden)
+
+	err, isInternal = adminService.AdjustRequest(e.ctx, dto.AdminOperationRequest{UserId: e.newUser(0), Sum: money(100), Reason: dto.AdminReasonCorrection})
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	e.requireBalanced()
+}
@@ -1,23 +1,72 @@
 package service
 
 import (
+	"avito/config"
 	"avito/storage"
 )
 
 type ServiceAPI interface {
 	GetBalanceService() BalanceServiceAPI
 	GetTransactionService() TransactionServiceAPI
+	GetWebhookService() WebhookServiceAPI
+	GetLimitService() LimitServiceAPI
+	GetAccountService() AccountServiceAPI
+	GetScheduleService() ScheduleServiceAPI
+	GetEscrowService() EscrowServiceAPI
+	GetInvoiceService() InvoiceServiceAPI
+	GetMoneyRequestService() MoneyRequestServiceAPI
+	GetFeeService() FeeServiceAPI
+	GetSystemAccountService() SystemAccountServiceAPI
+	GetLedgerService() LedgerServiceAPI
+	GetAuditService() AuditServiceAPI
+	GetAdminOperationService() AdminOperationServiceAPI
+	GetArchiveService() ArchiveServiceAPI
 }
 
 type serviceAPI struct {
 	balanceServiceAPI BalanceServiceAPI
 	transactionServiceAPI TransactionServiceAPI
+	webhookServiceAPI WebhookServiceAPI
+	limitServiceAPI LimitServiceAPI
+	accountServiceAPI AccountServiceAPI
+	scheduleServiceAPI ScheduleServiceAPI
+	escrowServiceAPI EscrowServiceAPI
+	invoiceServiceAPI InvoiceServiceAPI
+	moneyRequestServiceAPI MoneyRequestServiceAPI
+	feeServiceAPI FeeServiceAPI
+	systemAccountServiceAPI SystemAccountServiceAPI
+	ledgerServiceAPI LedgerServiceAPI
+	auditServiceAPI AuditServiceAPI
+	adminOperationServiceAPI AdminOperationServiceAPI
+	archiveServiceAPI ArchiveServiceAPI
 }
 
-func NewServiceAPI(api storage.StorageAPI) ServiceAPI {
+func NewServiceAPI(api storage.StorageAPI, conf *config.ApplicationConfig) ServiceAPI {
+	webhookServiceAPI := NewWebhookServiceAPI(api, conf.Webhook)
+	// лимиты пользователей и комиссии к служебным счетам не применяются
+	systemAccounts := NewSystemAccounts(conf.SystemAccounts)
+	limitServiceAPI := NewLimitServiceAPI(api, conf.Limits, systemAccounts.IDs()...)
+	feeServiceAPI := NewFeeServiceAPI(conf.Fees, systemAccounts.Revenue, systemAccounts.IDs()...)
+	// кэш общий для всех сервисов, меняющих балансы: каждый сбрасывает его после commit
+	balanceCache := NewBalanceCache(conf.BalanceCache)
+	balanceServiceAPI := NewBalanceServiceAPI(api, webhookServiceAPI, limitServiceAPI, feeServiceAPI, systemAccounts, conf.Webhook.LowBalanceThreshold, balanceCache)
+
 	return &serviceAPI{
-		balanceServiceAPI: NewBalanceServiceAPI(api),
+		balanceServiceAPI: balanceServiceAPI,
 		transactionServiceAPI: NewTransactionServiceAPI(api),
+		webhookServiceAPI: webhookServiceAPI,
+		limitServiceAPI: limitServiceAPI,
+		accountServiceAPI: NewAccountServiceAPI(api),
+		scheduleServiceAPI: NewScheduleServiceAPI(api, balanceServiceAPI, conf.Scheduler),
+		escrowServiceAPI: NewEscrowServiceAPI(api, balanceServiceAPI, systemAccounts, conf.Escrow),
+		invoiceServiceAPI: NewInvoiceServiceAPI(api, balanceServiceAPI, conf.Invoice),
+		moneyRequestServiceAPI: NewMoneyRequestServiceAPI(api, balanceServiceAPI, conf.MoneyRequest),
+		feeServiceAPI: feeServiceAPI,
+		systemAccountServiceAPI: NewSystemAccountServiceAPI(api, systemAccounts),
+		ledgerServiceAPI: NewLedgerServiceAPI(api, balanceCache),
+		auditServiceAPI: NewAuditServiceAPI(api),
+		adminOperationServiceAPI: NewAdminOperationServiceAPI(api, balanceServiceAPI, webhookServiceAPI, systemAccounts, balanceCache),
+		archiveServiceAPI: NewArchiveServiceAPI(api, conf.Archive),
 	}
 }
 
@@ -27,4 +76,55 @@ func (s *serviceAPI) GetBalanceService() BalanceServiceAPI {
 
 func (s *serviceAPI) GetTransactionService() TransactionServiceAPI {
 	return s.transactionServiceAPI
-}
\ No newline at end of file
+}
+
+func (s *serviceAPI) GetWebhookService() WebhookServiceAPI {
+	return s.webhookServiceAPI
+}
+
+func (s *serviceAPI) GetLimitService() LimitServiceAPI {
+	return s.limitServiceAPI
+}
+
+func (s *serviceAPI) GetAccountService() AccountServiceAPI {
+	return s.accountServiceAPI
+}
+
+func (s *serviceAPI) GetScheduleService() ScheduleServiceAPI {
+	return s.scheduleServiceAPI
+}
+func (s *serviceAPI) GetEscrowService() EscrowServiceAPI {
+	return s.escrowServiceAPI
+}
+
+func (s *serviceAPI) GetInvoiceService() InvoiceServiceAPI {
+	return s.invoiceServiceAPI
+}
+
+func (s *serviceAPI) GetMoneyRequestService() MoneyRequestServiceAPI {
+	return s.moneyRequestServiceAPI
+}
+
+func (s *serviceAPI) GetFeeService() FeeServiceAPI {
+	return s.feeServiceAPI
+}
+
+func (s *serviceAPI) GetSystemAccountService() SystemAccountServiceAPI {
+	return s.systemAccountServiceAPI
+}
+
+func (s *serviceAPI) GetLedgerService() LedgerServiceAPI {
+	return s.ledgerServiceAPI
+}
+
+func (s *serviceAPI) GetAuditService() AuditServiceAPI {
+	return s.auditServiceAPI
+}
+
+func (s *serviceAPI) GetAdminOperationService() AdminOperationServiceAPI {
+	return s.adminOperationServiceAPI
+}
+
+func (s *serviceAPI) GetArchiveService() ArchiveServiceAPI {
+	return s.archiveServiceAPI
+}
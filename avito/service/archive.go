@@ -0,0 +1,239 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type ArchiveServiceAPI interface {
+	// ArchiveRequest создает секции "transaction" до archive.partitions_ahead месяцев вперед и
+	// выгружает в файлы месяцы старше archive.retention_months, если задан archive.dir
+	ArchiveRequest(ctx context.Context) (*dto.ArchiveReport, error, bool)
+	// Start выполняет ArchiveRequest при запуске и каждые archive.check_interval до отмены ctx
+	Start(ctx context.Context)
+}
+
+type archiveService struct {
+	storage storage.StorageAPI
+	conf    config.ArchiveConfig
+	now     func() time.Time
+	log     *logger.Logger
+}
+
+func NewArchiveServiceAPI(api storage.StorageAPI, conf config.ArchiveConfig) ArchiveServiceAPI {
+	return &archiveService{
+		storage: api,
+		conf:    conf,
+		now:     time.Now,
+		log:     logger.New("archive-service"),
+	}
+}
+
+// archiveRecord - строка файла архива. Время записано в том виде, в котором оно входит в хеш
+// движения, поэтому цепочку счета можно проверить по файлу
+type archiveRecord struct {
+	Id      uuid.UUID `json:"id"`
+	EntryId uuid.UUID `json:"entry_id"`
+	UserId  uuid.UUID `json:"user_id"`
+	Seq     int64     `json:"seq"`
+	// в копейках
+	ChangeBalance int64  `json:"change_balance"`
+	Operation     string `json:"operation"`
+	Client        string `json:"client"`
+	Comment       string `json:"comment"`
+	CreatedAt     string `json:"created_at"`
+	PrevHash      string `json:"prev_hash"`
+	Hash          string `json:"hash"`
+}
+
+func (a *archiveService) ArchiveRequest(ctx context.Context) (*dto.ArchiveReport, error, bool) {
+	now := a.now()
+	report := &dto.ArchiveReport{Archived: make([]dto.ArchivedRange, 0)}
+
+	// секция текущего месяца тоже создается: при первом запуске ее может не быть
+	partitions, err := a.storage.GetArchiveStorage().EnsurePartitions(now, storage.MonthStart(now).AddDate(0, a.conf.PartitionsAhead, 0))
+	if err != nil {
+		a.log.Errorf(ctx, "Error while create transaction partitions in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+	for _, partition := range partitions {
+		a.log.Infof(ctx, "Created partition %s", partition)
+	}
+	report.Partitions = partitions
+
+	if a.conf.Dir == "" {
+		return report, nil, false
+	}
+
+	retention := a.conf.RetentionMonths
+	if retention < 0 {
+		retention = 0
+	}
+	periods, err := a.storage.GetArchiveStorage().GetArchivablePeriods(storage.MonthStart(now).AddDate(0, -retention, 0))
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get archivable periods from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	// месяцы выгружаются по порядку: цепочка счета продолжается с последнего архивного звена,
+	// поэтому после ошибки следующие месяцы не архивируются
+	for _, start := range periods {
+		archive, err := a.archivePeriod(ctx, start)
+		if err != nil {
+			a.log.Errorf(ctx, "Error while archive postings of %s, reason: %v", start.Format("2006-01"), err)
+			return nil, xerrors.Errorf("System error. Contact support"), true
+		}
+
+		report.Archived = append(report.Archived, archiveToDTO(*archive))
+	}
+
+	return report, nil, false
+}
+
+func (a *archiveService) Start(ctx context.Context) {
+	interval := a.conf.CheckInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// ошибки уже записаны в лог, следующая попытка - через interval
+		a.ArchiveRequest(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archivePeriod выгружает движения месяца в файл и удаляет их из базы. Если удалить не удалось,
+// файл удаляется, и месяц будет выгружен заново при следующем запуске
+func (a *archiveService) archivePeriod(ctx context.Context, start time.Time) (*storage.TransactionArchive, error) {
+	archive := storage.TransactionArchive{ID: uuid.New(), PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), ArchivedAt: a.now().UTC()}
+	// месяц может архивироваться несколько раз, если движения попали в него после архивирования
+	archive.File = fmt.Sprintf("%s_%s.ndjson.gz", storage.PartitionName(start), archive.ID.String()[:8])
+	path := filepath.Join(a.conf.Dir, archive.File)
+
+	accounts, err := a.export(start, path, &archive)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := a.storage.GetTransaction(ctx)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	if err := a.storage.GetArchiveStorage().ArchivePeriod(tx, archive, accounts); err != nil {
+		tx.Rollback(ctx)
+		os.Remove(path)
+		return nil, err
+	}
+
+	// при ошибке commit архив мог сохраниться, поэтому файл остается
+	if err := tx.Commit(ctx); err != nil {
+		return nil, xerrors.Errorf("commit archive of %s to %s: %w", start.Format("2006-01"), path, err)
+	}
+
+	a.log.Infof(ctx, "Archived %d postings of %d accounts for %s to %s", archive.Postings, len(accounts), start.Format("2006-01"), path)
+	return &archive, nil
+}
+
+// export записывает движения месяца в path через временный файл и возвращает итоги счетов.
+// Число движений и контрольная сумма файла записываются в archive
+func (a *archiveService) export(start time.Time, path string, archive *storage.TransactionArchive) ([]storage.ArchivedAccount, error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	written := false
+	defer func() {
+		if !written {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	checksum := sha256.New()
+	compressed := gzip.NewWriter(io.MultiWriter(file, checksum))
+	encoder := json.NewEncoder(compressed)
+
+	accounts := make([]storage.ArchivedAccount, 0)
+	err = a.storage.GetArchiveStorage().WalkPeriod(start, func(link storage.ChainLink) error {
+		if len(accounts) == 0 || accounts[len(accounts)-1].UserID != link.UserID {
+			accounts = append(accounts, storage.ArchivedAccount{UserID: link.UserID})
+		}
+		account := &accounts[len(accounts)-1]
+		account.Postings++
+		account.Total += link.Sum
+		account.LastSeq, account.LastHash = link.Seq, link.Hash
+		archive.Postings++
+
+		return encoder.Encode(archiveRecord{
+			Id:            link.ID,
+			EntryId:       link.EntryID,
+			UserId:        link.UserID,
+			Seq:           link.Seq,
+			ChangeBalance: link.Sum,
+			Operation:     link.Operation,
+			Client:        link.Client,
+			Comment:       link.Comment,
+			CreatedAt:     link.CreatedAt.Format("2006-01-02 15:04:05.999999"),
+			PrevHash:      link.PrevHash,
+			Hash:          link.Hash,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := compressed.Close(); err != nil {
+		return nil, err
+	}
+	// файл должен быть на диске до удаления движений из базы
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, err
+	}
+	written = true
+
+	archive.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	return accounts, nil
+}
+
+func archiveToDTO(archive storage.TransactionArchive) dto.ArchivedRange {
+	return dto.ArchivedRange{
+		From:       archive.PeriodStart.Format(time.RFC3339),
+		To:         archive.PeriodEnd.Format(time.RFC3339),
+		Postings:   archive.Postings,
+		File:       archive.File,
+		ArchivedAt: archive.ArchivedAt.Format(time.RFC3339),
+	}
+}
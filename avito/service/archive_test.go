@@ -0,0 +1,188 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/storage"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newArchiveEnv возвращает окружение, архив которого считает текущий месяц прошедшим
+func newArchiveEnv(t *testing.T, conf config.ArchiveConfig) (*testEnv, *archiveService) {
+	e := newTestEnv(t, func(c *config.ApplicationConfig) {
+		c.Archive = conf
+	})
+
+	archive := e.service.GetArchiveService().(*archiveService)
+	nextMonth := storage.MonthStart(time.Now()).AddDate(0, 1, 0)
+	archive.now = func() time.Time { return nextMonth }
+
+	return e, archive
+}
+
+func TestArchiveRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "balance-archive")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	e, archive := newArchiveEnv(t, config.ArchiveConfig{Dir: dir, RetentionMonths: 0})
+	userID, receiverID := e.newUser(1000), e.newUser(0)
+	err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: receiverID, Sum: money(300)})
+	requireNoError(t, err, isInternal)
+
+	report, err, isInternal := archive.ArchiveRequest(e.ctx)
+	requireNoError(t, err, isInternal)
+	if len(report.Archived) != 1 {
+		t.Fatalf("expected 1 archived month, got %+v", report)
+	}
+	archived := report.Archived[0]
+	if archived.From != storage.MonthStart(time.Now()).Format(time.RFC3339) || archived.Postings != 4 {
+		t.Fatalf("unexpected archived month %+v", archived)
+	}
+
+	// файл содержит движения с хешами, по которым проверяется цепочка
+	data, err := ioutil.ReadFile(filepath.Join(dir, archived.File))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	checksum := sha256.Sum256(data)
+	archives, err := e.storage.GetArchiveStorage().GetArchives(nil)
+	if err != nil || len(archives) != 1 || archives[0].Checksum != hex.EncodeToString(checksum[:]) {
+		t.Fatalf("unexpected archives %+v, %v", archives, err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, archived.File))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	records := 0
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var record archiveRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Unmarshal %s: %v", scanner.Text(), err)
+		}
+		createdAt, err := time.Parse("2006-01-02 15:04:05.999999", record.CreatedAt)
+		if err != nil {
+			t.Fatalf("created_at %q: %v", record.CreatedAt, err)
+		}
+		link := storage.ChainLink{ID: record.Id, EntryID: record.EntryId, UserID: record.UserId, Seq: record.Seq, Sum: record.ChangeBalance,
+			Operation: record.Operation, Client: record.Client, Comment: record.Comment, CreatedAt: createdAt, PrevHash: record.PrevHash}
+		if link.ComputeHash() != record.Hash {
+			t.Fatalf("hash of archived record %+v does not match", record)
+		}
+		records++
+	}
+	if err := scanner.Err(); err != nil || records != 4 {
+		t.Fatalf("expected 4 records, got %d: %v", records, err)
+	}
+
+	// история сообщает об архивных месяцах, балансы и сверка их учитывают
+	rows, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(rows) != 0 {
+		t.Fatalf("expected archived transactions to be removed, got %v", rows)
+	}
+	ranges, err, isInternal := e.service.GetTransactionService().GetArchivedRangesRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+	if len(ranges) != 1 || ranges[0].Postings != 2 || ranges[0].File != archived.File {
+		t.Fatalf("unexpected archived ranges %+v", ranges)
+	}
+	e.requireBalance(userID, 700)
+	e.requireBalanced()
+
+	// цепочка продолжается с последнего архивного звена
+	e.credit(userID, 100)
+	verification, err, isInternal := e.service.GetLedgerService().VerifyLedgerRequest(e.ctx, nil)
+	requireNoError(t, err, isInternal)
+	if !verification.Valid {
+		t.Fatalf("ledger is not valid after archive: %+v", verification.Breaks)
+	}
+	e.requireBalanced()
+
+	// движения, записанные в месяц после его архивирования, выгружаются отдельным архивом
+	report, err, isInternal = archive.ArchiveRequest(e.ctx)
+	requireNoError(t, err, isInternal)
+	if len(report.Archived) != 1 || report.Archived[0].Postings != 2 {
+		t.Fatalf("expected new postings of the month to be archived, got %+v", report.Archived)
+	}
+	ranges, err, isInternal = e.service.GetTransactionService().GetArchivedRangesRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+	if len(ranges) != 2 {
+		t.Fatalf("expected 2 archived ranges, got %+v", ranges)
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "balance-archive")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, conf := range []config.ArchiveConfig{{Dir: dir, RetentionMonths: 1}, {Dir: "", RetentionMonths: 0}} {
+		e, archive := newArchiveEnv(t, conf)
+		userID := e.newUser(1000)
+
+		report, err, isInternal := archive.ArchiveRequest(e.ctx)
+		requireNoError(t, err, isInternal)
+		if len(report.Archived) != 0 {
+			t.Fatalf("%+v: unexpected archived months %+v", conf, report.Archived)
+		}
+
+		rows, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+		requireNoError(t, err, isInternal)
+		if len(rows) != 1 {
+			t.Fatalf("%+v: expected transaction to stay in DB, got %v", conf, rows)
+		}
+	}
+
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Fatalf("expected no archive files, got %v, %v", files, err)
+	}
+}
+
+func TestGetArchivedJournalEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "balance-archive")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	e, archive := newArchiveEnv(t, config.ArchiveConfig{Dir: dir, RetentionMonths: 0})
+	userID := e.newUser(1000)
+	rows, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+
+	entry, err, isInternal := e.service.GetLedgerService().GetJournalEntryRequest(e.ctx, rows[0].EntryId)
+	requireNoError(t, err, isInternal)
+	if entry.Archived != nil || len(entry.Postings) != 2 {
+		t.Fatalf("unexpected journal entry before archive %+v", entry)
+	}
+
+	report, err, isInternal := archive.ArchiveRequest(e.ctx)
+	requireNoError(t, err, isInternal)
+
+	// движения выгружены в файл, проводка указывает на него
+	entry, err, isInternal = e.service.GetLedgerService().GetJournalEntryRequest(e.ctx, rows[0].EntryId)
+	requireNoError(t, err, isInternal)
+	if entry.Archived == nil || entry.Archived.File != report.Archived[0].File || entry.Archived.From != report.Archived[0].From || len(entry.Postings) != 0 {
+		t.Fatalf("expected journal entry to be marked archived, got %+v", entry)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// AuditRequest - данные изменяющего запроса, известные обработчику
+type AuditRequest struct {
+	RequestID   string
+	Method      string
+	Path        string
+	SourceIP    string
+	PayloadHash string
+	// пользователь из запроса, если он указан
+	UserID *uuid.UUID
+	Status int
+	Error  string
+}
+
+// AuditRecord накапливает счета и движения, записанные операциями запроса
+type AuditRecord struct {
+	userIDs        []uuid.UUID
+	transactionIDs []uuid.UUID
+}
+
+type auditRecordKey struct{}
+
+// WithAuditRecord возвращает контекст, в котором операции сервиса добавляют свои движения в record
+func WithAuditRecord(ctx context.Context) (context.Context, *AuditRecord) {
+	record := &AuditRecord{}
+	return context.WithValue(ctx, auditRecordKey{}, record), record
+}
+
+// recordPostings добавляет в запись аудита движения закоммиченной проводки
+func recordPostings(ctx context.Context, entry storage.JournalEntry, postingIDs []uuid.UUID) {
+	record, ok := ctx.Value(auditRecordKey{}).(*AuditRecord)
+	if !ok {
+		return
+	}
+
+	for _, posting := range entry.Postings {
+		record.addUser(posting.UserID)
+	}
+	record.transactionIDs = append(record.transactionIDs, postingIDs...)
+}
+
+func (a *AuditRecord) addUser(userID uuid.UUID) {
+	for _, id := range a.userIDs {
+		if id == userID {
+			return
+		}
+	}
+
+	a.userIDs = append(a.userIDs, userID)
+}
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type AuditServiceAPI interface {
+	// WriteAuditRequest сохраняет запись о запросе вместе с клиентом и движениями из ctx.
+	// Ошибка записи только логируется: ответ клиенту к этому моменту уже сформирован
+	WriteAuditRequest(ctx context.Context, request AuditRequest)
+	GetAuditLogRequest(ctx context.Context, filter dto.AuditFilter, limit int, offset int) ([]dto.AuditEntry, error, bool)
+}
+
+type auditService struct {
+	storage storage.StorageAPI
+	log     *logger.Logger
+}
+
+func NewAuditServiceAPI(api storage.StorageAPI) AuditServiceAPI {
+	return &auditService{
+		storage: api,
+		log:     logger.New("audit-service"),
+	}
+}
+
+func (a *auditService) WriteAuditRequest(ctx context.Context, request AuditRequest) {
+	record, ok := ctx.Value(auditRecordKey{}).(*AuditRecord)
+	if !ok {
+		record = &AuditRecord{}
+	}
+	if request.UserID != nil {
+		record.addUser(*request.UserID)
+	}
+
+	entry := storage.AuditEntry{
+		RequestID:      request.RequestID,
+		Client:         auth.ClientName(ctx),
+		Method:         request.Method,
+		Path:           request.Path,
+		SourceIP:       request.SourceIP,
+		PayloadHash:    request.PayloadHash,
+		Status:         request.Status,
+		Result:         dto.AuditResultSuccess,
+		UserIDs:        make([]string, 0, len(record.userIDs)),
+		TransactionIDs: make([]string, 0, len(record.transactionIDs)),
+	}
+	if request.Status >= 400 {
+		entry.Result = dto.AuditResultFailure
+	}
+	if request.Error != "" {
+		entry.Error = &request.Error
+	}
+	for _, id := range record.userIDs {
+		entry.UserIDs = append(entry.UserIDs, id.String())
+	}
+	for _, id := range record.transactionIDs {
+		entry.TransactionIDs = append(entry.TransactionIDs, id.String())
+	}
+
+	err := a.storage.GetAuditStorage().WriteAuditEntry(entry)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while write audit entry for request %s to DB, reason: %v", request.RequestID, err)
+	}
+}
+
+func (a *auditService) GetAuditLogRequest(ctx context.Context, filter dto.AuditFilter, limit int, offset int) ([]dto.AuditEntry, error, bool) {
+	storageFilter := storage.AuditFilter{UserID: filter.UserId, Client: filter.Client}
+	if filter.From != "" {
+		from, err := time.Parse(time.RFC3339, filter.From)
+		if err != nil {
+			return nil, xerrors.Errorf("from must be in RFC3339 format"), false
+		}
+		storageFilter.From = &from
+	}
+	if filter.To != "" {
+		to, err := time.Parse(time.RFC3339, filter.To)
+		if err != nil {
+			return nil, xerrors.Errorf("to must be in RFC3339 format"), false
+		}
+		storageFilter.To = &to
+	}
+
+	entries, err := a.storage.GetAuditStorage().GetAuditEntries(storageFilter, limit, offset)
+	if err != nil {
+		a.log.Errorf(ctx, "Error while get audit entries from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := make([]dto.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, auditEntryToDTO(entry))
+	}
+
+	return result, nil, false
+}
+
+func auditEntryToDTO(entry storage.AuditEntry) dto.AuditEntry {
+	result := dto.AuditEntry{
+		Id:             entry.ID,
+		RequestId:      entry.RequestID,
+		Client:         entry.Client,
+		Method:         entry.Method,
+		Path:           entry.Path,
+		SourceIP:       entry.SourceIP,
+		PayloadHash:    entry.PayloadHash,
+		Status:         entry.Status,
+		Result:         entry.Result,
+		Error:          entry.Error,
+		UserIds:        make([]uuid.UUID, 0, len(entry.UserIDs)),
+		TransactionIds: make([]uuid.UUID, 0, len(entry.TransactionIDs)),
+		CreatedAt:      entry.CreatedAt.Format(time.RFC3339),
+	}
+	for _, id := range entry.UserIDs {
+		result.UserIds = append(result.UserIds, uuid.MustParse(id))
+	}
+	for _, id := range entry.TransactionIDs {
+		result.TransactionIds = append(result.TransactionIds, uuid.MustParse(id))
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/dto"
+	"github.com/google/uuid"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuditRecordsPostings(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(1000)
+	receiverID := e.newUser(0)
+
+	ctx := auth.WithClient(e.ctx, &auth.Client{Name: "shop"})
+	ctx, _ = WithAuditRecord(ctx)
+	err, isInternal := e.service.GetBalanceService().TransferFundsRequest(ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+	e.service.GetAuditService().WriteAuditRequest(ctx, AuditRequest{RequestID: "req-1", Method: http.MethodPost, Path: "/balance/transfer", SourceIP: "127.0.0.1", PayloadHash: "hash", Status: http.StatusOK})
+
+	failedCtx := auth.WithClient(e.ctx, &auth.Client{Name: "billing"})
+	e.service.GetAuditService().WriteAuditRequest(failedCtx, AuditRequest{RequestID: "req-2", Method: http.MethodPost, Path: "/balance/withdraw", UserID: &receiverID, Status: http.StatusBadRequest, Error: "You have not enough funds to complete this operation"})
+
+	entries, err, isInternal := e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{}, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", entries)
+	}
+
+	failed, succeeded := entries[0], entries[1]
+	if succeeded.RequestId != "req-1" || succeeded.Client != "shop" || succeeded.Result != dto.AuditResultSuccess || len(succeeded.UserIds) != 2 || len(succeeded.TransactionIds) != 2 {
+		t.Fatalf("unexpected audit entry %+v", succeeded)
+	}
+	if failed.RequestId != "req-2" || failed.Result != dto.AuditResultFailure || failed.Error == nil || len(failed.UserIds) != 1 || len(failed.TransactionIds) != 0 {
+		t.Fatalf("unexpected audit entry %+v", failed)
+	}
+
+	entries, err, isInternal = e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{UserId: &senderID}, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(entries) != 1 || entries[0].RequestId != "req-1" {
+		t.Fatalf("unexpected entries for sender %+v", entries)
+	}
+
+	entries, err, isInternal = e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{Client: "billing"}, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(entries) != 1 || entries[0].RequestId != "req-2" {
+		t.Fatalf("unexpected entries for client %+v", entries)
+	}
+
+	other := uuid.New()
+	entries, err, isInternal = e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{UserId: &other, To: time.Now().Add(time.Hour).Format(time.RFC3339)}, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries, got %+v", entries)
+	}
+
+	entries, err, isInternal = e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{From: time.Now().Add(time.Hour).Format(time.RFC3339)}, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries, got %+v", entries)
+	}
+
+	_, err, isInternal = e.service.GetAuditService().GetAuditLogRequest(e.ctx, dto.AuditFilter{From: "yesterday"}, 10, 0)
+	requireUserError(t, err, isInternal, "from must be in RFC3339 format")
+}
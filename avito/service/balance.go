@@ -1,95 +1,185 @@
 package service
 
 import (
+	"avito/auth"
 	"avito/dto"
+	"avito/logger"
 	"avito/storage"
 	"context"
 	"encoding/json"
 	"github.com/google/uuid"
 	"golang.org/x/xerrors"
-	"log"
 	"net/http"
-	"os"
 )
 
 // последний параметр в функциях - isInternal, для определения типа ошибки в handlers
 type BalanceServiceAPI interface {
-	CreditFundsRequest(creditFundsRequest dto.OperationRequest) (error, bool)
-	WithdrawFundsRequest(withdrawFundsRequest dto.OperationRequest) (error, bool)
-	TransferFundsRequest(transferFundsRequest dto.TransferFundsRequest) (error, bool)
-	GetBalanceRequest(userID uuid.UUID, currency string) (*dto.Money, error, bool)
+	CreditFundsRequest(ctx context.Context, creditFundsRequest dto.OperationRequest) (error, bool)
+	WithdrawFundsRequest(ctx context.Context, withdrawFundsRequest dto.OperationRequest) (error, bool)
+	TransferFundsRequest(ctx context.Context, transferFundsRequest dto.TransferFundsRequest) (error, bool)
+	// варианты операций, выполняющие hook в той же транзакции перед commit
+	WithdrawFundsRequestWithHook(ctx context.Context, withdrawFundsRequest dto.OperationRequest, hook TxHook) (error, bool)
+	TransferFundsRequestWithHook(ctx context.Context, transferFundsRequest dto.TransferFundsRequest, hook TxHook) (error, bool)
+	GetBalanceRequest(ctx context.Context, userID uuid.UUID, currency string) (*dto.Money, error, bool)
+}
+
+// TxHook выполняется в транзакции операции перед commit, ошибка откатывает операцию.
+// Ошибка ServiceError возвращается как пользовательская, остальные - как внутренние без изменений
+type TxHook func(tx storage.Tx) error
+
+type transactionCommentKey struct{}
+
+// withTransactionComment задает комментарий к транзакциям операции, выполняемой с этим контекстом
+func withTransactionComment(ctx context.Context, comment string) context.Context {
+	return context.WithValue(ctx, transactionCommentKey{}, comment)
+}
+
+func transactionComment(ctx context.Context) string {
+	comment, _ := ctx.Value(transactionCommentKey{}).(string)
+	return comment
+}
+
+type strongConsistencyKey struct{}
+
+// WithStrongConsistency требует читать баланс и историю из основной базы, минуя реплику и кэш
+func WithStrongConsistency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongConsistencyKey{}, true)
+}
+
+func isStrongConsistency(ctx context.Context) bool {
+	strong, _ := ctx.Value(strongConsistencyKey{}).(bool)
+	return strong
 }
 
 type balanceService struct {
 	storage storage.StorageAPI
-	ctx context.Context
-	log *log.Logger
+	webhooks WebhookServiceAPI
+	limits LimitServiceAPI
+	fees FeeServiceAPI
+	system SystemAccounts
+	lowBalanceThreshold int64
+	cache BalanceCache
+	log *logger.Logger
 }
 
-func NewBalanceServiceAPI(api storage.StorageAPI) BalanceServiceAPI {
+func NewBalanceServiceAPI(api storage.StorageAPI, webhooks WebhookServiceAPI, limits LimitServiceAPI, fees FeeServiceAPI, system SystemAccounts, lowBalanceThreshold int64, cache BalanceCache) BalanceServiceAPI {
 	return &balanceService{
 		storage: api,
-		ctx: context.Background(),
-		log: log.New(os.Stdout, "BALANCE-SERVICE: ", log.LstdFlags),
+		webhooks: webhooks,
+		limits: limits,
+		fees: fees,
+		system: system,
+		lowBalanceThreshold: lowBalanceThreshold,
+		cache: cache,
+		log: logger.New("balance-service"),
 	}
 }
 
-func (b *balanceService) CreditFundsRequest(creditFundsRequest dto.OperationRequest) (error, bool) {
-	b.log.Printf("Trying to increase balance of user %v", creditFundsRequest.UserId)
+func (b *balanceService) CreditFundsRequest(ctx context.Context, creditFundsRequest dto.OperationRequest) (error, bool) {
+	b.log.Infof(ctx, "Trying to increase balance of user %v", creditFundsRequest.UserId)
 
 	if creditFundsRequest.Sum.FracPart  < 0 || creditFundsRequest.Sum.FracPart > 99 {
 		return xerrors.Errorf("frac_part must be between 0 and 99"), false
 	}
 
+	// отрицательная сумма списала бы средства мимо проверок статуса и лимитов
 	sum := creditFundsRequest.Sum.IntPart * 100 + creditFundsRequest.Sum.FracPart
-	if sum == 0 {
-		return xerrors.Errorf("Sum cannot be 0"), false
+	if sum <= 0 {
+		return xerrors.Errorf("Sum must be positive"), false
+	}
+
+	var sourceID uuid.UUID
+	switch creditFundsRequest.Source {
+	case "", dto.CreditSourceExternal:
+		sourceID = b.system.Clearing
+	case dto.CreditSourcePromotion:
+		sourceID = b.system.Promotions
+	default:
+		return xerrors.Errorf("source must be external or promotion"), false
+	}
+
+	if err := b.checkSystemAccounts(ctx, creditFundsRequest.UserId); err != nil {
+		return err, false
 	}
 
-	tx, err := b.storage.GetTransaction(b.ctx)
+	tx, err := b.storage.GetTransaction(ctx)
 	if err != nil {
-		b.log.Printf("Error while create transaction, reason: %+v", err)
+		b.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetBalanceStorage().BalanceIncrease(tx, creditFundsRequest.UserId, sum)
+	err, isInternal := b.checkAccountStatus(ctx, tx, creditFundsRequest.UserId, false)
 	if err != nil {
-		b.log.Printf("Error while increase balance in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	// зачисление пользователю списывается со служебного счета источника
+	entry := storage.JournalEntry{
+		Operation: dto.OperationCredit,
+		Client: auth.ClientName(ctx),
+		Comment: transactionComment(ctx),
+		Postings: []storage.Posting{
+			{UserID: creditFundsRequest.UserId, Sum: sum, Operation: dto.OperationCredit},
+			{UserID: sourceID, Sum: -sum, Operation: dto.OperationCredit},
+		},
+	}
+
+	if err := b.system.checkEntry(entry); err != nil {
+		b.log.Errorf(ctx, "Error while check journal entry, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetTransactionStorage().WriteTransaction(tx, creditFundsRequest.UserId, sum)
+	_, postingIDs, err := b.storage.GetLedgerStorage().PostEntry(tx, entry)
 	if err != nil {
-		b.log.Printf("Error while write transaction in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		b.log.Errorf(ctx, "Error while post journal entry in DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = tx.Commit(b.ctx)
+	err = tx.Commit(ctx)
+	// при ошибке commit изменения могли сохраниться, поэтому кэш сбрасывается в любом случае
+	b.cache.Invalidate(postingUsers(entry)...)
 	if err != nil {
-		b.log.Printf("Error while commit transaction, reason: %+v", err)
+		b.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
+	recordPostings(ctx, entry, postingIDs)
+
+	go b.webhooks.Notify(ctx, dto.WebhookEvent{Event: dto.EventCredit, UserID: creditFundsRequest.UserId, Sum: creditFundsRequest.Sum})
 
 	return nil, false
 }
 
-func (b *balanceService) WithdrawFundsRequest(withdrawFundsRequest dto.OperationRequest) (error, bool) {
-	b.log.Printf("Trying to decrease balance of user %v", withdrawFundsRequest.UserId)
+func (b *balanceService) WithdrawFundsRequest(ctx context.Context, withdrawFundsRequest dto.OperationRequest) (error, bool) {
+	return b.WithdrawFundsRequestWithHook(ctx, withdrawFundsRequest, nil)
+}
+
+func (b *balanceService) WithdrawFundsRequestWithHook(ctx context.Context, withdrawFundsRequest dto.OperationRequest, hook TxHook) (error, bool) {
+	b.log.Infof(ctx, "Trying to decrease balance of user %v", withdrawFundsRequest.UserId)
 
 	if withdrawFundsRequest.Sum.FracPart  < 0 || withdrawFundsRequest.Sum.FracPart > 99 {
 		return xerrors.Errorf("frac_part must be between 0 and 99"), false
 	}
 
+	// лимиты считают только положительные списания, отрицательная сумма прошла бы мимо них
 	sum := withdrawFundsRequest.Sum.IntPart * 100 + withdrawFundsRequest.Sum.FracPart
-	if sum == 0 {
-		return xerrors.Errorf("Sum cannot be 0"), false
+	if sum <= 0 {
+		return xerrors.Errorf("Sum must be positive"), false
+	}
+
+	if withdrawFundsRequest.Source != "" {
+		return xerrors.Errorf("source is allowed only for credit"), false
+	}
+
+	if err := b.checkSystemAccounts(ctx, withdrawFundsRequest.UserId); err != nil {
+		return err, false
 	}
 
 	count, err := b.storage.GetBalanceStorage().CountUsers(withdrawFundsRequest.UserId)
 	if err != nil {
-		b.log.Printf("Error while count users in DB, reason: %v", err)
+		b.log.Errorf(ctx, "Error while count users in DB, reason: %v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
@@ -97,47 +187,99 @@ func (b *balanceService) WithdrawFundsRequest(withdrawFundsRequest dto.Operation
 		return xerrors.Errorf("User does not exist"), false
 	}
 
-	balance, err := b.storage.GetBalanceStorage().GetBalance(withdrawFundsRequest.UserId)
+	tx, err := b.storage.GetTransaction(ctx)
 	if err != nil {
-		b.log.Printf("Error while get balance from DB, reason: %v", withdrawFundsRequest)
+		b.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	if balance < sum {
-		return xerrors.Errorf("You have not enough funds to complete this operation"), false
+	balance, err := b.storage.GetBalanceStorage().LockBalance(tx, withdrawFundsRequest.UserId)
+	if err != nil {
+		b.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	tx, err := b.storage.GetTransaction(b.ctx)
+	creditLimit, err := b.storage.GetBalanceStorage().GetCreditLimit(tx, withdrawFundsRequest.UserId)
 	if err != nil {
-		b.log.Printf("Error while create transaction, reason: %+v", err)
+		b.log.Errorf(ctx, "Error while get credit limit from DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetBalanceStorage().BalanceDecrease(tx, withdrawFundsRequest.UserId, sum)
+	// счет с кредитным лимитом может уйти в минус не больше чем на лимит
+	if balance+creditLimit < sum {
+		tx.Rollback(ctx)
+		return newServiceError(dto.ErrCodeInsufficientFunds, "You have not enough funds to complete this operation"), false
+	}
+
+	err, isInternal := b.checkAccountStatus(ctx, tx, withdrawFundsRequest.UserId, true)
 	if err != nil {
-		b.log.Printf("Error while decrease balance in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	// административные списания не ограничиваются лимитами пользователя
+	if !isAdminOperation(ctx) {
+		err, isInternal = b.limits.CheckLimits(ctx, tx, withdrawFundsRequest.UserId, dto.OperationWithdraw, sum)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err, isInternal
+		}
+	}
+
+	// списание за услуги зачисляется на счет доходов
+	entry := storage.JournalEntry{
+		Operation: dto.OperationWithdraw,
+		Client: auth.ClientName(ctx),
+		Comment: transactionComment(ctx),
+		Postings: []storage.Posting{
+			{UserID: withdrawFundsRequest.UserId, Sum: -sum, Operation: dto.OperationWithdraw},
+			{UserID: b.system.Revenue, Sum: sum, Operation: dto.OperationWithdraw},
+		},
+	}
+
+	if err := b.system.checkEntry(entry); err != nil {
+		b.log.Errorf(ctx, "Error while check journal entry, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetTransactionStorage().WriteTransaction(tx, withdrawFundsRequest.UserId, -sum)
+	_, postingIDs, err := b.storage.GetLedgerStorage().PostEntry(tx, entry)
 	if err != nil {
-		b.log.Printf("Error while write transaction in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		b.log.Errorf(ctx, "Error while post journal entry in DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = tx.Commit(b.ctx)
+	err, isInternal = b.runHook(tx, hook)
 	if err != nil {
-		b.log.Printf("Error while commit transaction, reason: %+v", err)
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	err = tx.Commit(ctx)
+	b.cache.Invalidate(postingUsers(entry)...)
+	if err != nil {
+		b.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
+	recordPostings(ctx, entry, postingIDs)
+
+	go func() {
+		b.webhooks.Notify(ctx, dto.WebhookEvent{Event: dto.EventWithdraw, UserID: withdrawFundsRequest.UserId, Sum: withdrawFundsRequest.Sum})
+		b.notifyLowBalance(ctx, withdrawFundsRequest.UserId)
+	}()
 
 	return nil, false
 }
 
-func (b *balanceService) TransferFundsRequest(transferFundsRequest dto.TransferFundsRequest) (error, bool) {
-	b.log.Printf("Trying to transfer funds from user %v to user %v", transferFundsRequest.IdSender, transferFundsRequest.IdReceiver)
+func (b *balanceService) TransferFundsRequest(ctx context.Context, transferFundsRequest dto.TransferFundsRequest) (error, bool) {
+	return b.TransferFundsRequestWithHook(ctx, transferFundsRequest, nil)
+}
+
+func (b *balanceService) TransferFundsRequestWithHook(ctx context.Context, transferFundsRequest dto.TransferFundsRequest, hook TxHook) (error, bool) {
+	b.log.Infof(ctx, "Trying to transfer funds from user %v to user %v", transferFundsRequest.IdSender, transferFundsRequest.IdReceiver)
 
 	if transferFundsRequest.Sum.FracPart  < 0 || transferFundsRequest.Sum.FracPart > 99 {
 		return xerrors.Errorf("frac_part must be between 0 and 99"), false
@@ -148,87 +290,161 @@ func (b *balanceService) TransferFundsRequest(transferFundsRequest dto.TransferF
 		return xerrors.Errorf("ReceiverID and senderID cannot be equal"), false
 	}
 
-	if sum == 0 {
-		return xerrors.Errorf("Sum cannot be 0"), false
+	// при отрицательной сумме средства ушли бы от получателя, статус которого проверяется только на зачисление
+	if sum <= 0 {
+		return xerrors.Errorf("Sum must be positive"), false
 	}
 
-	balance, err := b.storage.GetBalanceStorage().GetBalance(transferFundsRequest.IdSender)
+	if err := b.checkSystemAccounts(ctx, transferFundsRequest.IdSender, transferFundsRequest.IdReceiver); err != nil {
+		return err, false
+	}
+
+	tx, err := b.storage.GetTransaction(ctx)
 	if err != nil {
-		b.log.Printf("Error while get balance from DB, reason: %v", err)
+		b.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	if balance < sum {
-		return xerrors.Errorf("You have not enough funds to complete this operation"), false
+	balance, err := b.storage.GetBalanceStorage().LockBalance(tx, transferFundsRequest.IdSender)
+	if err == storage.ErrNoRows {
+		tx.Rollback(ctx)
+		return xerrors.Errorf("User does not exist"), false
 	}
-
-	tx, err := b.storage.GetTransaction(b.ctx)
 	if err != nil {
-		b.log.Printf("Error while create transaction, reason: %+v", err)
+		b.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetBalanceStorage().BalanceDecrease(tx, transferFundsRequest.IdSender, sum)
+	creditLimit, err := b.storage.GetBalanceStorage().GetCreditLimit(tx, transferFundsRequest.IdSender)
 	if err != nil {
-		b.log.Printf("Error while decrease balance in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		b.log.Errorf(ctx, "Error while get credit limit from DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetTransactionStorage().WriteTransaction(tx, transferFundsRequest.IdSender, -sum)
+	// комиссия списывается с отправителя сверх суммы перевода
+	fee := b.fees.TransferFee(transferFundsRequest.IdSender, transferFundsRequest.IdReceiver, sum)
+
+	// счет с кредитным лимитом может уйти в минус не больше чем на лимит
+	if balance+creditLimit < sum+fee {
+		tx.Rollback(ctx)
+		return newServiceError(dto.ErrCodeInsufficientFunds, "You have not enough funds to complete this operation"), false
+	}
+
+	err, isInternal := b.checkAccountStatus(ctx, tx, transferFundsRequest.IdSender, true)
 	if err != nil {
-		b.log.Printf("Error while write transaction in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
-		return xerrors.Errorf("System error. Contact support"), true
+		tx.Rollback(ctx)
+		return err, isInternal
 	}
 
-	err = b.storage.GetBalanceStorage().BalanceIncrease(tx, transferFundsRequest.IdReceiver, sum)
+	err, isInternal = b.checkAccountStatus(ctx, tx, transferFundsRequest.IdReceiver, false)
 	if err != nil {
-		b.log.Printf("Error while increase balance in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	// лимиты считают все, что уходит со счета отправителя, вместе с комиссией
+	err, isInternal = b.limits.CheckLimits(ctx, tx, transferFundsRequest.IdSender, dto.OperationTransfer, sum+fee)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	entry := storage.JournalEntry{
+		Operation: dto.OperationTransfer,
+		Client: auth.ClientName(ctx),
+		Comment: transactionComment(ctx),
+		Postings: []storage.Posting{
+			{UserID: transferFundsRequest.IdSender, Sum: -sum, Operation: dto.OperationTransfer},
+			{UserID: transferFundsRequest.IdReceiver, Sum: sum, Operation: dto.OperationTransfer},
+		},
+	}
+
+	// комиссия входит в ту же проводку отдельными движениями на счет доходов
+	if fee > 0 {
+		entry.Postings = append(entry.Postings,
+			storage.Posting{UserID: transferFundsRequest.IdSender, Sum: -fee, Operation: dto.OperationFee},
+			storage.Posting{UserID: b.fees.RevenueAccountID(), Sum: fee, Operation: dto.OperationFee},
+		)
+	}
+
+	if err := b.system.checkEntry(entry); err != nil {
+		b.log.Errorf(ctx, "Error while check journal entry, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = b.storage.GetTransactionStorage().WriteTransaction(tx, transferFundsRequest.IdReceiver, sum)
+	_, postingIDs, err := b.storage.GetLedgerStorage().PostEntry(tx, entry)
 	if err != nil {
-		b.log.Printf("Error while write transaction in DB, reason: %v", err)
-		tx.Rollback(b.ctx)
+		b.log.Errorf(ctx, "Error while post journal entry in DB, reason: %v", err)
+		tx.Rollback(ctx)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
 
-	err = tx.Commit(b.ctx)
+	err, isInternal = b.runHook(tx, hook)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err, isInternal
+	}
+
+	err = tx.Commit(ctx)
+	b.cache.Invalidate(postingUsers(entry)...)
 	if err != nil {
-		b.log.Printf("Error while commit transaction, reason: %+v", err)
+		b.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
 		return xerrors.Errorf("System error. Contact support"), true
 	}
+	recordPostings(ctx, entry, postingIDs)
+
+	go func() {
+		receiverID := transferFundsRequest.IdReceiver
+		b.webhooks.Notify(ctx, dto.WebhookEvent{Event: dto.EventTransfer, UserID: transferFundsRequest.IdSender, CounterpartyID: &receiverID, Sum: transferFundsRequest.Sum})
+		b.notifyLowBalance(ctx, transferFundsRequest.IdSender)
+	}()
 
 	return nil, false
 }
 
-func (b *balanceService) GetBalanceRequest(userID uuid.UUID, currency string) (*dto.Money, error, bool) {
-	b.log.Printf("Trying to get balance of user %v", userID)
+func (b *balanceService) GetBalanceRequest(ctx context.Context, userID uuid.UUID, currency string) (*dto.Money, error, bool) {
+	b.log.Infof(ctx, "Trying to get balance of user %v", userID)
 
-	count, err := b.storage.GetBalanceStorage().CountUsers(userID)
-	if err != nil {
-		b.log.Printf("Error while count users in DB, reason: %v", err)
-		return nil, xerrors.Errorf("System error. Contact support"), true
+	strong := isStrongConsistency(ctx)
+	var balance int64
+	var version uint64
+	var ok bool
+	if !strong {
+		balance, version, ok = b.cache.Get(userID)
 	}
 
-	if count != 1 {
-		return nil, xerrors.Errorf("User does not exist"), false
-	}
+	if !ok {
+		reads, replica := b.storage.GetReadStorage(strong)
+		count, err := reads.CountUsers(userID)
+		if err != nil {
+			b.log.Errorf(ctx, "Error while count users in DB, reason: %v", err)
+			return nil, xerrors.Errorf("System error. Contact support"), true
+		}
 
-	balance, err := b.storage.GetBalanceStorage().GetBalance(userID)
-	if err != nil {
-		b.log.Printf("Error while get balance from DB, reason: %v", err)
-		return nil, xerrors.Errorf("System error. Contact support"), true
-	}
+		if count != 1 {
+			return nil, xerrors.Errorf("User does not exist"), false
+		}
 
+		balance, err = reads.GetBalance(userID)
+		if err != nil {
+			b.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+			return nil, xerrors.Errorf("System error. Contact support"), true
+		}
+
+		// реплика может еще не содержать изменение, после которого кэш был сброшен,
+		// поэтому кэшируются только чтения основной базы
+		if !strong && !replica {
+			b.cache.Set(userID, balance, version)
+		}
+	}
 
 	if currency != "" {
 		cur, err, isUserError := GetCurrencyRequest(currency)
 		if err != nil {
-			b.log.Printf("Error while get currency, reason: %v", err)
+			b.log.Errorf(ctx, "Error while get currency, reason: %v", err)
 			if isUserError {
 				return nil, err, false
 			}
@@ -242,6 +458,81 @@ func (b *balanceService) GetBalanceRequest(userID uuid.UUID, currency string) (*
 	return &dto.Money{IntPart: balance / 100, FracPart: balance % 100}, nil, false
 }
 
+// checkSystemAccounts запрещает операции API со служебными счетами,
+// их средства двигаются только внутренними операциями сервиса
+func (b *balanceService) checkSystemAccounts(ctx context.Context, userIDs ...uuid.UUID) error {
+	if isSystemOperation(ctx) {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		if b.system.Contains(userID) {
+			return newServiceError(dto.ErrCodeForbidden, "Operation is not permitted for system account")
+		}
+	}
+
+	return nil
+}
+
+func (b *balanceService) runHook(tx storage.Tx, hook TxHook) (error, bool) {
+	if hook == nil {
+		return nil, false
+	}
+
+	err := hook(tx)
+	if err == nil {
+		return nil, false
+	}
+
+	var serviceError *ServiceError
+	if xerrors.As(err, &serviceError) {
+		return err, false
+	}
+
+	return err, true
+}
+
+// checkAccountStatus проверяет, что статус счета разрешает списание (debit) или зачисление.
+// Счета, которого еще нет, зачисление создаст активным
+func (b *balanceService) checkAccountStatus(ctx context.Context, tx storage.Tx, userID uuid.UUID, debit bool) (error, bool) {
+	status, err := b.storage.GetAccountStorage().GetAccountStatus(tx, userID)
+	if err == storage.ErrNoRows && !debit {
+		return nil, false
+	}
+	if err != nil {
+		b.log.Errorf(ctx, "Error while get account status from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if debit {
+		err = checkCanDebit(status)
+	} else {
+		err = checkCanCredit(status)
+	}
+	if err != nil {
+		return err, false
+	}
+
+	return nil, false
+}
+
+// notifyLowBalance отправляет событие low_balance, если после списания баланс опустился ниже порога
+func (b *balanceService) notifyLowBalance(ctx context.Context, userID uuid.UUID) {
+	if b.system.Contains(userID) {
+		return
+	}
+
+	balance, err := b.storage.GetBalanceStorage().GetBalance(userID)
+	if err != nil {
+		b.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		return
+	}
+
+	if balance < b.lowBalanceThreshold {
+		b.webhooks.Notify(ctx, dto.WebhookEvent{Event: dto.EventLowBalance, UserID: userID, Balance: &dto.Money{IntPart: balance / 100, FracPart: balance % 100}})
+	}
+}
+
 func GetCurrencyRequest(value string) (float64, error, bool) {
 	r, err := http.Get("https://api.exchangeratesapi.io/latest?base=RUB")
 	if err != nil {
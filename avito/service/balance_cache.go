@@ -0,0 +1,151 @@
+package service
+
+import (
+	"avito/config"
+	"avito/metrics"
+	"avito/storage"
+	"container/list"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// balanceCacheStripes - число счетчиков версий, между которыми распределяются счета
+const balanceCacheStripes = 256
+
+var (
+	balanceCacheRequests  = metrics.NewCounterVec("balance_cache_requests_total", "Balance reads by cache result", "result")
+	balanceCacheEvictions = metrics.NewCounterVec("balance_cache_evictions_total", "Balances removed from cache", "reason")
+)
+
+// BalanceCache - кэш балансов счетов в копейках. Запись в кэш после промаха выполняется
+// с версией, полученной при промахе: если счет за это время был сброшен, значение,
+// прочитанное до изменения, в кэш не попадает
+type BalanceCache interface {
+	// Get возвращает баланс из кэша, при промахе - версию для последующего Set
+	Get(userID uuid.UUID) (int64, uint64, bool)
+	Set(userID uuid.UUID, balance int64, version uint64)
+	// Invalidate сбрасывает балансы счетов, вызывается после commit каждого их изменения
+	Invalidate(userIDs ...uuid.UUID)
+}
+
+// NewBalanceCache возвращает кэш, который ничего не хранит, если он выключен в конфигурации
+func NewBalanceCache(conf config.BalanceCacheConfig) BalanceCache {
+	if !conf.Enabled || conf.Size <= 0 || conf.TTL <= 0 {
+		return noBalanceCache{}
+	}
+
+	return newBalanceCache(conf, time.Now)
+}
+
+type noBalanceCache struct{}
+
+func (noBalanceCache) Get(uuid.UUID) (int64, uint64, bool) {
+	return 0, 0, false
+}
+
+func (noBalanceCache) Set(uuid.UUID, int64, uint64) {}
+
+func (noBalanceCache) Invalidate(...uuid.UUID) {}
+
+type balanceCacheEntry struct {
+	userID  uuid.UUID
+	balance int64
+	expires time.Time
+}
+
+// balanceCache - LRU с ограничением времени жизни записей
+type balanceCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]*list.Element
+	// в начале - последние прочитанные
+	order    *list.List
+	versions [balanceCacheStripes]uint64
+}
+
+func newBalanceCache(conf config.BalanceCacheConfig, now func() time.Time) *balanceCache {
+	return &balanceCache{
+		size:    conf.Size,
+		ttl:     conf.TTL,
+		now:     now,
+		entries: make(map[uuid.UUID]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func stripe(userID uuid.UUID) int {
+	return int(userID[len(userID)-1]) % balanceCacheStripes
+}
+
+func (c *balanceCache) Get(userID uuid.UUID) (int64, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[userID]; ok {
+		entry := element.Value.(*balanceCacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(element)
+			balanceCacheRequests.Inc("hit")
+			return entry.balance, 0, true
+		}
+
+		c.remove(element, "expired")
+	}
+
+	balanceCacheRequests.Inc("miss")
+	return 0, c.versions[stripe(userID)], false
+}
+
+func (c *balanceCache) Set(userID uuid.UUID, balance int64, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.versions[stripe(userID)] != version {
+		return
+	}
+
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.entries[userID]; ok {
+		entry := element.Value.(*balanceCacheEntry)
+		entry.balance, entry.expires = balance, expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[userID] = c.order.PushFront(&balanceCacheEntry{userID: userID, balance: balance, expires: expires})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back(), "size")
+	}
+}
+
+func (c *balanceCache) Invalidate(userIDs ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, userID := range userIDs {
+		c.versions[stripe(userID)]++
+		if element, ok := c.entries[userID]; ok {
+			c.remove(element, "invalidated")
+		}
+	}
+}
+
+func (c *balanceCache) remove(element *list.Element, reason string) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*balanceCacheEntry).userID)
+	balanceCacheEvictions.Inc(reason)
+}
+
+// postingUsers возвращает счета, балансы которых меняет проводка
+func postingUsers(entry storage.JournalEntry) []uuid.UUID {
+	result := make([]uuid.UUID, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		result = append(result, posting.UserID)
+	}
+
+	return result
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestBalanceCacheEviction(t *testing.T) {
+	now := time.Now()
+	cache := newBalanceCache(config.BalanceCacheConfig{Enabled: true, Size: 2, TTL: time.Minute}, func() time.Time { return now })
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	for i, userID := range []uuid.UUID{first, second} {
+		_, version, ok := cache.Get(userID)
+		if ok {
+			t.Fatalf("unexpected hit for %v", userID)
+		}
+		cache.Set(userID, int64(i+1), version)
+	}
+
+	// first прочитан последним, поэтому при переполнении вытесняется second
+	if balance, _, ok := cache.Get(first); !ok || balance != 1 {
+		t.Fatalf("expected hit with 1, got %d %v", balance, ok)
+	}
+	_, version, _ := cache.Get(third)
+	cache.Set(third, 3, version)
+
+	if _, _, ok := cache.Get(second); ok {
+		t.Fatalf("expected second to be evicted")
+	}
+	if balance, _, ok := cache.Get(third); !ok || balance != 3 {
+		t.Fatalf("expected hit with 3, got %d %v", balance, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := cache.Get(first); ok {
+		t.Fatalf("expected first to expire")
+	}
+	if len(cache.entries) != 1 || cache.order.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(cache.entries))
+	}
+}
+
+func TestBalanceCacheInvalidate(t *testing.T) {
+	cache := newBalanceCache(config.BalanceCacheConfig{Enabled: true, Size: 10, TTL: time.Minute}, time.Now)
+	userID := uuid.New()
+
+	_, version, _ := cache.Get(userID)
+	cache.Set(userID, 100, version)
+	cache.Invalidate(userID)
+	if _, _, ok := cache.Get(userID); ok {
+		t.Fatalf("expected miss after invalidation")
+	}
+
+	// значение, прочитанное до изменения, не должно попасть в кэш после его сброса
+	_, version, _ = cache.Get(userID)
+	cache.Invalidate(userID)
+	cache.Set(userID, 100, version)
+	if _, _, ok := cache.Get(userID); ok {
+		t.Fatalf("stale balance has been cached")
+	}
+}
+
+func TestNewBalanceCacheDisabled(t *testing.T) {
+	cache := NewBalanceCache(config.BalanceCacheConfig{Enabled: false, Size: 10, TTL: time.Minute})
+	userID := uuid.New()
+
+	_, version, _ := cache.Get(userID)
+	cache.Set(userID, 100, version)
+	if _, _, ok := cache.Get(userID); ok {
+		t.Fatalf("disabled cache must not store balances")
+	}
+}
+
+func TestGetBalanceCached(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.BalanceCache = config.BalanceCacheConfig{Enabled: true, Size: 100, TTL: time.Hour}
+	})
+	userID, receiverID := e.newUser(1000), e.newUser(0)
+	balanceService := e.service.GetBalanceService()
+
+	requireCachedBalance := func(userID uuid.UUID, expected int64) {
+		t.Helper()
+		for i := 0; i < 2; i++ {
+			balance, err, isInternal := balanceService.GetBalanceRequest(e.ctx, userID, "")
+			requireNoError(t, err, isInternal)
+			if *balance != *money(expected) {
+				t.Fatalf("balance of %v is %v, expected %d", userID, balance, expected)
+			}
+		}
+	}
+
+	_, err, isInternal := balanceService.GetBalanceRequest(e.ctx, receiverID, "")
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	requireCachedBalance(userID, 1000)
+
+	e.credit(userID, 500)
+	requireCachedBalance(userID, 1500)
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(200)})
+	requireNoError(t, err, isInternal)
+	requireCachedBalance(userID, 1300)
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: receiverID, Sum: money(300)})
+	requireNoError(t, err, isInternal)
+	requireCachedBalance(userID, 1000)
+	requireCachedBalance(receiverID, 300)
+
+	err, isInternal = e.service.GetAdminOperationService().AdjustRequest(e.ctx, dto.AdminOperationRequest{UserId: userID, Sum: money(700), Reason: dto.AdminReasonCorrection})
+	requireNoError(t, err, isInternal)
+	requireCachedBalance(userID, 700)
+
+	// баланс, исправленный сверкой, тоже сбрасывается в кэше
+	corruptedID := e.newUser(500)
+	tx, err := e.storage.GetTransaction(e.ctx)
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+	if err := e.storage.GetLedgerStorage().AdjustBalance(tx, corruptedID, 500, 200, "manual edit", "test"); err != nil {
+		t.Fatalf("AdjustBalance: %v", err)
+	}
+	if err := tx.Commit(e.ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	requireCachedBalance(corruptedID, 200)
+
+	_, err, isInternal = e.service.GetLedgerService().ReconcileRequest(e.ctx, true, "restore")
+	requireNoError(t, err, isInternal)
+	requireCachedBalance(corruptedID, 500)
+	e.requireBalanced()
+}
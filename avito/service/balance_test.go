@@ -0,0 +1,373 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreditFunds(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 10, FracPart: 50}})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(userID, 1050)
+	e.requireBalance(e.system.Clearing, -1050)
+	e.requireBalanced()
+
+	balance, err, isInternal := e.service.GetBalanceService().GetBalanceRequest(e.ctx, userID, "")
+	requireNoError(t, err, isInternal)
+	if *balance != (dto.Money{IntPart: 10, FracPart: 50}) {
+		t.Fatalf("unexpected balance %v", balance)
+	}
+}
+
+func TestCreditFundsFromPromotion(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(300), Source: dto.CreditSourcePromotion})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(userID, 300)
+	e.requireBalance(e.system.Promotions, -300)
+	e.requireBalance(e.system.Clearing, 0)
+}
+
+func TestCreditFundsValidation(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(0)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: &dto.Money{IntPart: 1, FracPart: 100}})
+	requireUserError(t, err, isInternal, "frac_part must be between 0 and 99")
+
+	err, isInternal = balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(0)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	err, isInternal = balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100), Source: "bank"})
+	requireUserError(t, err, isInternal, "source must be external or promotion")
+
+	err, isInternal = balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: e.system.Revenue, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeForbidden)
+}
+
+// отрицательное зачисление отклоняется сервисом и не меняет ни одного счета
+func TestNegativeCredit(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+
+	err, isInternal := e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(-500)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	e.requireBalance(userID, 100)
+	e.requireBalance(e.system.Clearing, -100)
+	e.requireBalanced()
+}
+
+// проводка, уменьшающая счет пользователя зачислением или увеличивающая его списанием, не записывается:
+// служебные счета могут уходить в минус без ограничений, и такая проводка создала бы средства
+func TestCheckEntryDirections(t *testing.T) {
+	e := newTestEnv(t)
+	userID, otherID := uuid.New(), uuid.New()
+
+	for _, test := range []struct {
+		postings []storage.Posting
+		valid    bool
+	}{
+		{[]storage.Posting{{UserID: userID, Sum: 100, Operation: dto.OperationCredit}, {UserID: e.system.Clearing, Sum: -100, Operation: dto.OperationCredit}}, true},
+		{[]storage.Posting{{UserID: userID, Sum: -100, Operation: dto.OperationCredit}, {UserID: e.system.Clearing, Sum: 100, Operation: dto.OperationCredit}}, false},
+		{[]storage.Posting{{UserID: userID, Sum: -100, Operation: dto.OperationWithdraw}, {UserID: e.system.Revenue, Sum: 100, Operation: dto.OperationWithdraw}}, true},
+		{[]storage.Posting{{UserID: userID, Sum: 100, Operation: dto.OperationWithdraw}, {UserID: e.system.Revenue, Sum: -100, Operation: dto.OperationWithdraw}}, false},
+		{[]storage.Posting{{UserID: userID, Sum: 10, Operation: dto.OperationFee}, {UserID: e.system.Revenue, Sum: -10, Operation: dto.OperationFee}}, false},
+		{[]storage.Posting{{UserID: userID, Sum: -100, Operation: dto.OperationTransfer}, {UserID: otherID, Sum: 100, Operation: dto.OperationTransfer}}, true},
+		{[]storage.Posting{{UserID: userID, Sum: 0, Operation: dto.OperationTransfer}, {UserID: otherID, Sum: 0, Operation: dto.OperationTransfer}}, false},
+	} {
+		err := e.system.checkEntry(storage.JournalEntry{Postings: test.postings})
+		if (err == nil) != test.valid {
+			t.Fatalf("postings %+v: expected valid %v, got %v", test.postings, test.valid, err)
+		}
+	}
+}
+
+func TestWithdrawFunds(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+
+	err, isInternal := e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(250)})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(userID, 750)
+	e.requireBalance(e.system.Revenue, 250)
+	e.requireBalanced()
+}
+
+func TestWithdrawFundsErrors(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: uuid.New(), Sum: money(100)})
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100), Source: dto.CreditSourcePromotion})
+	requireUserError(t, err, isInternal, "source is allowed only for credit")
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(101)})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: e.system.Clearing, Sum: money(1)})
+	requireCode(t, err, isInternal, dto.ErrCodeForbidden)
+
+	e.requireBalance(userID, 100)
+}
+
+func TestWithdrawWithinCreditLimit(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(100)
+
+	err, isInternal := e.service.GetAccountService().SetCreditLimitRequest(e.ctx, dto.SetCreditLimitRequest{UserId: userID, CreditLimit: money(500)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(600)})
+	requireNoError(t, err, isInternal)
+	e.requireBalance(userID, -500)
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(1)})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	accounts, err, isInternal := e.service.GetAccountService().GetOverdraftAccountsRequest(e.ctx, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(accounts) != 1 || accounts[0].UserId != userID || *accounts[0].Sum != *money(-500) {
+		t.Fatalf("unexpected overdraft accounts %+v", accounts)
+	}
+	e.requireBalanced()
+}
+
+func TestTransferFunds(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(1000)
+	receiverID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(400)})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(senderID, 600)
+	e.requireBalance(receiverID, 400)
+	e.requireBalanced()
+}
+
+func TestTransferFundsErrors(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(100)
+	receiverID := e.newUser(0)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: senderID, Sum: money(10)})
+	requireUserError(t, err, isInternal, "ReceiverID and senderID cannot be equal")
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: uuid.New(), IdReceiver: receiverID, Sum: money(10)})
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(101)})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: e.system.Escrow, Sum: money(10)})
+	requireCode(t, err, isInternal, dto.ErrCodeForbidden)
+
+	e.requireBalance(senderID, 100)
+	e.requireBalance(receiverID, 0)
+}
+
+func TestTransferFee(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Fees.Tiers = []config.FeeTier{
+			{UpTo: 1000, Fixed: 10},
+			{Percent: 1, Min: 20, Max: 500},
+		}
+	})
+	senderID := e.newUser(100000)
+	receiverID := e.newUser(0)
+
+	quote, err, isInternal := e.service.GetFeeService().QuoteTransferRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(5000)})
+	requireNoError(t, err, isInternal)
+	if *quote.Fee != *money(50) || *quote.Total != *money(5050) {
+		t.Fatalf("unexpected quote %+v", quote)
+	}
+
+	for _, sum := range []int64{500, 5000, 100000 - 510 - 5050 - 500} {
+		err, isInternal = e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(sum)})
+		requireNoError(t, err, isInternal)
+	}
+
+	// 10 за первый перевод, 1% от второго и максимум 500 за третий
+	e.requireBalance(e.system.Revenue, 10+50+500)
+	e.requireBalance(senderID, 0)
+	e.requireBalanced()
+
+	err, isInternal = e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: receiverID, IdReceiver: senderID, Sum: money(e.balance(receiverID))})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+}
+
+func TestGetTransactions(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	otherID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+	err, isInternal = e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(200)})
+	requireNoError(t, err, isInternal)
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(transactions) != 3 {
+		t.Fatalf("expected 3 transactions, got %d", len(transactions))
+	}
+
+	operations := map[string]int64{}
+	for _, transaction := range transactions {
+		if transaction.UserID != userID {
+			t.Fatalf("transaction %v belongs to user %v", transaction.Id, transaction.UserID)
+		}
+		operations[transaction.Operation] += transaction.ChangeBalance.IntPart*100 + transaction.ChangeBalance.FracPart
+	}
+	if operations[dto.OperationCredit] != 1000 || operations[dto.OperationWithdraw] != -100 || operations[dto.OperationTransfer] != -200 {
+		t.Fatalf("unexpected transactions %+v", transactions)
+	}
+
+	page, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 2, 2)
+	requireNoError(t, err, isInternal)
+	if len(page) != 1 || page[0].Id != transactions[2].Id {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	_, err, isInternal = e.service.GetTransactionService().GetTransactionsRequest(e.ctx, uuid.New(), 10, 0)
+	requireUserError(t, err, isInternal, "User does not exist")
+}
+
+func TestTransactionComment(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().CreditFundsRequest(withTransactionComment(e.ctx, "bonus"), dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(transactions) != 1 || transactions[0].Comment != "bonus" {
+		t.Fatalf("unexpected transactions %+v", transactions)
+	}
+}
+
+func TestConcurrentTransfers(t *testing.T) {
+	e := newTestEnv(t)
+	users := make([]uuid.UUID, 5)
+	for i := range users {
+		users[i] = e.newUser(1000)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			request := dto.TransferFundsRequest{IdSender: users[i%len(users)], IdReceiver: users[(i+1)%len(users)], Sum: money(int64(100 + i))}
+			err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, request)
+			if err != nil && isInternal {
+				t.Errorf("transfer %d failed: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var total int64
+	for _, userID := range users {
+		balance := e.balance(userID)
+		if balance < 0 {
+			t.Fatalf("balance of %v became negative: %d", userID, balance)
+		}
+		total += balance
+	}
+	if total != 5000 {
+		t.Fatalf("total of user balances is %d, expected 5000", total)
+	}
+	e.requireBalanced()
+}
+
+func TestTxHookRollback(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(500)
+
+	hookErr := newServiceError(dto.ErrCodeInvalidState, "rejected by hook")
+	err, isInternal := e.service.GetBalanceService().WithdrawFundsRequestWithHook(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(200)}, func(tx storage.Tx) error {
+		return hookErr
+	})
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+
+	e.requireBalance(userID, 500)
+	e.requireBalance(e.system.Revenue, 0)
+}
+
+// replicaStorage читает баланс и историю из отдельного хранилища, пока реплика доступна
+type replicaStorage struct {
+	storage.StorageAPI
+	replica   storage.StorageAPI
+	available bool
+}
+
+func (s *replicaStorage) GetReadStorage(strong bool) (storage.ReadStorageAPI, bool) {
+	if strong || !s.available {
+		return s.StorageAPI.GetReadStorage(strong)
+	}
+
+	reads, _ := s.replica.GetReadStorage(false)
+	return reads, true
+}
+
+func TestReadConsistency(t *testing.T) {
+	primary, replica := newTestEnv(t), newTestEnv(t)
+	userID := primary.newUser(1000)
+	// реплика отстает: на ней другие движения и баланс
+	replica.credit(userID, 200)
+	replica.credit(userID, 200)
+
+	api := &replicaStorage{StorageAPI: primary.storage, replica: replica.storage, available: true}
+	conf := testConfig()
+	conf.BalanceCache = config.BalanceCacheConfig{Enabled: true, Size: 10, TTL: time.Hour}
+	serviceAPI := NewServiceAPI(api, conf)
+	strong := WithStrongConsistency(primary.ctx)
+
+	requireRead := func(ctx context.Context, balance int64, transactions int) {
+		t.Helper()
+		result, err, isInternal := serviceAPI.GetBalanceService().GetBalanceRequest(ctx, userID, "")
+		requireNoError(t, err, isInternal)
+		if *result != *money(balance) {
+			t.Fatalf("balance is %v, expected %d", result, balance)
+		}
+
+		rows, err, isInternal := serviceAPI.GetTransactionService().GetTransactionsRequest(ctx, userID, 10, 0)
+		requireNoError(t, err, isInternal)
+		if len(rows) != transactions {
+			t.Fatalf("got %d transactions, expected %d", len(rows), transactions)
+		}
+	}
+
+	requireRead(primary.ctx, 400, 2)
+	requireRead(strong, 1000, 1)
+
+	// баланс с реплики не кэшируется, поэтому без нее читается из основной базы
+	api.available = false
+	requireRead(primary.ctx, 1000, 1)
+
+	// баланс основной базы закэширован и читается раньше реплики
+	api.available = true
+	requireRead(primary.ctx, 1000, 2)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkUsers - число счетов, между которыми распределяются операции параллельных бенчмарков
+const benchmarkUsers = 100
+
+func newBenchmarkUsers(e *testEnv, sum int64) []uuid.UUID {
+	users := make([]uuid.UUID, benchmarkUsers)
+	for i := range users {
+		users[i] = e.newUser(sum)
+	}
+
+	return users
+}
+
+func BenchmarkCredit(b *testing.B) {
+	e := newTestEnv(b)
+	userID := e.newUser(0)
+	request := dto.OperationRequest{UserId: userID, Sum: money(100)}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err, isInternal := e.service.GetBalanceService().CreditFundsRequest(e.ctx, request)
+		requireNoError(b, err, isInternal)
+	}
+}
+
+func BenchmarkWithdraw(b *testing.B) {
+	e := newTestEnv(b)
+	userID := e.newUser(int64(b.N) * 100)
+	request := dto.OperationRequest{UserId: userID, Sum: money(100)}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err, isInternal := e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, request)
+		requireNoError(b, err, isInternal)
+	}
+}
+
+func BenchmarkTransfer(b *testing.B) {
+	e := newTestEnv(b)
+	senderID := e.newUser(int64(b.N) * 100)
+	request := dto.TransferFundsRequest{IdSender: senderID, IdReceiver: e.newUser(0), Sum: money(100)}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, request)
+		requireNoError(b, err, isInternal)
+	}
+}
+
+func BenchmarkGetBalance(b *testing.B) {
+	e := newTestEnv(b)
+	userID := e.newUser(100)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err, isInternal := e.service.GetBalanceService().GetBalanceRequest(e.ctx, userID, "")
+		requireNoError(b, err, isInternal)
+	}
+}
+
+func BenchmarkGetTransactions(b *testing.B) {
+	e := newTestEnv(b)
+	userID := e.newUser(0)
+	for i := 0; i < 1000; i++ {
+		e.credit(userID, 100)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, userID, 100, 0)
+		requireNoError(b, err, isInternal)
+	}
+}
+
+// BenchmarkTransferParallel выполняет переводы между случайными счетами из нескольких горутин
+func BenchmarkTransferParallel(b *testing.B) {
+	e := newTestEnv(b)
+	users := newBenchmarkUsers(e, 1000000000)
+	var seed int64
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+		for pb.Next() {
+			sender, receiver := rnd.Intn(len(users)), rnd.Intn(len(users)-1)
+			if receiver >= sender {
+				receiver++
+			}
+
+			err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: users[sender], IdReceiver: users[receiver], Sum: money(100)})
+			requireNoError(b, err, isInternal)
+		}
+	})
+	b.StopTimer()
+
+	e.requireBalanced()
+}
+
+// BenchmarkMixedParallel выполняет смесь операций в пропорции генератора нагрузки по умолчанию:
+// 20% зачислений, 20% списаний, 40% переводов и 20% запросов баланса
+func BenchmarkMixedParallel(b *testing.B) {
+	e := newTestEnv(b)
+	users := newBenchmarkUsers(e, 1000000000)
+	balanceService := e.service.GetBalanceService()
+	var seed int64
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+		for pb.Next() {
+			userID := users[rnd.Intn(len(users))]
+
+			var err error
+			var isInternal bool
+			switch n := rnd.Intn(10); {
+			case n < 2:
+				err, isInternal = balanceService.CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+			case n < 4:
+				err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+			case n < 8:
+				receiverID := users[rnd.Intn(len(users))]
+				if receiverID == userID {
+					continue
+				}
+				err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: receiverID, Sum: money(100)})
+			default:
+				_, err, isInternal = balanceService.GetBalanceRequest(e.ctx, userID, "")
+			}
+			requireNoError(b, err, isInternal)
+		}
+	})
+	b.StopTimer()
+
+	e.requireBalanced()
+}
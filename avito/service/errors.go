@@ -0,0 +1,15 @@
+package service
+
+// ServiceError - пользовательская ошибка с кодом, который handlers передают в ответе
+type ServiceError struct {
+	Code    string
+	Message string
+}
+
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+func newServiceError(code string, message string) *ServiceError {
+	return &ServiceError{Code: code, Message: message}
+}
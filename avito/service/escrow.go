@@ -0,0 +1,225 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type EscrowServiceAPI interface {
+	// FundEscrowRequest переводит средства плательщика на счет сделок
+	FundEscrowRequest(ctx context.Context, fundEscrowRequest dto.FundEscrowRequest) (*dto.Escrow, error, bool)
+	// ReleaseEscrowRequest переводит средства сделки получателю
+	ReleaseEscrowRequest(ctx context.Context, escrowActionRequest dto.EscrowActionRequest) (error, bool)
+	// CancelEscrowRequest возвращает средства сделки плательщику
+	CancelEscrowRequest(ctx context.Context, escrowActionRequest dto.EscrowActionRequest) (error, bool)
+	GetEscrowRequest(ctx context.Context, dealID string) (*dto.Escrow, error, bool)
+	// Start возвращает плательщикам средства просроченных сделок каждые escrow.check_interval до отмены ctx
+	Start(ctx context.Context)
+}
+
+type escrowService struct {
+	storage   storage.StorageAPI
+	balance   BalanceServiceAPI
+	accounts  SystemAccounts
+	conf      config.EscrowConfig
+	log       *logger.Logger
+}
+
+const expiredEscrowBatchSize = 100
+
+func NewEscrowServiceAPI(api storage.StorageAPI, balance BalanceServiceAPI, accounts SystemAccounts, conf config.EscrowConfig) EscrowServiceAPI {
+	return &escrowService{
+		storage:   api,
+		balance:   balance,
+		accounts:  accounts,
+		conf:      conf,
+		log:       logger.New("escrow-service"),
+	}
+}
+
+func (e *escrowService) FundEscrowRequest(ctx context.Context, fundEscrowRequest dto.FundEscrowRequest) (*dto.Escrow, error, bool) {
+	e.log.Infof(ctx, "Trying to fund escrow %v", fundEscrowRequest)
+
+	if fundEscrowRequest.DealId == "" {
+		return nil, xerrors.Errorf("deal_id cannot be empty"), false
+	}
+
+	if fundEscrowRequest.PayerId == fundEscrowRequest.PayeeId {
+		return nil, xerrors.Errorf("PayerID and payeeID cannot be equal"), false
+	}
+
+	if e.accounts.Contains(fundEscrowRequest.PayerId) || e.accounts.Contains(fundEscrowRequest.PayeeId) {
+		return nil, xerrors.Errorf("System account cannot be a party of the deal"), false
+	}
+
+	if fundEscrowRequest.Sum == nil {
+		return nil, xerrors.Errorf("amount cannot be empty"), false
+	}
+
+	if fundEscrowRequest.Sum.FracPart < 0 || fundEscrowRequest.Sum.FracPart > 99 {
+		return nil, xerrors.Errorf("frac_part must be between 0 and 99"), false
+	}
+
+	sum := fundEscrowRequest.Sum.IntPart*100 + fundEscrowRequest.Sum.FracPart
+	if sum <= 0 {
+		return nil, xerrors.Errorf("Sum must be positive"), false
+	}
+
+	expiresAt := time.Now().UTC().Add(e.conf.DefaultTTL)
+	if fundEscrowRequest.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, fundEscrowRequest.ExpiresAt)
+		if err != nil {
+			return nil, xerrors.Errorf("expires_at must be in RFC3339 format"), false
+		}
+		if !t.After(time.Now()) {
+			return nil, xerrors.Errorf("expires_at must be in the future"), false
+		}
+		expiresAt = t.UTC()
+	}
+
+	escrow := storage.Escrow{
+		DealID:  fundEscrowRequest.DealId,
+		PayerID: fundEscrowRequest.PayerId,
+		PayeeID: fundEscrowRequest.PayeeId,
+		Sum:     sum,
+		Status:  dto.EscrowFunded,
+	}
+
+	hook := func(tx storage.Tx) error {
+		_, err := e.storage.GetEscrowStorage().LockEscrow(tx, escrow.DealID)
+		if err == nil {
+			return newServiceError(dto.ErrCodeInvalidState, "Escrow for this deal already exists")
+		}
+		if err != storage.ErrNoRows {
+			return err
+		}
+
+		id, err := e.storage.GetEscrowStorage().CreateEscrow(tx, escrow, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		return e.storage.GetEscrowStorage().WriteEscrowEvent(tx, id, dto.EscrowFunded, "", auth.ClientName(ctx))
+	}
+
+	transferFundsRequest := dto.TransferFundsRequest{IdSender: escrow.PayerID, IdReceiver: e.accounts.Escrow, Sum: fundEscrowRequest.Sum}
+	err, isInternal := e.balance.TransferFundsRequestWithHook(withSystemOperation(ctx), transferFundsRequest, hook)
+	if err != nil {
+		if isInternal {
+			e.log.Errorf(ctx, "Error while fund escrow %s, reason: %v", escrow.DealID, err)
+			return nil, xerrors.Errorf("System error. Contact support"), true
+		}
+		return nil, err, false
+	}
+
+	return e.GetEscrowRequest(ctx, escrow.DealID)
+}
+
+func (e *escrowService) ReleaseEscrowRequest(ctx context.Context, escrowActionRequest dto.EscrowActionRequest) (error, bool) {
+	e.log.Infof(ctx, "Trying to release escrow %v", escrowActionRequest)
+
+	return e.settle(ctx, escrowActionRequest.DealId, dto.EscrowReleased, escrowActionRequest.Comment)
+}
+
+func (e *escrowService) CancelEscrowRequest(ctx context.Context, escrowActionRequest dto.EscrowActionRequest) (error, bool) {
+	e.log.Infof(ctx, "Trying to cancel escrow %v", escrowActionRequest)
+
+	return e.settle(ctx, escrowActionRequest.DealId, dto.EscrowCancelled, escrowActionRequest.Comment)
+}
+
+func (e *escrowService) GetEscrowRequest(ctx context.Context, dealID string) (*dto.Escrow, error, bool) {
+	escrow, err := e.storage.GetEscrowStorage().GetEscrow(dealID)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("Escrow does not exist"), false
+	}
+	if err != nil {
+		e.log.Errorf(ctx, "Error while get escrow from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return escrow, nil, false
+}
+
+func (e *escrowService) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.conf.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.expireDue(ctx)
+		}
+	}
+}
+
+func (e *escrowService) expireDue(ctx context.Context) {
+	dealIDs, err := e.storage.GetEscrowStorage().GetExpiredEscrows(time.Now().UTC(), expiredEscrowBatchSize)
+	if err != nil {
+		e.log.Errorf(ctx, "Error while get expired escrows from DB, reason: %v", err)
+		return
+	}
+
+	for _, dealID := range dealIDs {
+		err, _ := e.settle(ctx, dealID, dto.EscrowExpired, "Escrow has expired")
+		if err != nil {
+			e.log.Errorf(ctx, "Error while expire escrow %s, reason: %v", dealID, err)
+		}
+	}
+}
+
+// settle завершает сделку: released переводит средства получателю, cancelled и expired - плательщику.
+// Статус сделки меняется в транзакции перевода, поэтому средства сделки не могут уйти дважды
+func (e *escrowService) settle(ctx context.Context, dealID string, status string, comment string) (error, bool) {
+	escrow, err, isInternal := e.GetEscrowRequest(ctx, dealID)
+	if err != nil {
+		return err, isInternal
+	}
+
+	if escrow.Status != dto.EscrowFunded {
+		return newServiceError(dto.ErrCodeInvalidState, "Escrow is already "+escrow.Status), false
+	}
+
+	receiverID := escrow.PayerId
+	if status == dto.EscrowReleased {
+		receiverID = escrow.PayeeId
+	}
+
+	hook := func(tx storage.Tx) error {
+		locked, err := e.storage.GetEscrowStorage().LockEscrow(tx, dealID)
+		if err != nil {
+			return err
+		}
+
+		if locked.Status != dto.EscrowFunded {
+			return newServiceError(dto.ErrCodeInvalidState, "Escrow is already "+locked.Status)
+		}
+
+		err = e.storage.GetEscrowStorage().UpdateEscrowStatus(tx, locked.Id, status)
+		if err != nil {
+			return err
+		}
+
+		return e.storage.GetEscrowStorage().WriteEscrowEvent(tx, locked.Id, status, comment, auth.ClientName(ctx))
+	}
+
+	transferFundsRequest := dto.TransferFundsRequest{IdSender: e.accounts.Escrow, IdReceiver: receiverID, Sum: escrow.Sum}
+	err, isInternal = e.balance.TransferFundsRequestWithHook(withSystemOperation(ctx), transferFundsRequest, hook)
+	if err != nil {
+		if isInternal {
+			e.log.Errorf(ctx, "Error while settle escrow %s, reason: %v", dealID, err)
+			return xerrors.Errorf("System error. Contact support"), true
+		}
+		return err, false
+	}
+
+	return nil, false
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func (e *testEnv) fundEscrow(dealID string, payerID uuid.UUID, payeeID uuid.UUID, sum int64) *dto.Escrow {
+	e.t.Helper()
+	escrow, err, isInternal := e.service.GetEscrowService().FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: dealID, PayerId: payerID, PayeeId: payeeID, Sum: money(sum)})
+	requireNoError(e.t, err, isInternal)
+
+	return escrow
+}
+
+func TestEscrowRelease(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	payeeID := e.newUser(0)
+
+	escrow := e.fundEscrow("deal-1", payerID, payeeID, 600)
+	if escrow.Status != dto.EscrowFunded || *escrow.Sum != *money(600) || len(escrow.History) != 1 {
+		t.Fatalf("unexpected escrow %+v", escrow)
+	}
+	e.requireBalance(payerID, 400)
+	e.requireBalance(e.system.Escrow, 600)
+
+	err, isInternal := e.service.GetEscrowService().ReleaseEscrowRequest(e.ctx, dto.EscrowActionRequest{DealId: "deal-1", Comment: "delivered"})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(payeeID, 600)
+	e.requireBalance(e.system.Escrow, 0)
+	e.requireBalanced()
+
+	escrow, err, isInternal = e.service.GetEscrowService().GetEscrowRequest(e.ctx, "deal-1")
+	requireNoError(t, err, isInternal)
+	if escrow.Status != dto.EscrowReleased || len(escrow.History) != 2 || escrow.History[1].Comment != "delivered" {
+		t.Fatalf("unexpected escrow %+v", escrow)
+	}
+
+	err, isInternal = e.service.GetEscrowService().CancelEscrowRequest(e.ctx, dto.EscrowActionRequest{DealId: "deal-1"})
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+	e.requireBalance(payeeID, 600)
+}
+
+func TestEscrowCancel(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	payeeID := e.newUser(0)
+	e.fundEscrow("deal-1", payerID, payeeID, 600)
+
+	err, isInternal := e.service.GetEscrowService().CancelEscrowRequest(e.ctx, dto.EscrowActionRequest{DealId: "deal-1"})
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(payerID, 1000)
+	e.requireBalance(payeeID, 0)
+	e.requireBalance(e.system.Escrow, 0)
+
+	err, isInternal = e.service.GetEscrowService().ReleaseEscrowRequest(e.ctx, dto.EscrowActionRequest{DealId: "deal-1"})
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+}
+
+func TestEscrowFundErrors(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	payeeID := e.newUser(0)
+	escrowService := e.service.GetEscrowService()
+	e.fundEscrow("deal-1", payerID, payeeID, 100)
+
+	_, err, isInternal := escrowService.FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "deal-1", PayerId: payerID, PayeeId: payeeID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+
+	_, err, isInternal = escrowService.FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "deal-2", PayerId: payerID, PayeeId: payeeID, Sum: money(1000)})
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	_, err, isInternal = escrowService.FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "deal-2", PayerId: payerID, PayeeId: e.system.Revenue, Sum: money(100)})
+	requireUserError(t, err, isInternal, "System account cannot be a party of the deal")
+
+	_, err, isInternal = escrowService.FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "deal-2", PayerId: payerID, PayeeId: payeeID, Sum: money(100), ExpiresAt: "tomorrow"})
+	requireUserError(t, err, isInternal, "expires_at must be in RFC3339 format")
+
+	_, err, isInternal = escrowService.FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "", PayerId: payerID, PayeeId: payeeID, Sum: money(100)})
+	requireUserError(t, err, isInternal, "deal_id cannot be empty")
+
+	_, err, isInternal = escrowService.GetEscrowRequest(e.ctx, "deal-2")
+	requireUserError(t, err, isInternal, "Escrow does not exist")
+
+	e.requireBalance(payerID, 900)
+	e.requireBalance(e.system.Escrow, 100)
+}
+
+func TestEscrowExpire(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Escrow.DefaultTTL = -time.Minute
+	})
+	payerID := e.newUser(1000)
+	payeeID := e.newUser(0)
+	e.fundEscrow("deal-1", payerID, payeeID, 600)
+
+	_, err, isInternal := e.service.GetEscrowService().FundEscrowRequest(e.ctx, dto.FundEscrowRequest{DealId: "deal-2", PayerId: payerID, PayeeId: payeeID, Sum: money(100), ExpiresAt: time.Now().Add(time.Hour).Format(time.RFC3339)})
+	requireNoError(t, err, isInternal)
+
+	e.service.GetEscrowService().(*escrowService).expireDue(e.ctx)
+
+	escrow, err, isInternal := e.service.GetEscrowService().GetEscrowRequest(e.ctx, "deal-1")
+	requireNoError(t, err, isInternal)
+	if escrow.Status != dto.EscrowExpired {
+		t.Fatalf("expected expired escrow, got %+v", escrow)
+	}
+
+	escrow, err, isInternal = e.service.GetEscrowService().GetEscrowRequest(e.ctx, "deal-2")
+	requireNoError(t, err, isInternal)
+	if escrow.Status != dto.EscrowFunded {
+		t.Fatalf("expected funded escrow, got %+v", escrow)
+	}
+
+	e.requireBalance(payerID, 900)
+	e.requireBalance(e.system.Escrow, 100)
+	e.requireBalanced()
+}
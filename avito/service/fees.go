@@ -0,0 +1,95 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"math"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type FeeServiceAPI interface {
+	// QuoteTransferRequest показывает комиссию и итоговую сумму списания до перевода
+	QuoteTransferRequest(ctx context.Context, quoteRequest dto.TransferFundsRequest) (*dto.TransferQuote, error, bool)
+	// TransferFee возвращает комиссию в копейках за перевод sum от senderID к receiverID
+	TransferFee(senderID uuid.UUID, receiverID uuid.UUID, sum int64) int64
+	RevenueAccountID() uuid.UUID
+}
+
+type feeService struct {
+	conf      config.FeesConfig
+	revenueID uuid.UUID
+	// служебные счета, переводы с которых и на которые не облагаются комиссией
+	exempt map[uuid.UUID]bool
+}
+
+func NewFeeServiceAPI(conf config.FeesConfig, revenueID uuid.UUID, exempt ...uuid.UUID) FeeServiceAPI {
+	f := &feeService{
+		conf:      conf,
+		revenueID: revenueID,
+		exempt:    map[uuid.UUID]bool{revenueID: true},
+	}
+	for _, id := range exempt {
+		f.exempt[id] = true
+	}
+
+	return f
+}
+
+func (f *feeService) QuoteTransferRequest(ctx context.Context, quoteRequest dto.TransferFundsRequest) (*dto.TransferQuote, error, bool) {
+	if quoteRequest.Sum == nil {
+		return nil, xerrors.Errorf("amount cannot be empty"), false
+	}
+
+	if quoteRequest.Sum.FracPart < 0 || quoteRequest.Sum.FracPart > 99 {
+		return nil, xerrors.Errorf("frac_part must be between 0 and 99"), false
+	}
+
+	if quoteRequest.IdReceiver == quoteRequest.IdSender {
+		return nil, xerrors.Errorf("ReceiverID and senderID cannot be equal"), false
+	}
+
+	sum := quoteRequest.Sum.IntPart*100 + quoteRequest.Sum.FracPart
+	if sum <= 0 {
+		return nil, xerrors.Errorf("Sum must be positive"), false
+	}
+
+	fee := f.TransferFee(quoteRequest.IdSender, quoteRequest.IdReceiver, sum)
+	total := sum + fee
+
+	return &dto.TransferQuote{
+		Sum:   &dto.Money{IntPart: sum / 100, FracPart: sum % 100},
+		Fee:   &dto.Money{IntPart: fee / 100, FracPart: fee % 100},
+		Total: &dto.Money{IntPart: total / 100, FracPart: total % 100},
+	}, nil, false
+}
+
+func (f *feeService) TransferFee(senderID uuid.UUID, receiverID uuid.UUID, sum int64) int64 {
+	if f.exempt[senderID] || f.exempt[receiverID] {
+		return 0
+	}
+
+	for _, tier := range f.conf.Tiers {
+		if tier.UpTo != 0 && sum > tier.UpTo {
+			continue
+		}
+
+		fee := tier.Fixed + int64(math.Round(float64(sum)*tier.Percent/100))
+		if fee < tier.Min {
+			fee = tier.Min
+		}
+		if tier.Max > 0 && fee > tier.Max {
+			fee = tier.Max
+		}
+
+		return fee
+	}
+
+	return 0
+}
+
+func (f *feeService) RevenueAccountID() uuid.UUID {
+	return f.revenueID
+}
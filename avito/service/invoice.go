@@ -0,0 +1,299 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type InvoiceServiceAPI interface {
+	CreateInvoiceRequest(ctx context.Context, createInvoiceRequest dto.CreateInvoiceRequest) (*dto.Invoice, error, bool)
+	// PayInvoiceRequest списывает сумму счета с баланса плательщика одной транзакцией
+	PayInvoiceRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	CancelInvoiceRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	GetInvoiceRequest(ctx context.Context, id uuid.UUID) (*dto.Invoice, error, bool)
+	GetInvoicesRequest(ctx context.Context, payerID uuid.UUID, status string, limit int, offset int) ([]dto.Invoice, error, bool)
+	// Start переводит просроченные счета в expired каждые invoice.check_interval до отмены ctx
+	Start(ctx context.Context)
+}
+
+type invoiceService struct {
+	storage storage.StorageAPI
+	balance BalanceServiceAPI
+	conf    config.InvoiceConfig
+	log     *logger.Logger
+}
+
+func NewInvoiceServiceAPI(api storage.StorageAPI, balance BalanceServiceAPI, conf config.InvoiceConfig) InvoiceServiceAPI {
+	return &invoiceService{
+		storage: api,
+		balance: balance,
+		conf:    conf,
+		log:     logger.New("invoice-service"),
+	}
+}
+
+func (i *invoiceService) CreateInvoiceRequest(ctx context.Context, createInvoiceRequest dto.CreateInvoiceRequest) (*dto.Invoice, error, bool) {
+	i.log.Infof(ctx, "Trying to create invoice %v", createInvoiceRequest)
+
+	if createInvoiceRequest.PayerId == uuid.Nil {
+		return nil, xerrors.Errorf("payer_id cannot be empty"), false
+	}
+
+	sum, err := invoiceKopecks(createInvoiceRequest.Sum)
+	if err != nil {
+		return nil, err, false
+	}
+	if sum <= 0 {
+		return nil, xerrors.Errorf("Sum must be positive"), false
+	}
+
+	dueAt, err := time.Parse(time.RFC3339, createInvoiceRequest.DueAt)
+	if err != nil {
+		return nil, xerrors.Errorf("due_at must be in RFC3339 format"), false
+	}
+	if !dueAt.After(time.Now()) {
+		return nil, xerrors.Errorf("due_at must be in the future"), false
+	}
+
+	invoice := storage.Invoice{
+		PayerID:     createInvoiceRequest.PayerId,
+		Sum:         sum,
+		Description: createInvoiceRequest.Description,
+		DueAt:       dueAt.UTC(),
+		Items:       make([]storage.InvoiceItem, 0, len(createInvoiceRequest.Items)),
+		Client:      auth.ClientName(ctx),
+	}
+
+	var itemsTotal int64
+	for _, item := range createInvoiceRequest.Items {
+		if item.Description == "" {
+			return nil, xerrors.Errorf("Item description cannot be empty"), false
+		}
+		if item.Quantity <= 0 {
+			return nil, xerrors.Errorf("Item quantity must be positive"), false
+		}
+
+		price, err := invoiceKopecks(item.Price)
+		if err != nil {
+			return nil, err, false
+		}
+		if price < 0 {
+			return nil, xerrors.Errorf("Item price cannot be negative"), false
+		}
+
+		itemsTotal += price * int64(item.Quantity)
+		invoice.Items = append(invoice.Items, storage.InvoiceItem{Description: item.Description, Quantity: item.Quantity, Price: price})
+	}
+
+	if len(invoice.Items) > 0 && itemsTotal != sum {
+		return nil, xerrors.Errorf("Sum of items does not match amount"), false
+	}
+
+	tx, err := i.storage.GetTransaction(ctx)
+	if err != nil {
+		i.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	id, err := i.storage.GetInvoiceStorage().CreateInvoice(tx, invoice)
+	if err != nil {
+		i.log.Errorf(ctx, "Error while create invoice in DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		i.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return i.GetInvoiceRequest(ctx, id)
+}
+
+func (i *invoiceService) PayInvoiceRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	i.log.Infof(ctx, "Trying to pay invoice %v", id)
+
+	invoice, err := i.storage.GetInvoiceStorage().GetInvoice(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Invoice does not exist"), false
+	}
+	if err != nil {
+		i.log.Errorf(ctx, "Error while get invoice from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if err := checkInvoicePayable(invoice); err != nil {
+		return err, false
+	}
+
+	hook := func(tx storage.Tx) error {
+		locked, err := i.storage.GetInvoiceStorage().LockInvoice(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkInvoicePayable(locked); err != nil {
+			return err
+		}
+
+		return i.storage.GetInvoiceStorage().SetInvoiceStatus(tx, id, dto.InvoicePaid)
+	}
+
+	// транзакция списания ссылается на счет через комментарий
+	payCtx := withTransactionComment(ctx, "invoice:"+id.String())
+	withdrawFundsRequest := dto.OperationRequest{UserId: invoice.PayerID, Sum: &dto.Money{IntPart: invoice.Sum / 100, FracPart: invoice.Sum % 100}}
+	err, isInternal := i.balance.WithdrawFundsRequestWithHook(payCtx, withdrawFundsRequest, hook)
+	if err != nil {
+		if isInternal {
+			i.log.Errorf(ctx, "Error while pay invoice %v, reason: %v", id, err)
+			return xerrors.Errorf("System error. Contact support"), true
+		}
+		return err, false
+	}
+
+	return nil, false
+}
+
+func (i *invoiceService) CancelInvoiceRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	i.log.Infof(ctx, "Trying to cancel invoice %v", id)
+
+	invoice, err := i.storage.GetInvoiceStorage().GetInvoice(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Invoice does not exist"), false
+	}
+	if err != nil {
+		i.log.Errorf(ctx, "Error while get invoice from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	count, err := i.storage.GetInvoiceStorage().CancelInvoice(id)
+	if err != nil {
+		i.log.Errorf(ctx, "Error while cancel invoice in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count == 0 {
+		return newServiceError(dto.ErrCodeInvalidState, "Invoice is already "+invoice.Status), false
+	}
+
+	return nil, false
+}
+
+func (i *invoiceService) GetInvoiceRequest(ctx context.Context, id uuid.UUID) (*dto.Invoice, error, bool) {
+	invoice, err := i.storage.GetInvoiceStorage().GetInvoice(id)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("Invoice does not exist"), false
+	}
+	if err != nil {
+		i.log.Errorf(ctx, "Error while get invoice from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := invoiceToDTO(*invoice)
+	return &result, nil, false
+}
+
+func (i *invoiceService) GetInvoicesRequest(ctx context.Context, payerID uuid.UUID, status string, limit int, offset int) ([]dto.Invoice, error, bool) {
+	switch status {
+	case "", dto.InvoiceOpen, dto.InvoicePaid, dto.InvoiceCancelled, dto.InvoiceExpired:
+	default:
+		return nil, xerrors.Errorf("Unknown invoice status %s", status), false
+	}
+
+	invoices, err := i.storage.GetInvoiceStorage().GetInvoices(payerID, status, limit, offset)
+	if err != nil {
+		i.log.Errorf(ctx, "Error while get invoices from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := make([]dto.Invoice, 0, len(invoices))
+	for _, invoice := range invoices {
+		result = append(result, invoiceToDTO(invoice))
+	}
+
+	return result, nil, false
+}
+
+func (i *invoiceService) Start(ctx context.Context) {
+	ticker := time.NewTicker(i.conf.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := i.storage.GetInvoiceStorage().ExpireInvoices(time.Now().UTC())
+			if err != nil {
+				i.log.Errorf(ctx, "Error while expire invoices in DB, reason: %v", err)
+				continue
+			}
+			if count > 0 {
+				i.log.Infof(ctx, "%d invoices have expired", count)
+			}
+		}
+	}
+}
+
+// checkInvoicePayable проверяет, что счет открыт и срок его оплаты не истек
+func checkInvoicePayable(invoice *storage.Invoice) error {
+	if invoice.Status != dto.InvoiceOpen {
+		return newServiceError(dto.ErrCodeInvalidState, "Invoice is already "+invoice.Status)
+	}
+
+	if !invoice.DueAt.After(time.Now().UTC()) {
+		return newServiceError(dto.ErrCodeInvalidState, "Invoice has expired")
+	}
+
+	return nil
+}
+
+func invoiceKopecks(money *dto.Money) (int64, error) {
+	if money == nil {
+		return 0, xerrors.Errorf("amount cannot be empty")
+	}
+
+	if money.FracPart < 0 || money.FracPart > 99 {
+		return 0, xerrors.Errorf("frac_part must be between 0 and 99")
+	}
+
+	return money.IntPart*100 + money.FracPart, nil
+}
+
+func invoiceToDTO(invoice storage.Invoice) dto.Invoice {
+	result := dto.Invoice{
+		Id:          invoice.Id,
+		PayerId:     invoice.PayerID,
+		Sum:         &dto.Money{IntPart: invoice.Sum / 100, FracPart: invoice.Sum % 100},
+		Description: invoice.Description,
+		DueAt:       invoice.DueAt.Format(time.RFC3339),
+		Status:      invoice.Status,
+		Items:       make([]dto.InvoiceItem, 0, len(invoice.Items)),
+		Client:      invoice.Client,
+		CreatedAt:   invoice.CreatedAt.Format(time.RFC3339),
+	}
+
+	for _, item := range invoice.Items {
+		result.Items = append(result.Items, dto.InvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			Price:       &dto.Money{IntPart: item.Price / 100, FracPart: item.Price % 100},
+		})
+	}
+
+	if invoice.PaidAt != nil {
+		paidAt := invoice.PaidAt.Format(time.RFC3339)
+		result.PaidAt = &paidAt
+	}
+
+	return result
+}
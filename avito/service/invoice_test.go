@@ -0,0 +1,147 @@
+package service
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func (e *testEnv) createInvoice(payerID uuid.UUID, sum int64) *dto.Invoice {
+	e.t.Helper()
+	invoice, err, isInternal := e.service.GetInvoiceService().CreateInvoiceRequest(e.ctx, dto.CreateInvoiceRequest{
+		PayerId: payerID,
+		Sum:     money(sum),
+		DueAt:   time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	requireNoError(e.t, err, isInternal)
+
+	return invoice
+}
+
+func TestCreateInvoiceWithItems(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(0)
+	dueAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	invoice, err, isInternal := e.service.GetInvoiceService().CreateInvoiceRequest(e.ctx, dto.CreateInvoiceRequest{
+		PayerId: payerID,
+		Sum:     money(1250),
+		DueAt:   dueAt,
+		Items: []dto.InvoiceItem{
+			{Description: "subscription", Quantity: 2, Price: money(500)},
+			{Description: "delivery", Quantity: 1, Price: money(250)},
+		},
+	})
+	requireNoError(t, err, isInternal)
+	if invoice.Status != dto.InvoiceOpen || len(invoice.Items) != 2 || invoice.PaidAt != nil {
+		t.Fatalf("unexpected invoice %+v", invoice)
+	}
+
+	_, err, isInternal = e.service.GetInvoiceService().CreateInvoiceRequest(e.ctx, dto.CreateInvoiceRequest{
+		PayerId: payerID,
+		Sum:     money(1000),
+		DueAt:   dueAt,
+		Items:   []dto.InvoiceItem{{Description: "subscription", Quantity: 3, Price: money(500)}},
+	})
+	requireUserError(t, err, isInternal, "Sum of items does not match amount")
+
+	_, err, isInternal = e.service.GetInvoiceService().CreateInvoiceRequest(e.ctx, dto.CreateInvoiceRequest{PayerId: payerID, Sum: money(1000), DueAt: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+	requireUserError(t, err, isInternal, "due_at must be in the future")
+
+	_, err, isInternal = e.service.GetInvoiceService().CreateInvoiceRequest(e.ctx, dto.CreateInvoiceRequest{PayerId: payerID, DueAt: dueAt})
+	requireUserError(t, err, isInternal, "amount cannot be empty")
+}
+
+func TestPayInvoice(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	invoice := e.createInvoice(payerID, 700)
+
+	err, isInternal := e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, invoice.Id)
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(payerID, 300)
+	e.requireBalance(e.system.Revenue, 700)
+
+	paid, err, isInternal := e.service.GetInvoiceService().GetInvoiceRequest(e.ctx, invoice.Id)
+	requireNoError(t, err, isInternal)
+	if paid.Status != dto.InvoicePaid || paid.PaidAt == nil {
+		t.Fatalf("unexpected invoice %+v", paid)
+	}
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, payerID, 1, 0)
+	requireNoError(t, err, isInternal)
+	if transactions[0].Operation != dto.OperationWithdraw || transactions[0].Comment != "invoice:"+invoice.Id.String() {
+		t.Fatalf("unexpected transaction %+v", transactions[0])
+	}
+
+	err, isInternal = e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, invoice.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+	e.requireBalance(payerID, 300)
+}
+
+func TestPayInvoiceInsufficientFunds(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(100)
+	invoice := e.createInvoice(payerID, 700)
+
+	err, isInternal := e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, invoice.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	unpaid, err, isInternal := e.service.GetInvoiceService().GetInvoiceRequest(e.ctx, invoice.Id)
+	requireNoError(t, err, isInternal)
+	if unpaid.Status != dto.InvoiceOpen {
+		t.Fatalf("unexpected invoice %+v", unpaid)
+	}
+
+	err, isInternal = e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, uuid.New())
+	requireUserError(t, err, isInternal, "Invoice does not exist")
+}
+
+func TestCancelInvoice(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	invoice := e.createInvoice(payerID, 700)
+
+	err, isInternal := e.service.GetInvoiceService().CancelInvoiceRequest(e.ctx, invoice.Id)
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetInvoiceService().CancelInvoiceRequest(e.ctx, invoice.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+
+	err, isInternal = e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, invoice.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+	e.requireBalance(payerID, 1000)
+}
+
+func TestExpireAndListInvoices(t *testing.T) {
+	e := newTestEnv(t)
+	payerID := e.newUser(1000)
+	expired := e.createInvoice(payerID, 100)
+	e.createInvoice(payerID, 200)
+
+	count, err := e.storage.GetInvoiceStorage().ExpireInvoices(time.Now().Add(2 * time.Hour))
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 expired invoices, got %d (%v)", count, err)
+	}
+
+	err, isInternal := e.service.GetInvoiceService().PayInvoiceRequest(e.ctx, expired.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+
+	open := e.createInvoice(payerID, 300)
+	invoices, err, isInternal := e.service.GetInvoiceService().GetInvoicesRequest(e.ctx, payerID, dto.InvoiceExpired, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(invoices) != 2 {
+		t.Fatalf("expected 2 expired invoices, got %+v", invoices)
+	}
+
+	invoices, err, isInternal = e.service.GetInvoiceService().GetInvoicesRequest(e.ctx, payerID, "", 1, 0)
+	requireNoError(t, err, isInternal)
+	if len(invoices) != 1 || invoices[0].Id != open.Id {
+		t.Fatalf("expected the latest invoice first, got %+v", invoices)
+	}
+
+	_, err, isInternal = e.service.GetInvoiceService().GetInvoicesRequest(e.ctx, payerID, "unknown", 10, 0)
+	requireUserError(t, err, isInternal, "Unknown invoice status unknown")
+}
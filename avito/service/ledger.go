@@ -0,0 +1,232 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type LedgerServiceAPI interface {
+	GetJournalEntryRequest(ctx context.Context, id uuid.UUID) (*dto.JournalEntry, error, bool)
+	// ReconcileRequest сверяет балансы всех счетов с суммами их движений. При fix баланс
+	// расходящегося счета исправляется на сумму движений с записью корректировки и причины
+	ReconcileRequest(ctx context.Context, fix bool, reason string) (*dto.ReconcileReport, error, bool)
+	// VerifyLedgerRequest пересчитывает цепочки хешей движений (всех счетов или одного, если userID
+	// не nil) и для каждого счета с нарушенной цепочкой возвращает первое неверное звено
+	VerifyLedgerRequest(ctx context.Context, userID *uuid.UUID) (*dto.LedgerVerification, error, bool)
+}
+
+type ledgerService struct {
+	storage storage.StorageAPI
+	cache   BalanceCache
+	log     *logger.Logger
+}
+
+func NewLedgerServiceAPI(api storage.StorageAPI, cache BalanceCache) LedgerServiceAPI {
+	return &ledgerService{
+		storage: api,
+		cache:   cache,
+		log:     logger.New("ledger-service"),
+	}
+}
+
+func (l *ledgerService) GetJournalEntryRequest(ctx context.Context, id uuid.UUID) (*dto.JournalEntry, error, bool) {
+	entry, err := l.storage.GetLedgerStorage().GetJournalEntry(id)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("Journal entry does not exist"), false
+	}
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get journal entry from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, entry.CreatedAt)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while parse created_at of journal entry %v, reason: %v", id, err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	// движения архивного месяца удалены из базы, проводка возвращается с указанием архива
+	archives, err := l.storage.GetArchiveStorage().GetArchives(nil)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get archives from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	for _, archive := range archives {
+		if !createdAt.Before(archive.PeriodStart) && createdAt.Before(archive.PeriodEnd) {
+			archived := archiveToDTO(archive)
+			entry.Archived = &archived
+			break
+		}
+	}
+
+	return entry, nil, false
+}
+
+func (l *ledgerService) ReconcileRequest(ctx context.Context, fix bool, reason string) (*dto.ReconcileReport, error, bool) {
+	l.log.Infof(ctx, "Trying to reconcile balances, fix: %v", fix)
+
+	if fix && reason == "" {
+		return nil, xerrors.Errorf("reason cannot be empty"), false
+	}
+
+	accounts, err := l.storage.GetLedgerStorage().CountAccounts()
+	if err != nil {
+		l.log.Errorf(ctx, "Error while count accounts in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	mismatches, err := l.storage.GetLedgerStorage().GetBalanceMismatches()
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get balance mismatches from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	report := &dto.ReconcileReport{
+		Accounts:   accounts,
+		Mismatches: make([]dto.BalanceMismatch, 0, len(mismatches)),
+		Fixed:      fix,
+		Reason:     reason,
+	}
+
+	for _, mismatch := range mismatches {
+		l.log.Warnf(ctx, "Balance of %v is %d, postings sum is %d", mismatch.UserID, mismatch.Balance, mismatch.PostingsSum)
+
+		corrected := false
+		if fix {
+			mismatch, corrected = l.adjust(ctx, mismatch, reason)
+		}
+
+		difference := mismatch.Balance - mismatch.PostingsSum
+		report.Mismatches = append(report.Mismatches, dto.BalanceMismatch{
+			UserId:      mismatch.UserID,
+			Balance:     &dto.Money{IntPart: mismatch.Balance / 100, FracPart: mismatch.Balance % 100},
+			PostingsSum: &dto.Money{IntPart: mismatch.PostingsSum / 100, FracPart: mismatch.PostingsSum % 100},
+			Difference:  &dto.Money{IntPart: difference / 100, FracPart: difference % 100},
+			Postings:    mismatch.Postings,
+			Corrected:   corrected,
+		})
+	}
+
+	return report, nil, false
+}
+
+// adjust исправляет баланс одного счета на сумму движений. Сумма пересчитывается
+// под блокировкой счета, поэтому параллельные операции не искажают корректировку
+func (l *ledgerService) adjust(ctx context.Context, mismatch storage.BalanceMismatch, reason string) (storage.BalanceMismatch, bool) {
+	tx, err := l.storage.GetTransaction(ctx)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return mismatch, false
+	}
+
+	balance, err := l.storage.GetBalanceStorage().LockBalance(tx, mismatch.UserID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get balance from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return mismatch, false
+	}
+
+	total, postings, err := l.storage.GetLedgerStorage().GetPostingsSum(tx, mismatch.UserID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get postings sum from DB, reason: %v", err)
+		tx.Rollback(ctx)
+		return mismatch, false
+	}
+
+	mismatch.Balance, mismatch.PostingsSum, mismatch.Postings = balance, total, postings
+	if balance == total {
+		tx.Rollback(ctx)
+		return mismatch, false
+	}
+
+	err = l.storage.GetLedgerStorage().AdjustBalance(tx, mismatch.UserID, balance, total, reason, auth.ClientName(ctx))
+	if err != nil {
+		l.log.Errorf(ctx, "Error while adjust balance of %v in DB, reason: %v", mismatch.UserID, err)
+		tx.Rollback(ctx)
+		return mismatch, false
+	}
+
+	err = tx.Commit(ctx)
+	l.cache.Invalidate(mismatch.UserID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+		return mismatch, false
+	}
+
+	l.log.Infof(ctx, "Balance of %v has been adjusted from %d to %d", mismatch.UserID, balance, total)
+	return mismatch, true
+}
+
+func (l *ledgerService) VerifyLedgerRequest(ctx context.Context, userID *uuid.UUID) (*dto.LedgerVerification, error, bool) {
+	l.log.Infof(ctx, "Trying to verify ledger hash chains")
+
+	result := &dto.LedgerVerification{Breaks: make([]dto.ChainBreak, 0)}
+
+	// цепочки счетов с архивными движениями продолжаются с последнего архивного звена
+	archived, err := l.storage.GetArchiveStorage().GetArchivedAccounts(userID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get archived accounts from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+	anchors := make(map[uuid.UUID]storage.ArchivedAccount, len(archived))
+	for _, account := range archived {
+		anchors[account.UserID] = account
+	}
+
+	var current uuid.UUID
+	var expectedSeq int64
+	var expectedPrevHash string
+	broken := false
+	err = l.storage.GetLedgerStorage().WalkChain(userID, func(link storage.ChainLink) error {
+		if result.Postings == 0 || link.UserID != current {
+			current, expectedSeq, expectedPrevHash, broken = link.UserID, 1, "", false
+			if anchor, ok := anchors[link.UserID]; ok {
+				expectedSeq, expectedPrevHash = anchor.LastSeq+1, anchor.LastHash
+			}
+			result.Accounts++
+		}
+		result.Postings++
+
+		// после первого разрыва остальные звенья счета не проверяются: они зависят от неверного
+		if broken {
+			return nil
+		}
+
+		chainBreak := dto.ChainBreak{UserId: link.UserID, TransactionId: link.ID, Seq: link.Seq}
+		if link.Seq < expectedSeq {
+			// номер уже занят предыдущим звеном счета или архивом
+			chainBreak.Reason, chainBreak.Expected, chainBreak.Actual = dto.ChainBreakDuplicateSeq, fmt.Sprint(expectedSeq), fmt.Sprint(link.Seq)
+		} else if link.Seq > expectedSeq {
+			chainBreak.Reason, chainBreak.Expected, chainBreak.Actual = dto.ChainBreakSequence, fmt.Sprint(expectedSeq), fmt.Sprint(link.Seq)
+		} else if link.PrevHash != expectedPrevHash {
+			chainBreak.Reason, chainBreak.Expected, chainBreak.Actual = dto.ChainBreakPrevHash, expectedPrevHash, link.PrevHash
+		} else if hash := link.ComputeHash(); hash != link.Hash {
+			chainBreak.Reason, chainBreak.Expected, chainBreak.Actual = dto.ChainBreakHash, hash, link.Hash
+		} else {
+			expectedSeq++
+			expectedPrevHash = link.Hash
+			return nil
+		}
+
+		l.log.Warnf(ctx, "Hash chain of %v is broken at transaction %v, reason: %s", link.UserID, link.ID, chainBreak.Reason)
+		result.Breaks = append(result.Breaks, chainBreak)
+		broken = true
+		return nil
+	})
+	if err != nil {
+		l.log.Errorf(ctx, "Error while walk hash chains in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result.Valid = len(result.Breaks) == 0
+	return result, nil, false
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestGetJournalEntry(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(1000)
+	receiverID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: senderID, IdReceiver: receiverID, Sum: money(250)})
+	requireNoError(t, err, isInternal)
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, receiverID, 10, 0)
+	requireNoError(t, err, isInternal)
+
+	entry, err, isInternal := e.service.GetLedgerService().GetJournalEntryRequest(e.ctx, transactions[0].EntryId)
+	requireNoError(t, err, isInternal)
+	if entry.Operation != dto.OperationTransfer || len(entry.Postings) != 2 {
+		t.Fatalf("unexpected journal entry %+v", entry)
+	}
+
+	var total int64
+	for _, posting := range entry.Postings {
+		total += posting.ChangeBalance.IntPart*100 + posting.ChangeBalance.FracPart
+	}
+	if total != 0 || entry.Postings[0].UserID != senderID {
+		t.Fatalf("unexpected postings %+v", entry.Postings)
+	}
+
+	_, err, isInternal = e.service.GetLedgerService().GetJournalEntryRequest(e.ctx, uuid.New())
+	requireUserError(t, err, isInternal, "Journal entry does not exist")
+}
+
+func TestReconcile(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	e.newUser(500)
+
+	report, err, isInternal := e.service.GetLedgerService().ReconcileRequest(e.ctx, false, "")
+	requireNoError(t, err, isInternal)
+	if report.Accounts != 6 || len(report.Mismatches) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// баланс меняется в обход проводок, как при ручной правке в базе
+	tx, err := e.storage.GetTransaction(e.ctx)
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+	if err := e.storage.GetLedgerStorage().AdjustBalance(tx, userID, 1000, 700, "manual edit", "test"); err != nil {
+		t.Fatalf("AdjustBalance: %v", err)
+	}
+	if err := tx.Commit(e.ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	_, err, isInternal = e.service.GetLedgerService().ReconcileRequest(e.ctx, true, "")
+	requireUserError(t, err, isInternal, "reason cannot be empty")
+
+	report, err, isInternal = e.service.GetLedgerService().ReconcileRequest(e.ctx, false, "")
+	requireNoError(t, err, isInternal)
+	if len(report.Mismatches) != 1 || report.Mismatches[0].UserId != userID || *report.Mismatches[0].Difference != *money(-300) || report.Mismatches[0].Corrected {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	report, err, isInternal = e.service.GetLedgerService().ReconcileRequest(e.ctx, true, "restore")
+	requireNoError(t, err, isInternal)
+	if len(report.Mismatches) != 1 || !report.Mismatches[0].Corrected {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	e.requireBalance(userID, 1000)
+	e.requireBalanced()
+}
+
+func TestVerifyLedger(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	otherID := e.newUser(0)
+	for i := 0; i < 3; i++ {
+		err, isInternal := e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(100)})
+		requireNoError(t, err, isInternal)
+	}
+
+	verification, err, isInternal := e.service.GetLedgerService().VerifyLedgerRequest(e.ctx, nil)
+	requireNoError(t, err, isInternal)
+	if !verification.Valid || verification.Accounts != 3 || verification.Postings != 8 {
+		t.Fatalf("unexpected verification %+v", verification)
+	}
+
+	verification, err, isInternal = e.service.GetLedgerService().VerifyLedgerRequest(e.ctx, &userID)
+	requireNoError(t, err, isInternal)
+	if !verification.Valid || verification.Accounts != 1 || verification.Postings != 4 {
+		t.Fatalf("unexpected verification %+v", verification)
+	}
+}
+
+// chainStorage подменяет цепочки движений и архивные итоги, которые читает проверка
+type chainStorage struct {
+	storage.StorageAPI
+	ledger  chainLedger
+	archive chainArchive
+}
+
+func (s chainStorage) GetLedgerStorage() storage.LedgerStorageAPI   { return s.ledger }
+func (s chainStorage) GetArchiveStorage() storage.ArchiveStorageAPI { return s.archive }
+
+type chainLedger struct {
+	storage.LedgerStorageAPI
+	links []storage.ChainLink
+}
+
+func (l chainLedger) WalkChain(userID *uuid.UUID, fn func(link storage.ChainLink) error) error {
+	for _, link := range l.links {
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type chainArchive struct {
+	storage.ArchiveStorageAPI
+	accounts []storage.ArchivedAccount
+}
+
+func (a chainArchive) GetArchivedAccounts(userID *uuid.UUID) ([]storage.ArchivedAccount, error) {
+	return a.accounts, nil
+}
+
+// chain строит цепочку счета с верными хешами и заданными номерами движений
+func chain(userID uuid.UUID, prevHash string, seqs ...int64) []storage.ChainLink {
+	links := make([]storage.ChainLink, 0, len(seqs))
+	for _, seq := range seqs {
+		link := storage.ChainLink{ID: uuid.New(), EntryID: uuid.New(), UserID: userID, Seq: seq, Sum: 100,
+			Operation: dto.OperationCredit, CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), PrevHash: prevHash}
+		link.Hash = link.ComputeHash()
+		prevHash = link.Hash
+		links = append(links, link)
+	}
+	return links
+}
+
+func TestVerifyLedgerSequenceBreaks(t *testing.T) {
+	e := newTestEnv(t)
+	archivedID := uuid.New()
+	anchor := storage.ArchivedAccount{UserID: archivedID, Postings: 2, Total: 200, LastSeq: 2, LastHash: "archived"}
+
+	tests := []struct {
+		name     string
+		links    []storage.ChainLink
+		reason   string
+		expected string
+		actual   string
+	}{
+		{"valid", chain(uuid.New(), "", 1, 2, 3), "", "", ""},
+		{"gap", chain(uuid.New(), "", 1, 2, 4), dto.ChainBreakSequence, "3", "4"},
+		{"duplicate", chain(uuid.New(), "", 1, 2, 2), dto.ChainBreakDuplicateSeq, "3", "2"},
+		{"archived continues", chain(archivedID, "archived", 3, 4), "", "", ""},
+		{"duplicate of archived", chain(archivedID, "archived", 2, 3), dto.ChainBreakDuplicateSeq, "3", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := chainStorage{StorageAPI: e.storage, ledger: chainLedger{links: tt.links}, archive: chainArchive{accounts: []storage.ArchivedAccount{anchor}}}
+			verification, err, isInternal := NewLedgerServiceAPI(api, nil).VerifyLedgerRequest(e.ctx, nil)
+			requireNoError(t, err, isInternal)
+			if tt.reason == "" {
+				if !verification.Valid || verification.Postings != len(tt.links) {
+					t.Fatalf("unexpected verification %+v", verification)
+				}
+				return
+			}
+			if verification.Valid || len(verification.Breaks) != 1 {
+				t.Fatalf("unexpected verification %+v", verification)
+			}
+			chainBreak := verification.Breaks[0]
+			if chainBreak.Reason != tt.reason || chainBreak.Expected != tt.expected || chainBreak.Actual != tt.actual {
+				t.Fatalf("unexpected break %+v", chainBreak)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type LimitServiceAPI interface {
+	GetUserLimitsRequest(ctx context.Context, userID uuid.UUID) (*dto.UserLimits, error, bool)
+	SetUserLimitsRequest(ctx context.Context, userLimits dto.UserLimits) (error, bool)
+	ResetUserLimitsRequest(ctx context.Context, userID uuid.UUID) (error, bool)
+	// CheckLimits проверяет, что списание sum по операции withdraw или transfer не превысит лимиты.
+	// Вызывается внутри транзакции после LockBalance, поэтому параллельные списания
+	// одного пользователя не могут обойти лимит. Для перевода sum включает комиссию. Неположительная
+	// sum - ошибка пользователя
+	CheckLimits(ctx context.Context, tx storage.Tx, userID uuid.UUID, operation string, sum int64) (error, bool)
+}
+
+type limitService struct {
+	storage  storage.StorageAPI
+	defaults config.LimitsConfig
+	// служебные счета, на которые лимиты не распространяются
+	exempt map[uuid.UUID]bool
+	log    *logger.Logger
+}
+
+func NewLimitServiceAPI(api storage.StorageAPI, defaults config.LimitsConfig, exempt ...uuid.UUID) LimitServiceAPI {
+	l := &limitService{
+		storage:  api,
+		defaults: defaults,
+		exempt:   make(map[uuid.UUID]bool, len(exempt)),
+		log:      logger.New("limit-service"),
+	}
+	for _, id := range exempt {
+		l.exempt[id] = true
+	}
+
+	return l
+}
+
+func (l *limitService) GetUserLimitsRequest(ctx context.Context, userID uuid.UUID) (*dto.UserLimits, error, bool) {
+	limits, err := l.effectiveLimits(userID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get user limits from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := &dto.UserLimits{UserID: userID}
+	result.WithdrawDaily = limitMoney(limits.WithdrawDaily)
+	result.WithdrawMonthly = limitMoney(limits.WithdrawMonthly)
+	result.TransferDaily = limitMoney(limits.TransferDaily)
+	result.TransferMonthly = limitMoney(limits.TransferMonthly)
+	result.SingleOperationMax = limitMoney(limits.SingleOperationMax)
+	if limits.TransfersPerDay > 0 {
+		result.TransfersPerDay = &limits.TransfersPerDay
+	}
+
+	return result, nil, false
+}
+
+func (l *limitService) SetUserLimitsRequest(ctx context.Context, userLimits dto.UserLimits) (error, bool) {
+	l.log.Infof(ctx, "Trying to set limits %v", userLimits)
+
+	var limits storage.UserLimits
+	var err error
+	for _, field := range []struct {
+		money  *dto.Money
+		target **int64
+	}{
+		{userLimits.WithdrawDaily, &limits.WithdrawDaily},
+		{userLimits.WithdrawMonthly, &limits.WithdrawMonthly},
+		{userLimits.TransferDaily, &limits.TransferDaily},
+		{userLimits.TransferMonthly, &limits.TransferMonthly},
+		{userLimits.SingleOperationMax, &limits.SingleOperationMax},
+	} {
+		if field.money == nil {
+			continue
+		}
+		if *field.target, err = overrideKopecks(field.money); err != nil {
+			return err, false
+		}
+	}
+
+	if userLimits.TransfersPerDay != nil {
+		if *userLimits.TransfersPerDay < 0 {
+			return xerrors.Errorf("transfers_per_day cannot be negative"), false
+		}
+		limits.TransfersPerDay = userLimits.TransfersPerDay
+	}
+
+	err = l.storage.GetLimitStorage().SetUserLimits(userLimits.UserID, limits)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while set user limits in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return nil, false
+}
+
+func (l *limitService) ResetUserLimitsRequest(ctx context.Context, userID uuid.UUID) (error, bool) {
+	l.log.Infof(ctx, "Trying to reset limits of user %v", userID)
+
+	count, err := l.storage.GetLimitStorage().DeleteUserLimits(userID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while delete user limits in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count == 0 {
+		return xerrors.Errorf("User has no individual limits"), false
+	}
+
+	return nil, false
+}
+
+// effectiveLimits накладывает индивидуальные лимиты пользователя на лимиты по умолчанию
+func (l *limitService) effectiveLimits(userID uuid.UUID) (config.LimitsConfig, error) {
+	limits := l.defaults
+
+	overrides, err := l.storage.GetLimitStorage().GetUserLimits(userID)
+	if err != nil {
+		return limits, err
+	}
+
+	if overrides.WithdrawDaily != nil {
+		limits.WithdrawDaily = *overrides.WithdrawDaily
+	}
+	if overrides.WithdrawMonthly != nil {
+		limits.WithdrawMonthly = *overrides.WithdrawMonthly
+	}
+	if overrides.TransferDaily != nil {
+		limits.TransferDaily = *overrides.TransferDaily
+	}
+	if overrides.TransferMonthly != nil {
+		limits.TransferMonthly = *overrides.TransferMonthly
+	}
+	if overrides.SingleOperationMax != nil {
+		limits.SingleOperationMax = *overrides.SingleOperationMax
+	}
+	if overrides.TransfersPerDay != nil {
+		limits.TransfersPerDay = *overrides.TransfersPerDay
+	}
+
+	return limits, nil
+}
+
+func (l *limitService) CheckLimits(ctx context.Context, tx storage.Tx, userID uuid.UUID, operation string, sum int64) (error, bool) {
+	// все сравнения ниже верны только для положительной суммы
+	if sum <= 0 {
+		return xerrors.Errorf("Sum must be positive"), false
+	}
+
+	if l.exempt[userID] {
+		return nil, false
+	}
+
+	limits, err := l.effectiveLimits(userID)
+	if err != nil {
+		l.log.Errorf(ctx, "Error while get user limits from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if limits.SingleOperationMax > 0 && sum > limits.SingleOperationMax {
+		return newServiceError(dto.ErrCodeLimitExceeded, "Operation exceeds single operation limit"), false
+	}
+
+	dailyLimit, monthlyLimit := limits.WithdrawDaily, limits.WithdrawMonthly
+	if operation == dto.OperationTransfer {
+		dailyLimit, monthlyLimit = limits.TransferDaily, limits.TransferMonthly
+	}
+
+	if dailyLimit > 0 || limits.TransfersPerDay > 0 {
+		total, count, err := l.spentTotals(tx, userID, operation, "day")
+		if err != nil {
+			l.log.Errorf(ctx, "Error while get daily totals from DB, reason: %v", err)
+			return xerrors.Errorf("System error. Contact support"), true
+		}
+
+		if dailyLimit > 0 && total+sum > dailyLimit {
+			return newServiceError(dto.ErrCodeLimitExceeded, "Operation exceeds daily "+operation+" limit"), false
+		}
+
+		if operation == dto.OperationTransfer && limits.TransfersPerDay > 0 && count >= limits.TransfersPerDay {
+			return newServiceError(dto.ErrCodeLimitExceeded, "Daily number of transfers is exceeded"), false
+		}
+	}
+
+	if monthlyLimit > 0 {
+		total, _, err := l.spentTotals(tx, userID, operation, "month")
+		if err != nil {
+			l.log.Errorf(ctx, "Error while get monthly totals from DB, reason: %v", err)
+			return xerrors.Errorf("System error. Contact support"), true
+		}
+
+		if total+sum > monthlyLimit {
+			return newServiceError(dto.ErrCodeLimitExceeded, "Operation exceeds monthly "+operation+" limit"), false
+		}
+	}
+
+	return nil, false
+}
+
+// spentTotals возвращает сумму и число списаний операции за период. Комиссии переводов
+// списываются отдельными движениями и входят в сумму переводов
+func (l *limitService) spentTotals(tx storage.Tx, userID uuid.UUID, operation string, period string) (int64, int, error) {
+	total, count, err := l.storage.GetLimitStorage().GetOperationTotals(tx, userID, operation, period)
+	if err != nil || operation != dto.OperationTransfer {
+		return total, count, err
+	}
+
+	fees, _, err := l.storage.GetLimitStorage().GetOperationTotals(tx, userID, dto.OperationFee, period)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return total + fees, count, nil
+}
+
+func overrideKopecks(money *dto.Money) (*int64, error) {
+	if money.FracPart < 0 || money.FracPart > 99 {
+		return nil, xerrors.Errorf("frac_part must be between 0 and 99")
+	}
+
+	sum := money.IntPart*100 + money.FracPart
+	if sum < 0 {
+		return nil, xerrors.Errorf("Limit cannot be negative")
+	}
+
+	return &sum, nil
+}
+
+func limitMoney(sum int64) *dto.Money {
+	if sum <= 0 {
+		return nil
+	}
+
+	return &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+}
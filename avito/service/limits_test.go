@@ -0,0 +1,163 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"testing"
+)
+
+func TestSingleOperationLimit(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.SingleOperationMax = 500
+	})
+	userID := e.newUser(1000)
+	otherID := e.newUser(0)
+
+	err, isInternal := e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(501)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	err, isInternal = e.service.GetBalanceService().TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(501)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(500)})
+	requireNoError(t, err, isInternal)
+}
+
+// отрицательное списание не обходит лимиты и не зачисляет средства пользователю
+func TestNegativeWithdrawRejected(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.SingleOperationMax = 1000
+		conf.Limits.WithdrawDaily = 1000
+	})
+	userID := e.newUser(1000)
+
+	err, isInternal := e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(-500000)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	err, isInternal = e.service.GetLimitService().CheckLimits(e.ctx, nil, userID, dto.OperationWithdraw, -500000)
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	e.requireBalance(userID, 1000)
+	e.requireBalance(e.system.Revenue, 0)
+	e.requireBalanced()
+}
+
+func TestDailyLimits(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.WithdrawDaily = 300
+		conf.Limits.TransfersPerDay = 2
+	})
+	userID := e.newUser(10000)
+	otherID := e.newUser(0)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(200)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(101)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	err, isInternal = balanceService.WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+
+	for i := 0; i < 2; i++ {
+		err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(100)})
+		requireNoError(t, err, isInternal)
+	}
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(100)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	e.requireBalance(userID, 10000-300-200)
+}
+
+// комиссия уходит со счета вместе с переводом и расходует тот же лимит
+func TestTransferLimitsIncludeFee(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.TransferDaily = 230
+		conf.Fees.Tiers = []config.FeeTier{{Fixed: 10}}
+	})
+	userID := e.newUser(10000)
+	otherID := e.newUser(0)
+	balanceService := e.service.GetBalanceService()
+
+	err, isInternal := balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+
+	// 110 списано, 120 + 10 комиссии превысили бы лимит
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(120)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(110)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = balanceService.TransferFundsRequest(e.ctx, dto.TransferFundsRequest{IdSender: userID, IdReceiver: otherID, Sum: money(1)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	e.requireBalance(userID, 10000-230)
+	e.requireBalance(otherID, 210)
+}
+
+func TestUserLimitsOverride(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.WithdrawDaily = 300
+		conf.Limits.TransferMonthly = 1000
+	})
+	userID := e.newUser(10000)
+	limitService := e.service.GetLimitService()
+
+	limits, err, isInternal := limitService.GetUserLimitsRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+	if *limits.WithdrawDaily != *money(300) || *limits.TransferMonthly != *money(1000) || limits.TransfersPerDay != nil {
+		t.Fatalf("unexpected default limits %+v", limits)
+	}
+
+	transfersPerDay := 5
+	err, isInternal = limitService.SetUserLimitsRequest(e.ctx, dto.UserLimits{UserID: userID, WithdrawDaily: money(1000), TransfersPerDay: &transfersPerDay})
+	requireNoError(t, err, isInternal)
+
+	limits, err, isInternal = limitService.GetUserLimitsRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+	if *limits.WithdrawDaily != *money(1000) || *limits.TransferMonthly != *money(1000) || *limits.TransfersPerDay != 5 {
+		t.Fatalf("unexpected overridden limits %+v", limits)
+	}
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(800)})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = limitService.ResetUserLimitsRequest(e.ctx, userID)
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(1)})
+	requireCode(t, err, isInternal, dto.ErrCodeLimitExceeded)
+
+	err, isInternal = limitService.ResetUserLimitsRequest(e.ctx, userID)
+	requireUserError(t, err, isInternal, "User has no individual limits")
+}
+
+func TestSetUserLimitsValidation(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(0)
+	limitService := e.service.GetLimitService()
+
+	err, isInternal := limitService.SetUserLimitsRequest(e.ctx, dto.UserLimits{UserID: userID, WithdrawDaily: money(-100)})
+	requireUserError(t, err, isInternal, "Limit cannot be negative")
+
+	err, isInternal = limitService.SetUserLimitsRequest(e.ctx, dto.UserLimits{UserID: userID, TransferDaily: &dto.Money{IntPart: 1, FracPart: 100}})
+	requireUserError(t, err, isInternal, "frac_part must be between 0 and 99")
+
+	transfersPerDay := -1
+	err, isInternal = limitService.SetUserLimitsRequest(e.ctx, dto.UserLimits{UserID: userID, TransfersPerDay: &transfersPerDay})
+	requireUserError(t, err, isInternal, "transfers_per_day cannot be negative")
+}
+
+func TestSystemAccountsExemptFromLimits(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Limits.SingleOperationMax = 100
+	})
+	userID := e.newUser(0)
+
+	// зачисление не проверяет лимиты, а служебный счет источника освобожден от них
+	e.credit(userID, 1000)
+	e.requireBalance(userID, 1000)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type MoneyRequestServiceAPI interface {
+	CreateMoneyRequest(ctx context.Context, createMoneyRequest dto.CreateMoneyRequest) (*dto.MoneyRequest, error, bool)
+	// AcceptMoneyRequest переводит запрошенную сумму от плательщика отправителю запроса
+	AcceptMoneyRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	DeclineMoneyRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	GetMoneyRequest(ctx context.Context, id uuid.UUID) (*dto.MoneyRequest, error, bool)
+	GetPendingMoneyRequests(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.MoneyRequest, error, bool)
+	// Start переводит просроченные запросы в expired каждые money_request.check_interval до отмены ctx
+	Start(ctx context.Context)
+}
+
+type moneyRequestService struct {
+	storage storage.StorageAPI
+	balance BalanceServiceAPI
+	conf    config.MoneyRequestConfig
+	log     *logger.Logger
+}
+
+func NewMoneyRequestServiceAPI(api storage.StorageAPI, balance BalanceServiceAPI, conf config.MoneyRequestConfig) MoneyRequestServiceAPI {
+	return &moneyRequestService{
+		storage: api,
+		balance: balance,
+		conf:    conf,
+		log:     logger.New("money-request-service"),
+	}
+}
+
+func (m *moneyRequestService) CreateMoneyRequest(ctx context.Context, createMoneyRequest dto.CreateMoneyRequest) (*dto.MoneyRequest, error, bool) {
+	m.log.Infof(ctx, "Trying to create money request %v", createMoneyRequest)
+
+	if createMoneyRequest.RequesterId == createMoneyRequest.PayerId {
+		return nil, xerrors.Errorf("RequesterID and payerID cannot be equal"), false
+	}
+
+	if createMoneyRequest.Sum == nil {
+		return nil, xerrors.Errorf("amount cannot be empty"), false
+	}
+
+	if createMoneyRequest.Sum.FracPart < 0 || createMoneyRequest.Sum.FracPart > 99 {
+		return nil, xerrors.Errorf("frac_part must be between 0 and 99"), false
+	}
+
+	sum := createMoneyRequest.Sum.IntPart*100 + createMoneyRequest.Sum.FracPart
+	if sum <= 0 {
+		return nil, xerrors.Errorf("Sum must be positive"), false
+	}
+
+	expiresAt := time.Now().UTC().Add(m.conf.DefaultTTL)
+	if createMoneyRequest.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, createMoneyRequest.ExpiresAt)
+		if err != nil {
+			return nil, xerrors.Errorf("expires_at must be in RFC3339 format"), false
+		}
+		if !t.After(time.Now()) {
+			return nil, xerrors.Errorf("expires_at must be in the future"), false
+		}
+		expiresAt = t.UTC()
+	}
+
+	count, err := m.storage.GetBalanceStorage().CountUsers(createMoneyRequest.PayerId)
+	if err != nil {
+		m.log.Errorf(ctx, "Error while count users in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count != 1 {
+		return nil, xerrors.Errorf("User does not exist"), false
+	}
+
+	request := storage.MoneyRequest{
+		RequesterID: createMoneyRequest.RequesterId,
+		PayerID:     createMoneyRequest.PayerId,
+		Sum:         sum,
+		Message:     createMoneyRequest.Message,
+		ExpiresAt:   expiresAt,
+		Client:      auth.ClientName(ctx),
+	}
+
+	id, err := m.storage.GetMoneyRequestStorage().CreateMoneyRequest(request)
+	if err != nil {
+		m.log.Errorf(ctx, "Error while create money request in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return m.GetMoneyRequest(ctx, id)
+}
+
+func (m *moneyRequestService) AcceptMoneyRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	m.log.Infof(ctx, "Trying to accept money request %v", id)
+
+	request, err := m.storage.GetMoneyRequestStorage().GetMoneyRequest(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Money request does not exist"), false
+	}
+	if err != nil {
+		m.log.Errorf(ctx, "Error while get money request from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if err := checkMoneyRequestPending(request); err != nil {
+		return err, false
+	}
+
+	hook := func(tx storage.Tx) error {
+		locked, err := m.storage.GetMoneyRequestStorage().LockMoneyRequest(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkMoneyRequestPending(locked); err != nil {
+			return err
+		}
+
+		return m.storage.GetMoneyRequestStorage().SetMoneyRequestStatus(tx, id, dto.MoneyRequestAccepted)
+	}
+
+	// транзакции перевода ссылаются на запрос через комментарий
+	acceptCtx := withTransactionComment(ctx, "money_request:"+id.String())
+	transferFundsRequest := dto.TransferFundsRequest{IdSender: request.PayerID, IdReceiver: request.RequesterID, Sum: &dto.Money{IntPart: request.Sum / 100, FracPart: request.Sum % 100}}
+	err, isInternal := m.balance.TransferFundsRequestWithHook(acceptCtx, transferFundsRequest, hook)
+	if err != nil {
+		if isInternal {
+			m.log.Errorf(ctx, "Error while accept money request %v, reason: %v", id, err)
+			return xerrors.Errorf("System error. Contact support"), true
+		}
+		return err, false
+	}
+
+	return nil, false
+}
+
+func (m *moneyRequestService) DeclineMoneyRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	m.log.Infof(ctx, "Trying to decline money request %v", id)
+
+	request, err := m.storage.GetMoneyRequestStorage().GetMoneyRequest(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Money request does not exist"), false
+	}
+	if err != nil {
+		m.log.Errorf(ctx, "Error while get money request from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if err := checkMoneyRequestPending(request); err != nil {
+		return err, false
+	}
+
+	count, err := m.storage.GetMoneyRequestStorage().DeclineMoneyRequest(id)
+	if err != nil {
+		m.log.Errorf(ctx, "Error while decline money request in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count == 0 {
+		return newServiceError(dto.ErrCodeInvalidState, "Money request is no longer pending"), false
+	}
+
+	return nil, false
+}
+
+func (m *moneyRequestService) GetMoneyRequest(ctx context.Context, id uuid.UUID) (*dto.MoneyRequest, error, bool) {
+	request, err := m.storage.GetMoneyRequestStorage().GetMoneyRequest(id)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("Money request does not exist"), false
+	}
+	if err != nil {
+		m.log.Errorf(ctx, "Error while get money request from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := moneyRequestToDTO(*request)
+	return &result, nil, false
+}
+
+func (m *moneyRequestService) GetPendingMoneyRequests(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.MoneyRequest, error, bool) {
+	requests, err := m.storage.GetMoneyRequestStorage().GetPendingMoneyRequests(userID, time.Now().UTC(), limit, offset)
+	if err != nil {
+		m.log.Errorf(ctx, "Error while get money requests from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := make([]dto.MoneyRequest, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, moneyRequestToDTO(request))
+	}
+
+	return result, nil, false
+}
+
+func (m *moneyRequestService) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.conf.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := m.storage.GetMoneyRequestStorage().ExpireMoneyRequests(time.Now().UTC())
+			if err != nil {
+				m.log.Errorf(ctx, "Error while expire money requests in DB, reason: %v", err)
+				continue
+			}
+			if count > 0 {
+				m.log.Infof(ctx, "%d money requests have expired", count)
+			}
+		}
+	}
+}
+
+// checkMoneyRequestPending проверяет, что запрос ожидает ответа и его срок не истек
+func checkMoneyRequestPending(request *storage.MoneyRequest) error {
+	if request.Status != dto.MoneyRequestPending {
+		return newServiceError(dto.ErrCodeInvalidState, "Money request is already "+request.Status)
+	}
+
+	if !request.ExpiresAt.After(time.Now().UTC()) {
+		return newServiceError(dto.ErrCodeInvalidState, "Money request has expired")
+	}
+
+	return nil
+}
+
+func moneyRequestToDTO(request storage.MoneyRequest) dto.MoneyRequest {
+	return dto.MoneyRequest{
+		Id:          request.Id,
+		RequesterId: request.RequesterID,
+		PayerId:     request.PayerID,
+		Sum:         &dto.Money{IntPart: request.Sum / 100, FracPart: request.Sum % 100},
+		Message:     request.Message,
+		Status:      request.Status,
+		ExpiresAt:   request.ExpiresAt.Format(time.RFC3339),
+		Client:      request.Client,
+		CreatedAt:   request.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func (e *testEnv) createMoneyRequest(requesterID uuid.UUID, payerID uuid.UUID, sum int64) *dto.MoneyRequest {
+	e.t.Helper()
+	request, err, isInternal := e.service.GetMoneyRequestService().CreateMoneyRequest(e.ctx, dto.CreateMoneyRequest{RequesterId: requesterID, PayerId: payerID, Sum: money(sum), Message: "lunch"})
+	requireNoError(e.t, err, isInternal)
+
+	return request
+}
+
+func TestAcceptMoneyRequest(t *testing.T) {
+	e := newTestEnv(t)
+	requesterID := e.newUser(0)
+	payerID := e.newUser(1000)
+	request := e.createMoneyRequest(requesterID, payerID, 400)
+	if request.Status != dto.MoneyRequestPending || request.Message != "lunch" {
+		t.Fatalf("unexpected money request %+v", request)
+	}
+
+	err, isInternal := e.service.GetMoneyRequestService().AcceptMoneyRequest(e.ctx, request.Id)
+	requireNoError(t, err, isInternal)
+
+	e.requireBalance(payerID, 600)
+	e.requireBalance(requesterID, 400)
+	e.requireBalanced()
+
+	accepted, err, isInternal := e.service.GetMoneyRequestService().GetMoneyRequest(e.ctx, request.Id)
+	requireNoError(t, err, isInternal)
+	if accepted.Status != dto.MoneyRequestAccepted {
+		t.Fatalf("unexpected money request %+v", accepted)
+	}
+
+	transactions, err, isInternal := e.service.GetTransactionService().GetTransactionsRequest(e.ctx, requesterID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(transactions) != 1 || transactions[0].Comment != "money_request:"+request.Id.String() {
+		t.Fatalf("unexpected transactions %+v", transactions)
+	}
+
+	err, isInternal = e.service.GetMoneyRequestService().AcceptMoneyRequest(e.ctx, request.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+	e.requireBalance(payerID, 600)
+}
+
+func TestAcceptMoneyRequestInsufficientFunds(t *testing.T) {
+	e := newTestEnv(t)
+	requesterID := e.newUser(0)
+	payerID := e.newUser(100)
+	request := e.createMoneyRequest(requesterID, payerID, 400)
+
+	err, isInternal := e.service.GetMoneyRequestService().AcceptMoneyRequest(e.ctx, request.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInsufficientFunds)
+
+	pending, err, isInternal := e.service.GetMoneyRequestService().GetMoneyRequest(e.ctx, request.Id)
+	requireNoError(t, err, isInternal)
+	if pending.Status != dto.MoneyRequestPending {
+		t.Fatalf("unexpected money request %+v", pending)
+	}
+}
+
+func TestDeclineMoneyRequest(t *testing.T) {
+	e := newTestEnv(t)
+	requesterID := e.newUser(0)
+	payerID := e.newUser(1000)
+	request := e.createMoneyRequest(requesterID, payerID, 400)
+
+	err, isInternal := e.service.GetMoneyRequestService().DeclineMoneyRequest(e.ctx, request.Id)
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetMoneyRequestService().DeclineMoneyRequest(e.ctx, request.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+
+	err, isInternal = e.service.GetMoneyRequestService().AcceptMoneyRequest(e.ctx, request.Id)
+	requireCode(t, err, isInternal, dto.ErrCodeInvalidState)
+	e.requireBalance(payerID, 1000)
+
+	err, isInternal = e.service.GetMoneyRequestService().DeclineMoneyRequest(e.ctx, uuid.New())
+	requireUserError(t, err, isInternal, "Money request does not exist")
+}
+
+func TestCreateMoneyRequestValidation(t *testing.T) {
+	e := newTestEnv(t)
+	requesterID := e.newUser(0)
+	payerID := e.newUser(1000)
+	moneyRequestService := e.service.GetMoneyRequestService()
+
+	_, err, isInternal := moneyRequestService.CreateMoneyRequest(e.ctx, dto.CreateMoneyRequest{RequesterId: payerID, PayerId: payerID, Sum: money(100)})
+	requireUserError(t, err, isInternal, "RequesterID and payerID cannot be equal")
+
+	_, err, isInternal = moneyRequestService.CreateMoneyRequest(e.ctx, dto.CreateMoneyRequest{RequesterId: requesterID, PayerId: payerID, Sum: money(0)})
+	requireUserError(t, err, isInternal, "Sum must be positive")
+
+	_, err, isInternal = moneyRequestService.CreateMoneyRequest(e.ctx, dto.CreateMoneyRequest{RequesterId: requesterID, PayerId: uuid.New(), Sum: money(100)})
+	requireUserError(t, err, isInternal, "User does not exist")
+
+	_, err, isInternal = moneyRequestService.CreateMoneyRequest(e.ctx, dto.CreateMoneyRequest{RequesterId: requesterID, PayerId: payerID, Sum: money(100), ExpiresAt: time.Now().Add(-time.Hour).Format(time.RFC3339)})
+	requireUserError(t, err, isInternal, "expires_at must be in the future")
+}
+
+func TestPendingMoneyRequests(t *testing.T) {
+	e := newTestEnv(t)
+	requesterID := e.newUser(0)
+	payerID := e.newUser(1000)
+	otherID := e.newUser(1000)
+
+	first := e.createMoneyRequest(requesterID, payerID, 100)
+	second := e.createMoneyRequest(requesterID, otherID, 200)
+	declined := e.createMoneyRequest(requesterID, payerID, 300)
+	err, isInternal := e.service.GetMoneyRequestService().DeclineMoneyRequest(e.ctx, declined.Id)
+	requireNoError(t, err, isInternal)
+
+	pending, err, isInternal := e.service.GetMoneyRequestService().GetPendingMoneyRequests(e.ctx, requesterID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(pending) != 2 || pending[0].Id != second.Id || pending[1].Id != first.Id {
+		t.Fatalf("unexpected pending requests %+v", pending)
+	}
+
+	pending, err, isInternal = e.service.GetMoneyRequestService().GetPendingMoneyRequests(e.ctx, payerID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(pending) != 1 || pending[0].Id != first.Id {
+		t.Fatalf("unexpected pending requests %+v", pending)
+	}
+
+	count, err := e.storage.GetMoneyRequestStorage().ExpireMoneyRequests(time.Now().Add(2 * time.Hour))
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 expired money requests, got %d (%v)", count, err)
+	}
+
+	pending, err, isInternal = e.service.GetMoneyRequestService().GetPendingMoneyRequests(e.ctx, requesterID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending requests, got %+v", pending)
+	}
+}
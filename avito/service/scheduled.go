@@ -0,0 +1,364 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/cron"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type ScheduleServiceAPI interface {
+	CreateScheduledOperationRequest(ctx context.Context, scheduledOperationRequest dto.ScheduledOperationRequest) (*dto.ScheduledOperation, error, bool)
+	GetScheduledOperationRequest(ctx context.Context, id uuid.UUID) (*dto.ScheduledOperation, error, bool)
+	GetScheduledOperationsRequest(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.ScheduledOperation, error, bool)
+	CancelScheduledOperationRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	// Start выполняет наступившие операции каждые scheduler.interval до отмены ctx
+	Start(ctx context.Context)
+}
+
+type scheduleService struct {
+	storage storage.StorageAPI
+	balance BalanceServiceAPI
+	conf    config.SchedulerConfig
+	log     *logger.Logger
+}
+
+// errOccurrenceExecuted - выполнение за этот момент уже проведено другим исполнителем
+var errOccurrenceExecuted = xerrors.New("occurrence has already been executed")
+
+// errOperationInactive - операция отменена или завершена после выборки к выполнению
+var errOperationInactive = xerrors.New("scheduled operation is no longer active")
+
+func NewScheduleServiceAPI(api storage.StorageAPI, balance BalanceServiceAPI, conf config.SchedulerConfig) ScheduleServiceAPI {
+	return &scheduleService{
+		storage: api,
+		balance: balance,
+		conf:    conf,
+		log:     logger.New("schedule-service"),
+	}
+}
+
+func (s *scheduleService) CreateScheduledOperationRequest(ctx context.Context, scheduledOperationRequest dto.ScheduledOperationRequest) (*dto.ScheduledOperation, error, bool) {
+	s.log.Infof(ctx, "Trying to create scheduled operation %v", scheduledOperationRequest)
+
+	operation := storage.ScheduledOperation{
+		Operation:  scheduledOperationRequest.Operation,
+		UserID:     scheduledOperationRequest.UserId,
+		ReceiverID: scheduledOperationRequest.ReceiverId,
+		Schedule:   scheduledOperationRequest.Schedule,
+		Client:     auth.ClientName(ctx),
+	}
+
+	switch operation.Operation {
+	case dto.OperationWithdraw:
+		if operation.ReceiverID != nil {
+			return nil, xerrors.Errorf("receiver_id is allowed only for transfer"), false
+		}
+	case dto.OperationTransfer:
+		if operation.ReceiverID == nil {
+			return nil, xerrors.Errorf("receiver_id cannot be empty"), false
+		}
+		if *operation.ReceiverID == operation.UserID {
+			return nil, xerrors.Errorf("ReceiverID and senderID cannot be equal"), false
+		}
+	default:
+		return nil, xerrors.Errorf("operation must be withdraw or transfer"), false
+	}
+
+	if err := checkClientScope(ctx, operation.Operation); err != nil {
+		return nil, err, false
+	}
+
+	if scheduledOperationRequest.Sum == nil {
+		return nil, xerrors.Errorf("amount cannot be empty"), false
+	}
+
+	if scheduledOperationRequest.Sum.FracPart < 0 || scheduledOperationRequest.Sum.FracPart > 99 {
+		return nil, xerrors.Errorf("frac_part must be between 0 and 99"), false
+	}
+
+	operation.Sum = scheduledOperationRequest.Sum.IntPart*100 + scheduledOperationRequest.Sum.FracPart
+	if operation.Sum <= 0 {
+		return nil, xerrors.Errorf("Sum must be positive"), false
+	}
+
+	switch {
+	case scheduledOperationRequest.RunAt != "" && scheduledOperationRequest.Schedule != "":
+		return nil, xerrors.Errorf("Only one of run_at and schedule can be set"), false
+	case scheduledOperationRequest.RunAt != "":
+		runAt, err := time.Parse(time.RFC3339, scheduledOperationRequest.RunAt)
+		if err != nil {
+			return nil, xerrors.Errorf("run_at must be in RFC3339 format"), false
+		}
+		operation.NextRunAt = runAt.UTC().Truncate(time.Second)
+	case scheduledOperationRequest.Schedule != "":
+		schedule, err := cron.Parse(scheduledOperationRequest.Schedule)
+		if err != nil {
+			return nil, xerrors.Errorf("Incorrect schedule: %v", err), false
+		}
+		operation.NextRunAt = schedule.Next(time.Now().UTC())
+		if operation.NextRunAt.IsZero() {
+			return nil, xerrors.Errorf("Schedule never fires"), false
+		}
+	default:
+		return nil, xerrors.Errorf("One of run_at and schedule must be set"), false
+	}
+
+	id, err := s.storage.GetScheduledStorage().CreateScheduledOperation(operation)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while create scheduled operation in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return s.GetScheduledOperationRequest(ctx, id)
+}
+
+func (s *scheduleService) GetScheduledOperationRequest(ctx context.Context, id uuid.UUID) (*dto.ScheduledOperation, error, bool) {
+	operation, err := s.storage.GetScheduledStorage().GetScheduledOperation(id)
+	if err == storage.ErrNoRows {
+		return nil, xerrors.Errorf("Scheduled operation does not exist"), false
+	}
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get scheduled operation from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := scheduledOperationToDTO(*operation)
+	result.Occurrences, err = s.storage.GetScheduledStorage().GetOccurrences(id)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get occurrences from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return &result, nil, false
+}
+
+func (s *scheduleService) GetScheduledOperationsRequest(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.ScheduledOperation, error, bool) {
+	operations, err := s.storage.GetScheduledStorage().GetScheduledOperations(userID, limit, offset)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get scheduled operations from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := make([]dto.ScheduledOperation, 0, len(operations))
+	for _, operation := range operations {
+		result = append(result, scheduledOperationToDTO(operation))
+	}
+
+	return result, nil, false
+}
+
+func (s *scheduleService) CancelScheduledOperationRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	s.log.Infof(ctx, "Trying to cancel scheduled operation %v", id)
+
+	operation, err := s.storage.GetScheduledStorage().GetScheduledOperation(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Scheduled operation does not exist"), false
+	}
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get scheduled operation from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if err := checkClientScope(ctx, operation.Operation); err != nil {
+		return err, false
+	}
+
+	count, err := s.storage.GetScheduledStorage().CancelScheduledOperation(id)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while cancel scheduled operation in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count == 0 {
+		return xerrors.Errorf("Scheduled operation is already %s", operation.Status), false
+	}
+
+	return nil, false
+}
+
+func (s *scheduleService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.executeDue(ctx)
+		}
+	}
+}
+
+func (s *scheduleService) executeDue(ctx context.Context) {
+	operations, err := s.storage.GetScheduledStorage().GetDueScheduledOperations(time.Now().UTC(), s.conf.BatchSize)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get due scheduled operations from DB, reason: %v", err)
+		return
+	}
+
+	for _, operation := range operations {
+		s.execute(ctx, operation)
+	}
+}
+
+// execute проводит одно выполнение операции. Выполнение идентифицируется парой
+// (операция, next_run_at) и фиксируется в той же транзакции, что и списание,
+// поэтому повторный запуск не спишет средства второй раз
+func (s *scheduleService) execute(ctx context.Context, operation storage.ScheduledOperation) {
+	s.log.Infof(ctx, "Executing scheduled operation %v for %v", operation.Id, operation.NextRunAt)
+
+	now := time.Now().UTC()
+	attempts := operation.Attempts + 1
+
+	next := operation
+	next.Attempts = 0
+	next.LastError = nil
+	next.Status = dto.ScheduledCompleted
+	if operation.Schedule != "" {
+		if schedule, err := cron.Parse(operation.Schedule); err == nil {
+			next.Status = dto.ScheduledActive
+			next.NextRunAt = schedule.Next(now)
+			next.NextAttemptAt = next.NextRunAt
+		}
+	}
+
+	hook := func(tx storage.Tx) error {
+		ok, err := s.storage.GetScheduledStorage().SaveOccurrence(tx, operation.Id, operation.NextRunAt, dto.OccurrenceSucceeded, attempts, nil)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errOccurrenceExecuted
+		}
+
+		// отмена между выборкой и выполнением откатывает движение средств вместе с выполнением
+		updated, err := s.storage.GetScheduledStorage().UpdateScheduledOperation(tx, next)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errOperationInactive
+		}
+
+		return nil
+	}
+
+	// транзакции запланированной операции записываются от имени клиента, который ее создал
+	opCtx := auth.WithClient(ctx, &auth.Client{Name: operation.Client})
+	sum := &dto.Money{IntPart: operation.Sum / 100, FracPart: operation.Sum % 100}
+
+	var err error
+	var isInternal bool
+	if operation.Operation == dto.OperationTransfer {
+		err, isInternal = s.balance.TransferFundsRequestWithHook(opCtx, dto.TransferFundsRequest{IdSender: operation.UserID, IdReceiver: *operation.ReceiverID, Sum: sum}, hook)
+	} else {
+		err, isInternal = s.balance.WithdrawFundsRequestWithHook(opCtx, dto.OperationRequest{UserId: operation.UserID, Sum: sum}, hook)
+	}
+
+	if err == nil {
+		s.log.Infof(ctx, "Scheduled operation %v has been executed", operation.Id)
+		return
+	}
+
+	if err == errOccurrenceExecuted {
+		s.log.Infof(ctx, "Scheduled operation %v for %v has already been executed", operation.Id, operation.NextRunAt)
+		return
+	}
+
+	if err == errOperationInactive {
+		s.log.Infof(ctx, "Scheduled operation %v is no longer active", operation.Id)
+		return
+	}
+
+	if isInternal {
+		// внутренние ошибки не считаются попыткой, операция повторится на следующем запуске
+		s.log.Errorf(ctx, "Error while execute scheduled operation %v, reason: %v", operation.Id, err)
+		return
+	}
+
+	s.log.Warnf(ctx, "Scheduled operation %v failed, reason: %v", operation.Id, err)
+
+	lastError := err.Error()
+	occurrenceStatus := dto.OccurrenceFailed
+
+	var serviceError *ServiceError
+	if xerrors.As(err, &serviceError) && serviceError.Code == dto.ErrCodeInsufficientFunds && attempts < s.conf.MaxAttempts {
+		occurrenceStatus = dto.OccurrenceRetrying
+		next = operation
+		next.Attempts = attempts
+		next.NextAttemptAt = now.Add(s.conf.RetryInterval)
+	} else if next.Status == dto.ScheduledCompleted {
+		next.Status = dto.ScheduledFailed
+	}
+	next.LastError = &lastError
+
+	s.saveFailure(ctx, operation, next, occurrenceStatus, attempts, &lastError)
+}
+
+func (s *scheduleService) saveFailure(ctx context.Context, operation storage.ScheduledOperation, next storage.ScheduledOperation, occurrenceStatus string, attempts int, lastError *string) {
+	tx, err := s.storage.GetTransaction(ctx)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while create transaction, reason: %+v", err)
+		return
+	}
+
+	ok, err := s.storage.GetScheduledStorage().SaveOccurrence(tx, operation.Id, operation.NextRunAt, occurrenceStatus, attempts, lastError)
+	if err != nil || !ok {
+		if err != nil {
+			s.log.Errorf(ctx, "Error while save occurrence in DB, reason: %v", err)
+		}
+		tx.Rollback(ctx)
+		return
+	}
+
+	updated, err := s.storage.GetScheduledStorage().UpdateScheduledOperation(tx, next)
+	if err != nil || !updated {
+		if err != nil {
+			s.log.Errorf(ctx, "Error while update scheduled operation in DB, reason: %v", err)
+		}
+		tx.Rollback(ctx)
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.log.Errorf(ctx, "Error while commit transaction, reason: %+v", err)
+	}
+}
+
+// checkClientScope проверяет, что клиент может выполнять операцию данного типа.
+// Внутренние вызовы без клиента в контексте разрешены
+func checkClientScope(ctx context.Context, scope string) error {
+	client := auth.ClientFromContext(ctx)
+	if client != nil && !client.HasScope(scope) {
+		return newServiceError(dto.ErrCodeForbidden, "Operation is not permitted for this client")
+	}
+
+	return nil
+}
+
+func scheduledOperationToDTO(operation storage.ScheduledOperation) dto.ScheduledOperation {
+	return dto.ScheduledOperation{
+		Id:         operation.Id,
+		Operation:  operation.Operation,
+		UserId:     operation.UserID,
+		ReceiverId: operation.ReceiverID,
+		Sum:        &dto.Money{IntPart: operation.Sum / 100, FracPart: operation.Sum % 100},
+		Schedule:   operation.Schedule,
+		NextRunAt:  operation.NextRunAt.Format(time.RFC3339),
+		Status:     operation.Status,
+		Attempts:   operation.Attempts,
+		LastError:  operation.LastError,
+		Client:     operation.Client,
+		CreatedAt:  operation.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"avito/auth"
+	"avito/config"
+	"avito/dto"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func (e *testEnv) scheduleOnce(request dto.ScheduledOperationRequest) *dto.ScheduledOperation {
+	e.t.Helper()
+	request.RunAt = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	operation, err, isInternal := e.service.GetScheduleService().CreateScheduledOperationRequest(e.ctx, request)
+	requireNoError(e.t, err, isInternal)
+
+	return operation
+}
+
+func (e *testEnv) scheduledOperation(id uuid.UUID) *dto.ScheduledOperation {
+	e.t.Helper()
+	operation, err, isInternal := e.service.GetScheduleService().GetScheduledOperationRequest(e.ctx, id)
+	requireNoError(e.t, err, isInternal)
+
+	return operation
+}
+
+func TestScheduledTransfer(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(1000)
+	receiverID := e.newUser(0)
+	operation := e.scheduleOnce(dto.ScheduledOperationRequest{Operation: dto.OperationTransfer, UserId: senderID, ReceiverId: &receiverID, Sum: money(300)})
+	if operation.Status != dto.ScheduledActive {
+		t.Fatalf("unexpected operation %+v", operation)
+	}
+
+	scheduler := e.service.GetScheduleService().(*scheduleService)
+	scheduler.executeDue(e.ctx)
+	// повторный запуск не должен списать средства второй раз
+	scheduler.executeDue(e.ctx)
+
+	e.requireBalance(senderID, 700)
+	e.requireBalance(receiverID, 300)
+
+	operation = e.scheduledOperation(operation.Id)
+	if operation.Status != dto.ScheduledCompleted || len(operation.Occurrences) != 1 || operation.Occurrences[0].Status != dto.OccurrenceSucceeded {
+		t.Fatalf("unexpected operation %+v", operation)
+	}
+}
+
+// операция, отмененная после выборки к выполнению, не списывает средства и не записывает выполнение
+func TestScheduledOperationCancelledBeforeExecute(t *testing.T) {
+	e := newTestEnv(t)
+	senderID := e.newUser(1000)
+	receiverID := e.newUser(0)
+	scheduler := e.service.GetScheduleService().(*scheduleService)
+
+	for _, request := range []dto.ScheduledOperationRequest{
+		{Operation: dto.OperationTransfer, UserId: senderID, ReceiverId: &receiverID, Sum: money(300)},
+		{Operation: dto.OperationWithdraw, UserId: senderID, Sum: money(200)},
+		// отмена не записывает и неудачное выполнение
+		{Operation: dto.OperationWithdraw, UserId: senderID, Sum: money(5000)},
+	} {
+		operation := e.scheduleOnce(request)
+
+		due, err := e.storage.GetScheduledStorage().GetDueScheduledOperations(time.Now().UTC(), 10)
+		if err != nil || len(due) != 1 || due[0].Id != operation.Id {
+			t.Fatalf("GetDueScheduledOperations: %+v, %v", due, err)
+		}
+
+		err, isInternal := scheduler.CancelScheduledOperationRequest(e.ctx, operation.Id)
+		requireNoError(t, err, isInternal)
+
+		scheduler.execute(e.ctx, due[0])
+
+		operation = e.scheduledOperation(operation.Id)
+		if operation.Status != dto.ScheduledCancelled || len(operation.Occurrences) != 0 {
+			t.Fatalf("unexpected operation %+v", operation)
+		}
+	}
+
+	e.requireBalance(senderID, 1000)
+	e.requireBalance(receiverID, 0)
+	e.requireBalanced()
+}
+
+func TestScheduledWithdrawRetries(t *testing.T) {
+	e := newTestEnv(t, func(conf *config.ApplicationConfig) {
+		conf.Scheduler.RetryInterval = -time.Second
+	})
+	userID := e.newUser(100)
+	operation := e.scheduleOnce(dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: money(300)})
+	scheduler := e.service.GetScheduleService().(*scheduleService)
+
+	scheduler.executeDue(e.ctx)
+	operation = e.scheduledOperation(operation.Id)
+	if operation.Status != dto.ScheduledActive || operation.Attempts != 1 || operation.Occurrences[0].Status != dto.OccurrenceRetrying {
+		t.Fatalf("expected operation to be retried, got %+v", operation)
+	}
+
+	scheduler.executeDue(e.ctx)
+	operation = e.scheduledOperation(operation.Id)
+	if operation.Status != dto.ScheduledFailed || operation.LastError == nil || operation.Occurrences[0].Status != dto.OccurrenceFailed {
+		t.Fatalf("expected operation to fail, got %+v", operation)
+	}
+
+	e.requireBalance(userID, 100)
+}
+
+func TestScheduledOperationValidation(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	scheduleService := e.service.GetScheduleService()
+
+	_, err, isInternal := scheduleService.CreateScheduledOperationRequest(e.ctx, dto.ScheduledOperationRequest{Operation: dto.OperationCredit, UserId: userID, Sum: money(100), RunAt: time.Now().Format(time.RFC3339)})
+	requireUserError(t, err, isInternal, "operation must be withdraw or transfer")
+
+	_, err, isInternal = scheduleService.CreateScheduledOperationRequest(e.ctx, dto.ScheduledOperationRequest{Operation: dto.OperationTransfer, UserId: userID, Sum: money(100), RunAt: time.Now().Format(time.RFC3339)})
+	requireUserError(t, err, isInternal, "receiver_id cannot be empty")
+
+	_, err, isInternal = scheduleService.CreateScheduledOperationRequest(e.ctx, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: money(100)})
+	requireUserError(t, err, isInternal, "One of run_at and schedule must be set")
+
+	_, err, isInternal = scheduleService.CreateScheduledOperationRequest(e.ctx, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: money(100), Schedule: "every day"})
+	if err == nil || isInternal {
+		t.Fatalf("expected user error for incorrect schedule, got %v (internal: %v)", err, isInternal)
+	}
+
+	readOnly := auth.WithClient(e.ctx, &auth.Client{Name: "reader", Scopes: map[string]bool{auth.ScopeRead: true}})
+	_, err, isInternal = scheduleService.CreateScheduledOperationRequest(readOnly, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: money(100), RunAt: time.Now().Format(time.RFC3339)})
+	requireCode(t, err, isInternal, dto.ErrCodeForbidden)
+}
+
+func TestCancelScheduledOperation(t *testing.T) {
+	e := newTestEnv(t)
+	userID := e.newUser(1000)
+	operation, err, isInternal := e.service.GetScheduleService().CreateScheduledOperationRequest(e.ctx, dto.ScheduledOperationRequest{Operation: dto.OperationWithdraw, UserId: userID, Sum: money(100), Schedule: "0 9 * * *"})
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetScheduleService().CancelScheduledOperationRequest(e.ctx, operation.Id)
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetScheduleService().CancelScheduledOperationRequest(e.ctx, operation.Id)
+	requireUserError(t, err, isInternal, "Scheduled operation is already cancelled")
+
+	operations, err, isInternal := e.service.GetScheduleService().GetScheduledOperationsRequest(e.ctx, userID, 10, 0)
+	requireNoError(t, err, isInternal)
+	if len(operations) != 1 || operations[0].Status != dto.ScheduledCancelled {
+		t.Fatalf("unexpected operations %+v", operations)
+	}
+}
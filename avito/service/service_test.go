@@ -0,0 +1,180 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"avito/storage/memory"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+var testSystemAccounts = config.SystemAccountsConfig{
+	Revenue:    "00000000-0000-0000-0000-000000000001",
+	Promotions: "00000000-0000-0000-0000-000000000002",
+	Clearing:   "00000000-0000-0000-0000-000000000003",
+	Escrow:     "00000000-0000-0000-0000-000000000004",
+}
+
+func testConfig() *config.ApplicationConfig {
+	return &config.ApplicationConfig{
+		Webhook: config.WebhookConfig{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Timeout:        time.Second,
+		},
+		Scheduler: config.SchedulerConfig{
+			Interval:      time.Hour,
+			RetryInterval: time.Hour,
+			MaxAttempts:   2,
+			BatchSize:     10,
+		},
+		Escrow:         config.EscrowConfig{DefaultTTL: time.Hour, CheckInterval: time.Hour},
+		Invoice:        config.InvoiceConfig{CheckInterval: time.Hour},
+		MoneyRequest:   config.MoneyRequestConfig{DefaultTTL: time.Hour, CheckInterval: time.Hour},
+		SystemAccounts: testSystemAccounts,
+	}
+}
+
+// testEnv - сервис поверх хранилища в памяти со служебными счетами из testSystemAccounts
+type testEnv struct {
+	t       testing.TB
+	ctx     context.Context
+	storage storage.StorageAPI
+	service ServiceAPI
+	system  SystemAccounts
+}
+
+func newTestEnv(t testing.TB, configure ...func(conf *config.ApplicationConfig)) *testEnv {
+	conf := testConfig()
+	for _, fn := range configure {
+		fn(conf)
+	}
+
+	api := memory.NewStorageAPI()
+	e := &testEnv{
+		t:       t,
+		ctx:     context.Background(),
+		storage: api,
+		service: NewServiceAPI(api, conf),
+		system:  NewSystemAccounts(conf.SystemAccounts),
+	}
+	if err := e.service.GetSystemAccountService().EnsureSystemAccounts(e.ctx); err != nil {
+		t.Fatalf("EnsureSystemAccounts: %v", err)
+	}
+
+	return e
+}
+
+// newUser создает счет с балансом sum копеек, при sum = 0 счет появится с первым зачислением
+func (e *testEnv) newUser(sum int64) uuid.UUID {
+	e.t.Helper()
+	userID := uuid.New()
+	if sum > 0 {
+		e.credit(userID, sum)
+	}
+
+	return userID
+}
+
+func (e *testEnv) credit(userID uuid.UUID, sum int64) {
+	e.t.Helper()
+	err, _ := e.service.GetBalanceService().CreditFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(sum)})
+	if err != nil {
+		e.t.Fatalf("credit %d to %v: %v", sum, userID, err)
+	}
+}
+
+func (e *testEnv) balance(userID uuid.UUID) int64 {
+	e.t.Helper()
+	// счета, на который еще ничего не зачисляли, нет в хранилище
+	balance, err := e.storage.GetBalanceStorage().GetBalance(userID)
+	if err == storage.ErrNoRows {
+		return 0
+	}
+	if err != nil {
+		e.t.Fatalf("get balance of %v: %v", userID, err)
+	}
+
+	return balance
+}
+
+func (e *testEnv) requireBalance(userID uuid.UUID, expected int64) {
+	e.t.Helper()
+	if balance := e.balance(userID); balance != expected {
+		e.t.Fatalf("balance of %v is %d, expected %d", userID, balance, expected)
+	}
+}
+
+// requireBalanced проверяет, что сумма по всем счетам равна нулю и балансы совпадают с движениями
+func (e *testEnv) requireBalanced() {
+	e.t.Helper()
+	report, err, _ := e.service.GetSystemAccountService().GetSystemAccountsRequest(e.ctx)
+	if err != nil {
+		e.t.Fatalf("GetSystemAccountsRequest: %v", err)
+	}
+	if !report.Balanced {
+		e.t.Fatalf("ledger is not balanced, total %v", report.Total)
+	}
+
+	reconcile, err, _ := e.service.GetLedgerService().ReconcileRequest(e.ctx, false, "")
+	if err != nil {
+		e.t.Fatalf("ReconcileRequest: %v", err)
+	}
+	if len(reconcile.Mismatches) != 0 {
+		e.t.Fatalf("balances do not match postings: %+v", reconcile.Mismatches)
+	}
+}
+
+func money(sum int64) *dto.Money {
+	return &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+}
+
+func requireNoError(t testing.TB, err error, isInternal bool) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error (internal: %v): %v", isInternal, err)
+	}
+}
+
+// requireCode проверяет, что err - пользовательская ошибка сервиса с кодом code
+func requireCode(t testing.TB, err error, isInternal bool, code string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error with code %s, got nil", code)
+	}
+	if isInternal {
+		t.Fatalf("expected user error with code %s, got internal error: %v", code, err)
+	}
+
+	var serviceError *ServiceError
+	if !xerrors.As(err, &serviceError) || serviceError.Code != code {
+		t.Fatalf("expected error with code %s, got: %v", code, err)
+	}
+}
+
+// requireUserError проверяет, что err - пользовательская ошибка с текстом message
+func requireUserError(t testing.TB, err error, isInternal bool, message string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error %q, got nil", message)
+	}
+	if isInternal {
+		t.Fatalf("expected user error %q, got internal error: %v", message, err)
+	}
+	if err.Error() != message {
+		t.Fatalf("expected error %q, got %q", message, err.Error())
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+)
+
+// SystemAccounts - идентификаторы служебных счетов платформы
+type SystemAccounts struct {
+	Revenue    uuid.UUID
+	Promotions uuid.UUID
+	Clearing   uuid.UUID
+	Escrow     uuid.UUID
+}
+
+// NewSystemAccounts разбирает идентификаторы из конфигурации, некорректный идентификатор - ошибка запуска
+func NewSystemAccounts(conf config.SystemAccountsConfig) SystemAccounts {
+	return SystemAccounts{
+		Revenue:    uuid.MustParse(conf.Revenue),
+		Promotions: uuid.MustParse(conf.Promotions),
+		Clearing:   uuid.MustParse(conf.Clearing),
+		Escrow:     uuid.MustParse(conf.Escrow),
+	}
+}
+
+func (s SystemAccounts) IDs() []uuid.UUID {
+	return []uuid.UUID{s.Revenue, s.Promotions, s.Clearing, s.Escrow}
+}
+
+func (s SystemAccounts) Contains(id uuid.UUID) bool {
+	for _, systemID := range s.IDs() {
+		if id == systemID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s SystemAccounts) names() map[string]uuid.UUID {
+	return map[string]uuid.UUID{
+		dto.SystemAccountRevenue:    s.Revenue,
+		dto.SystemAccountPromotions: s.Promotions,
+		dto.SystemAccountClearing:   s.Clearing,
+		dto.SystemAccountEscrow:     s.Escrow,
+	}
+}
+
+// checkPosting проверяет направление движения по счету пользователя: зачисление только увеличивает
+// счет, списание и комиссия только уменьшают. Служебные счета не ограничены кредитным лимитом,
+// поэтому движение в обратную сторону со встречным движением служебного счета создало бы средства из ничего
+func (s SystemAccounts) checkPosting(userID uuid.UUID, sum int64, operation string) error {
+	if sum == 0 {
+		return xerrors.Errorf("zero posting of %s to %v", operation, userID)
+	}
+	if s.Contains(userID) {
+		return nil
+	}
+
+	switch operation {
+	case dto.OperationCredit:
+		if sum < 0 {
+			return xerrors.Errorf("credit of %d decreases account %v", sum, userID)
+		}
+	case dto.OperationWithdraw, dto.OperationFee:
+		if sum > 0 {
+			return xerrors.Errorf("%s of %d increases account %v", operation, sum, userID)
+		}
+	}
+
+	return nil
+}
+
+// checkEntry проверяет направление каждого движения проводки
+func (s SystemAccounts) checkEntry(entry storage.JournalEntry) error {
+	for _, posting := range entry.Postings {
+		if err := s.checkPosting(posting.UserID, posting.Sum, posting.Operation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type systemOperationKey struct{}
+
+// withSystemOperation разрешает операции со служебными счетами, выполняемые с этим контекстом
+func withSystemOperation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemOperationKey{}, true)
+}
+
+func isSystemOperation(ctx context.Context) bool {
+	allowed, _ := ctx.Value(systemOperationKey{}).(bool)
+	return allowed
+}
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type SystemAccountServiceAPI interface {
+	// EnsureSystemAccounts создает служебные счета, которых еще нет
+	EnsureSystemAccounts(ctx context.Context) error
+	// GetSystemAccountsRequest возвращает балансы служебных счетов и сверку суммы по всем счетам
+	GetSystemAccountsRequest(ctx context.Context) (*dto.SystemAccountsReport, error, bool)
+}
+
+type systemAccountService struct {
+	storage  storage.StorageAPI
+	accounts SystemAccounts
+	log      *logger.Logger
+}
+
+func NewSystemAccountServiceAPI(api storage.StorageAPI, accounts SystemAccounts) SystemAccountServiceAPI {
+	return &systemAccountService{
+		storage:  api,
+		accounts: accounts,
+		log:      logger.New("system-account-service"),
+	}
+}
+
+func (s *systemAccountService) EnsureSystemAccounts(ctx context.Context) error {
+	for name, id := range s.accounts.names() {
+		err := s.storage.GetBalanceStorage().EnsureSystemAccount(id, name)
+		if err != nil {
+			return xerrors.Errorf("cannot create system account %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *systemAccountService) GetSystemAccountsRequest(ctx context.Context) (*dto.SystemAccountsReport, error, bool) {
+	accounts, err := s.storage.GetBalanceStorage().GetSystemAccounts()
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get system accounts from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	usersTotal, total, err := s.storage.GetBalanceStorage().GetTotals()
+	if err != nil {
+		s.log.Errorf(ctx, "Error while get totals from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return &dto.SystemAccountsReport{
+		Accounts:   accounts,
+		UsersTotal: &dto.Money{IntPart: usersTotal / 100, FracPart: usersTotal % 100},
+		Total:      &dto.Money{IntPart: total / 100, FracPart: total % 100},
+		Balanced:   total == 0,
+	}, nil, false
+}
@@ -2,39 +2,41 @@ package service
 
 import (
 	"avito/dto"
+	"avito/logger"
 	"avito/storage"
 	"context"
 	"github.com/google/uuid"
 	"golang.org/x/xerrors"
-	"log"
-	"os"
 )
 
 // последний параметр в функциях - isInternal, для определения типа ошибки в handlers
 type TransactionServiceAPI interface {
-	GetTransactionsRequest(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error, bool)
+	GetTransactionsRequest(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error, bool)
+	// GetArchivedRangesRequest возвращает месяцы, движения счета за которые выгружены в архив
+	GetArchivedRangesRequest(ctx context.Context, userID uuid.UUID) ([]dto.ArchivedRange, error, bool)
 }
 
 type transactionService struct {
 	storage storage.StorageAPI
 	ctx context.Context
-	log *log.Logger
+	log *logger.Logger
 }
 
 func NewTransactionServiceAPI(api storage.StorageAPI) TransactionServiceAPI {
 	return &transactionService{
 		storage: api,
 		ctx: context.Background(),
-		log: log.New(os.Stdout, "TRANSACTION-SERVICE: ", log.LstdFlags),
+		log: logger.New("transaction-service"),
 	}
 }
 
-func (t *transactionService) GetTransactionsRequest(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error, bool) {
-	t.log.Printf("Trying get transactions of user %v", userID)
+func (t *transactionService) GetTransactionsRequest(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error, bool) {
+	t.log.Infof(ctx, "Trying get transactions of user %v", userID)
 
-	count, err := t.storage.GetBalanceStorage().CountUsers(userID)
+	reads, _ := t.storage.GetReadStorage(isStrongConsistency(ctx))
+	count, err := reads.CountUsers(userID)
 	if err != nil {
-		t.log.Printf("Error while count users in DB, reason: %v", err)
+		t.log.Errorf(ctx, "Error while count users in DB, reason: %v", err)
 		return nil, xerrors.Errorf("System error. Contact support"), true
 	}
 
@@ -42,11 +44,26 @@ func (t *transactionService) GetTransactionsRequest(userID uuid.UUID, limit int,
 		return nil, xerrors.Errorf("User does not exist"), false
 	}
 
-	rows, err := t.storage.GetTransactionStorage().GetTransactions(userID, limit, offset)
+	rows, err := reads.GetTransactions(userID, limit, offset)
 	if err != nil {
-		t.log.Printf("Error while get transactions from DB, reason: %v", err)
+		t.log.Errorf(ctx, "Error while get transactions from DB, reason: %v", err)
 		return nil, xerrors.Errorf("System error. Contact support"), true
 	}
 
 	return rows, nil, false
-}
\ No newline at end of file
+}
+
+func (t *transactionService) GetArchivedRangesRequest(ctx context.Context, userID uuid.UUID) ([]dto.ArchivedRange, error, bool) {
+	archives, err := t.storage.GetArchiveStorage().GetArchives(&userID)
+	if err != nil {
+		t.log.Errorf(ctx, "Error while get archives from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	result := make([]dto.ArchivedRange, 0, len(archives))
+	for _, archive := range archives {
+		result = append(result, archiveToDTO(archive))
+	}
+
+	return result, nil, false
+}
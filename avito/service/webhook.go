@@ -0,0 +1,311 @@
+package service
+
+import (
+	"avito/config"
+	"avito/dto"
+	"avito/logger"
+	"avito/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// последний параметр в функциях - isInternal, для определения типа ошибки в handlers
+type WebhookServiceAPI interface {
+	RegisterWebhookRequest(ctx context.Context, webhookRequest dto.WebhookRequest) (*dto.Webhook, error, bool)
+	GetWebhooksRequest(ctx context.Context) ([]dto.Webhook, error, bool)
+	DeleteWebhookRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	GetDeliveriesRequest(ctx context.Context, webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error, bool)
+	ReplayDeliveryRequest(ctx context.Context, id uuid.UUID) (error, bool)
+	// Notify ставит событие в очередь доставки всем подписчикам, ошибки только логируются
+	Notify(ctx context.Context, event dto.WebhookEvent)
+	// ResumeDeliveries возобновляет доставки, прерванные перезапуском сервиса
+	ResumeDeliveries(ctx context.Context)
+}
+
+type webhookService struct {
+	storage storage.StorageAPI
+	conf    config.WebhookConfig
+	client  *http.Client
+	log     *logger.Logger
+}
+
+var webhookEvents = map[string]bool{
+	dto.EventCredit:     true,
+	dto.EventWithdraw:   true,
+	dto.EventTransfer:   true,
+	dto.EventLowBalance: true,
+}
+
+func NewWebhookServiceAPI(api storage.StorageAPI, conf config.WebhookConfig) WebhookServiceAPI {
+	return &webhookService{
+		storage: api,
+		conf:    conf,
+		client:  &http.Client{Timeout: conf.Timeout},
+		log:     logger.New("webhook-service"),
+	}
+}
+
+func (w *webhookService) RegisterWebhookRequest(ctx context.Context, webhookRequest dto.WebhookRequest) (*dto.Webhook, error, bool) {
+	w.log.Infof(ctx, "Trying to register webhook %v", webhookRequest)
+
+	u, err := url.Parse(webhookRequest.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, xerrors.Errorf("url must be an absolute http(s) URL"), false
+	}
+
+	if len(webhookRequest.Events) == 0 {
+		return nil, xerrors.Errorf("events cannot be empty"), false
+	}
+
+	events := make([]string, 0, len(webhookRequest.Events))
+	seen := make(map[string]bool)
+	for _, event := range webhookRequest.Events {
+		if !webhookEvents[event] {
+			return nil, xerrors.Errorf("Unknown event %q", event), false
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		w.log.Errorf(ctx, "Error while generate webhook secret, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	webhook, err := w.storage.GetWebhookStorage().CreateWebhook(webhookRequest.URL, events, hex.EncodeToString(secret))
+	if err != nil {
+		w.log.Errorf(ctx, "Error while create webhook in DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return webhook, nil, false
+}
+
+func (w *webhookService) GetWebhooksRequest(ctx context.Context) ([]dto.Webhook, error, bool) {
+	webhooks, err := w.storage.GetWebhookStorage().GetWebhooks()
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get webhooks from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	// секрет отдается только при регистрации
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil, false
+}
+
+func (w *webhookService) DeleteWebhookRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	w.log.Infof(ctx, "Trying to delete webhook %v", id)
+
+	count, err := w.storage.GetWebhookStorage().DeleteWebhook(id)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while delete webhook in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if count == 0 {
+		return xerrors.Errorf("Webhook does not exist"), false
+	}
+
+	return nil, false
+}
+
+func (w *webhookService) GetDeliveriesRequest(ctx context.Context, webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error, bool) {
+	if status != "" && status != dto.DeliveryPending && status != dto.DeliveryDelivered && status != dto.DeliveryDead {
+		return nil, xerrors.Errorf("Unknown delivery status %q", status), false
+	}
+
+	deliveries, err := w.storage.GetWebhookStorage().GetDeliveries(webhookID, status, limit, offset)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get deliveries from DB, reason: %v", err)
+		return nil, xerrors.Errorf("System error. Contact support"), true
+	}
+
+	return deliveries, nil, false
+}
+
+func (w *webhookService) ReplayDeliveryRequest(ctx context.Context, id uuid.UUID) (error, bool) {
+	w.log.Infof(ctx, "Trying to replay delivery %v", id)
+
+	delivery, err := w.storage.GetWebhookStorage().GetDelivery(id)
+	if err == storage.ErrNoRows {
+		return xerrors.Errorf("Delivery does not exist"), false
+	}
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get delivery from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	if delivery.Status == dto.DeliveryPending {
+		return xerrors.Errorf("Delivery is still in progress"), false
+	}
+
+	webhook, err := w.storage.GetWebhookStorage().GetWebhook(delivery.WebhookID)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get webhook from DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	err = w.storage.GetWebhookStorage().UpdateDelivery(delivery.Id, dto.DeliveryPending, 0, nil, nil)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while update delivery in DB, reason: %v", err)
+		return xerrors.Errorf("System error. Contact support"), true
+	}
+
+	delivery.Status = dto.DeliveryPending
+	delivery.Attempts = 0
+	go w.deliver(ctx, *webhook, *delivery)
+
+	return nil, false
+}
+
+func (w *webhookService) Notify(ctx context.Context, event dto.WebhookEvent) {
+	webhooks, err := w.storage.GetWebhookStorage().GetWebhooksByEvent(event.Event)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get webhooks for event %s, reason: %v", event.Event, err)
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	event.Id = uuid.New()
+	event.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while marshal event %s, reason: %v", event.Event, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		id, err := w.storage.GetWebhookStorage().CreateDelivery(webhook.Id, event.Event, string(payload))
+		if err != nil {
+			w.log.Errorf(ctx, "Error while create delivery for webhook %v, reason: %v", webhook.Id, err)
+			continue
+		}
+
+		delivery := dto.WebhookDelivery{Id: id, WebhookID: webhook.Id, Event: event.Event, Payload: string(payload), Status: dto.DeliveryPending}
+		go w.deliver(ctx, webhook, delivery)
+	}
+}
+
+func (w *webhookService) ResumeDeliveries(ctx context.Context) {
+	deliveries, err := w.storage.GetWebhookStorage().GetDeliveries(nil, dto.DeliveryPending, 1000, 0)
+	if err != nil {
+		w.log.Errorf(ctx, "Error while get pending deliveries from DB, reason: %v", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		webhook, err := w.storage.GetWebhookStorage().GetWebhook(delivery.WebhookID)
+		if err != nil {
+			w.log.Errorf(ctx, "Error while get webhook %v from DB, reason: %v", delivery.WebhookID, err)
+			continue
+		}
+
+		go w.deliver(ctx, *webhook, delivery)
+	}
+
+	w.log.Infof(ctx, "Resumed %d pending deliveries", len(deliveries))
+}
+
+// deliver отправляет событие, повторяя попытки с экспоненциальной задержкой;
+// после исчерпания попыток доставка попадает в dead-letter список (статус dead)
+func (w *webhookService) deliver(ctx context.Context, webhook dto.Webhook, delivery dto.WebhookDelivery) {
+	attempts := delivery.Attempts
+	for attempts < w.conf.MaxAttempts {
+		if attempts > 0 {
+			time.Sleep(w.backoff(attempts))
+		}
+		attempts++
+
+		code, err := w.send(webhook, delivery)
+		if err == nil {
+			if err := w.storage.GetWebhookStorage().UpdateDelivery(delivery.Id, dto.DeliveryDelivered, attempts, &code, nil); err != nil {
+				w.log.Errorf(ctx, "Error while update delivery %v in DB, reason: %v", delivery.Id, err)
+			}
+			return
+		}
+
+		w.log.Warnf(ctx, "Attempt %d of delivery %v failed, reason: %v", attempts, delivery.Id, err)
+
+		status := dto.DeliveryPending
+		if attempts >= w.conf.MaxAttempts {
+			status = dto.DeliveryDead
+		}
+
+		var responseCode *int
+		if code != 0 {
+			responseCode = &code
+		}
+		lastError := err.Error()
+		if err := w.storage.GetWebhookStorage().UpdateDelivery(delivery.Id, status, attempts, responseCode, &lastError); err != nil {
+			w.log.Errorf(ctx, "Error while update delivery %v in DB, reason: %v", delivery.Id, err)
+		}
+	}
+}
+
+func (w *webhookService) send(webhook dto.Webhook, delivery dto.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", webhook.Id.String())
+	req.Header.Set("X-Webhook-Delivery", delivery.Id.String())
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, xerrors.Errorf("Unexpected response status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (w *webhookService) backoff(attempt int) time.Duration {
+	delay := w.conf.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= w.conf.MaxBackoff {
+			return w.conf.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// Sign вычисляет подпись HMAC-SHA256 от строки "<timestamp>.<payload>"
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s.", timestamp)))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
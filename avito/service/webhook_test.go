@@ -0,0 +1,137 @@
+package service
+
+import (
+	"avito/dto"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type receivedEvent struct {
+	event     dto.WebhookEvent
+	valid     bool
+	eventName string
+}
+
+// newWebhookServer принимает события и проверяет их подпись секретом, который вернет secret
+func newWebhookServer(t *testing.T, status int, secret func() string) (*httptest.Server, chan receivedEvent) {
+	events := make(chan receivedEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := ioutil.ReadAll(r.Body)
+		var event dto.WebhookEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Errorf("cannot decode webhook payload %s: %v", payload, err)
+		}
+
+		signature := "sha256=" + Sign(secret(), r.Header.Get("X-Webhook-Timestamp"), payload)
+		events <- receivedEvent{event: event, valid: signature == r.Header.Get("X-Webhook-Signature"), eventName: r.Header.Get("X-Webhook-Event")}
+		w.WriteHeader(status)
+	}))
+
+	return server, events
+}
+
+func (e *testEnv) waitDelivery(webhookID uuid.UUID, status string) dto.WebhookDelivery {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err, isInternal := e.service.GetWebhookService().GetDeliveriesRequest(e.ctx, &webhookID, status, 10, 0)
+		requireNoError(e.t, err, isInternal)
+		if len(deliveries) > 0 {
+			return deliveries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	e.t.Fatalf("no %s delivery for webhook %v", status, webhookID)
+	return dto.WebhookDelivery{}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	e := newTestEnv(t)
+	var webhook *dto.Webhook
+	server, events := newWebhookServer(t, http.StatusOK, func() string { return webhook.Secret })
+	defer server.Close()
+
+	webhook, err, isInternal := e.service.GetWebhookService().RegisterWebhookRequest(e.ctx, dto.WebhookRequest{URL: server.URL, Events: []string{dto.EventCredit, dto.EventCredit}})
+	requireNoError(t, err, isInternal)
+	if webhook.Secret == "" || len(webhook.Events) != 1 {
+		t.Fatalf("unexpected webhook %+v", webhook)
+	}
+
+	userID := e.newUser(150)
+
+	select {
+	case received := <-events:
+		if !received.valid || received.eventName != dto.EventCredit || received.event.UserID != userID || *received.event.Sum != *money(150) {
+			t.Fatalf("unexpected webhook event %+v", received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook event was not delivered")
+	}
+
+	delivery := e.waitDelivery(webhook.Id, dto.DeliveryDelivered)
+	if delivery.Attempts != 1 || delivery.ResponseCode == nil || *delivery.ResponseCode != http.StatusOK {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+
+	webhooks, err, isInternal := e.service.GetWebhookService().GetWebhooksRequest(e.ctx)
+	requireNoError(t, err, isInternal)
+	if len(webhooks) != 1 || webhooks[0].Secret != "" {
+		t.Fatalf("unexpected webhooks %+v", webhooks)
+	}
+}
+
+func TestWebhookDeadLetterAndReplay(t *testing.T) {
+	e := newTestEnv(t)
+	var webhook *dto.Webhook
+	server, events := newWebhookServer(t, http.StatusInternalServerError, func() string { return webhook.Secret })
+	defer server.Close()
+
+	webhook, err, isInternal := e.service.GetWebhookService().RegisterWebhookRequest(e.ctx, dto.WebhookRequest{URL: server.URL, Events: []string{dto.EventWithdraw}})
+	requireNoError(t, err, isInternal)
+
+	userID := e.newUser(1000)
+	err, isInternal = e.service.GetBalanceService().WithdrawFundsRequest(e.ctx, dto.OperationRequest{UserId: userID, Sum: money(100)})
+	requireNoError(t, err, isInternal)
+
+	delivery := e.waitDelivery(webhook.Id, dto.DeliveryDead)
+	if delivery.Attempts != 2 || delivery.LastError == nil || len(events) != 2 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+
+	err, isInternal = e.service.GetWebhookService().ReplayDeliveryRequest(e.ctx, delivery.Id)
+	requireNoError(t, err, isInternal)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatal("replayed delivery was not sent")
+		}
+	}
+	e.waitDelivery(webhook.Id, dto.DeliveryDead)
+
+	err, isInternal = e.service.GetWebhookService().DeleteWebhookRequest(e.ctx, webhook.Id)
+	requireNoError(t, err, isInternal)
+
+	err, isInternal = e.service.GetWebhookService().ReplayDeliveryRequest(e.ctx, delivery.Id)
+	requireUserError(t, err, isInternal, "Delivery does not exist")
+}
+
+func TestRegisterWebhookValidation(t *testing.T) {
+	e := newTestEnv(t)
+	webhookService := e.service.GetWebhookService()
+
+	_, err, isInternal := webhookService.RegisterWebhookRequest(e.ctx, dto.WebhookRequest{URL: "ftp://example.com", Events: []string{dto.EventCredit}})
+	requireUserError(t, err, isInternal, "url must be an absolute http(s) URL")
+
+	_, err, isInternal = webhookService.RegisterWebhookRequest(e.ctx, dto.WebhookRequest{URL: "http://example.com"})
+	requireUserError(t, err, isInternal, "events cannot be empty")
+
+	_, err, isInternal = webhookService.RegisterWebhookRequest(e.ctx, dto.WebhookRequest{URL: "http://example.com", Events: []string{"refund"}})
+	requireUserError(t, err, isInternal, `Unknown event "refund"`)
+}
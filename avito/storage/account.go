@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"avito/db"
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+)
+
+type AccountStorageAPI interface {
+	// GetAccountStatus возвращает статус счета, блокируя строку до конца транзакции
+	GetAccountStatus(tx Tx, userID uuid.UUID) (string, error)
+	SetAccountStatus(tx Tx, userID uuid.UUID, status string, reason string) error
+	WriteStatusChange(tx Tx, userID uuid.UUID, oldStatus string, newStatus string, reason string, client string) error
+	GetAccount(userID uuid.UUID) (*dto.GetAccountStatusResponse, error)
+}
+
+type accountStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewAccountStorageAPI(connDB *db.ConnDB, ctx context.Context) AccountStorageAPI {
+	return &accountStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (a *accountStorage) GetAccountStatus(tx Tx, userID uuid.UUID) (string, error) {
+	var status string
+	err := pgTx(tx).QueryRow(a.ctx, "select status from balance where user_id=$1 for update;", userID).Scan(&status)
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+func (a *accountStorage) SetAccountStatus(tx Tx, userID uuid.UUID, status string, reason string) error {
+	_, err := pgTx(tx).Exec(a.ctx, "update balance set status=$2, status_reason=$3 where user_id=$1;", userID, status, reason)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *accountStorage) WriteStatusChange(tx Tx, userID uuid.UUID, oldStatus string, newStatus string, reason string, client string) error {
+	_, err := pgTx(tx).Exec(a.ctx, "insert into account_status_history (user_id, old_status, new_status, reason, client) values ($1, $2, $3, $4, $5);", userID, oldStatus, newStatus, reason, client)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *accountStorage) GetAccount(userID uuid.UUID) (*dto.GetAccountStatusResponse, error) {
+	result := &dto.GetAccountStatusResponse{UserId: userID}
+	err := a.db.DB.QueryRow(a.ctx, "select status, status_reason from balance where user_id=$1;", userID).Scan(&result.Status, &result.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.DB.Query(a.ctx, "select id, old_status, new_status, reason, client, created_at from account_status_history where user_id=$1 order by created_at desc;", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.History = make([]dto.AccountStatusChange, 0)
+	for rows.Next() {
+		var change dto.AccountStatusChange
+		err := rows.Scan(&change.Id, &change.OldStatus, &change.NewStatus, &change.Reason, &change.Client, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result.History = append(result.History, change)
+	}
+
+	return result, rows.Err()
+}
@@ -3,22 +3,48 @@ package storage
 import (
 	"avito/db"
 	"context"
-	"github.com/jackc/pgx/v4"
 )
 
 type StorageAPI interface {
 	GetBalanceStorage() BalanceStorageAPI
 	GetTransactionStorage() TransactionStorageAPI
-	GetTransaction(ctx context.Context) (pgx.Tx, error)
+	GetWebhookStorage() WebhookStorageAPI
+	GetLimitStorage() LimitStorageAPI
+	GetAccountStorage() AccountStorageAPI
+	GetScheduledStorage() ScheduledStorageAPI
+	GetEscrowStorage() EscrowStorageAPI
+	GetInvoiceStorage() InvoiceStorageAPI
+	GetMoneyRequestStorage() MoneyRequestStorageAPI
+	GetLedgerStorage() LedgerStorageAPI
+	GetAuditStorage() AuditStorageAPI
+	GetArchiveStorage() ArchiveStorageAPI
+	GetTransaction(ctx context.Context) (Tx, error)
+	// GetReadStorage возвращает чтения из реплики, если она настроена, отстает не больше
+	// допустимого и не требуется strong, иначе из основной базы. true - выбрана реплика
+	GetReadStorage(strong bool) (ReadStorageAPI, bool)
 }
 
 type storageAPI struct {
 	balanceStorage BalanceStorageAPI
 	transactionStorage TransactionStorageAPI
+	webhookStorage WebhookStorageAPI
+	limitStorage LimitStorageAPI
+	accountStorage AccountStorageAPI
+	scheduledStorage ScheduledStorageAPI
+	escrowStorage EscrowStorageAPI
+	invoiceStorage InvoiceStorageAPI
+	moneyRequestStorage MoneyRequestStorageAPI
+	ledgerStorage LedgerStorageAPI
+	auditStorage AuditStorageAPI
+	archiveStorage ArchiveStorageAPI
+	primaryReadStorage ReadStorageAPI
+	// nil, если реплика не настроена
+	replica *db.Replica
+	replicaReadStorage ReadStorageAPI
 	connDB *db.ConnDB
 }
 
-func (s *storageAPI) GetTransaction(ctx context.Context) (pgx.Tx, error) {
+func (s *storageAPI) GetTransaction(ctx context.Context) (Tx, error) {
 	tx, err := s.connDB.DB.Begin(ctx)
 	if err != nil {
 		return nil, err
@@ -27,6 +53,14 @@ func (s *storageAPI) GetTransaction(ctx context.Context) (pgx.Tx, error) {
 	return tx, nil
 }
 
+func (s *storageAPI) GetReadStorage(strong bool) (ReadStorageAPI, bool) {
+	if strong || s.replica == nil || !s.replica.Available() {
+		return s.primaryReadStorage, false
+	}
+
+	return s.replicaReadStorage, true
+}
+
 func (s *storageAPI) GetBalanceStorage() BalanceStorageAPI {
 	return s.balanceStorage
 }
@@ -35,10 +69,76 @@ func (s *storageAPI) GetTransactionStorage() TransactionStorageAPI {
 	return s.transactionStorage
 }
 
+func (s *storageAPI) GetWebhookStorage() WebhookStorageAPI {
+	return s.webhookStorage
+}
+
+func (s *storageAPI) GetLimitStorage() LimitStorageAPI {
+	return s.limitStorage
+}
+
+func (s *storageAPI) GetAccountStorage() AccountStorageAPI {
+	return s.accountStorage
+}
+
+func (s *storageAPI) GetScheduledStorage() ScheduledStorageAPI {
+	return s.scheduledStorage
+}
+
+func (s *storageAPI) GetEscrowStorage() EscrowStorageAPI {
+	return s.escrowStorage
+}
+
+func (s *storageAPI) GetInvoiceStorage() InvoiceStorageAPI {
+	return s.invoiceStorage
+}
+
+func (s *storageAPI) GetMoneyRequestStorage() MoneyRequestStorageAPI {
+	return s.moneyRequestStorage
+}
+
+func (s *storageAPI) GetLedgerStorage() LedgerStorageAPI {
+	return s.ledgerStorage
+}
+
+func (s *storageAPI) GetAuditStorage() AuditStorageAPI {
+	return s.auditStorage
+}
+
+func (s *storageAPI) GetArchiveStorage() ArchiveStorageAPI {
+	return s.archiveStorage
+}
+
 func NewStorageAPI(connDB *db.ConnDB, ctx context.Context) StorageAPI {
-	return &storageAPI{
-		balanceStorage: NewBalanceStorageAPI(connDB, ctx),
-		transactionStorage: NewTransactionStorageAPI(connDB, ctx),
+	return NewStorageAPIWithReplica(connDB, nil, ctx)
+}
+
+// NewStorageAPIWithReplica создает хранилище, которое выполняет чтения баланса и истории
+// на реплике, пока она не отстает больше допустимого
+func NewStorageAPIWithReplica(connDB *db.ConnDB, replica *db.Replica, ctx context.Context) StorageAPI {
+	balanceStorage := NewBalanceStorageAPI(connDB, ctx)
+	transactionStorage := NewTransactionStorageAPI(connDB, ctx)
+
+	s := &storageAPI{
+		balanceStorage: balanceStorage,
+		transactionStorage: transactionStorage,
+		webhookStorage: NewWebhookStorageAPI(connDB, ctx),
+		limitStorage: NewLimitStorageAPI(connDB, ctx),
+		accountStorage: NewAccountStorageAPI(connDB, ctx),
+		scheduledStorage: NewScheduledStorageAPI(connDB, ctx),
+		escrowStorage: NewEscrowStorageAPI(connDB, ctx),
+		invoiceStorage: NewInvoiceStorageAPI(connDB, ctx),
+		moneyRequestStorage: NewMoneyRequestStorageAPI(connDB, ctx),
+		ledgerStorage: NewLedgerStorageAPI(connDB, ctx),
+		auditStorage: NewAuditStorageAPI(connDB, ctx),
+		archiveStorage: NewArchiveStorageAPI(connDB, ctx),
+		primaryReadStorage: NewReadStorageAPI(balanceStorage, transactionStorage),
+		replica: replica,
 		connDB: connDB,
 	}
+	if replica != nil {
+		s.replicaReadStorage = NewReadStorageAPI(NewBalanceStorageAPI(replica.Conn(), ctx), NewTransactionStorageAPI(replica.Conn(), ctx))
+	}
+
+	return s
 }
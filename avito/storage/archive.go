@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"avito/db"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// ErrArchiveChanged возвращается ArchivePeriod, если в месяце не столько движений, сколько выгружено в файл
+var ErrArchiveChanged = xerrors.New("postings of archived period have changed")
+
+// TransactionArchive - месяц движений, выгруженный в файл и удаленный из "transaction"
+type TransactionArchive struct {
+	ID uuid.UUID
+	// начало месяца и начало следующего месяца
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	File        string
+	// число движений в файле, в GetArchives по счету - число движений счета
+	Postings int
+	// sha256 файла в hex
+	Checksum   string
+	ArchivedAt time.Time
+}
+
+// ArchivedAccount - итоги архивных движений счета: сумма нужна сверке балансов, последнее звено -
+// продолжению и проверке цепочки хешей
+type ArchivedAccount struct {
+	UserID   uuid.UUID
+	Postings int
+	Total    int64
+	LastSeq  int64
+	LastHash string
+}
+
+type ArchiveStorageAPI interface {
+	// EnsurePartitions создает секции "transaction" для месяцев с from по to включительно,
+	// возвращает имена созданных секций
+	EnsurePartitions(from time.Time, to time.Time) ([]string, error)
+	// GetArchivablePeriods возвращает по порядку начала месяцев до before, движения которых хранятся в базе
+	GetArchivablePeriods(before time.Time) ([]time.Time, error)
+	// WalkPeriod вызывает fn для движений месяца, начинающегося в start, по счетам, внутри счета по seq
+	WalkPeriod(start time.Time, fn func(link ChainLink) error) error
+	// ArchivePeriod удаляет движения месяца архива и записывает архив с итогами счетов
+	ArchivePeriod(tx Tx, archive TransactionArchive, accounts []ArchivedAccount) error
+	// GetArchives возвращает архивы по порядку месяцев. Если userID не nil - только архивы
+	// с движениями счета, Postings - число его движений
+	GetArchives(userID *uuid.UUID) ([]TransactionArchive, error)
+	// GetArchivedAccounts возвращает итоги архивов по счетам, если userID не nil - одного счета
+	GetArchivedAccounts(userID *uuid.UUID) ([]ArchivedAccount, error)
+}
+
+// последнее архивное звено счета, с которого продолжается его цепочка
+const archivedChainTailQuery = "select last_seq, last_hash from transaction_archive_account where user_id=$1 order by last_seq desc limit 1;"
+
+const archivedAccountsColumns = "a.user_id, sum(a.postings), sum(a.total), max(a.last_seq), " +
+	"(select l.last_hash from transaction_archive_account l where l.user_id = a.user_id order by l.last_seq desc limit 1)"
+
+type archiveStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewArchiveStorageAPI(connDB *db.ConnDB, ctx context.Context) ArchiveStorageAPI {
+	return &archiveStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+// PartitionName возвращает имя секции "transaction" месяца, начинающегося в start
+func PartitionName(start time.Time) string {
+	return fmt.Sprintf("transaction_%s", start.Format("2006_01"))
+}
+
+// MonthStart возвращает начало месяца t в UTC
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (a *archiveStorage) EnsurePartitions(from time.Time, to time.Time) ([]string, error) {
+	created := make([]string, 0)
+	for month := MonthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		var ok bool
+		err := a.db.DB.QueryRow(a.ctx, "select create_transaction_partition($1);", month).Scan(&ok)
+		if err != nil {
+			return created, err
+		}
+
+		if ok {
+			created = append(created, PartitionName(month))
+		}
+	}
+
+	return created, nil
+}
+
+func (a *archiveStorage) GetArchivablePeriods(before time.Time) ([]time.Time, error) {
+	// месяцы секций и месяцы движений, попавших в секцию по умолчанию
+	rows, err := a.db.DB.Query(a.ctx, "select p.start from ("+
+		"select to_timestamp(substring(c.relname from 13), 'YYYY_MM')::timestamp as start from pg_inherits i join pg_class c on c.oid = i.inhrelid "+
+		"where i.inhparent = '\"transaction\"'::regclass and c.relname ~ '^transaction_[0-9]{4}_[0-9]{2}$' "+
+		"union select date_trunc('month', created_at) from transaction_default) p where p.start < $1 order by p.start;", before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]time.Time, 0)
+	for rows.Next() {
+		var start time.Time
+		if err := rows.Scan(&start); err != nil {
+			return nil, err
+		}
+
+		result = append(result, start)
+	}
+
+	return result, rows.Err()
+}
+
+func (a *archiveStorage) WalkPeriod(start time.Time, fn func(link ChainLink) error) error {
+	rows, err := a.db.DB.Query(a.ctx, "select id, entry_id, user_id, seq, change_balance, operation, client, comment, created_at, prev_hash, hash from \"transaction\" "+
+		"where created_at >= $1 and created_at < $2 order by user_id, seq;", start, start.AddDate(0, 1, 0))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link ChainLink
+		err := rows.Scan(&link.ID, &link.EntryID, &link.UserID, &link.Seq, &link.Sum, &link.Operation, &link.Client, &link.Comment, &link.CreatedAt, &link.PrevHash, &link.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (a *archiveStorage) ArchivePeriod(tx Tx, archive TransactionArchive, accounts []ArchivedAccount) error {
+	var deleted int64
+	name := PartitionName(archive.PeriodStart)
+
+	var exists bool
+	err := pgTx(tx).QueryRow(a.ctx, "select to_regclass($1) is not null;", name).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	// отключение секции ждет завершения транзакций, которые в нее пишут, поэтому движения
+	// считаются после него
+	if exists {
+		partition := pgx.Identifier{name}.Sanitize()
+		if _, err := pgTx(tx).Exec(a.ctx, "alter table \"transaction\" detach partition "+partition+";"); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := pgTx(tx).QueryRow(a.ctx, "select count(*) from "+partition+";").Scan(&count); err != nil {
+			return err
+		}
+		deleted += count
+
+		if _, err := pgTx(tx).Exec(a.ctx, "drop table "+partition+";"); err != nil {
+			return err
+		}
+	}
+
+	tag, err := pgTx(tx).Exec(a.ctx, "delete from transaction_default where created_at >= $1 and created_at < $2;", archive.PeriodStart, archive.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	deleted += tag.RowsAffected()
+
+	if deleted != int64(archive.Postings) {
+		return xerrors.Errorf("%d postings in DB, %d in file: %w", deleted, archive.Postings, ErrArchiveChanged)
+	}
+
+	_, err = pgTx(tx).Exec(a.ctx, "insert into transaction_archive (id, period_start, period_end, file, postings, checksum) values ($1, $2, $3, $4, $5, $6);",
+		archive.ID, archive.PeriodStart, archive.PeriodEnd, archive.File, archive.Postings, archive.Checksum)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		_, err := pgTx(tx).Exec(a.ctx, "insert into transaction_archive_account (archive_id, user_id, postings, total, last_seq, last_hash) values ($1, $2, $3, $4, $5, $6);",
+			archive.ID, account.UserID, account.Postings, account.Total, account.LastSeq, account.LastHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archiveStorage) GetArchives(userID *uuid.UUID) ([]TransactionArchive, error) {
+	var rows pgx.Rows
+	var err error
+	if userID != nil {
+		rows, err = a.db.DB.Query(a.ctx, "select t.id, t.period_start, t.period_end, t.file, a.postings, t.checksum, t.archived_at from transaction_archive t "+
+			"join transaction_archive_account a on a.archive_id = t.id where a.user_id=$1 order by t.period_start;", *userID)
+	} else {
+		rows, err = a.db.DB.Query(a.ctx, "select id, period_start, period_end, file, postings, checksum, archived_at from transaction_archive order by period_start;")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]TransactionArchive, 0)
+	for rows.Next() {
+		var archive TransactionArchive
+		err := rows.Scan(&archive.ID, &archive.PeriodStart, &archive.PeriodEnd, &archive.File, &archive.Postings, &archive.Checksum, &archive.ArchivedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, archive)
+	}
+
+	return result, rows.Err()
+}
+
+func (a *archiveStorage) GetArchivedAccounts(userID *uuid.UUID) ([]ArchivedAccount, error) {
+	var rows pgx.Rows
+	var err error
+	if userID != nil {
+		rows, err = a.db.DB.Query(a.ctx, "select "+archivedAccountsColumns+" from transaction_archive_account a where a.user_id=$1 group by a.user_id;", *userID)
+	} else {
+		rows, err = a.db.DB.Query(a.ctx, "select "+archivedAccountsColumns+" from transaction_archive_account a group by a.user_id order by a.user_id;")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]ArchivedAccount, 0)
+	for rows.Next() {
+		var account ArchivedAccount
+		err := rows.Scan(&account.UserID, &account.Postings, &account.Total, &account.LastSeq, &account.LastHash)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"avito/db"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// запись журнала аудита об одном изменяющем запросе
+type AuditEntry struct {
+	ID          uuid.UUID
+	RequestID   string
+	Client      string
+	Method      string
+	Path        string
+	SourceIP    string
+	PayloadHash string
+	Status      int
+	Result      string
+	Error       *string
+	// счета из запроса и из движений операции
+	UserIDs        []string
+	TransactionIDs []string
+	CreatedAt      time.Time
+}
+
+// условия отбора записей аудита, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	UserID *uuid.UUID
+	Client string
+	From   *time.Time
+	To     *time.Time
+}
+
+type AuditStorageAPI interface {
+	WriteAuditEntry(entry AuditEntry) error
+	GetAuditEntries(filter AuditFilter, limit int, offset int) ([]AuditEntry, error)
+}
+
+type auditStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewAuditStorageAPI(connDB *db.ConnDB, ctx context.Context) AuditStorageAPI {
+	return &auditStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (a *auditStorage) WriteAuditEntry(entry AuditEntry) error {
+	_, err := a.db.DB.Exec(a.ctx, "insert into audit_log (request_id, client, method, path, source_ip, payload_hash, status, result, error, user_ids, transaction_ids) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);",
+		entry.RequestID, entry.Client, entry.Method, entry.Path, entry.SourceIP, entry.PayloadHash, entry.Status, entry.Result, entry.Error, entry.UserIDs, entry.TransactionIDs)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *auditStorage) GetAuditEntries(filter AuditFilter, limit int, offset int) ([]AuditEntry, error) {
+	conditions := make([]string, 0, 4)
+	args := make([]interface{}, 0, 6)
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("$%d = any(user_ids)", len(args)))
+	}
+	if filter.Client != "" {
+		args = append(args, filter.Client)
+		conditions = append(conditions, fmt.Sprintf("client = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " where " + strings.Join(conditions, " and ")
+	}
+	args = append(args, limit, offset)
+
+	rows, err := a.db.DB.Query(a.ctx, fmt.Sprintf("select id, request_id, client, method, path, source_ip, payload_hash, status, result, error, user_ids, transaction_ids, created_at from audit_log%s order by created_at desc limit $%d offset $%d;",
+		where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		err := rows.Scan(&e.ID, &e.RequestID, &e.Client, &e.Method, &e.Path, &e.SourceIP, &e.PayloadHash, &e.Status, &e.Result, &e.Error, &e.UserIDs, &e.TransactionIDs, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
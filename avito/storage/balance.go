@@ -2,16 +2,24 @@ package storage
 
 import (
 	"avito/db"
+	"avito/dto"
 	"context"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4"
 )
 
 type BalanceStorageAPI interface {
-	BalanceIncrease(tx pgx.Tx, userID uuid.UUID, sum int64) error
-	BalanceDecrease(tx pgx.Tx, userID uuid.UUID, sum int64) error
+	// LockBalance блокирует строку баланса до конца транзакции и возвращает баланс
+	LockBalance(tx Tx, userID uuid.UUID) (int64, error)
+	GetCreditLimit(tx Tx, userID uuid.UUID) (int64, error)
+	SetCreditLimit(tx Tx, userID uuid.UUID, creditLimit int64) error
+	GetOverdraftAccounts(limit int, offset int) ([]dto.OverdraftAccount, error)
 	GetBalance(userID uuid.UUID) (int64, error)
 	CountUsers(userID uuid.UUID) (int, error)
+	// EnsureSystemAccount создает служебный счет name, если его еще нет
+	EnsureSystemAccount(userID uuid.UUID, name string) error
+	GetSystemAccounts() ([]dto.SystemAccount, error)
+	// GetTotals возвращает сумму балансов пользователей и сумму по всем счетам
+	GetTotals() (int64, int64, error)
 }
 
 type balanceStorage struct {
@@ -27,17 +35,28 @@ func NewBalanceStorageAPI(connDB *db.ConnDB, ctx context.Context) BalanceStorage
 	}
 }
 
-func (c *balanceStorage) BalanceIncrease(tx pgx.Tx, userID uuid.UUID, sum int64) error {
-	_, err := tx.Exec(c.ctx,"insert into balance (user_id, amount) values ($1, $2) on conflict (user_id) do update set amount = (select amount from balance where user_id = $1) + $2;", userID, sum)
+func (c *balanceStorage) LockBalance(tx Tx, userID uuid.UUID) (int64, error) {
+	var result int64
+	err := pgTx(tx).QueryRow(c.ctx, "select amount from balance where user_id=$1 for update", userID).Scan(&result)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return result, nil
 }
 
-func (c *balanceStorage) BalanceDecrease(tx pgx.Tx, userID uuid.UUID, sum int64) error {
-	_, err := tx.Exec(c.ctx,"update balance set amount = (select amount from balance where user_id = $1) - $2 where user_id=$1;", userID, sum)
+func (c *balanceStorage) GetCreditLimit(tx Tx, userID uuid.UUID) (int64, error) {
+	var result int64
+	err := pgTx(tx).QueryRow(c.ctx, "select credit_limit from balance where user_id=$1", userID).Scan(&result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) SetCreditLimit(tx Tx, userID uuid.UUID, creditLimit int64) error {
+	_, err := pgTx(tx).Exec(c.ctx, "update balance set credit_limit=$2 where user_id=$1;", userID, creditLimit)
 	if err != nil {
 		return err
 	}
@@ -45,6 +64,30 @@ func (c *balanceStorage) BalanceDecrease(tx pgx.Tx, userID uuid.UUID, sum int64)
 	return nil
 }
 
+func (c *balanceStorage) GetOverdraftAccounts(limit int, offset int) ([]dto.OverdraftAccount, error) {
+	rows, err := c.db.DB.Query(c.ctx, "select user_id, amount, credit_limit from balance where amount < 0 and system_account is null order by amount asc limit $1 offset $2;", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.OverdraftAccount, 0)
+	for rows.Next() {
+		var account dto.OverdraftAccount
+		var amount, creditLimit int64
+		err := rows.Scan(&account.UserId, &amount, &creditLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Sum = &dto.Money{IntPart: amount / 100, FracPart: amount % 100}
+		account.CreditLimit = &dto.Money{IntPart: creditLimit / 100, FracPart: creditLimit % 100}
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
+
 func (c *balanceStorage) GetBalance(userID uuid.UUID) (int64, error) {
 	var result int64
 	err := c.db.DB.QueryRow(c.ctx, "select amount from balance where user_id=$1", userID).Scan(&result)
@@ -63,4 +106,46 @@ func (c *balanceStorage) CountUsers(userID uuid.UUID) (int, error) {
 	}
 
 	return result, nil
-}
\ No newline at end of file
+}
+
+func (c *balanceStorage) EnsureSystemAccount(userID uuid.UUID, name string) error {
+	_, err := c.db.DB.Exec(c.ctx, "insert into balance (user_id, amount, system_account) values ($1, 0, $2) on conflict (user_id) do update set system_account = excluded.system_account;", userID, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *balanceStorage) GetSystemAccounts() ([]dto.SystemAccount, error) {
+	rows, err := c.db.DB.Query(c.ctx, "select system_account, user_id, amount from balance where system_account is not null order by system_account;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.SystemAccount, 0)
+	for rows.Next() {
+		var account dto.SystemAccount
+		var amount int64
+		err := rows.Scan(&account.Name, &account.UserId, &amount)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Sum = &dto.Money{IntPart: amount / 100, FracPart: amount % 100}
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
+
+func (c *balanceStorage) GetTotals() (int64, int64, error) {
+	var usersTotal, total int64
+	err := c.db.DB.QueryRow(c.ctx, "select coalesce(sum(amount) filter (where system_account is null), 0), coalesce(sum(amount), 0) from balance;").Scan(&usersTotal, &total)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return usersTotal, total, nil
+}
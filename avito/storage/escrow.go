@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"avito/db"
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// сделка с гарантией оплаты, сумма в копейках
+type Escrow struct {
+	Id      uuid.UUID
+	DealID  string
+	PayerID uuid.UUID
+	PayeeID uuid.UUID
+	Sum     int64
+	Status  string
+}
+
+type EscrowStorageAPI interface {
+	CreateEscrow(tx Tx, escrow Escrow, expiresAt time.Time) (uuid.UUID, error)
+	// LockEscrow возвращает сделку, блокируя ее до конца транзакции
+	LockEscrow(tx Tx, dealID string) (*Escrow, error)
+	UpdateEscrowStatus(tx Tx, id uuid.UUID, status string) error
+	WriteEscrowEvent(tx Tx, escrowID uuid.UUID, status string, comment string, client string) error
+	GetEscrow(dealID string) (*dto.Escrow, error)
+	GetExpiredEscrows(now time.Time, limit int) ([]string, error)
+}
+
+type escrowStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewEscrowStorageAPI(connDB *db.ConnDB, ctx context.Context) EscrowStorageAPI {
+	return &escrowStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (e *escrowStorage) CreateEscrow(tx Tx, escrow Escrow, expiresAt time.Time) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := pgTx(tx).QueryRow(e.ctx, "insert into escrow (deal_id, payer_id, payee_id, amount, status, expires_at) values ($1, $2, $3, $4, $5, $6) returning id;",
+		escrow.DealID, escrow.PayerID, escrow.PayeeID, escrow.Sum, escrow.Status, expiresAt).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (e *escrowStorage) LockEscrow(tx Tx, dealID string) (*Escrow, error) {
+	var result Escrow
+	err := pgTx(tx).QueryRow(e.ctx, "select id, deal_id, payer_id, payee_id, amount, status from escrow where deal_id=$1 for update;", dealID).
+		Scan(&result.Id, &result.DealID, &result.PayerID, &result.PayeeID, &result.Sum, &result.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (e *escrowStorage) UpdateEscrowStatus(tx Tx, id uuid.UUID, status string) error {
+	_, err := pgTx(tx).Exec(e.ctx, "update escrow set status=$2, updated_at=current_timestamp where id=$1;", id, status)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *escrowStorage) WriteEscrowEvent(tx Tx, escrowID uuid.UUID, status string, comment string, client string) error {
+	_, err := pgTx(tx).Exec(e.ctx, "insert into escrow_event (escrow_id, status, comment, client) values ($1, $2, $3, $4);", escrowID, status, comment, client)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *escrowStorage) GetEscrow(dealID string) (*dto.Escrow, error) {
+	var result dto.Escrow
+	var sum int64
+	var expiresAt, createdAt time.Time
+	err := e.db.DB.QueryRow(e.ctx, "select id, deal_id, payer_id, payee_id, amount, status, expires_at, created_at from escrow where deal_id=$1;", dealID).
+		Scan(&result.Id, &result.DealId, &result.PayerId, &result.PayeeId, &sum, &result.Status, &expiresAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Sum = &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+	result.ExpiresAt = expiresAt.Format(time.RFC3339)
+	result.CreatedAt = createdAt.Format(time.RFC3339)
+
+	rows, err := e.db.DB.Query(e.ctx, "select status, comment, client, created_at from escrow_event where escrow_id=$1 order by created_at;", result.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.History = make([]dto.EscrowEvent, 0)
+	for rows.Next() {
+		var event dto.EscrowEvent
+		var eventCreatedAt time.Time
+		err := rows.Scan(&event.Status, &event.Comment, &event.Client, &eventCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.CreatedAt = eventCreatedAt.Format(time.RFC3339)
+		result.History = append(result.History, event)
+	}
+
+	return &result, rows.Err()
+}
+
+func (e *escrowStorage) GetExpiredEscrows(now time.Time, limit int) ([]string, error) {
+	rows, err := e.db.DB.Query(e.ctx, "select deal_id from escrow where status='funded' and expires_at <= $1 order by expires_at limit $2;", now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var dealID string
+		if err := rows.Scan(&dealID); err != nil {
+			return nil, err
+		}
+
+		result = append(result, dealID)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"avito/db"
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// счет на оплату, суммы в копейках, время в UTC
+type Invoice struct {
+	Id          uuid.UUID
+	PayerID     uuid.UUID
+	Sum         int64
+	Description string
+	DueAt       time.Time
+	Status      string
+	Items       []InvoiceItem
+	Client      string
+	PaidAt      *time.Time
+	CreatedAt   time.Time
+}
+
+type InvoiceItem struct {
+	Description string
+	Quantity    int
+	Price       int64
+}
+
+type InvoiceStorageAPI interface {
+	CreateInvoice(tx Tx, invoice Invoice) (uuid.UUID, error)
+	GetInvoice(id uuid.UUID) (*Invoice, error)
+	// GetInvoices возвращает счета плательщика, status - необязательный фильтр
+	GetInvoices(payerID uuid.UUID, status string, limit int, offset int) ([]Invoice, error)
+	// LockInvoice возвращает счет без позиций, блокируя его до конца транзакции
+	LockInvoice(tx Tx, id uuid.UUID) (*Invoice, error)
+	SetInvoiceStatus(tx Tx, id uuid.UUID, status string) error
+	CancelInvoice(id uuid.UUID) (int64, error)
+	// ExpireInvoices переводит в expired открытые счета с истекшим сроком оплаты
+	ExpireInvoices(now time.Time) (int64, error)
+}
+
+type invoiceStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+const invoiceColumns = "id, payer_id, amount, description, due_at, status, client, paid_at, created_at"
+
+func NewInvoiceStorageAPI(connDB *db.ConnDB, ctx context.Context) InvoiceStorageAPI {
+	return &invoiceStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (i *invoiceStorage) CreateInvoice(tx Tx, invoice Invoice) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := pgTx(tx).QueryRow(i.ctx, "insert into invoice (payer_id, amount, description, due_at, client) values ($1, $2, $3, $4, $5) returning id;",
+		invoice.PayerID, invoice.Sum, invoice.Description, invoice.DueAt, invoice.Client).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	for position, item := range invoice.Items {
+		_, err := pgTx(tx).Exec(i.ctx, "insert into invoice_item (invoice_id, position, description, quantity, price) values ($1, $2, $3, $4, $5);",
+			id, position, item.Description, item.Quantity, item.Price)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	return id, nil
+}
+
+func (i *invoiceStorage) GetInvoice(id uuid.UUID) (*Invoice, error) {
+	invoices, err := i.query("select "+invoiceColumns+" from invoice where id=$1;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invoices) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return &invoices[0], nil
+}
+
+func (i *invoiceStorage) GetInvoices(payerID uuid.UUID, status string, limit int, offset int) ([]Invoice, error) {
+	if status != "" {
+		return i.query("select "+invoiceColumns+" from invoice where payer_id=$1 and status=$2 order by created_at desc limit $3 offset $4;", payerID, status, limit, offset)
+	}
+
+	return i.query("select "+invoiceColumns+" from invoice where payer_id=$1 order by created_at desc limit $2 offset $3;", payerID, limit, offset)
+}
+
+func (i *invoiceStorage) LockInvoice(tx Tx, id uuid.UUID) (*Invoice, error) {
+	var o Invoice
+	err := pgTx(tx).QueryRow(i.ctx, "select "+invoiceColumns+" from invoice where id=$1 for update;", id).
+		Scan(&o.Id, &o.PayerID, &o.Sum, &o.Description, &o.DueAt, &o.Status, &o.Client, &o.PaidAt, &o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+func (i *invoiceStorage) SetInvoiceStatus(tx Tx, id uuid.UUID, status string) error {
+	_, err := pgTx(tx).Exec(i.ctx, "update invoice set status=$2, paid_at=case when $2='paid' then current_timestamp else paid_at end, updated_at=current_timestamp where id=$1;", id, status)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *invoiceStorage) CancelInvoice(id uuid.UUID) (int64, error) {
+	tag, err := i.db.DB.Exec(i.ctx, "update invoice set status='cancelled', updated_at=current_timestamp where id=$1 and status='open';", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (i *invoiceStorage) ExpireInvoices(now time.Time) (int64, error) {
+	tag, err := i.db.DB.Exec(i.ctx, "update invoice set status='expired', updated_at=current_timestamp where status='open' and due_at <= $1;", now)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// query выбирает счета и догружает их позиции одним запросом
+func (i *invoiceStorage) query(sql string, args ...interface{}) ([]Invoice, error) {
+	rows, err := i.db.DB.Query(i.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Invoice, 0)
+	ids := make([]string, 0)
+	byID := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var o Invoice
+		err := rows.Scan(&o.Id, &o.PayerID, &o.Sum, &o.Description, &o.DueAt, &o.Status, &o.Client, &o.PaidAt, &o.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		o.Items = make([]InvoiceItem, 0)
+		byID[o.Id] = len(result)
+		ids = append(ids, o.Id.String())
+		result = append(result, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	itemRows, err := i.db.DB.Query(i.ctx, "select invoice_id, description, quantity, price from invoice_item where invoice_id = any($1) order by invoice_id, position;", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var invoiceID uuid.UUID
+		var item InvoiceItem
+		if err := itemRows.Scan(&invoiceID, &item.Description, &item.Quantity, &item.Price); err != nil {
+			return nil, err
+		}
+
+		idx := byID[invoiceID]
+		result[idx].Items = append(result[idx].Items, item)
+	}
+
+	return result, itemRows.Err()
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"avito/db"
+	"avito/dto"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+// Posting - движение по одному счету, положительная сумма зачисляет, отрицательная списывает
+type Posting struct {
+	UserID    uuid.UUID
+	Sum       int64
+	Operation string
+}
+
+// JournalEntry - проводка: набор движений, сумма которых равна нулю
+type JournalEntry struct {
+	Operation string
+	Client    string
+	Comment   string
+	Postings  []Posting
+}
+
+// ChainLink - движение счета в цепочке хешей. Каждое движение хранит хеш предыдущего
+// движения того же счета (PrevHash) и хеш своего содержимого вместе с PrevHash (Hash)
+type ChainLink struct {
+	ID        uuid.UUID
+	EntryID   uuid.UUID
+	UserID    uuid.UUID
+	Seq       int64
+	Sum       int64
+	Operation string
+	Client    string
+	Comment   string
+	CreatedAt time.Time
+	PrevHash  string
+	Hash      string
+}
+
+// ComputeHash возвращает sha256 в hex от содержимого движения и хеша предыдущего движения
+func (c ChainLink) ComputeHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%d|%d|%q|%q|%q|%s", c.PrevHash, c.ID, c.EntryID, c.UserID, c.Seq, c.Sum,
+		c.Operation, c.Client, c.Comment, c.CreatedAt.Format("2006-01-02 15:04:05.999999"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var ErrUnbalancedEntry = xerrors.New("journal entry is not balanced")
+
+type LedgerStorageAPI interface {
+	// PostEntry записывает проводку и ее движения в "transaction" и изменяет балансы счетов.
+	// Возвращает идентификатор проводки и идентификаторы движений в порядке entry.Postings
+	PostEntry(tx Tx, entry JournalEntry) (uuid.UUID, []uuid.UUID, error)
+	GetJournalEntry(id uuid.UUID) (*dto.JournalEntry, error)
+	CountAccounts() (int, error)
+	// GetBalanceMismatches возвращает счета, баланс которых не равен сумме движений, включая архивные
+	GetBalanceMismatches() ([]BalanceMismatch, error)
+	// GetPostingsSum возвращает сумму и число движений счета, включая архивные
+	GetPostingsSum(tx Tx, userID uuid.UUID) (int64, int, error)
+	// AdjustBalance устанавливает баланс счета и записывает корректировку с причиной
+	AdjustBalance(tx Tx, userID uuid.UUID, oldAmount int64, newAmount int64, reason string, client string) error
+	// WalkChain вызывает fn для движений по порядку цепочек: по счетам, внутри счета по seq.
+	// Если userID не nil, обходится только цепочка этого счета. Архивные движения не обходятся:
+	// цепочка продолжается с последнего звена из ArchiveStorageAPI.GetArchivedAccounts
+	WalkChain(userID *uuid.UUID, fn func(link ChainLink) error) error
+}
+
+// суммы и число движений счетов в базе и в архиве
+const postingsTotals = "select user_id, sum(change_balance) as total, count(*) as postings from \"transaction\" group by user_id " +
+	"union all select user_id, total, postings from transaction_archive_account"
+
+// расхождение баланса счета с суммой движений, суммы в копейках
+type BalanceMismatch struct {
+	UserID      uuid.UUID
+	Balance     int64
+	PostingsSum int64
+	Postings    int
+}
+
+type ledgerStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewLedgerStorageAPI(connDB *db.ConnDB, ctx context.Context) LedgerStorageAPI {
+	return &ledgerStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (l *ledgerStorage) PostEntry(tx Tx, entry JournalEntry) (uuid.UUID, []uuid.UUID, error) {
+	var total int64
+	for _, posting := range entry.Postings {
+		total += posting.Sum
+	}
+	if len(entry.Postings) < 2 || total != 0 {
+		return uuid.Nil, nil, ErrUnbalancedEntry
+	}
+
+	var entryID uuid.UUID
+	err := pgTx(tx).QueryRow(l.ctx, "insert into journal_entry (operation, client, comment) values ($1, $2, $3) returning id;", entry.Operation, entry.Client, entry.Comment).Scan(&entryID)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	postingIDs := make([]uuid.UUID, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		// баланс счета - сумма его движений, меняется только вместе с записью движения
+		_, err := pgTx(tx).Exec(l.ctx, "insert into balance (user_id, amount) values ($1, $2) on conflict (user_id) do update set amount = balance.amount + excluded.amount;", posting.UserID, posting.Sum)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		// счет уже заблокирован изменением баланса, поэтому конец его цепочки не меняется до commit
+		link := ChainLink{EntryID: entryID, UserID: posting.UserID, Sum: posting.Sum, Operation: posting.Operation, Client: entry.Client, Comment: entry.Comment}
+		err = pgTx(tx).QueryRow(l.ctx, "select seq, hash from \"transaction\" where user_id=$1 order by seq desc limit 1;", posting.UserID).Scan(&link.Seq, &link.PrevHash)
+		if err == pgx.ErrNoRows {
+			// все движения счета могли быть выгружены в архив
+			err = pgTx(tx).QueryRow(l.ctx, archivedChainTailQuery, posting.UserID).Scan(&link.Seq, &link.PrevHash)
+		}
+		if err != nil && err != pgx.ErrNoRows {
+			return uuid.Nil, nil, err
+		}
+		link.Seq++
+
+		err = pgTx(tx).QueryRow(l.ctx, "insert into \"transaction\" (entry_id, user_id, change_balance, operation, client, comment, seq, prev_hash) values ($1, $2, $3, $4, $5, $6, $7, $8) returning id, created_at;",
+			entryID, posting.UserID, posting.Sum, posting.Operation, entry.Client, entry.Comment, link.Seq, link.PrevHash).Scan(&link.ID, &link.CreatedAt)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		// хеш включает id и created_at, которые назначает база, поэтому записывается после вставки
+		_, err = pgTx(tx).Exec(l.ctx, "update \"transaction\" set hash=$3 where id=$1 and created_at=$2;", link.ID, link.CreatedAt, link.ComputeHash())
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		postingIDs = append(postingIDs, link.ID)
+	}
+
+	return entryID, postingIDs, nil
+}
+
+func (l *ledgerStorage) GetJournalEntry(id uuid.UUID) (*dto.JournalEntry, error) {
+	var result dto.JournalEntry
+	var createdAt time.Time
+	err := l.db.DB.QueryRow(l.ctx, "select id, operation, client, comment, created_at from journal_entry where id=$1;", id).
+		Scan(&result.Id, &result.Operation, &result.Client, &result.Comment, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	result.CreatedAt = createdAt.Format(time.RFC3339)
+
+	rows, err := l.db.DB.Query(l.ctx, "select id, entry_id, user_id, change_balance, operation, client, comment, created_at from \"transaction\" where entry_id=$1 order by change_balance asc;", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.Postings = make([]dto.Transaction, 0)
+	for rows.Next() {
+		var posting dto.Transaction
+		var money int64
+		var postingCreatedAt time.Time
+		err := rows.Scan(&posting.Id, &posting.EntryId, &posting.UserID, &money, &posting.Operation, &posting.Client, &posting.Comment, &postingCreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		posting.ChangeBalance = &dto.Money{IntPart: money / 100, FracPart: money % 100}
+		posting.CreatedAt = postingCreatedAt.Format(time.RFC3339)
+		result.Postings = append(result.Postings, posting)
+	}
+
+	return &result, rows.Err()
+}
+
+func (l *ledgerStorage) CountAccounts() (int, error) {
+	var result int
+	err := l.db.DB.QueryRow(l.ctx, "select count(*) from balance;").Scan(&result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (l *ledgerStorage) GetBalanceMismatches() ([]BalanceMismatch, error) {
+	rows, err := l.db.DB.Query(l.ctx, "select b.user_id, b.amount, coalesce(t.total, 0), coalesce(t.postings, 0) from balance b "+
+		"left join (select user_id, sum(total) as total, sum(postings) as postings from ("+postingsTotals+") p group by user_id) t on t.user_id = b.user_id "+
+		"where b.amount <> coalesce(t.total, 0) order by b.user_id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]BalanceMismatch, 0)
+	for rows.Next() {
+		var mismatch BalanceMismatch
+		err := rows.Scan(&mismatch.UserID, &mismatch.Balance, &mismatch.PostingsSum, &mismatch.Postings)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, mismatch)
+	}
+
+	return result, rows.Err()
+}
+
+func (l *ledgerStorage) GetPostingsSum(tx Tx, userID uuid.UUID) (int64, int, error) {
+	var total int64
+	var postings int
+	err := pgTx(tx).QueryRow(l.ctx, "select coalesce(sum(total), 0), coalesce(sum(postings), 0) from ("+
+		"select sum(change_balance) as total, count(*) as postings from \"transaction\" where user_id=$1 "+
+		"union all select total, postings from transaction_archive_account where user_id=$1) p;", userID).Scan(&total, &postings)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return total, postings, nil
+}
+
+func (l *ledgerStorage) AdjustBalance(tx Tx, userID uuid.UUID, oldAmount int64, newAmount int64, reason string, client string) error {
+	_, err := pgTx(tx).Exec(l.ctx, "update balance set amount=$2 where user_id=$1;", userID, newAmount)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgTx(tx).Exec(l.ctx, "insert into reconciliation_adjustment (user_id, old_amount, new_amount, reason, client) values ($1, $2, $3, $4, $5);", userID, oldAmount, newAmount, reason, client)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *ledgerStorage) WalkChain(userID *uuid.UUID, fn func(link ChainLink) error) error {
+	const columns = "id, entry_id, user_id, seq, change_balance, operation, client, comment, created_at, prev_hash, hash"
+
+	var rows pgx.Rows
+	var err error
+	if userID != nil {
+		rows, err = l.db.DB.Query(l.ctx, "select "+columns+" from \"transaction\" where user_id=$1 order by seq;", *userID)
+	} else {
+		rows, err = l.db.DB.Query(l.ctx, "select "+columns+" from \"transaction\" order by user_id, seq;")
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link ChainLink
+		err := rows.Scan(&link.ID, &link.EntryID, &link.UserID, &link.Seq, &link.Sum, &link.Operation, &link.Client, &link.Comment, &link.CreatedAt, &link.PrevHash, &link.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
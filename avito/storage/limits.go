@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"avito/db"
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// индивидуальные лимиты пользователя в копейках, nil - используется значение по умолчанию
+type UserLimits struct {
+	WithdrawDaily      *int64
+	WithdrawMonthly    *int64
+	TransferDaily      *int64
+	TransferMonthly    *int64
+	SingleOperationMax *int64
+	TransfersPerDay    *int
+}
+
+type LimitStorageAPI interface {
+	GetUserLimits(userID uuid.UUID) (*UserLimits, error)
+	SetUserLimits(userID uuid.UUID, limits UserLimits) error
+	DeleteUserLimits(userID uuid.UUID) (int64, error)
+	// GetOperationTotals возвращает сумму и число списаний пользователя по операции
+	// с начала текущего периода (day или month)
+	GetOperationTotals(tx Tx, userID uuid.UUID, operation string, period string) (int64, int, error)
+}
+
+type limitStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewLimitStorageAPI(connDB *db.ConnDB, ctx context.Context) LimitStorageAPI {
+	return &limitStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (l *limitStorage) GetUserLimits(userID uuid.UUID) (*UserLimits, error) {
+	var result UserLimits
+	err := l.db.DB.QueryRow(l.ctx, "select withdraw_daily, withdraw_monthly, transfer_daily, transfer_monthly, single_operation_max, transfers_per_day from user_limit where user_id=$1;", userID).
+		Scan(&result.WithdrawDaily, &result.WithdrawMonthly, &result.TransferDaily, &result.TransferMonthly, &result.SingleOperationMax, &result.TransfersPerDay)
+	if err == pgx.ErrNoRows {
+		return &result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (l *limitStorage) SetUserLimits(userID uuid.UUID, limits UserLimits) error {
+	_, err := l.db.DB.Exec(l.ctx, "insert into user_limit (user_id, withdraw_daily, withdraw_monthly, transfer_daily, transfer_monthly, single_operation_max, transfers_per_day) values ($1, $2, $3, $4, $5, $6, $7) "+
+		"on conflict (user_id) do update set withdraw_daily=$2, withdraw_monthly=$3, transfer_daily=$4, transfer_monthly=$5, single_operation_max=$6, transfers_per_day=$7, updated_at=current_timestamp;",
+		userID, limits.WithdrawDaily, limits.WithdrawMonthly, limits.TransferDaily, limits.TransferMonthly, limits.SingleOperationMax, limits.TransfersPerDay)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *limitStorage) DeleteUserLimits(userID uuid.UUID) (int64, error) {
+	tag, err := l.db.DB.Exec(l.ctx, "delete from user_limit where user_id=$1;", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (l *limitStorage) GetOperationTotals(tx Tx, userID uuid.UUID, operation string, period string) (int64, int, error) {
+	var sum int64
+	var count int
+	err := pgTx(tx).QueryRow(l.ctx, "select coalesce(sum(-change_balance), 0), count(*) from \"transaction\" where user_id=$1 and operation=$2 and change_balance < 0 and created_at >= date_trunc($3, localtimestamp);", userID, operation, period).Scan(&sum, &count)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return sum, count, nil
+}
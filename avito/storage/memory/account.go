@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+)
+
+type accountStorage struct {
+	store *store
+}
+
+func (a *accountStorage) GetAccountStatus(tx storage.Tx, userID uuid.UUID) (string, error) {
+	b, ok := txWriter(tx).balances[userID]
+	if !ok {
+		return "", storage.ErrNoRows
+	}
+
+	return b.status, nil
+}
+
+func (a *accountStorage) SetAccountStatus(tx storage.Tx, userID uuid.UUID, status string, reason string) error {
+	w := txWriter(tx)
+	b, ok := w.balances[userID]
+	if !ok {
+		return nil
+	}
+
+	b.status = status
+	b.statusReason = reason
+	if err := b.check(); err != nil {
+		return err
+	}
+
+	w.writeBalances()[userID] = b
+	return nil
+}
+
+func (a *accountStorage) WriteStatusChange(tx storage.Tx, userID uuid.UUID, oldStatus string, newStatus string, reason string, client string) error {
+	w := txWriter(tx)
+	if _, ok := w.balances[userID]; !ok {
+		return constraintError("account %v does not exist", userID)
+	}
+
+	w.statusHistory = append(w.statusHistory, statusChange{
+		id:        uuid.New(),
+		userID:    userID,
+		oldStatus: oldStatus,
+		newStatus: newStatus,
+		reason:    reason,
+		client:    client,
+		createdAt: w.now,
+	})
+	return nil
+}
+
+func (a *accountStorage) GetAccount(userID uuid.UUID) (*dto.GetAccountStatusResponse, error) {
+	s := a.store.read()
+	b, ok := s.balances[userID]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	result := &dto.GetAccountStatusResponse{UserId: userID, Status: b.status, Reason: b.statusReason}
+	result.History = make([]dto.AccountStatusChange, 0)
+	for i := len(s.statusHistory) - 1; i >= 0; i-- {
+		change := s.statusHistory[i]
+		if change.userID != userID {
+			continue
+		}
+
+		result.History = append(result.History, dto.AccountStatusChange{
+			Id:        change.id,
+			OldStatus: change.oldStatus,
+			NewStatus: change.newStatus,
+			Reason:    change.reason,
+			Client:    change.client,
+			CreatedAt: change.createdAt.Format(timestampFormat),
+		})
+	}
+
+	return result, nil
+}
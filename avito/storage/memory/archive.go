@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"avito/storage"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"sort"
+	"time"
+)
+
+type archiveStorage struct {
+	store *store
+}
+
+// EnsurePartitions ничего не делает: месяцы архивируются удалением движений
+func (a *archiveStorage) EnsurePartitions(from time.Time, to time.Time) ([]string, error) {
+	return []string{}, nil
+}
+
+func (a *archiveStorage) GetArchivablePeriods(before time.Time) ([]time.Time, error) {
+	s := a.store.read()
+
+	months := make(map[time.Time]bool)
+	for _, link := range s.postings {
+		if link.CreatedAt.Before(before) {
+			months[storage.MonthStart(link.CreatedAt)] = true
+		}
+	}
+
+	result := make([]time.Time, 0, len(months))
+	for month := range months {
+		result = append(result, month)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Before(result[j])
+	})
+
+	return result, nil
+}
+
+func (a *archiveStorage) WalkPeriod(start time.Time, fn func(link storage.ChainLink) error) error {
+	s := a.store.read()
+	end := start.AddDate(0, 1, 0)
+
+	userIDs := make([]uuid.UUID, 0, len(s.userPostings))
+	for id := range s.userPostings {
+		userIDs = append(userIDs, id)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		return userIDs[i].String() < userIDs[j].String()
+	})
+
+	for _, id := range userIDs {
+		for _, i := range s.userPostings[id] {
+			link := s.postings[i]
+			if link.CreatedAt.Before(start) || !link.CreatedAt.Before(end) {
+				continue
+			}
+
+			if err := fn(link); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ArchivePeriod пересобирает движения, индексы счетов и проводок без движений месяца:
+// опубликованный state ссылается на прежние срезы и не меняется
+func (a *archiveStorage) ArchivePeriod(tx storage.Tx, archive storage.TransactionArchive, accounts []storage.ArchivedAccount) error {
+	w := txWriter(tx)
+	for _, account := range accounts {
+		if _, ok := w.balances[account.UserID]; !ok {
+			return constraintError("account %v does not exist", account.UserID)
+		}
+	}
+
+	// новые индексы движений, -1 - движение удалено
+	moved := make([]int, len(w.postings))
+	postings := make([]storage.ChainLink, 0, len(w.postings))
+	for i, link := range w.postings {
+		if !link.CreatedAt.Before(archive.PeriodStart) && link.CreatedAt.Before(archive.PeriodEnd) {
+			moved[i] = -1
+			continue
+		}
+
+		moved[i] = len(postings)
+		postings = append(postings, link)
+	}
+
+	if deleted := len(w.postings) - len(postings); deleted != archive.Postings {
+		return xerrors.Errorf("%d postings in DB, %d in file: %w", deleted, archive.Postings, storage.ErrArchiveChanged)
+	}
+
+	userPostings := make(map[uuid.UUID][]int, len(w.userPostings))
+	for userID, chain := range w.userPostings {
+		rest := make([]int, 0, len(chain))
+		for _, i := range chain {
+			if moved[i] >= 0 {
+				rest = append(rest, moved[i])
+			}
+		}
+		if len(rest) > 0 {
+			userPostings[userID] = rest
+		}
+	}
+
+	entries := make([]journalEntry, len(w.entries))
+	for i, entry := range w.entries {
+		rest := make([]int, 0, len(entry.postings))
+		for _, p := range entry.postings {
+			if moved[p] >= 0 {
+				rest = append(rest, moved[p])
+			}
+		}
+		entry.postings = rest
+		entries[i] = entry
+	}
+
+	w.postings, w.userPostings, w.entries = postings, userPostings, entries
+	w.copied |= copiedUserPostings
+
+	archive.ArchivedAt = w.now
+	w.archives = append(w.archives, archive)
+	for _, account := range accounts {
+		w.archivedAccounts = append(w.archivedAccounts, archivedAccount{archiveID: archive.ID, ArchivedAccount: account})
+	}
+
+	return nil
+}
+
+func (a *archiveStorage) GetArchives(userID *uuid.UUID) ([]storage.TransactionArchive, error) {
+	s := a.store.read()
+
+	result := make([]storage.TransactionArchive, 0)
+	for _, archive := range s.archives {
+		if userID != nil {
+			postings := 0
+			for _, account := range s.archivedAccounts {
+				if account.archiveID == archive.ID && account.UserID == *userID {
+					postings = account.Postings
+				}
+			}
+			if postings == 0 {
+				continue
+			}
+			archive.Postings = postings
+		}
+
+		result = append(result, archive)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].PeriodStart.Before(result[j].PeriodStart)
+	})
+
+	return result, nil
+}
+
+func (a *archiveStorage) GetArchivedAccounts(userID *uuid.UUID) ([]storage.ArchivedAccount, error) {
+	s := a.store.read()
+
+	userIDs := make([]uuid.UUID, 0)
+	if userID != nil {
+		userIDs = append(userIDs, *userID)
+	} else {
+		seen := make(map[uuid.UUID]bool)
+		for _, account := range s.archivedAccounts {
+			if !seen[account.UserID] {
+				seen[account.UserID] = true
+				userIDs = append(userIDs, account.UserID)
+			}
+		}
+		sort.Slice(userIDs, func(i, j int) bool {
+			return userIDs[i].String() < userIDs[j].String()
+		})
+	}
+
+	result := make([]storage.ArchivedAccount, 0, len(userIDs))
+	for _, id := range userIDs {
+		if account, ok := archivedTotals(s, id); ok {
+			result = append(result, account)
+		}
+	}
+
+	return result, nil
+}
+
+// archivedTotals суммирует итоги архивов счета, false - архивных движений у счета нет
+func archivedTotals(s *state, userID uuid.UUID) (storage.ArchivedAccount, bool) {
+	result := storage.ArchivedAccount{UserID: userID}
+	found := false
+	for _, account := range s.archivedAccounts {
+		if account.UserID != userID {
+			continue
+		}
+
+		found = true
+		result.Postings += account.Postings
+		result.Total += account.Total
+		if account.LastSeq > result.LastSeq {
+			result.LastSeq, result.LastHash = account.LastSeq, account.LastHash
+		}
+	}
+
+	return result, found
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+)
+
+type auditStorage struct {
+	store *store
+}
+
+func (a *auditStorage) WriteAuditEntry(entry storage.AuditEntry) error {
+	if entry.Result != dto.AuditResultSuccess && entry.Result != dto.AuditResultFailure {
+		return constraintError("unknown audit result %q", entry.Result)
+	}
+
+	return a.store.update(func(w *writer) error {
+		entry.ID = uuid.New()
+		entry.UserIDs = append(make([]string, 0, len(entry.UserIDs)), entry.UserIDs...)
+		entry.TransactionIDs = append(make([]string, 0, len(entry.TransactionIDs)), entry.TransactionIDs...)
+		entry.CreatedAt = w.now
+		w.audit = append(w.audit, entry)
+		return nil
+	})
+}
+
+func (a *auditStorage) GetAuditEntries(filter storage.AuditFilter, limit int, offset int) ([]storage.AuditEntry, error) {
+	audit := a.store.read().audit
+
+	entries := make([]storage.AuditEntry, 0)
+	for i := len(audit) - 1; i >= 0; i-- {
+		entry := audit[i]
+		if filter.UserID != nil && !containsID(entry.UserIDs, *filter.UserID) {
+			continue
+		}
+		if filter.Client != "" && entry.Client != filter.Client {
+			continue
+		}
+		if filter.From != nil && entry.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !entry.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	start, end := page(len(entries), limit, offset)
+	return entries[start:end], nil
+}
+
+func containsID(ids []string, id uuid.UUID) bool {
+	for _, value := range ids {
+		if value == id.String() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"sort"
+)
+
+type balanceStorage struct {
+	store *store
+}
+
+func (c *balanceStorage) LockBalance(tx storage.Tx, userID uuid.UUID) (int64, error) {
+	b, ok := txWriter(tx).balances[userID]
+	if !ok {
+		return 0, storage.ErrNoRows
+	}
+
+	return b.amount, nil
+}
+
+func (c *balanceStorage) GetCreditLimit(tx storage.Tx, userID uuid.UUID) (int64, error) {
+	b, ok := txWriter(tx).balances[userID]
+	if !ok {
+		return 0, storage.ErrNoRows
+	}
+
+	return b.creditLimit, nil
+}
+
+func (c *balanceStorage) SetCreditLimit(tx storage.Tx, userID uuid.UUID, creditLimit int64) error {
+	w := txWriter(tx)
+	b, ok := w.balances[userID]
+	if !ok {
+		return nil
+	}
+
+	b.creditLimit = creditLimit
+	if err := b.check(); err != nil {
+		return err
+	}
+
+	w.writeBalances()[userID] = b
+	return nil
+}
+
+func (c *balanceStorage) GetOverdraftAccounts(limit int, offset int) ([]dto.OverdraftAccount, error) {
+	accounts := make([]balance, 0)
+	for _, b := range c.store.read().balances {
+		if b.amount < 0 && b.systemAccount == nil {
+			accounts = append(accounts, b)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].amount != accounts[j].amount {
+			return accounts[i].amount < accounts[j].amount
+		}
+		return accounts[i].userID.String() < accounts[j].userID.String()
+	})
+
+	start, end := page(len(accounts), limit, offset)
+	result := make([]dto.OverdraftAccount, 0, end-start)
+	for _, b := range accounts[start:end] {
+		result = append(result, dto.OverdraftAccount{UserId: b.userID, Sum: toMoney(b.amount), CreditLimit: toMoney(b.creditLimit)})
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) GetBalance(userID uuid.UUID) (int64, error) {
+	b, ok := c.store.read().balances[userID]
+	if !ok {
+		return 0, storage.ErrNoRows
+	}
+
+	return b.amount, nil
+}
+
+func (c *balanceStorage) CountUsers(userID uuid.UUID) (int, error) {
+	if _, ok := c.store.read().balances[userID]; ok {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (c *balanceStorage) EnsureSystemAccount(userID uuid.UUID, name string) error {
+	return c.store.update(func(w *writer) error {
+		for _, b := range w.balances {
+			if b.systemAccount != nil && *b.systemAccount == name && b.userID != userID {
+				return constraintError("system account %q already exists", name)
+			}
+		}
+
+		b, ok := w.balances[userID]
+		if !ok {
+			b = balance{userID: userID, status: dto.AccountActive}
+		}
+		b.systemAccount = &name
+
+		w.writeBalances()[userID] = b
+		return nil
+	})
+}
+
+func (c *balanceStorage) GetSystemAccounts() ([]dto.SystemAccount, error) {
+	result := make([]dto.SystemAccount, 0)
+	for _, b := range c.store.read().balances {
+		if b.systemAccount != nil {
+			result = append(result, dto.SystemAccount{Name: *b.systemAccount, UserId: b.userID, Sum: toMoney(b.amount)})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func (c *balanceStorage) GetTotals() (int64, int64, error) {
+	var usersTotal, total int64
+	for _, b := range c.store.read().balances {
+		if b.systemAccount == nil {
+			usersTotal += b.amount
+		}
+		total += b.amount
+	}
+
+	return usersTotal, total, nil
+}
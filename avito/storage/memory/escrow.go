@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+type escrowStorage struct {
+	store *store
+}
+
+var escrowStatuses = map[string]bool{dto.EscrowFunded: true, dto.EscrowReleased: true, dto.EscrowCancelled: true, dto.EscrowExpired: true}
+
+func (e *escrowStorage) CreateEscrow(tx storage.Tx, escrowData storage.Escrow, expiresAt time.Time) (uuid.UUID, error) {
+	if escrowData.Sum <= 0 {
+		return uuid.Nil, constraintError("amount of escrow must be positive")
+	}
+	if !escrowStatuses[escrowData.Status] {
+		return uuid.Nil, constraintError("unknown escrow status %q", escrowData.Status)
+	}
+
+	w := txWriter(tx)
+	if _, ok := w.escrows[escrowData.DealID]; ok {
+		return uuid.Nil, constraintError("escrow with deal id %q already exists", escrowData.DealID)
+	}
+
+	escrowData.Id = uuid.New()
+	w.writeEscrows()[escrowData.DealID] = escrow{Escrow: escrowData, expiresAt: pgTime(expiresAt), createdAt: w.now}
+	return escrowData.Id, nil
+}
+
+func (e *escrowStorage) LockEscrow(tx storage.Tx, dealID string) (*storage.Escrow, error) {
+	found, ok := txWriter(tx).escrows[dealID]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return &found.Escrow, nil
+}
+
+func (e *escrowStorage) UpdateEscrowStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	if !escrowStatuses[status] {
+		return constraintError("unknown escrow status %q", status)
+	}
+
+	w := txWriter(tx)
+	for dealID, found := range w.escrows {
+		if found.Id == id {
+			found.Status = status
+			w.writeEscrows()[dealID] = found
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (e *escrowStorage) WriteEscrowEvent(tx storage.Tx, escrowID uuid.UUID, status string, comment string, client string) error {
+	w := txWriter(tx)
+	found := false
+	for _, escrow := range w.escrows {
+		found = found || escrow.Id == escrowID
+	}
+	if !found {
+		return constraintError("escrow %v does not exist", escrowID)
+	}
+
+	events := w.writeEscrowEvents()
+	events[escrowID] = append(events[escrowID], escrowEvent{status: status, comment: comment, client: client, createdAt: w.now})
+	return nil
+}
+
+func (e *escrowStorage) GetEscrow(dealID string) (*dto.Escrow, error) {
+	s := e.store.read()
+	found, ok := s.escrows[dealID]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	result := &dto.Escrow{
+		Id:        found.Id,
+		DealId:    found.DealID,
+		PayerId:   found.PayerID,
+		PayeeId:   found.PayeeID,
+		Sum:       toMoney(found.Sum),
+		Status:    found.Status,
+		ExpiresAt: found.expiresAt.Format(time.RFC3339),
+		CreatedAt: found.createdAt.Format(time.RFC3339),
+		History:   make([]dto.EscrowEvent, 0),
+	}
+	for _, event := range s.escrowEvents[found.Id] {
+		result.History = append(result.History, dto.EscrowEvent{
+			Status:    event.status,
+			Comment:   event.comment,
+			Client:    event.client,
+			CreatedAt: event.createdAt.Format(time.RFC3339),
+		})
+	}
+
+	return result, nil
+}
+
+func (e *escrowStorage) GetExpiredEscrows(now time.Time, limit int) ([]string, error) {
+	expired := make([]escrow, 0)
+	for _, found := range e.store.read().escrows {
+		if found.Status == dto.EscrowFunded && !found.expiresAt.After(now) {
+			expired = append(expired, found)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].expiresAt.Equal(expired[j].expiresAt) {
+			return expired[i].expiresAt.Before(expired[j].expiresAt)
+		}
+		return expired[i].DealID < expired[j].DealID
+	})
+
+	start, end := page(len(expired), limit, 0)
+	result := make([]string, 0, end-start)
+	for _, found := range expired[start:end] {
+		result = append(result, found.DealID)
+	}
+
+	return result, nil
+}
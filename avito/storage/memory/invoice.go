@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"time"
+)
+
+type invoiceStorage struct {
+	store *store
+}
+
+var invoiceStatuses = map[string]bool{dto.InvoiceOpen: true, dto.InvoicePaid: true, dto.InvoiceCancelled: true, dto.InvoiceExpired: true}
+
+func (i *invoiceStorage) CreateInvoice(tx storage.Tx, invoice storage.Invoice) (uuid.UUID, error) {
+	if invoice.Sum <= 0 {
+		return uuid.Nil, constraintError("amount of invoice must be positive")
+	}
+	for _, item := range invoice.Items {
+		if item.Quantity <= 0 || item.Price < 0 {
+			return uuid.Nil, constraintError("invoice item %q has incorrect quantity or price", item.Description)
+		}
+	}
+
+	w := txWriter(tx)
+	created := storage.Invoice{
+		Id:          uuid.New(),
+		PayerID:     invoice.PayerID,
+		Sum:         invoice.Sum,
+		Description: invoice.Description,
+		DueAt:       pgTime(invoice.DueAt),
+		Status:      dto.InvoiceOpen,
+		Items:       append(make([]storage.InvoiceItem, 0, len(invoice.Items)), invoice.Items...),
+		Client:      invoice.Client,
+		CreatedAt:   w.now,
+	}
+	w.writeInvoices()[created.Id] = created
+	w.invoiceOrder = append(w.invoiceOrder, created.Id)
+
+	return created.Id, nil
+}
+
+func (i *invoiceStorage) GetInvoice(id uuid.UUID) (*storage.Invoice, error) {
+	invoice, ok := i.store.read().invoices[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return copyInvoice(invoice), nil
+}
+
+func (i *invoiceStorage) GetInvoices(payerID uuid.UUID, status string, limit int, offset int) ([]storage.Invoice, error) {
+	s := i.store.read()
+
+	invoices := make([]storage.Invoice, 0)
+	for j := len(s.invoiceOrder) - 1; j >= 0; j-- {
+		invoice := s.invoices[s.invoiceOrder[j]]
+		if invoice.PayerID != payerID || (status != "" && invoice.Status != status) {
+			continue
+		}
+		invoices = append(invoices, *copyInvoice(invoice))
+	}
+
+	start, end := page(len(invoices), limit, offset)
+	return invoices[start:end], nil
+}
+
+func (i *invoiceStorage) LockInvoice(tx storage.Tx, id uuid.UUID) (*storage.Invoice, error) {
+	invoice, ok := txWriter(tx).invoices[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	result := *copyInvoice(invoice)
+	result.Items = nil
+	return &result, nil
+}
+
+func (i *invoiceStorage) SetInvoiceStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	if !invoiceStatuses[status] {
+		return constraintError("unknown invoice status %q", status)
+	}
+
+	w := txWriter(tx)
+	invoice, ok := w.invoices[id]
+	if !ok {
+		return nil
+	}
+
+	invoice.Status = status
+	if status == dto.InvoicePaid {
+		paidAt := w.now
+		invoice.PaidAt = &paidAt
+	}
+	w.writeInvoices()[id] = invoice
+	return nil
+}
+
+func (i *invoiceStorage) CancelInvoice(id uuid.UUID) (int64, error) {
+	var cancelled int64
+	err := i.store.update(func(w *writer) error {
+		invoice, ok := w.invoices[id]
+		if !ok || invoice.Status != dto.InvoiceOpen {
+			return nil
+		}
+
+		invoice.Status = dto.InvoiceCancelled
+		w.writeInvoices()[id] = invoice
+		cancelled = 1
+		return nil
+	})
+
+	return cancelled, err
+}
+
+func (i *invoiceStorage) ExpireInvoices(now time.Time) (int64, error) {
+	var expired int64
+	err := i.store.update(func(w *writer) error {
+		for id, invoice := range w.invoices {
+			if invoice.Status != dto.InvoiceOpen || invoice.DueAt.After(now) {
+				continue
+			}
+
+			invoice.Status = dto.InvoiceExpired
+			w.writeInvoices()[id] = invoice
+			expired++
+		}
+		return nil
+	})
+
+	return expired, err
+}
+
+// copyInvoice возвращает копию счета, которую вызывающий может изменять
+func copyInvoice(invoice storage.Invoice) *storage.Invoice {
+	invoice.Items = append(make([]storage.InvoiceItem, 0, len(invoice.Items)), invoice.Items...)
+	if invoice.PaidAt != nil {
+		paidAt := *invoice.PaidAt
+		invoice.PaidAt = &paidAt
+	}
+
+	return &invoice
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+type ledgerStorage struct {
+	store *store
+}
+
+var ledgerOperations = map[string]bool{"credit": true, "withdraw": true, "transfer": true, "fee": true}
+
+func (l *ledgerStorage) PostEntry(tx storage.Tx, entry storage.JournalEntry) (uuid.UUID, []uuid.UUID, error) {
+	var total int64
+	for _, posting := range entry.Postings {
+		total += posting.Sum
+	}
+	if len(entry.Postings) < 2 || total != 0 {
+		return uuid.Nil, nil, storage.ErrUnbalancedEntry
+	}
+	for _, posting := range entry.Postings {
+		if !ledgerOperations[posting.Operation] {
+			return uuid.Nil, nil, constraintError("unknown operation %q", posting.Operation)
+		}
+	}
+
+	w := txWriter(tx)
+	journal := journalEntry{
+		id:        uuid.New(),
+		operation: entry.Operation,
+		client:    entry.Client,
+		comment:   entry.Comment,
+		createdAt: w.now,
+		postings:  make([]int, 0, len(entry.Postings)),
+	}
+
+	// проверки выполняются до записи, чтобы ошибка не оставила проводку записанной частично
+	balances := make(map[uuid.UUID]balance, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		b, ok := balances[posting.UserID]
+		if !ok {
+			b, ok = w.balances[posting.UserID]
+			if !ok {
+				b = balance{userID: posting.UserID, status: dto.AccountActive}
+			}
+		}
+
+		b.amount += posting.Sum
+		if err := b.check(); err != nil {
+			return uuid.Nil, nil, err
+		}
+		balances[posting.UserID] = b
+	}
+
+	writeBalances := w.writeBalances()
+	userPostings := w.writeUserPostings()
+	postingIDs := make([]uuid.UUID, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		link := storage.ChainLink{
+			ID:        uuid.New(),
+			EntryID:   journal.id,
+			UserID:    posting.UserID,
+			Seq:       1,
+			Sum:       posting.Sum,
+			Operation: posting.Operation,
+			Client:    entry.Client,
+			Comment:   entry.Comment,
+			CreatedAt: w.now,
+		}
+		if chain := userPostings[posting.UserID]; len(chain) > 0 {
+			last := w.postings[chain[len(chain)-1]]
+			link.Seq = last.Seq + 1
+			link.PrevHash = last.Hash
+		} else if archived, ok := archivedTotals(&w.state, posting.UserID); ok {
+			// все движения счета выгружены в архив
+			link.Seq = archived.LastSeq + 1
+			link.PrevHash = archived.LastHash
+		}
+		link.Hash = link.ComputeHash()
+
+		journal.postings = append(journal.postings, len(w.postings))
+		userPostings[posting.UserID] = append(userPostings[posting.UserID], len(w.postings))
+		w.postings = append(w.postings, link)
+		postingIDs = append(postingIDs, link.ID)
+	}
+	for userID, b := range balances {
+		writeBalances[userID] = b
+	}
+	w.entries = append(w.entries, journal)
+
+	return journal.id, postingIDs, nil
+}
+
+func (l *ledgerStorage) GetJournalEntry(id uuid.UUID) (*dto.JournalEntry, error) {
+	s := l.store.read()
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if entry.id != id {
+			continue
+		}
+
+		result := &dto.JournalEntry{
+			Id:        entry.id,
+			Operation: entry.operation,
+			Client:    entry.client,
+			Comment:   entry.comment,
+			CreatedAt: entry.createdAt.Format(time.RFC3339),
+			Postings:  make([]dto.Transaction, 0, len(entry.postings)),
+		}
+		postings := append([]int(nil), entry.postings...)
+		sort.SliceStable(postings, func(i, j int) bool {
+			return s.postings[postings[i]].Sum < s.postings[postings[j]].Sum
+		})
+		for _, p := range postings {
+			posting := transactionToDTO(s.postings[p])
+			posting.CreatedAt = s.postings[p].CreatedAt.Format(time.RFC3339)
+			result.Postings = append(result.Postings, posting)
+		}
+
+		return result, nil
+	}
+
+	return nil, storage.ErrNoRows
+}
+
+func (l *ledgerStorage) CountAccounts() (int, error) {
+	return len(l.store.read().balances), nil
+}
+
+func (l *ledgerStorage) GetBalanceMismatches() ([]storage.BalanceMismatch, error) {
+	s := l.store.read()
+	result := make([]storage.BalanceMismatch, 0)
+	for userID, b := range s.balances {
+		total, postings := postingsSum(s, userID)
+		if b.amount != total {
+			result = append(result, storage.BalanceMismatch{UserID: userID, Balance: b.amount, PostingsSum: total, Postings: postings})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UserID.String() < result[j].UserID.String()
+	})
+
+	return result, nil
+}
+
+func (l *ledgerStorage) GetPostingsSum(tx storage.Tx, userID uuid.UUID) (int64, int, error) {
+	total, postings := postingsSum(&txWriter(tx).state, userID)
+	return total, postings, nil
+}
+
+func (l *ledgerStorage) AdjustBalance(tx storage.Tx, userID uuid.UUID, oldAmount int64, newAmount int64, reason string, client string) error {
+	w := txWriter(tx)
+	b, ok := w.balances[userID]
+	if !ok {
+		return constraintError("account %v does not exist", userID)
+	}
+
+	b.amount = newAmount
+	if err := b.check(); err != nil {
+		return err
+	}
+
+	w.writeBalances()[userID] = b
+	w.adjustments = append(w.adjustments, adjustment{
+		userID:    userID,
+		oldAmount: oldAmount,
+		newAmount: newAmount,
+		reason:    reason,
+		client:    client,
+		createdAt: w.now,
+	})
+	return nil
+}
+
+func (l *ledgerStorage) WalkChain(userID *uuid.UUID, fn func(link storage.ChainLink) error) error {
+	s := l.store.read()
+
+	userIDs := make([]uuid.UUID, 0)
+	if userID != nil {
+		userIDs = append(userIDs, *userID)
+	} else {
+		for id := range s.userPostings {
+			userIDs = append(userIDs, id)
+		}
+		sort.Slice(userIDs, func(i, j int) bool {
+			return userIDs[i].String() < userIDs[j].String()
+		})
+	}
+
+	for _, id := range userIDs {
+		for _, i := range s.userPostings[id] {
+			if err := fn(s.postings[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// postingsSum возвращает сумму и число движений счета вместе с архивными
+func postingsSum(s *state, userID uuid.UUID) (int64, int) {
+	archived, _ := archivedTotals(s, userID)
+	total := archived.Total
+	for _, i := range s.userPostings[userID] {
+		total += s.postings[i].Sum
+	}
+
+	return total, archived.Postings + len(s.userPostings[userID])
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"avito/storage"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+type limitStorage struct {
+	store *store
+}
+
+func (l *limitStorage) GetUserLimits(userID uuid.UUID) (*storage.UserLimits, error) {
+	result := l.store.read().limits[userID]
+	return &result, nil
+}
+
+func (l *limitStorage) SetUserLimits(userID uuid.UUID, limits storage.UserLimits) error {
+	for _, value := range []*int64{limits.WithdrawDaily, limits.WithdrawMonthly, limits.TransferDaily, limits.TransferMonthly, limits.SingleOperationMax} {
+		if value != nil && *value < 0 {
+			return constraintError("limits of %v are negative", userID)
+		}
+	}
+	if limits.TransfersPerDay != nil && *limits.TransfersPerDay < 0 {
+		return constraintError("limits of %v are negative", userID)
+	}
+
+	return l.store.update(func(w *writer) error {
+		w.writeLimits()[userID] = limits
+		return nil
+	})
+}
+
+func (l *limitStorage) DeleteUserLimits(userID uuid.UUID) (int64, error) {
+	var deleted int64
+	err := l.store.update(func(w *writer) error {
+		if _, ok := w.limits[userID]; ok {
+			delete(w.writeLimits(), userID)
+			deleted = 1
+		}
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (l *limitStorage) GetOperationTotals(tx storage.Tx, userID uuid.UUID, operation string, period string) (int64, int, error) {
+	w := txWriter(tx)
+
+	var start time.Time
+	switch period {
+	case "day":
+		start = time.Date(w.now.Year(), w.now.Month(), w.now.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		start = time.Date(w.now.Year(), w.now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return 0, 0, xerrors.Errorf("unknown period %q", period)
+	}
+
+	var sum int64
+	var count int
+	for _, i := range w.userPostings[userID] {
+		posting := w.postings[i]
+		if posting.Operation == operation && posting.Sum < 0 && !posting.CreatedAt.Before(start) {
+			sum -= posting.Sum
+			count++
+		}
+	}
+
+	return sum, count, nil
+}
@@ -0,0 +1,507 @@
+// Package memory - хранилище, целиком находящееся в памяти процесса. Повторяет поведение
+// хранилища Postgres, включая ограничения схемы, и используется в тестах и для локального запуска
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"sync"
+	"time"
+)
+
+// ErrConstraint возвращается, если изменение нарушает ограничение схемы: check, unique или внешний ключ
+var ErrConstraint = xerrors.New("constraint violation")
+
+var errTxClosed = xerrors.New("tx is closed")
+
+func constraintError(format string, args ...interface{}) error {
+	return xerrors.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrConstraint)
+}
+
+// так Postgres выводит значения TIMESTAMP в текстовом виде
+const timestampFormat = "2006-01-02 15:04:05.999999"
+
+// pgTime приводит время к точности и зоне колонки TIMESTAMP
+func pgTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func toMoney(sum int64) *dto.Money {
+	return &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+}
+
+// page возвращает границы среза длины n для limit и offset
+func page(n int, limit int, offset int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit >= 0 && offset+limit < n {
+		end = offset + limit
+	}
+
+	return offset, end
+}
+
+type balance struct {
+	userID        uuid.UUID
+	amount        int64
+	creditLimit   int64
+	status        string
+	statusReason  string
+	systemAccount *string
+}
+
+// check проверяет ограничения строки balance
+func (b balance) check() error {
+	if b.creditLimit < 0 {
+		return constraintError("credit_limit of %v is negative", b.userID)
+	}
+	if b.systemAccount == nil && b.amount < -b.creditLimit {
+		return constraintError("balance of %v is below credit limit", b.userID)
+	}
+	switch b.status {
+	case dto.AccountActive, dto.AccountFrozenDebits, dto.AccountBlocked, dto.AccountClosed:
+	default:
+		return constraintError("unknown account status %q", b.status)
+	}
+
+	return nil
+}
+
+type statusChange struct {
+	id        uuid.UUID
+	userID    uuid.UUID
+	oldStatus string
+	newStatus string
+	reason    string
+	client    string
+	createdAt time.Time
+}
+
+type journalEntry struct {
+	id        uuid.UUID
+	operation string
+	client    string
+	comment   string
+	createdAt time.Time
+	// индексы движений проводки в state.postings
+	postings []int
+}
+
+type adjustment struct {
+	userID    uuid.UUID
+	oldAmount int64
+	newAmount int64
+	reason    string
+	client    string
+	createdAt time.Time
+}
+
+type archivedAccount struct {
+	archiveID uuid.UUID
+	storage.ArchivedAccount
+}
+
+type occurrenceKey struct {
+	operationID  uuid.UUID
+	scheduledFor time.Time
+}
+
+type occurrence struct {
+	status    string
+	attempts  int
+	lastError *string
+	updatedAt time.Time
+}
+
+type escrow struct {
+	storage.Escrow
+	expiresAt time.Time
+	createdAt time.Time
+}
+
+type escrowEvent struct {
+	status    string
+	comment   string
+	client    string
+	createdAt time.Time
+}
+
+// state - содержимое хранилища. Опубликованный state не изменяется: транзакция копирует
+// изменяемые таблицы при первой записи, а в журналы только дописывает строки за их текущей длиной
+type state struct {
+	balances      map[uuid.UUID]balance
+	statusHistory []statusChange
+	limits        map[uuid.UUID]storage.UserLimits
+	entries       []journalEntry
+	postings      []storage.ChainLink
+	// индексы движений счета в postings в порядке seq
+	userPostings  map[uuid.UUID][]int
+	adjustments   []adjustment
+	webhooks      []dto.Webhook
+	deliveries    map[uuid.UUID]dto.WebhookDelivery
+	deliveryOrder []uuid.UUID
+	scheduled     map[uuid.UUID]storage.ScheduledOperation
+	scheduleOrder []uuid.UUID
+	occurrences   map[occurrenceKey]occurrence
+	escrows       map[string]escrow
+	escrowEvents  map[uuid.UUID][]escrowEvent
+	invoices      map[uuid.UUID]storage.Invoice
+	invoiceOrder  []uuid.UUID
+	requests      map[uuid.UUID]storage.MoneyRequest
+	requestOrder  []uuid.UUID
+	audit         []storage.AuditEntry
+	archives      []storage.TransactionArchive
+	// итоги счетов по архивам
+	archivedAccounts []archivedAccount
+}
+
+func newState() *state {
+	return &state{
+		balances:     make(map[uuid.UUID]balance),
+		limits:       make(map[uuid.UUID]storage.UserLimits),
+		userPostings: make(map[uuid.UUID][]int),
+		deliveries:   make(map[uuid.UUID]dto.WebhookDelivery),
+		scheduled:    make(map[uuid.UUID]storage.ScheduledOperation),
+		occurrences:  make(map[occurrenceKey]occurrence),
+		escrows:      make(map[string]escrow),
+		escrowEvents: make(map[uuid.UUID][]escrowEvent),
+		invoices:     make(map[uuid.UUID]storage.Invoice),
+		requests:     make(map[uuid.UUID]storage.MoneyRequest),
+	}
+}
+
+// таблицы, скопированные транзакцией
+const (
+	copiedBalances = 1 << iota
+	copiedLimits
+	copiedUserPostings
+	copiedDeliveries
+	copiedScheduled
+	copiedOccurrences
+	copiedEscrows
+	copiedEscrowEvents
+	copiedInvoices
+	copiedRequests
+)
+
+// writer - изменения одной транзакции поверх опубликованного state
+type writer struct {
+	state
+	// время начала транзакции, как current_timestamp в Postgres
+	now    time.Time
+	copied int
+}
+
+func (w *writer) copy(table int) bool {
+	if w.copied&table != 0 {
+		return false
+	}
+	w.copied |= table
+	return true
+}
+
+func (w *writer) writeBalances() map[uuid.UUID]balance {
+	if w.copy(copiedBalances) {
+		balances := make(map[uuid.UUID]balance, len(w.balances)+1)
+		for k, v := range w.balances {
+			balances[k] = v
+		}
+		w.balances = balances
+	}
+
+	return w.balances
+}
+
+func (w *writer) writeLimits() map[uuid.UUID]storage.UserLimits {
+	if w.copy(copiedLimits) {
+		limits := make(map[uuid.UUID]storage.UserLimits, len(w.limits)+1)
+		for k, v := range w.limits {
+			limits[k] = v
+		}
+		w.limits = limits
+	}
+
+	return w.limits
+}
+
+func (w *writer) writeUserPostings() map[uuid.UUID][]int {
+	if w.copy(copiedUserPostings) {
+		userPostings := make(map[uuid.UUID][]int, len(w.userPostings)+1)
+		for k, v := range w.userPostings {
+			userPostings[k] = v
+		}
+		w.userPostings = userPostings
+	}
+
+	return w.userPostings
+}
+
+func (w *writer) writeDeliveries() map[uuid.UUID]dto.WebhookDelivery {
+	if w.copy(copiedDeliveries) {
+		deliveries := make(map[uuid.UUID]dto.WebhookDelivery, len(w.deliveries)+1)
+		for k, v := range w.deliveries {
+			deliveries[k] = v
+		}
+		w.deliveries = deliveries
+	}
+
+	return w.deliveries
+}
+
+func (w *writer) writeScheduled() map[uuid.UUID]storage.ScheduledOperation {
+	if w.copy(copiedScheduled) {
+		scheduled := make(map[uuid.UUID]storage.ScheduledOperation, len(w.scheduled)+1)
+		for k, v := range w.scheduled {
+			scheduled[k] = v
+		}
+		w.scheduled = scheduled
+	}
+
+	return w.scheduled
+}
+
+func (w *writer) writeOccurrences() map[occurrenceKey]occurrence {
+	if w.copy(copiedOccurrences) {
+		occurrences := make(map[occurrenceKey]occurrence, len(w.occurrences)+1)
+		for k, v := range w.occurrences {
+			occurrences[k] = v
+		}
+		w.occurrences = occurrences
+	}
+
+	return w.occurrences
+}
+
+func (w *writer) writeEscrows() map[string]escrow {
+	if w.copy(copiedEscrows) {
+		escrows := make(map[string]escrow, len(w.escrows)+1)
+		for k, v := range w.escrows {
+			escrows[k] = v
+		}
+		w.escrows = escrows
+	}
+
+	return w.escrows
+}
+
+func (w *writer) writeEscrowEvents() map[uuid.UUID][]escrowEvent {
+	if w.copy(copiedEscrowEvents) {
+		events := make(map[uuid.UUID][]escrowEvent, len(w.escrowEvents)+1)
+		for k, v := range w.escrowEvents {
+			events[k] = v
+		}
+		w.escrowEvents = events
+	}
+
+	return w.escrowEvents
+}
+
+func (w *writer) writeInvoices() map[uuid.UUID]storage.Invoice {
+	if w.copy(copiedInvoices) {
+		invoices := make(map[uuid.UUID]storage.Invoice, len(w.invoices)+1)
+		for k, v := range w.invoices {
+			invoices[k] = v
+		}
+		w.invoices = invoices
+	}
+
+	return w.invoices
+}
+
+func (w *writer) writeRequests() map[uuid.UUID]storage.MoneyRequest {
+	if w.copy(copiedRequests) {
+		requests := make(map[uuid.UUID]storage.MoneyRequest, len(w.requests)+1)
+		for k, v := range w.requests {
+			requests[k] = v
+		}
+		w.requests = requests
+	}
+
+	return w.requests
+}
+
+// store хранит опубликованный state. Пишущие транзакции выполняются по одной, поэтому
+// блокировки строк не нужны, а чтения вне транзакции видят последний опубликованный state
+type store struct {
+	mu        sync.RWMutex
+	committed *state
+	// удерживается от начала пишущей транзакции до commit или rollback
+	writeMu sync.Mutex
+}
+
+func (s *store) read() *state {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.committed
+}
+
+func (s *store) begin() *writer {
+	s.writeMu.Lock()
+	return &writer{state: *s.read(), now: pgTime(time.Now())}
+}
+
+func (s *store) commit(w *writer) {
+	committed := w.state
+	s.mu.Lock()
+	s.committed = &committed
+	s.mu.Unlock()
+	s.writeMu.Unlock()
+}
+
+func (s *store) rollback() {
+	s.writeMu.Unlock()
+}
+
+// update выполняет fn в отдельной транзакции, как одиночный запрос вне транзакции в Postgres
+func (s *store) update(fn func(w *writer) error) error {
+	w := s.begin()
+	committed := false
+	defer func() {
+		if !committed {
+			s.rollback()
+		}
+	}()
+
+	if err := fn(w); err != nil {
+		return err
+	}
+
+	committed = true
+	s.commit(w)
+	return nil
+}
+
+type memoryTx struct {
+	store  *store
+	w      *writer
+	mu     sync.Mutex
+	closed bool
+}
+
+func (t *memoryTx) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errTxClosed
+	}
+
+	t.closed = true
+	t.store.commit(t.w)
+	return nil
+}
+
+func (t *memoryTx) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return errTxClosed
+	}
+
+	t.closed = true
+	t.store.rollback()
+	return nil
+}
+
+// txWriter возвращает изменения транзакции, созданной GetTransaction
+func txWriter(tx storage.Tx) *writer {
+	return tx.(*memoryTx).w
+}
+
+type storageAPI struct {
+	store               *store
+	balanceStorage      storage.BalanceStorageAPI
+	transactionStorage  storage.TransactionStorageAPI
+	webhookStorage      storage.WebhookStorageAPI
+	limitStorage        storage.LimitStorageAPI
+	accountStorage      storage.AccountStorageAPI
+	scheduledStorage    storage.ScheduledStorageAPI
+	escrowStorage       storage.EscrowStorageAPI
+	invoiceStorage      storage.InvoiceStorageAPI
+	moneyRequestStorage storage.MoneyRequestStorageAPI
+	ledgerStorage       storage.LedgerStorageAPI
+	auditStorage        storage.AuditStorageAPI
+	archiveStorage      storage.ArchiveStorageAPI
+}
+
+func NewStorageAPI() storage.StorageAPI {
+	s := &store{committed: newState()}
+	return &storageAPI{
+		store:               s,
+		balanceStorage:      &balanceStorage{store: s},
+		transactionStorage:  &transactionStorage{store: s},
+		webhookStorage:      &webhookStorage{store: s},
+		limitStorage:        &limitStorage{store: s},
+		accountStorage:      &accountStorage{store: s},
+		scheduledStorage:    &scheduledStorage{store: s},
+		escrowStorage:       &escrowStorage{store: s},
+		invoiceStorage:      &invoiceStorage{store: s},
+		moneyRequestStorage: &moneyRequestStorage{store: s},
+		ledgerStorage:       &ledgerStorage{store: s},
+		auditStorage:        &auditStorage{store: s},
+		archiveStorage:      &archiveStorage{store: s},
+	}
+}
+
+// GetTransaction начинает транзакцию. Пока она не завершена, другие изменения ждут
+func (s *storageAPI) GetTransaction(ctx context.Context) (storage.Tx, error) {
+	return &memoryTx{store: s.store, w: s.store.begin()}, nil
+}
+
+// GetReadStorage всегда читает из основного хранилища: реплик у него нет
+func (s *storageAPI) GetReadStorage(strong bool) (storage.ReadStorageAPI, bool) {
+	return storage.NewReadStorageAPI(s.balanceStorage, s.transactionStorage), false
+}
+
+func (s *storageAPI) GetBalanceStorage() storage.BalanceStorageAPI {
+	return s.balanceStorage
+}
+
+func (s *storageAPI) GetTransactionStorage() storage.TransactionStorageAPI {
+	return s.transactionStorage
+}
+
+func (s *storageAPI) GetWebhookStorage() storage.WebhookStorageAPI {
+	return s.webhookStorage
+}
+
+func (s *storageAPI) GetLimitStorage() storage.LimitStorageAPI {
+	return s.limitStorage
+}
+
+func (s *storageAPI) GetAccountStorage() storage.AccountStorageAPI {
+	return s.accountStorage
+}
+
+func (s *storageAPI) GetScheduledStorage() storage.ScheduledStorageAPI {
+	return s.scheduledStorage
+}
+
+func (s *storageAPI) GetEscrowStorage() storage.EscrowStorageAPI {
+	return s.escrowStorage
+}
+
+func (s *storageAPI) GetInvoiceStorage() storage.InvoiceStorageAPI {
+	return s.invoiceStorage
+}
+
+func (s *storageAPI) GetMoneyRequestStorage() storage.MoneyRequestStorageAPI {
+	return s.moneyRequestStorage
+}
+
+func (s *storageAPI) GetLedgerStorage() storage.LedgerStorageAPI {
+	return s.ledgerStorage
+}
+
+func (s *storageAPI) GetAuditStorage() storage.AuditStorageAPI {
+	return s.auditStorage
+}
+
+func (s *storageAPI) GetArchiveStorage() storage.ArchiveStorageAPI {
+	return s.archiveStorage
+}
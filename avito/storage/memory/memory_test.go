@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"avito/storage/storagetest"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"testing"
+)
+
+var clearing = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+
+func newTestStorage(t *testing.T) storage.StorageAPI {
+	api := NewStorageAPI()
+	if err := api.GetBalanceStorage().EnsureSystemAccount(clearing, "clearing"); err != nil {
+		t.Fatalf("EnsureSystemAccount: %v", err)
+	}
+
+	return api
+}
+
+func credit(userID uuid.UUID, sum int64) storage.JournalEntry {
+	return storage.JournalEntry{
+		Operation: dto.OperationCredit,
+		Postings: []storage.Posting{
+			{UserID: userID, Sum: sum, Operation: dto.OperationCredit},
+			{UserID: clearing, Sum: -sum, Operation: dto.OperationCredit},
+		},
+	}
+}
+
+func post(t *testing.T, api storage.StorageAPI, entry storage.JournalEntry) error {
+	t.Helper()
+	tx, err := api.GetTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+
+	if _, _, err := api.GetLedgerStorage().PostEntry(tx, entry); err != nil {
+		tx.Rollback(context.Background())
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
+func TestRollbackDiscardsChanges(t *testing.T) {
+	api := newTestStorage(t)
+	userID := uuid.New()
+
+	tx, err := api.GetTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+	if _, _, err := api.GetLedgerStorage().PostEntry(tx, credit(userID, 100)); err != nil {
+		t.Fatalf("PostEntry: %v", err)
+	}
+
+	// изменения незавершенной транзакции не видны чтениям вне ее
+	if count, _ := api.GetBalanceStorage().CountUsers(userID); count != 0 {
+		t.Fatalf("uncommitted account is visible")
+	}
+
+	if err := tx.Rollback(context.Background()); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if err := tx.Commit(context.Background()); err == nil {
+		t.Fatalf("expected error on commit of closed transaction")
+	}
+
+	if _, err := api.GetBalanceStorage().GetBalance(userID); err != storage.ErrNoRows {
+		t.Fatalf("expected ErrNoRows after rollback, got %v", err)
+	}
+	if err := post(t, api, credit(userID, 100)); err != nil {
+		t.Fatalf("PostEntry after rollback: %v", err)
+	}
+	if balance, _ := api.GetBalanceStorage().GetBalance(userID); balance != 100 {
+		t.Fatalf("unexpected balance %d", balance)
+	}
+}
+
+func TestBalanceCheck(t *testing.T) {
+	api := newTestStorage(t)
+	userID := uuid.New()
+	if err := post(t, api, credit(userID, 100)); err != nil {
+		t.Fatalf("PostEntry: %v", err)
+	}
+
+	err := post(t, api, credit(userID, -101))
+	if !xerrors.Is(err, ErrConstraint) {
+		t.Fatalf("expected constraint violation, got %v", err)
+	}
+
+	tx, _ := api.GetTransaction(context.Background())
+	if err := api.GetBalanceStorage().SetCreditLimit(tx, userID, 50); err != nil {
+		t.Fatalf("SetCreditLimit: %v", err)
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if err := post(t, api, credit(userID, -150)); err != nil {
+		t.Fatalf("expected overdraft within credit limit, got %v", err)
+	}
+	if err := post(t, api, credit(userID, -1)); !xerrors.Is(err, ErrConstraint) {
+		t.Fatalf("expected constraint violation, got %v", err)
+	}
+
+	if balance, _ := api.GetBalanceStorage().GetBalance(userID); balance != -50 {
+		t.Fatalf("unexpected balance %d", balance)
+	}
+	if balance, _ := api.GetBalanceStorage().GetBalance(clearing); balance != 50 {
+		t.Fatalf("unexpected clearing balance %d", balance)
+	}
+}
+
+func TestUnbalancedEntry(t *testing.T) {
+	api := newTestStorage(t)
+	entry := credit(uuid.New(), 100)
+	entry.Postings[1].Sum = -99
+
+	if err := post(t, api, entry); err != storage.ErrUnbalancedEntry {
+		t.Fatalf("expected ErrUnbalancedEntry, got %v", err)
+	}
+}
+
+func TestChainDetectsTampering(t *testing.T) {
+	api := newTestStorage(t)
+	userID := uuid.New()
+	for i := 0; i < 3; i++ {
+		if err := post(t, api, credit(userID, 100)); err != nil {
+			t.Fatalf("PostEntry: %v", err)
+		}
+	}
+
+	links := make([]storage.ChainLink, 0)
+	api.GetLedgerStorage().WalkChain(&userID, func(link storage.ChainLink) error {
+		links = append(links, link)
+		return nil
+	})
+	if len(links) != 3 || links[2].Seq != 3 || links[2].PrevHash != links[1].Hash || links[0].PrevHash != "" {
+		t.Fatalf("unexpected chain %+v", links)
+	}
+	for _, link := range links {
+		if link.ComputeHash() != link.Hash {
+			t.Fatalf("hash of %v does not match", link.ID)
+		}
+	}
+
+	// изменение суммы движения в обход PostEntry ломает его хеш
+	s := api.(*storageAPI).store.read()
+	s.postings[s.userPostings[userID][1]].Sum = 1000
+
+	api.GetLedgerStorage().WalkChain(&userID, func(link storage.ChainLink) error {
+		if (link.ComputeHash() == link.Hash) != (link.Seq != 2) {
+			t.Fatalf("unexpected hash check result for seq %d", link.Seq)
+		}
+		return nil
+	})
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageAPI {
+		return NewStorageAPI()
+	})
+}
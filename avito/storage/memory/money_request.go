@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"time"
+)
+
+type moneyRequestStorage struct {
+	store *store
+}
+
+var moneyRequestStatuses = map[string]bool{dto.MoneyRequestPending: true, dto.MoneyRequestAccepted: true, dto.MoneyRequestDeclined: true, dto.MoneyRequestExpired: true}
+
+func (m *moneyRequestStorage) CreateMoneyRequest(request storage.MoneyRequest) (uuid.UUID, error) {
+	if request.Sum <= 0 {
+		return uuid.Nil, constraintError("amount of money request must be positive")
+	}
+	if request.RequesterID == request.PayerID {
+		return uuid.Nil, constraintError("requester and payer of money request must differ")
+	}
+
+	id := uuid.New()
+	err := m.store.update(func(w *writer) error {
+		w.writeRequests()[id] = storage.MoneyRequest{
+			Id:          id,
+			RequesterID: request.RequesterID,
+			PayerID:     request.PayerID,
+			Sum:         request.Sum,
+			Message:     request.Message,
+			Status:      dto.MoneyRequestPending,
+			ExpiresAt:   pgTime(request.ExpiresAt),
+			Client:      request.Client,
+			CreatedAt:   w.now,
+		}
+		w.requestOrder = append(w.requestOrder, id)
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (m *moneyRequestStorage) GetMoneyRequest(id uuid.UUID) (*storage.MoneyRequest, error) {
+	request, ok := m.store.read().requests[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return &request, nil
+}
+
+func (m *moneyRequestStorage) GetPendingMoneyRequests(userID uuid.UUID, now time.Time, limit int, offset int) ([]storage.MoneyRequest, error) {
+	s := m.store.read()
+
+	requests := make([]storage.MoneyRequest, 0)
+	for i := len(s.requestOrder) - 1; i >= 0; i-- {
+		request := s.requests[s.requestOrder[i]]
+		if request.RequesterID != userID && request.PayerID != userID {
+			continue
+		}
+		if request.Status != dto.MoneyRequestPending || !request.ExpiresAt.After(now) {
+			continue
+		}
+		requests = append(requests, request)
+	}
+
+	start, end := page(len(requests), limit, offset)
+	return requests[start:end], nil
+}
+
+func (m *moneyRequestStorage) LockMoneyRequest(tx storage.Tx, id uuid.UUID) (*storage.MoneyRequest, error) {
+	request, ok := txWriter(tx).requests[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return &request, nil
+}
+
+func (m *moneyRequestStorage) SetMoneyRequestStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	if !moneyRequestStatuses[status] {
+		return constraintError("unknown money request status %q", status)
+	}
+
+	w := txWriter(tx)
+	request, ok := w.requests[id]
+	if !ok {
+		return nil
+	}
+
+	request.Status = status
+	w.writeRequests()[id] = request
+	return nil
+}
+
+func (m *moneyRequestStorage) DeclineMoneyRequest(id uuid.UUID) (int64, error) {
+	var declined int64
+	err := m.store.update(func(w *writer) error {
+		request, ok := w.requests[id]
+		if !ok || request.Status != dto.MoneyRequestPending {
+			return nil
+		}
+
+		request.Status = dto.MoneyRequestDeclined
+		w.writeRequests()[id] = request
+		declined = 1
+		return nil
+	})
+
+	return declined, err
+}
+
+func (m *moneyRequestStorage) ExpireMoneyRequests(now time.Time) (int64, error) {
+	var expired int64
+	err := m.store.update(func(w *writer) error {
+		for id, request := range w.requests {
+			if request.Status != dto.MoneyRequestPending || request.ExpiresAt.After(now) {
+				continue
+			}
+
+			request.Status = dto.MoneyRequestExpired
+			w.writeRequests()[id] = request
+			expired++
+		}
+		return nil
+	})
+
+	return expired, err
+}
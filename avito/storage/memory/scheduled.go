@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+type scheduledStorage struct {
+	store *store
+}
+
+var (
+	scheduledOperations = map[string]bool{"withdraw": true, "transfer": true}
+	scheduledStatuses   = map[string]bool{dto.ScheduledActive: true, dto.ScheduledCompleted: true, dto.ScheduledCancelled: true, dto.ScheduledFailed: true}
+	occurrenceStatuses  = map[string]bool{dto.OccurrenceSucceeded: true, dto.OccurrenceRetrying: true, dto.OccurrenceFailed: true}
+)
+
+func (s *scheduledStorage) CreateScheduledOperation(operation storage.ScheduledOperation) (uuid.UUID, error) {
+	if !scheduledOperations[operation.Operation] {
+		return uuid.Nil, constraintError("unknown operation %q", operation.Operation)
+	}
+	if operation.Sum <= 0 {
+		return uuid.Nil, constraintError("amount of scheduled operation must be positive")
+	}
+
+	id := uuid.New()
+	err := s.store.update(func(w *writer) error {
+		created := storage.ScheduledOperation{
+			Id:            id,
+			Operation:     operation.Operation,
+			UserID:        operation.UserID,
+			ReceiverID:    copyUUID(operation.ReceiverID),
+			Sum:           operation.Sum,
+			Schedule:      operation.Schedule,
+			NextRunAt:     pgTime(operation.NextRunAt),
+			NextAttemptAt: pgTime(operation.NextRunAt),
+			Status:        dto.ScheduledActive,
+			Client:        operation.Client,
+			CreatedAt:     w.now,
+		}
+		w.writeScheduled()[id] = created
+		w.scheduleOrder = append(w.scheduleOrder, id)
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (s *scheduledStorage) GetScheduledOperation(id uuid.UUID) (*storage.ScheduledOperation, error) {
+	operation, ok := s.store.read().scheduled[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return &operation, nil
+}
+
+func (s *scheduledStorage) GetScheduledOperations(userID uuid.UUID, limit int, offset int) ([]storage.ScheduledOperation, error) {
+	st := s.store.read()
+
+	operations := make([]storage.ScheduledOperation, 0)
+	for i := len(st.scheduleOrder) - 1; i >= 0; i-- {
+		operation := st.scheduled[st.scheduleOrder[i]]
+		if operation.UserID == userID || (operation.ReceiverID != nil && *operation.ReceiverID == userID) {
+			operations = append(operations, operation)
+		}
+	}
+
+	start, end := page(len(operations), limit, offset)
+	return operations[start:end], nil
+}
+
+func (s *scheduledStorage) GetDueScheduledOperations(now time.Time, limit int) ([]storage.ScheduledOperation, error) {
+	st := s.store.read()
+
+	operations := make([]storage.ScheduledOperation, 0)
+	for _, id := range st.scheduleOrder {
+		operation := st.scheduled[id]
+		if operation.Status == dto.ScheduledActive && !operation.NextAttemptAt.After(now) {
+			operations = append(operations, operation)
+		}
+	}
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].NextAttemptAt.Before(operations[j].NextAttemptAt)
+	})
+
+	start, end := page(len(operations), limit, 0)
+	return operations[start:end], nil
+}
+
+func (s *scheduledStorage) CancelScheduledOperation(id uuid.UUID) (int64, error) {
+	var cancelled int64
+	err := s.store.update(func(w *writer) error {
+		operation, ok := w.scheduled[id]
+		if !ok || operation.Status != dto.ScheduledActive {
+			return nil
+		}
+
+		operation.Status = dto.ScheduledCancelled
+		w.writeScheduled()[id] = operation
+		cancelled = 1
+		return nil
+	})
+
+	return cancelled, err
+}
+
+func (s *scheduledStorage) UpdateScheduledOperation(tx storage.Tx, operation storage.ScheduledOperation) (bool, error) {
+	if !scheduledStatuses[operation.Status] {
+		return false, constraintError("unknown scheduled operation status %q", operation.Status)
+	}
+
+	w := txWriter(tx)
+	current, ok := w.scheduled[operation.Id]
+	if !ok || current.Status != dto.ScheduledActive {
+		return false, nil
+	}
+
+	current.NextRunAt = pgTime(operation.NextRunAt)
+	current.NextAttemptAt = pgTime(operation.NextAttemptAt)
+	current.Attempts = operation.Attempts
+	current.Status = operation.Status
+	current.LastError = copyString(operation.LastError)
+	w.writeScheduled()[operation.Id] = current
+	return true, nil
+}
+
+func (s *scheduledStorage) SaveOccurrence(tx storage.Tx, operationID uuid.UUID, scheduledFor time.Time, status string, attempts int, lastError *string) (bool, error) {
+	if !occurrenceStatuses[status] {
+		return false, constraintError("unknown occurrence status %q", status)
+	}
+
+	w := txWriter(tx)
+	if _, ok := w.scheduled[operationID]; !ok {
+		return false, constraintError("scheduled operation %v does not exist", operationID)
+	}
+
+	key := occurrenceKey{operationID: operationID, scheduledFor: pgTime(scheduledFor)}
+	if current, ok := w.occurrences[key]; ok && current.status == dto.OccurrenceSucceeded {
+		return false, nil
+	}
+
+	w.writeOccurrences()[key] = occurrence{status: status, attempts: attempts, lastError: copyString(lastError), updatedAt: w.now}
+	return true, nil
+}
+
+func (s *scheduledStorage) GetOccurrences(operationID uuid.UUID) ([]dto.ScheduledOccurrence, error) {
+	keys := make([]occurrenceKey, 0)
+	st := s.store.read()
+	for key := range st.occurrences {
+		if key.operationID == operationID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].scheduledFor.After(keys[j].scheduledFor)
+	})
+
+	result := make([]dto.ScheduledOccurrence, 0, len(keys))
+	for _, key := range keys {
+		o := st.occurrences[key]
+		result = append(result, dto.ScheduledOccurrence{
+			ScheduledFor: key.scheduledFor.Format(timestampFormat),
+			Status:       o.status,
+			Attempts:     o.attempts,
+			LastError:    o.lastError,
+			UpdatedAt:    o.updatedAt.Format(timestampFormat),
+		})
+	}
+
+	return result, nil
+}
+
+func copyUUID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+
+	result := *id
+	return &result
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+
+	result := *s
+	return &result
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+	"sort"
+)
+
+type transactionStorage struct {
+	store *store
+}
+
+func (t *transactionStorage) GetTransactions(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error) {
+	s := t.store.read()
+
+	chain := s.userPostings[userID]
+	postings := make([]storage.ChainLink, 0, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		postings = append(postings, s.postings[chain[i]])
+	}
+	sort.SliceStable(postings, func(i, j int) bool {
+		if !postings[i].CreatedAt.Equal(postings[j].CreatedAt) {
+			return postings[i].CreatedAt.After(postings[j].CreatedAt)
+		}
+		return postings[i].Sum < postings[j].Sum
+	})
+
+	start, end := page(len(postings), limit, offset)
+	result := make([]dto.Transaction, 0, end-start)
+	for _, posting := range postings[start:end] {
+		result = append(result, transactionToDTO(posting))
+	}
+
+	return result, nil
+}
+
+// transactionToDTO возвращает движение со временем в текстовом виде Postgres
+func transactionToDTO(link storage.ChainLink) dto.Transaction {
+	return dto.Transaction{
+		Id:            link.ID,
+		EntryId:       link.EntryID,
+		UserID:        link.UserID,
+		ChangeBalance: toMoney(link.Sum),
+		Operation:     link.Operation,
+		Client:        link.Client,
+		Comment:       link.Comment,
+		CreatedAt:     link.CreatedAt.Format(timestampFormat),
+	}
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"github.com/google/uuid"
+)
+
+type webhookStorage struct {
+	store *store
+}
+
+var deliveryStatuses = map[string]bool{dto.DeliveryPending: true, dto.DeliveryDelivered: true, dto.DeliveryDead: true}
+
+func (w *webhookStorage) CreateWebhook(url string, events []string, secret string) (*dto.Webhook, error) {
+	var result dto.Webhook
+	err := w.store.update(func(wr *writer) error {
+		result = dto.Webhook{Id: uuid.New(), URL: url, Events: append([]string(nil), events...), Secret: secret, CreatedAt: wr.now.Format(timestampFormat)}
+		wr.webhooks = append(wr.webhooks, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (w *webhookStorage) GetWebhooks() ([]dto.Webhook, error) {
+	webhooks := w.store.read().webhooks
+	return append(make([]dto.Webhook, 0, len(webhooks)), webhooks...), nil
+}
+
+func (w *webhookStorage) GetWebhooksByEvent(event string) ([]dto.Webhook, error) {
+	result := make([]dto.Webhook, 0)
+	for _, webhook := range w.store.read().webhooks {
+		for _, e := range webhook.Events {
+			if e == event {
+				result = append(result, webhook)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (w *webhookStorage) GetWebhook(id uuid.UUID) (*dto.Webhook, error) {
+	for _, webhook := range w.store.read().webhooks {
+		if webhook.Id == id {
+			return &webhook, nil
+		}
+	}
+
+	return nil, storage.ErrNoRows
+}
+
+// DeleteWebhook удаляет подписку вместе с ее доставками, как on delete cascade
+func (w *webhookStorage) DeleteWebhook(id uuid.UUID) (int64, error) {
+	var deleted int64
+	err := w.store.update(func(wr *writer) error {
+		webhooks := make([]dto.Webhook, 0, len(wr.webhooks))
+		for _, webhook := range wr.webhooks {
+			if webhook.Id == id {
+				deleted++
+				continue
+			}
+			webhooks = append(webhooks, webhook)
+		}
+		if deleted == 0 {
+			return nil
+		}
+		wr.webhooks = webhooks
+
+		deliveries := wr.writeDeliveries()
+		for deliveryID, delivery := range deliveries {
+			if delivery.WebhookID == id {
+				delete(deliveries, deliveryID)
+			}
+		}
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (w *webhookStorage) CreateDelivery(webhookID uuid.UUID, event string, payload string) (uuid.UUID, error) {
+	id := uuid.New()
+	err := w.store.update(func(wr *writer) error {
+		found := false
+		for _, webhook := range wr.webhooks {
+			found = found || webhook.Id == webhookID
+		}
+		if !found {
+			return constraintError("webhook %v does not exist", webhookID)
+		}
+
+		now := wr.now.Format(timestampFormat)
+		wr.writeDeliveries()[id] = dto.WebhookDelivery{
+			Id:        id,
+			WebhookID: webhookID,
+			Event:     event,
+			Payload:   payload,
+			Status:    dto.DeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		wr.deliveryOrder = append(wr.deliveryOrder, id)
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (w *webhookStorage) UpdateDelivery(id uuid.UUID, status string, attempts int, responseCode *int, lastError *string) error {
+	if !deliveryStatuses[status] {
+		return constraintError("unknown delivery status %q", status)
+	}
+
+	return w.store.update(func(wr *writer) error {
+		delivery, ok := wr.deliveries[id]
+		if !ok {
+			return nil
+		}
+
+		delivery.Status = status
+		delivery.Attempts = attempts
+		delivery.ResponseCode = responseCode
+		delivery.LastError = lastError
+		delivery.UpdatedAt = wr.now.Format(timestampFormat)
+		wr.writeDeliveries()[id] = delivery
+		return nil
+	})
+}
+
+func (w *webhookStorage) GetDelivery(id uuid.UUID) (*dto.WebhookDelivery, error) {
+	delivery, ok := w.store.read().deliveries[id]
+	if !ok {
+		return nil, storage.ErrNoRows
+	}
+
+	return &delivery, nil
+}
+
+// пустой status и nil webhookID означают отсутствие фильтра
+func (w *webhookStorage) GetDeliveries(webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error) {
+	s := w.store.read()
+
+	deliveries := make([]dto.WebhookDelivery, 0)
+	for i := len(s.deliveryOrder) - 1; i >= 0; i-- {
+		delivery, ok := s.deliveries[s.deliveryOrder[i]]
+		if !ok {
+			continue
+		}
+		if webhookID != nil && delivery.WebhookID != *webhookID {
+			continue
+		}
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	start, end := page(len(deliveries), limit, offset)
+	return deliveries[start:end], nil
+}
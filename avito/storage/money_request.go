@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"avito/db"
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// запрос денег от requester к payer, сумма в копейках, время в UTC
+type MoneyRequest struct {
+	Id          uuid.UUID
+	RequesterID uuid.UUID
+	PayerID     uuid.UUID
+	Sum         int64
+	Message     string
+	Status      string
+	ExpiresAt   time.Time
+	Client      string
+	CreatedAt   time.Time
+}
+
+type MoneyRequestStorageAPI interface {
+	CreateMoneyRequest(request MoneyRequest) (uuid.UUID, error)
+	GetMoneyRequest(id uuid.UUID) (*MoneyRequest, error)
+	// GetPendingMoneyRequests возвращает ожидающие ответа запросы, где пользователь отправитель или плательщик
+	GetPendingMoneyRequests(userID uuid.UUID, now time.Time, limit int, offset int) ([]MoneyRequest, error)
+	// LockMoneyRequest возвращает запрос, блокируя его до конца транзакции
+	LockMoneyRequest(tx Tx, id uuid.UUID) (*MoneyRequest, error)
+	SetMoneyRequestStatus(tx Tx, id uuid.UUID, status string) error
+	DeclineMoneyRequest(id uuid.UUID) (int64, error)
+	// ExpireMoneyRequests переводит в expired ожидающие запросы с истекшим сроком
+	ExpireMoneyRequests(now time.Time) (int64, error)
+}
+
+type moneyRequestStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+const moneyRequestColumns = "id, requester_id, payer_id, amount, message, status, expires_at, client, created_at"
+
+func NewMoneyRequestStorageAPI(connDB *db.ConnDB, ctx context.Context) MoneyRequestStorageAPI {
+	return &moneyRequestStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (m *moneyRequestStorage) CreateMoneyRequest(request MoneyRequest) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := m.db.DB.QueryRow(m.ctx, "insert into money_request (requester_id, payer_id, amount, message, expires_at, client) values ($1, $2, $3, $4, $5, $6) returning id;",
+		request.RequesterID, request.PayerID, request.Sum, request.Message, request.ExpiresAt, request.Client).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (m *moneyRequestStorage) GetMoneyRequest(id uuid.UUID) (*MoneyRequest, error) {
+	requests, err := m.query("select "+moneyRequestColumns+" from money_request where id=$1;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return &requests[0], nil
+}
+
+func (m *moneyRequestStorage) GetPendingMoneyRequests(userID uuid.UUID, now time.Time, limit int, offset int) ([]MoneyRequest, error) {
+	return m.query("select "+moneyRequestColumns+" from money_request where (requester_id=$1 or payer_id=$1) and status='pending' and expires_at > $2 order by created_at desc limit $3 offset $4;", userID, now, limit, offset)
+}
+
+func (m *moneyRequestStorage) LockMoneyRequest(tx Tx, id uuid.UUID) (*MoneyRequest, error) {
+	var r MoneyRequest
+	err := pgTx(tx).QueryRow(m.ctx, "select "+moneyRequestColumns+" from money_request where id=$1 for update;", id).
+		Scan(&r.Id, &r.RequesterID, &r.PayerID, &r.Sum, &r.Message, &r.Status, &r.ExpiresAt, &r.Client, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (m *moneyRequestStorage) SetMoneyRequestStatus(tx Tx, id uuid.UUID, status string) error {
+	_, err := pgTx(tx).Exec(m.ctx, "update money_request set status=$2, updated_at=current_timestamp where id=$1;", id, status)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *moneyRequestStorage) DeclineMoneyRequest(id uuid.UUID) (int64, error) {
+	tag, err := m.db.DB.Exec(m.ctx, "update money_request set status='declined', updated_at=current_timestamp where id=$1 and status='pending';", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (m *moneyRequestStorage) ExpireMoneyRequests(now time.Time) (int64, error) {
+	tag, err := m.db.DB.Exec(m.ctx, "update money_request set status='expired', updated_at=current_timestamp where status='pending' and expires_at <= $1;", now)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (m *moneyRequestStorage) query(sql string, args ...interface{}) ([]MoneyRequest, error) {
+	rows, err := m.db.DB.Query(m.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]MoneyRequest, 0)
+	for rows.Next() {
+		var r MoneyRequest
+		err := rows.Scan(&r.Id, &r.RequesterID, &r.PayerID, &r.Sum, &r.Message, &r.Status, &r.ExpiresAt, &r.Client, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
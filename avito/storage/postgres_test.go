@@ -0,0 +1,75 @@
+package storage_test
+
+import (
+	"avito/db"
+	"avito/storage"
+	"avito/storage/storagetest"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestPostgresConformance запускается на базе, созданной postgres/init.sql, если задана
+// строка подключения TEST_POSTGRES_DSN, например "user=docker password=12345678 host=localhost port=5432 dbname=avito"
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	connDB, err := db.NewConnectToPGDSN(dsn, ctx)
+	if err != nil {
+		t.Fatalf("Cannot connect to DB: %v", err)
+	}
+	defer connDB.DB.Close()
+
+	storagetest.Run(t, func(t *testing.T) storage.StorageAPI {
+		return storage.NewStorageAPI(connDB, ctx)
+	})
+}
+
+// TestPostgresReplicaRouting использует основную базу как реплику: она не в режиме
+// восстановления, поэтому ее отставание равно нулю
+func TestPostgresReplicaRouting(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connDB, err := db.NewConnectToPGDSN(dsn, ctx)
+	if err != nil {
+		t.Fatalf("Cannot connect to DB: %v", err)
+	}
+	defer connDB.DB.Close()
+	replicaConn, err := db.NewLazyConnectToPGDSN(dsn, ctx)
+	if err != nil {
+		t.Fatalf("Cannot connect to replica: %v", err)
+	}
+	defer replicaConn.DB.Close()
+
+	replica := db.NewReplica(replicaConn, time.Second)
+	api := storage.NewStorageAPIWithReplica(connDB, replica, ctx)
+	if _, isReplica := api.GetReadStorage(false); isReplica {
+		t.Fatalf("replica must not be used before the lag is checked")
+	}
+
+	go replica.Monitor(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for !replica.Available() {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica has not become available")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, isReplica := api.GetReadStorage(false); !isReplica {
+		t.Fatalf("expected reads from replica")
+	}
+	if _, isReplica := api.GetReadStorage(true); isReplica {
+		t.Fatalf("strong reads must use primary")
+	}
+}
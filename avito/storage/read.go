@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"avito/dto"
+	"github.com/google/uuid"
+)
+
+// ReadStorageAPI - чтения баланса и истории, которые можно выполнять на реплике
+type ReadStorageAPI interface {
+	GetBalance(userID uuid.UUID) (int64, error)
+	CountUsers(userID uuid.UUID) (int, error)
+	GetTransactions(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error)
+}
+
+type readStorage struct {
+	BalanceStorageAPI
+	TransactionStorageAPI
+}
+
+// NewReadStorageAPI собирает чтения из хранилищ балансов и истории одной базы
+func NewReadStorageAPI(balance BalanceStorageAPI, transactions TransactionStorageAPI) ReadStorageAPI {
+	return &readStorage{
+		BalanceStorageAPI:     balance,
+		TransactionStorageAPI: transactions,
+	}
+}
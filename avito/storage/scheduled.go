@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"avito/db"
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// запланированная операция, суммы в копейках, время в UTC
+type ScheduledOperation struct {
+	Id            uuid.UUID
+	Operation     string
+	UserID        uuid.UUID
+	ReceiverID    *uuid.UUID
+	Sum           int64
+	Schedule      string
+	NextRunAt     time.Time
+	NextAttemptAt time.Time
+	Attempts      int
+	Status        string
+	LastError     *string
+	Client        string
+	CreatedAt     time.Time
+}
+
+type ScheduledStorageAPI interface {
+	CreateScheduledOperation(operation ScheduledOperation) (uuid.UUID, error)
+	GetScheduledOperation(id uuid.UUID) (*ScheduledOperation, error)
+	// GetScheduledOperations возвращает операции, где пользователь отправитель или получатель
+	GetScheduledOperations(userID uuid.UUID, limit int, offset int) ([]ScheduledOperation, error)
+	GetDueScheduledOperations(now time.Time, limit int) ([]ScheduledOperation, error)
+	CancelScheduledOperation(id uuid.UUID) (int64, error)
+	// UpdateScheduledOperation обновляет активную операцию. Возвращает false, если операция
+	// уже не активна, например отменена после выборки GetDueScheduledOperations
+	UpdateScheduledOperation(tx Tx, operation ScheduledOperation) (bool, error)
+	// SaveOccurrence сохраняет результат выполнения операции за момент scheduledFor.
+	// Возвращает false, если это выполнение уже завершилось успешно
+	SaveOccurrence(tx Tx, operationID uuid.UUID, scheduledFor time.Time, status string, attempts int, lastError *string) (bool, error)
+	GetOccurrences(operationID uuid.UUID) ([]dto.ScheduledOccurrence, error)
+}
+
+type scheduledStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+const scheduledColumns = "id, operation, user_id, receiver_id, amount, schedule, next_run_at, next_attempt_at, attempts, status, last_error, client, created_at"
+
+func NewScheduledStorageAPI(connDB *db.ConnDB, ctx context.Context) ScheduledStorageAPI {
+	return &scheduledStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (s *scheduledStorage) CreateScheduledOperation(operation ScheduledOperation) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := s.db.DB.QueryRow(s.ctx, "insert into scheduled_operation (operation, user_id, receiver_id, amount, schedule, next_run_at, next_attempt_at, client) values ($1, $2, $3, $4, $5, $6, $6, $7) returning id;",
+		operation.Operation, operation.UserID, operation.ReceiverID, operation.Sum, operation.Schedule, operation.NextRunAt, operation.Client).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (s *scheduledStorage) GetScheduledOperation(id uuid.UUID) (*ScheduledOperation, error) {
+	operations, err := s.query("select "+scheduledColumns+" from scheduled_operation where id=$1;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(operations) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return &operations[0], nil
+}
+
+func (s *scheduledStorage) GetScheduledOperations(userID uuid.UUID, limit int, offset int) ([]ScheduledOperation, error) {
+	return s.query("select "+scheduledColumns+" from scheduled_operation where user_id=$1 or receiver_id=$1 order by created_at desc limit $2 offset $3;", userID, limit, offset)
+}
+
+func (s *scheduledStorage) GetDueScheduledOperations(now time.Time, limit int) ([]ScheduledOperation, error) {
+	return s.query("select "+scheduledColumns+" from scheduled_operation where status='active' and next_attempt_at <= $1 order by next_attempt_at limit $2;", now, limit)
+}
+
+func (s *scheduledStorage) CancelScheduledOperation(id uuid.UUID) (int64, error) {
+	tag, err := s.db.DB.Exec(s.ctx, "update scheduled_operation set status='cancelled', updated_at=current_timestamp where id=$1 and status='active';", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *scheduledStorage) UpdateScheduledOperation(tx Tx, operation ScheduledOperation) (bool, error) {
+	tag, err := pgTx(tx).Exec(s.ctx, "update scheduled_operation set next_run_at=$2, next_attempt_at=$3, attempts=$4, status=$5, last_error=$6, updated_at=current_timestamp where id=$1 and status='active';",
+		operation.Id, operation.NextRunAt, operation.NextAttemptAt, operation.Attempts, operation.Status, operation.LastError)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *scheduledStorage) SaveOccurrence(tx Tx, operationID uuid.UUID, scheduledFor time.Time, status string, attempts int, lastError *string) (bool, error) {
+	tag, err := pgTx(tx).Exec(s.ctx, "insert into scheduled_occurrence (operation_id, scheduled_for, status, attempts, last_error) values ($1, $2, $3, $4, $5) "+
+		"on conflict (operation_id, scheduled_for) do update set status=excluded.status, attempts=excluded.attempts, last_error=excluded.last_error, updated_at=current_timestamp "+
+		"where scheduled_occurrence.status <> 'succeeded';", operationID, scheduledFor, status, attempts, lastError)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *scheduledStorage) GetOccurrences(operationID uuid.UUID) ([]dto.ScheduledOccurrence, error) {
+	rows, err := s.db.DB.Query(s.ctx, "select scheduled_for, status, attempts, last_error, updated_at from scheduled_occurrence where operation_id=$1 order by scheduled_for desc;", operationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.ScheduledOccurrence, 0)
+	for rows.Next() {
+		var occurrence dto.ScheduledOccurrence
+		err := rows.Scan(&occurrence.ScheduledFor, &occurrence.Status, &occurrence.Attempts, &occurrence.LastError, &occurrence.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, occurrence)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *scheduledStorage) query(sql string, args ...interface{}) ([]ScheduledOperation, error) {
+	rows, err := s.db.DB.Query(s.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]ScheduledOperation, 0)
+	for rows.Next() {
+		var o ScheduledOperation
+		err := rows.Scan(&o.Id, &o.Operation, &o.UserID, &o.ReceiverID, &o.Sum, &o.Schedule, &o.NextRunAt, &o.NextAttemptAt, &o.Attempts, &o.Status, &o.LastError, &o.Client, &o.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, o)
+	}
+
+	return result, rows.Err()
+}
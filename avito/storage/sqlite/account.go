@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type accountStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (a *accountStorage) GetAccountStatus(tx storage.Tx, userID uuid.UUID) (string, error) {
+	var status string
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(a.ctx, "select status from balance where user_id=?;", userID), &status)
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+func (a *accountStorage) SetAccountStatus(tx storage.Tx, userID uuid.UUID, status string, reason string) error {
+	_, err := sqlTx(tx).tx.ExecContext(a.ctx, "update balance set status=?, status_reason=? where user_id=?;", status, reason, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *accountStorage) WriteStatusChange(tx storage.Tx, userID uuid.UUID, oldStatus string, newStatus string, reason string, client string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(a.ctx, "insert into account_status_history (id, user_id, old_status, new_status, reason, client, created_at) values (?, ?, ?, ?, ?, ?, ?);",
+		uuid.New(), userID, oldStatus, newStatus, reason, client, timestamp(t.now))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *accountStorage) GetAccount(userID uuid.UUID) (*dto.GetAccountStatusResponse, error) {
+	result := &dto.GetAccountStatusResponse{UserId: userID}
+	err := scanRow(a.db.QueryRowContext(a.ctx, "select status, status_reason from balance where user_id=?;", userID), &result.Status, &result.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.db.QueryContext(a.ctx, "select id, old_status, new_status, reason, client, created_at from account_status_history where user_id=? order by created_at desc;", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.History = make([]dto.AccountStatusChange, 0)
+	for rows.Next() {
+		var change dto.AccountStatusChange
+		err := rows.Scan(&change.Id, &change.OldStatus, &change.NewStatus, &change.Reason, &change.Client, textTimeValue{&change.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result.History = append(result.History, change)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+const archivedAccountsColumns = "a.user_id, sum(a.postings), sum(a.total), max(a.last_seq), " +
+	"(select l.last_hash from transaction_archive_account l where l.user_id = a.user_id order by l.last_seq desc limit 1)"
+
+type archiveStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+// EnsurePartitions ничего не делает: в SQLite нет секционирования, месяцы архивируются удалением строк
+func (a *archiveStorage) EnsurePartitions(from time.Time, to time.Time) ([]string, error) {
+	return []string{}, nil
+}
+
+func (a *archiveStorage) GetArchivablePeriods(before time.Time) ([]time.Time, error) {
+	// время хранится строкой фиксированной длины, первые 7 символов - год и месяц
+	rows, err := a.db.QueryContext(a.ctx, "select distinct substr(created_at, 1, 7) from \"transaction\" where created_at < ? order by 1;", timestamp(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]time.Time, 0)
+	for rows.Next() {
+		var month string
+		if err := rows.Scan(&month); err != nil {
+			return nil, err
+		}
+
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, start)
+	}
+
+	return result, rows.Err()
+}
+
+func (a *archiveStorage) WalkPeriod(start time.Time, fn func(link storage.ChainLink) error) error {
+	rows, err := a.db.QueryContext(a.ctx, "select id, entry_id, user_id, seq, change_balance, operation, client, comment, created_at, prev_hash, hash from \"transaction\" "+
+		"where created_at >= ? and created_at < ? order by user_id, seq;", timestamp(start), timestamp(start.AddDate(0, 1, 0)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link storage.ChainLink
+		err := rows.Scan(&link.ID, &link.EntryID, &link.UserID, &link.Seq, &link.Sum, &link.Operation, &link.Client, &link.Comment, timeValue{&link.CreatedAt}, &link.PrevHash, &link.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (a *archiveStorage) ArchivePeriod(tx storage.Tx, archive storage.TransactionArchive, accounts []storage.ArchivedAccount) error {
+	t := sqlTx(tx)
+	result, err := t.tx.ExecContext(a.ctx, "delete from \"transaction\" where created_at >= ? and created_at < ?;", timestamp(archive.PeriodStart), timestamp(archive.PeriodEnd))
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted != int64(archive.Postings) {
+		return xerrors.Errorf("%d postings in DB, %d in file: %w", deleted, archive.Postings, storage.ErrArchiveChanged)
+	}
+
+	_, err = t.tx.ExecContext(a.ctx, "insert into transaction_archive (id, period_start, period_end, file, postings, checksum, archived_at) values (?, ?, ?, ?, ?, ?, ?);",
+		archive.ID, timestamp(archive.PeriodStart), timestamp(archive.PeriodEnd), archive.File, archive.Postings, archive.Checksum, timestamp(t.now))
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		_, err := t.tx.ExecContext(a.ctx, "insert into transaction_archive_account (archive_id, user_id, postings, total, last_seq, last_hash) values (?, ?, ?, ?, ?, ?);",
+			archive.ID, account.UserID, account.Postings, account.Total, account.LastSeq, account.LastHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archiveStorage) GetArchives(userID *uuid.UUID) ([]storage.TransactionArchive, error) {
+	var rows *sql.Rows
+	var err error
+	if userID != nil {
+		rows, err = a.db.QueryContext(a.ctx, "select t.id, t.period_start, t.period_end, t.file, a.postings, t.checksum, t.archived_at from transaction_archive t "+
+			"join transaction_archive_account a on a.archive_id = t.id where a.user_id=? order by t.period_start;", *userID)
+	} else {
+		rows, err = a.db.QueryContext(a.ctx, "select id, period_start, period_end, file, postings, checksum, archived_at from transaction_archive order by period_start;")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.TransactionArchive, 0)
+	for rows.Next() {
+		var archive storage.TransactionArchive
+		err := rows.Scan(&archive.ID, timeValue{&archive.PeriodStart}, timeValue{&archive.PeriodEnd}, &archive.File, &archive.Postings, &archive.Checksum, timeValue{&archive.ArchivedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, archive)
+	}
+
+	return result, rows.Err()
+}
+
+func (a *archiveStorage) GetArchivedAccounts(userID *uuid.UUID) ([]storage.ArchivedAccount, error) {
+	var rows *sql.Rows
+	var err error
+	if userID != nil {
+		rows, err = a.db.QueryContext(a.ctx, "select "+archivedAccountsColumns+" from transaction_archive_account a where a.user_id=? group by a.user_id;", *userID)
+	} else {
+		rows, err = a.db.QueryContext(a.ctx, "select "+archivedAccountsColumns+" from transaction_archive_account a group by a.user_id order by a.user_id;")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.ArchivedAccount, 0)
+	for rows.Next() {
+		var account storage.ArchivedAccount
+		err := rows.Scan(&account.UserID, &account.Postings, &account.Total, &account.LastSeq, &account.LastHash)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
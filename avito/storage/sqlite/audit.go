@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"strings"
+)
+
+type auditStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (a *auditStorage) WriteAuditEntry(entry storage.AuditEntry) error {
+	_, err := a.db.ExecContext(a.ctx, "insert into audit_log (id, request_id, client, method, path, source_ip, payload_hash, status, result, error, user_ids, transaction_ids, created_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);",
+		uuid.New(), entry.RequestID, entry.Client, entry.Method, entry.Path, entry.SourceIP, entry.PayloadHash, entry.Status, entry.Result, entry.Error, joinList(entry.UserIDs), joinList(entry.TransactionIDs), timestamp(now()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *auditStorage) GetAuditEntries(filter storage.AuditFilter, limit int, offset int) ([]storage.AuditEntry, error) {
+	conditions := make([]string, 0, 4)
+	args := make([]interface{}, 0, 6)
+	if filter.UserID != nil {
+		conditions = append(conditions, "user_ids like ?")
+		args = append(args, listPattern(filter.UserID.String()))
+	}
+	if filter.Client != "" {
+		conditions = append(conditions, "client = ?")
+		args = append(args, filter.Client)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, timestamp(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, timestamp(*filter.To))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " where " + strings.Join(conditions, " and ")
+	}
+	args = append(args, limit, offset)
+
+	rows, err := a.db.QueryContext(a.ctx, "select id, request_id, client, method, path, source_ip, payload_hash, status, result, error, user_ids, transaction_ids, created_at from audit_log"+where+" order by created_at desc limit ? offset ?;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.AuditEntry, 0)
+	for rows.Next() {
+		var e storage.AuditEntry
+		err := rows.Scan(&e.ID, &e.RequestID, &e.Client, &e.Method, &e.Path, &e.SourceIP, &e.PayloadHash, &e.Status, &e.Result, &e.Error, listValue{&e.UserIDs}, listValue{&e.TransactionIDs}, timeValue{&e.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type balanceStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+// LockBalance не блокирует строку отдельно: транзакция уже держит блокировку записи всей базы
+func (c *balanceStorage) LockBalance(tx storage.Tx, userID uuid.UUID) (int64, error) {
+	var result int64
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(c.ctx, "select amount from balance where user_id=?;", userID), &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) GetCreditLimit(tx storage.Tx, userID uuid.UUID) (int64, error) {
+	var result int64
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(c.ctx, "select credit_limit from balance where user_id=?;", userID), &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) SetCreditLimit(tx storage.Tx, userID uuid.UUID, creditLimit int64) error {
+	_, err := sqlTx(tx).tx.ExecContext(c.ctx, "update balance set credit_limit=? where user_id=?;", creditLimit, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *balanceStorage) GetOverdraftAccounts(limit int, offset int) ([]dto.OverdraftAccount, error) {
+	rows, err := c.db.QueryContext(c.ctx, "select user_id, amount, credit_limit from balance where amount < 0 and system_account is null order by amount asc limit ? offset ?;", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.OverdraftAccount, 0)
+	for rows.Next() {
+		var account dto.OverdraftAccount
+		var amount, creditLimit int64
+		err := rows.Scan(&account.UserId, &amount, &creditLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Sum = &dto.Money{IntPart: amount / 100, FracPart: amount % 100}
+		account.CreditLimit = &dto.Money{IntPart: creditLimit / 100, FracPart: creditLimit % 100}
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
+
+func (c *balanceStorage) GetBalance(userID uuid.UUID) (int64, error) {
+	var result int64
+	err := scanRow(c.db.QueryRowContext(c.ctx, "select amount from balance where user_id=?;", userID), &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) CountUsers(userID uuid.UUID) (int, error) {
+	var result int
+	err := scanRow(c.db.QueryRowContext(c.ctx, "select count(user_id) from balance where user_id=?;", userID), &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (c *balanceStorage) EnsureSystemAccount(userID uuid.UUID, name string) error {
+	_, err := c.db.ExecContext(c.ctx, "insert into balance (id, user_id, amount, system_account) values (?, ?, 0, ?) on conflict (user_id) do update set system_account = excluded.system_account;", uuid.New(), userID, name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *balanceStorage) GetSystemAccounts() ([]dto.SystemAccount, error) {
+	rows, err := c.db.QueryContext(c.ctx, "select system_account, user_id, amount from balance where system_account is not null order by system_account;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.SystemAccount, 0)
+	for rows.Next() {
+		var account dto.SystemAccount
+		var amount int64
+		err := rows.Scan(&account.Name, &account.UserId, &amount)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Sum = &dto.Money{IntPart: amount / 100, FracPart: amount % 100}
+		result = append(result, account)
+	}
+
+	return result, rows.Err()
+}
+
+func (c *balanceStorage) GetTotals() (int64, int64, error) {
+	var usersTotal, total int64
+	err := scanRow(c.db.QueryRowContext(c.ctx, "select coalesce(sum(case when system_account is null then amount end), 0), coalesce(sum(amount), 0) from balance;"), &usersTotal, &total)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return usersTotal, total, nil
+}
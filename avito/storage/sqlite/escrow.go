@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"time"
+)
+
+type escrowStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (e *escrowStorage) CreateEscrow(tx storage.Tx, escrow storage.Escrow, expiresAt time.Time) (uuid.UUID, error) {
+	t := sqlTx(tx)
+	id := uuid.New()
+	_, err := t.tx.ExecContext(e.ctx, "insert into escrow (id, deal_id, payer_id, payee_id, amount, status, expires_at, created_at, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?8);",
+		id, escrow.DealID, escrow.PayerID, escrow.PayeeID, escrow.Sum, escrow.Status, timestamp(expiresAt), timestamp(t.now))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (e *escrowStorage) LockEscrow(tx storage.Tx, dealID string) (*storage.Escrow, error) {
+	var result storage.Escrow
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(e.ctx, "select id, deal_id, payer_id, payee_id, amount, status from escrow where deal_id=?;", dealID),
+		&result.Id, &result.DealID, &result.PayerID, &result.PayeeID, &result.Sum, &result.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (e *escrowStorage) UpdateEscrowStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(e.ctx, "update escrow set status=?, updated_at=? where id=?;", status, timestamp(t.now), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *escrowStorage) WriteEscrowEvent(tx storage.Tx, escrowID uuid.UUID, status string, comment string, client string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(e.ctx, "insert into escrow_event (id, escrow_id, status, comment, client, created_at) values (?, ?, ?, ?, ?, ?);", uuid.New(), escrowID, status, comment, client, timestamp(t.now))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *escrowStorage) GetEscrow(dealID string) (*dto.Escrow, error) {
+	var result dto.Escrow
+	var sum int64
+	var expiresAt, createdAt time.Time
+	err := scanRow(e.db.QueryRowContext(e.ctx, "select id, deal_id, payer_id, payee_id, amount, status, expires_at, created_at from escrow where deal_id=?;", dealID),
+		&result.Id, &result.DealId, &result.PayerId, &result.PayeeId, &sum, &result.Status, timeValue{&expiresAt}, timeValue{&createdAt})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Sum = &dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+	result.ExpiresAt = expiresAt.Format(time.RFC3339)
+	result.CreatedAt = createdAt.Format(time.RFC3339)
+
+	// rowid сохраняет порядок событий с одинаковым временем
+	rows, err := e.db.QueryContext(e.ctx, "select status, comment, client, created_at from escrow_event where escrow_id=? order by created_at, rowid;", result.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.History = make([]dto.EscrowEvent, 0)
+	for rows.Next() {
+		var event dto.EscrowEvent
+		var eventCreatedAt time.Time
+		err := rows.Scan(&event.Status, &event.Comment, &event.Client, timeValue{&eventCreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		event.CreatedAt = eventCreatedAt.Format(time.RFC3339)
+		result.History = append(result.History, event)
+	}
+
+	return &result, rows.Err()
+}
+
+func (e *escrowStorage) GetExpiredEscrows(now time.Time, limit int) ([]string, error) {
+	rows, err := e.db.QueryContext(e.ctx, "select deal_id from escrow where status='funded' and expires_at <= ? order by expires_at limit ?;", timestamp(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var dealID string
+		if err := rows.Scan(&dealID); err != nil {
+			return nil, err
+		}
+
+		result = append(result, dealID)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"time"
+)
+
+type invoiceStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+const invoiceColumns = "id, payer_id, amount, description, due_at, status, client, paid_at, created_at"
+
+func (i *invoiceStorage) CreateInvoice(tx storage.Tx, invoice storage.Invoice) (uuid.UUID, error) {
+	t := sqlTx(tx)
+	id := uuid.New()
+	_, err := t.tx.ExecContext(i.ctx, "insert into invoice (id, payer_id, amount, description, due_at, client, created_at, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7);",
+		id, invoice.PayerID, invoice.Sum, invoice.Description, timestamp(invoice.DueAt), invoice.Client, timestamp(t.now))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	for position, item := range invoice.Items {
+		_, err := t.tx.ExecContext(i.ctx, "insert into invoice_item (id, invoice_id, position, description, quantity, price) values (?, ?, ?, ?, ?, ?);",
+			uuid.New(), id, position, item.Description, item.Quantity, item.Price)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	return id, nil
+}
+
+func (i *invoiceStorage) GetInvoice(id uuid.UUID) (*storage.Invoice, error) {
+	invoices, err := i.query("select "+invoiceColumns+" from invoice where id=?;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invoices) == 0 {
+		return nil, storage.ErrNoRows
+	}
+
+	return &invoices[0], nil
+}
+
+func (i *invoiceStorage) GetInvoices(payerID uuid.UUID, status string, limit int, offset int) ([]storage.Invoice, error) {
+	if status != "" {
+		return i.query("select "+invoiceColumns+" from invoice where payer_id=? and status=? order by created_at desc limit ? offset ?;", payerID, status, limit, offset)
+	}
+
+	return i.query("select "+invoiceColumns+" from invoice where payer_id=? order by created_at desc limit ? offset ?;", payerID, limit, offset)
+}
+
+func (i *invoiceStorage) LockInvoice(tx storage.Tx, id uuid.UUID) (*storage.Invoice, error) {
+	var o storage.Invoice
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(i.ctx, "select "+invoiceColumns+" from invoice where id=?;", id),
+		&o.Id, &o.PayerID, &o.Sum, &o.Description, timeValue{&o.DueAt}, &o.Status, &o.Client, nullTimeValue{&o.PaidAt}, timeValue{&o.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+func (i *invoiceStorage) SetInvoiceStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(i.ctx, "update invoice set status=?2, paid_at=case when ?2='paid' then ?3 else paid_at end, updated_at=?3 where id=?1;", id, status, timestamp(t.now))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *invoiceStorage) CancelInvoice(id uuid.UUID) (int64, error) {
+	result, err := i.db.ExecContext(i.ctx, "update invoice set status='cancelled', updated_at=? where id=? and status='open';", timestamp(now()), id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (i *invoiceStorage) ExpireInvoices(now time.Time) (int64, error) {
+	result, err := i.db.ExecContext(i.ctx, "update invoice set status='expired', updated_at=?1 where status='open' and due_at <= ?1;", timestamp(now))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// query выбирает счета и догружает их позиции одним запросом
+func (i *invoiceStorage) query(query string, args ...interface{}) ([]storage.Invoice, error) {
+	rows, err := i.db.QueryContext(i.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.Invoice, 0)
+	ids := make([]interface{}, 0)
+	byID := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var o storage.Invoice
+		err := rows.Scan(&o.Id, &o.PayerID, &o.Sum, &o.Description, timeValue{&o.DueAt}, &o.Status, &o.Client, nullTimeValue{&o.PaidAt}, timeValue{&o.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		o.Items = make([]storage.InvoiceItem, 0)
+		byID[o.Id] = len(result)
+		ids = append(ids, o.Id)
+		result = append(result, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	itemRows, err := i.db.QueryContext(i.ctx, "select invoice_id, description, quantity, price from invoice_item where invoice_id in ("+placeholders(len(ids))+") order by invoice_id, position;", ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var invoiceID uuid.UUID
+		var item storage.InvoiceItem
+		if err := itemRows.Scan(&invoiceID, &item.Description, &item.Quantity, &item.Price); err != nil {
+			return nil, err
+		}
+
+		idx := byID[invoiceID]
+		result[idx].Items = append(result[idx].Items, item)
+	}
+
+	return result, itemRows.Err()
+}
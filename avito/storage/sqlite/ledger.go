@@ -0,0 +1,204 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"time"
+)
+
+type ledgerStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+// PostEntry проверяет, что сумма движений равна нулю, до записи: в SQLite нет отложенного
+// триггера, которым эта проверка выполняется при commit в Postgres
+func (l *ledgerStorage) PostEntry(tx storage.Tx, entry storage.JournalEntry) (uuid.UUID, []uuid.UUID, error) {
+	var total int64
+	for _, posting := range entry.Postings {
+		total += posting.Sum
+	}
+	if len(entry.Postings) < 2 || total != 0 {
+		return uuid.Nil, nil, storage.ErrUnbalancedEntry
+	}
+
+	t := sqlTx(tx)
+	entryID := uuid.New()
+	_, err := t.tx.ExecContext(l.ctx, "insert into journal_entry (id, operation, client, comment, created_at) values (?, ?, ?, ?, ?);", entryID, entry.Operation, entry.Client, entry.Comment, timestamp(t.now))
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	postingIDs := make([]uuid.UUID, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		// баланс счета - сумма его движений, меняется только вместе с записью движения.
+		// SQLite проверяет check вставляемой строки до on conflict, поэтому существующий счет
+		// изменяется отдельным update, а новый создается вставкой
+		result, err := t.tx.ExecContext(l.ctx, "update balance set amount = amount + ? where user_id=?;", posting.Sum, posting.UserID)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		if updated, err := result.RowsAffected(); err != nil {
+			return uuid.Nil, nil, err
+		} else if updated == 0 {
+			_, err := t.tx.ExecContext(l.ctx, "insert into balance (id, user_id, amount) values (?, ?, ?);", uuid.New(), posting.UserID, posting.Sum)
+			if err != nil {
+				return uuid.Nil, nil, err
+			}
+		}
+
+		link := storage.ChainLink{ID: uuid.New(), EntryID: entryID, UserID: posting.UserID, Sum: posting.Sum, Operation: posting.Operation, Client: entry.Client, Comment: entry.Comment, CreatedAt: t.now}
+		err = t.tx.QueryRowContext(l.ctx, "select seq, hash from \"transaction\" where user_id=? order by seq desc limit 1;", posting.UserID).Scan(&link.Seq, &link.PrevHash)
+		if err == sql.ErrNoRows {
+			// все движения счета могли быть выгружены в архив
+			err = t.tx.QueryRowContext(l.ctx, "select last_seq, last_hash from transaction_archive_account where user_id=? order by last_seq desc limit 1;", posting.UserID).Scan(&link.Seq, &link.PrevHash)
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return uuid.Nil, nil, err
+		}
+		link.Seq++
+
+		// id и время назначаются до вставки, поэтому хеш записывается вместе с движением
+		_, err = t.tx.ExecContext(l.ctx, "insert into \"transaction\" (id, entry_id, user_id, change_balance, operation, client, comment, created_at, seq, prev_hash, hash) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);",
+			link.ID, entryID, posting.UserID, posting.Sum, posting.Operation, entry.Client, entry.Comment, timestamp(link.CreatedAt), link.Seq, link.PrevHash, link.ComputeHash())
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+
+		postingIDs = append(postingIDs, link.ID)
+	}
+
+	return entryID, postingIDs, nil
+}
+
+func (l *ledgerStorage) GetJournalEntry(id uuid.UUID) (*dto.JournalEntry, error) {
+	var result dto.JournalEntry
+	var createdAt time.Time
+	err := scanRow(l.db.QueryRowContext(l.ctx, "select id, operation, client, comment, created_at from journal_entry where id=?;", id),
+		&result.Id, &result.Operation, &result.Client, &result.Comment, timeValue{&createdAt})
+	if err != nil {
+		return nil, err
+	}
+
+	result.CreatedAt = createdAt.Format(time.RFC3339)
+
+	rows, err := l.db.QueryContext(l.ctx, "select id, entry_id, user_id, change_balance, operation, client, comment, created_at from \"transaction\" where entry_id=? order by change_balance asc;", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result.Postings = make([]dto.Transaction, 0)
+	for rows.Next() {
+		var posting dto.Transaction
+		var money int64
+		var postingCreatedAt time.Time
+		err := rows.Scan(&posting.Id, &posting.EntryId, &posting.UserID, &money, &posting.Operation, &posting.Client, &posting.Comment, timeValue{&postingCreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		posting.ChangeBalance = &dto.Money{IntPart: money / 100, FracPart: money % 100}
+		posting.CreatedAt = postingCreatedAt.Format(time.RFC3339)
+		result.Postings = append(result.Postings, posting)
+	}
+
+	return &result, rows.Err()
+}
+
+func (l *ledgerStorage) CountAccounts() (int, error) {
+	var result int
+	err := scanRow(l.db.QueryRowContext(l.ctx, "select count(*) from balance;"), &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (l *ledgerStorage) GetBalanceMismatches() ([]storage.BalanceMismatch, error) {
+	rows, err := l.db.QueryContext(l.ctx, "select b.user_id, b.amount, coalesce(t.total, 0), coalesce(t.postings, 0) from balance b "+
+		"left join (select user_id, sum(total) as total, sum(postings) as postings from ("+
+		"select user_id, sum(change_balance) as total, count(*) as postings from \"transaction\" group by user_id "+
+		"union all select user_id, total, postings from transaction_archive_account) p group by user_id) t on t.user_id = b.user_id "+
+		"where b.amount <> coalesce(t.total, 0) order by b.user_id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.BalanceMismatch, 0)
+	for rows.Next() {
+		var mismatch storage.BalanceMismatch
+		err := rows.Scan(&mismatch.UserID, &mismatch.Balance, &mismatch.PostingsSum, &mismatch.Postings)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, mismatch)
+	}
+
+	return result, rows.Err()
+}
+
+func (l *ledgerStorage) GetPostingsSum(tx storage.Tx, userID uuid.UUID) (int64, int, error) {
+	var total int64
+	var postings int
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(l.ctx, "select coalesce(sum(total), 0), coalesce(sum(postings), 0) from ("+
+		"select sum(change_balance) as total, count(*) as postings from \"transaction\" where user_id=? "+
+		"union all select total, postings from transaction_archive_account where user_id=?) p;", userID, userID), &total, &postings)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return total, postings, nil
+}
+
+func (l *ledgerStorage) AdjustBalance(tx storage.Tx, userID uuid.UUID, oldAmount int64, newAmount int64, reason string, client string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(l.ctx, "update balance set amount=? where user_id=?;", newAmount, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.tx.ExecContext(l.ctx, "insert into reconciliation_adjustment (id, user_id, old_amount, new_amount, reason, client, created_at) values (?, ?, ?, ?, ?, ?, ?);",
+		uuid.New(), userID, oldAmount, newAmount, reason, client, timestamp(t.now))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *ledgerStorage) WalkChain(userID *uuid.UUID, fn func(link storage.ChainLink) error) error {
+	const columns = "id, entry_id, user_id, seq, change_balance, operation, client, comment, created_at, prev_hash, hash"
+
+	var rows *sql.Rows
+	var err error
+	if userID != nil {
+		rows, err = l.db.QueryContext(l.ctx, "select "+columns+" from \"transaction\" where user_id=? order by seq;", *userID)
+	} else {
+		rows, err = l.db.QueryContext(l.ctx, "select "+columns+" from \"transaction\" order by user_id, seq;")
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link storage.ChainLink
+		err := rows.Scan(&link.ID, &link.EntryID, &link.UserID, &link.Seq, &link.Sum, &link.Operation, &link.Client, &link.Comment, timeValue{&link.CreatedAt}, &link.PrevHash, &link.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
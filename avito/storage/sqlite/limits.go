@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"time"
+)
+
+type limitStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (l *limitStorage) GetUserLimits(userID uuid.UUID) (*storage.UserLimits, error) {
+	var result storage.UserLimits
+	err := l.db.QueryRowContext(l.ctx, "select withdraw_daily, withdraw_monthly, transfer_daily, transfer_monthly, single_operation_max, transfers_per_day from user_limit where user_id=?;", userID).
+		Scan(&result.WithdrawDaily, &result.WithdrawMonthly, &result.TransferDaily, &result.TransferMonthly, &result.SingleOperationMax, &result.TransfersPerDay)
+	if err == sql.ErrNoRows {
+		return &result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (l *limitStorage) SetUserLimits(userID uuid.UUID, limits storage.UserLimits) error {
+	_, err := l.db.ExecContext(l.ctx, "insert into user_limit (user_id, withdraw_daily, withdraw_monthly, transfer_daily, transfer_monthly, single_operation_max, transfers_per_day, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8) "+
+		"on conflict (user_id) do update set withdraw_daily=?2, withdraw_monthly=?3, transfer_daily=?4, transfer_monthly=?5, single_operation_max=?6, transfers_per_day=?7, updated_at=?8;",
+		userID, limits.WithdrawDaily, limits.WithdrawMonthly, limits.TransferDaily, limits.TransferMonthly, limits.SingleOperationMax, limits.TransfersPerDay, timestamp(now()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (l *limitStorage) DeleteUserLimits(userID uuid.UUID) (int64, error) {
+	result, err := l.db.ExecContext(l.ctx, "delete from user_limit where user_id=?;", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (l *limitStorage) GetOperationTotals(tx storage.Tx, userID uuid.UUID, operation string, period string) (int64, int, error) {
+	t := sqlTx(tx)
+	var start time.Time
+	switch period {
+	case "day":
+		start = time.Date(t.now.Year(), t.now.Month(), t.now.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		start = time.Date(t.now.Year(), t.now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return 0, 0, xerrors.Errorf("unknown period %q", period)
+	}
+
+	var sum int64
+	var count int
+	err := scanRow(t.tx.QueryRowContext(l.ctx, "select coalesce(sum(-change_balance), 0), count(*) from \"transaction\" where user_id=? and operation=? and change_balance < 0 and created_at >= ?;", userID, operation, timestamp(start)), &sum, &count)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return sum, count, nil
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"time"
+)
+
+type moneyRequestStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+const moneyRequestColumns = "id, requester_id, payer_id, amount, message, status, expires_at, client, created_at"
+
+func (m *moneyRequestStorage) CreateMoneyRequest(request storage.MoneyRequest) (uuid.UUID, error) {
+	id := uuid.New()
+	_, err := m.db.ExecContext(m.ctx, "insert into money_request (id, requester_id, payer_id, amount, message, expires_at, client, created_at, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?8);",
+		id, request.RequesterID, request.PayerID, request.Sum, request.Message, timestamp(request.ExpiresAt), request.Client, timestamp(now()))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (m *moneyRequestStorage) GetMoneyRequest(id uuid.UUID) (*storage.MoneyRequest, error) {
+	requests, err := m.query("select "+moneyRequestColumns+" from money_request where id=?;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, storage.ErrNoRows
+	}
+
+	return &requests[0], nil
+}
+
+func (m *moneyRequestStorage) GetPendingMoneyRequests(userID uuid.UUID, now time.Time, limit int, offset int) ([]storage.MoneyRequest, error) {
+	return m.query("select "+moneyRequestColumns+" from money_request where (requester_id=?1 or payer_id=?1) and status='pending' and expires_at > ?2 order by created_at desc limit ?3 offset ?4;", userID, timestamp(now), limit, offset)
+}
+
+func (m *moneyRequestStorage) LockMoneyRequest(tx storage.Tx, id uuid.UUID) (*storage.MoneyRequest, error) {
+	var r storage.MoneyRequest
+	err := scanRow(sqlTx(tx).tx.QueryRowContext(m.ctx, "select "+moneyRequestColumns+" from money_request where id=?;", id),
+		&r.Id, &r.RequesterID, &r.PayerID, &r.Sum, &r.Message, &r.Status, timeValue{&r.ExpiresAt}, &r.Client, timeValue{&r.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (m *moneyRequestStorage) SetMoneyRequestStatus(tx storage.Tx, id uuid.UUID, status string) error {
+	t := sqlTx(tx)
+	_, err := t.tx.ExecContext(m.ctx, "update money_request set status=?, updated_at=? where id=?;", status, timestamp(t.now), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *moneyRequestStorage) DeclineMoneyRequest(id uuid.UUID) (int64, error) {
+	result, err := m.db.ExecContext(m.ctx, "update money_request set status='declined', updated_at=? where id=? and status='pending';", timestamp(now()), id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (m *moneyRequestStorage) ExpireMoneyRequests(now time.Time) (int64, error) {
+	result, err := m.db.ExecContext(m.ctx, "update money_request set status='expired', updated_at=?1 where status='pending' and expires_at <= ?1;", timestamp(now))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (m *moneyRequestStorage) query(query string, args ...interface{}) ([]storage.MoneyRequest, error) {
+	rows, err := m.db.QueryContext(m.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.MoneyRequest, 0)
+	for rows.Next() {
+		var r storage.MoneyRequest
+		err := rows.Scan(&r.Id, &r.RequesterID, &r.PayerID, &r.Sum, &r.Message, &r.Status, timeValue{&r.ExpiresAt}, &r.Client, timeValue{&r.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
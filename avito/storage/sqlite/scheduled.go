@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"time"
+)
+
+type scheduledStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+const scheduledColumns = "id, operation, user_id, receiver_id, amount, schedule, next_run_at, next_attempt_at, attempts, status, last_error, client, created_at"
+
+func (s *scheduledStorage) CreateScheduledOperation(operation storage.ScheduledOperation) (uuid.UUID, error) {
+	id := uuid.New()
+	createdAt := timestamp(now())
+	_, err := s.db.ExecContext(s.ctx, "insert into scheduled_operation (id, operation, user_id, receiver_id, amount, schedule, next_run_at, next_attempt_at, client, created_at, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7, ?8, ?9, ?9);",
+		id, operation.Operation, operation.UserID, operation.ReceiverID, operation.Sum, operation.Schedule, timestamp(operation.NextRunAt), operation.Client, createdAt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (s *scheduledStorage) GetScheduledOperation(id uuid.UUID) (*storage.ScheduledOperation, error) {
+	operations, err := s.query("select "+scheduledColumns+" from scheduled_operation where id=?;", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(operations) == 0 {
+		return nil, storage.ErrNoRows
+	}
+
+	return &operations[0], nil
+}
+
+func (s *scheduledStorage) GetScheduledOperations(userID uuid.UUID, limit int, offset int) ([]storage.ScheduledOperation, error) {
+	return s.query("select "+scheduledColumns+" from scheduled_operation where user_id=?1 or receiver_id=?1 order by created_at desc limit ?2 offset ?3;", userID, limit, offset)
+}
+
+func (s *scheduledStorage) GetDueScheduledOperations(now time.Time, limit int) ([]storage.ScheduledOperation, error) {
+	return s.query("select "+scheduledColumns+" from scheduled_operation where status='active' and next_attempt_at <= ? order by next_attempt_at limit ?;", timestamp(now), limit)
+}
+
+func (s *scheduledStorage) CancelScheduledOperation(id uuid.UUID) (int64, error) {
+	result, err := s.db.ExecContext(s.ctx, "update scheduled_operation set status='cancelled', updated_at=? where id=? and status='active';", timestamp(now()), id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *scheduledStorage) UpdateScheduledOperation(tx storage.Tx, operation storage.ScheduledOperation) (bool, error) {
+	t := sqlTx(tx)
+	result, err := t.tx.ExecContext(s.ctx, "update scheduled_operation set next_run_at=?, next_attempt_at=?, attempts=?, status=?, last_error=?, updated_at=? where id=? and status='active';",
+		timestamp(operation.NextRunAt), timestamp(operation.NextAttemptAt), operation.Attempts, operation.Status, operation.LastError, timestamp(t.now), operation.Id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *scheduledStorage) SaveOccurrence(tx storage.Tx, operationID uuid.UUID, scheduledFor time.Time, status string, attempts int, lastError *string) (bool, error) {
+	t := sqlTx(tx)
+	result, err := t.tx.ExecContext(s.ctx, "insert into scheduled_occurrence (id, operation_id, scheduled_for, status, attempts, last_error, created_at, updated_at) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7) "+
+		"on conflict (operation_id, scheduled_for) do update set status=excluded.status, attempts=excluded.attempts, last_error=excluded.last_error, updated_at=excluded.updated_at "+
+		"where scheduled_occurrence.status <> 'succeeded';", uuid.New(), operationID, timestamp(scheduledFor), status, attempts, lastError, timestamp(t.now))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *scheduledStorage) GetOccurrences(operationID uuid.UUID) ([]dto.ScheduledOccurrence, error) {
+	rows, err := s.db.QueryContext(s.ctx, "select scheduled_for, status, attempts, last_error, updated_at from scheduled_occurrence where operation_id=? order by scheduled_for desc;", operationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.ScheduledOccurrence, 0)
+	for rows.Next() {
+		var occurrence dto.ScheduledOccurrence
+		err := rows.Scan(textTimeValue{&occurrence.ScheduledFor}, &occurrence.Status, &occurrence.Attempts, &occurrence.LastError, textTimeValue{&occurrence.UpdatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, occurrence)
+	}
+
+	return result, rows.Err()
+}
+
+func (s *scheduledStorage) query(query string, args ...interface{}) ([]storage.ScheduledOperation, error) {
+	rows, err := s.db.QueryContext(s.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]storage.ScheduledOperation, 0)
+	for rows.Next() {
+		var o storage.ScheduledOperation
+		err := rows.Scan(&o.Id, &o.Operation, &o.UserID, &o.ReceiverID, &o.Sum, &o.Schedule, timeValue{&o.NextRunAt}, timeValue{&o.NextAttemptAt}, &o.Attempts, &o.Status, &o.LastError, &o.Client, timeValue{&o.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, o)
+	}
+
+	return result, rows.Err()
+}
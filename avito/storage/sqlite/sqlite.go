@@ -0,0 +1,311 @@
+// Package sqlite - хранилище в файле SQLite для локальной разработки и небольших установок
+// без Postgres. Схема повторяет postgres/init.sql, включая ограничения, а транзакции
+// начинаются с блокировки записи (BEGIN IMMEDIATE), поэтому пишущие транзакции выполняются
+// по одной, как при блокировке строк в Postgres
+package sqlite
+
+import (
+	"avito/storage"
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/xerrors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// timestampFormat хранит время в UTC с фиксированной длиной, чтобы строки сравнивались как время
+const timestampFormat = "2006-01-02 15:04:05.000000"
+
+// так Postgres выводит значения TIMESTAMP в текстовом виде
+const textFormat = "2006-01-02 15:04:05.999999"
+
+var schema = []string{
+	`create table if not exists balance (id text primary key, user_id text not null unique, amount integer not null, credit_limit integer not null default 0 check (credit_limit >= 0), status text not null default 'active' check (status in ('active', 'frozen_debits', 'blocked', 'closed')), status_reason text not null default '', system_account text unique, check (system_account is not null or amount >= -credit_limit))`,
+	`create index if not exists balance_overdraft_idx on balance (amount) where amount < 0`,
+	`create table if not exists journal_entry (id text primary key, operation text not null, client text not null default '', comment text not null default '', created_at text not null)`,
+	`create table if not exists "transaction" (id text primary key, entry_id text references journal_entry(id) not null, user_id text references balance(user_id) not null, change_balance integer not null, operation text not null check (operation in ('credit', 'withdraw', 'transfer', 'fee')), client text not null default '', comment text not null default '', created_at text not null, seq integer not null, prev_hash text not null, hash text not null default '', unique (user_id, seq))`,
+	`create index if not exists transaction_user_id_operation_idx on "transaction" (user_id, operation, created_at)`,
+	`create index if not exists transaction_entry_id_idx on "transaction" (entry_id)`,
+	`create table if not exists webhook (id text primary key, url text not null, events text not null, secret text not null, created_at text not null)`,
+	`create table if not exists webhook_delivery (id text primary key, webhook_id text references webhook(id) on delete cascade not null, event text not null, payload text not null, status text not null default 'pending' check (status in ('pending', 'delivered', 'dead')), attempts integer not null default 0, response_code integer, last_error text, created_at text not null, updated_at text not null)`,
+	`create index if not exists webhook_delivery_webhook_id_idx on webhook_delivery (webhook_id, created_at)`,
+	`create index if not exists webhook_delivery_status_idx on webhook_delivery (status)`,
+	`create table if not exists user_limit (user_id text primary key, withdraw_daily integer check (withdraw_daily >= 0), withdraw_monthly integer check (withdraw_monthly >= 0), transfer_daily integer check (transfer_daily >= 0), transfer_monthly integer check (transfer_monthly >= 0), single_operation_max integer check (single_operation_max >= 0), transfers_per_day integer check (transfers_per_day >= 0), updated_at text not null)`,
+	`create table if not exists account_status_history (id text primary key, user_id text references balance(user_id) not null, old_status text not null, new_status text not null, reason text not null, client text not null default '', created_at text not null)`,
+	`create index if not exists account_status_history_user_id_idx on account_status_history (user_id, created_at)`,
+	`create table if not exists scheduled_operation (id text primary key, operation text not null check (operation in ('withdraw', 'transfer')), user_id text not null, receiver_id text, amount integer not null check (amount > 0), schedule text not null default '', next_run_at text not null, next_attempt_at text not null, attempts integer not null default 0, status text not null default 'active' check (status in ('active', 'completed', 'cancelled', 'failed')), last_error text, client text not null default '', created_at text not null, updated_at text not null)`,
+	`create index if not exists scheduled_operation_due_idx on scheduled_operation (next_attempt_at) where status = 'active'`,
+	`create index if not exists scheduled_operation_user_id_idx on scheduled_operation (user_id)`,
+	`create index if not exists scheduled_operation_receiver_id_idx on scheduled_operation (receiver_id)`,
+	`create table if not exists scheduled_occurrence (id text primary key, operation_id text references scheduled_operation(id) not null, scheduled_for text not null, status text not null check (status in ('succeeded', 'retrying', 'failed')), attempts integer not null, last_error text, created_at text not null, updated_at text not null, unique (operation_id, scheduled_for))`,
+	`create table if not exists escrow (id text primary key, deal_id text not null unique, payer_id text not null, payee_id text not null, amount integer not null check (amount > 0), status text not null check (status in ('funded', 'released', 'cancelled', 'expired')), expires_at text not null, created_at text not null, updated_at text not null)`,
+	`create index if not exists escrow_expires_at_idx on escrow (expires_at) where status = 'funded'`,
+	`create table if not exists escrow_event (id text primary key, escrow_id text references escrow(id) not null, status text not null, comment text not null default '', client text not null default '', created_at text not null)`,
+	`create index if not exists escrow_event_escrow_id_idx on escrow_event (escrow_id, created_at)`,
+	`create table if not exists invoice (id text primary key, payer_id text not null, amount integer not null check (amount > 0), description text not null default '', due_at text not null, status text not null default 'open' check (status in ('open', 'paid', 'cancelled', 'expired')), client text not null default '', paid_at text, created_at text not null, updated_at text not null)`,
+	`create index if not exists invoice_payer_id_idx on invoice (payer_id, created_at)`,
+	`create index if not exists invoice_due_at_idx on invoice (due_at) where status = 'open'`,
+	`create table if not exists invoice_item (id text primary key, invoice_id text references invoice(id) on delete cascade not null, position integer not null, description text not null, quantity integer not null check (quantity > 0), price integer not null check (price >= 0), unique (invoice_id, position))`,
+	`create table if not exists money_request (id text primary key, requester_id text not null, payer_id text not null, amount integer not null check (amount > 0), message text not null default '', status text not null default 'pending' check (status in ('pending', 'accepted', 'declined', 'expired')), expires_at text not null, client text not null default '', created_at text not null, updated_at text not null, check (requester_id <> payer_id))`,
+	`create index if not exists money_request_requester_id_idx on money_request (requester_id, created_at) where status = 'pending'`,
+	`create index if not exists money_request_payer_id_idx on money_request (payer_id, created_at) where status = 'pending'`,
+	`create index if not exists money_request_expires_at_idx on money_request (expires_at) where status = 'pending'`,
+	`create table if not exists reconciliation_adjustment (id text primary key, user_id text references balance(user_id) not null, old_amount integer not null, new_amount integer not null, reason text not null, client text not null default '', created_at text not null)`,
+	`create index if not exists reconciliation_adjustment_user_id_idx on reconciliation_adjustment (user_id, created_at)`,
+	`create index if not exists transaction_user_id_created_at_idx on "transaction" (user_id, created_at)`,
+	`create table if not exists transaction_archive (id text primary key, period_start text not null, period_end text not null, file text not null, postings integer not null, checksum text not null, archived_at text not null)`,
+	`create table if not exists transaction_archive_account (archive_id text references transaction_archive(id) not null, user_id text references balance(user_id) not null, postings integer not null, total integer not null, last_seq integer not null, last_hash text not null, primary key (archive_id, user_id))`,
+	`create index if not exists transaction_archive_account_user_id_idx on transaction_archive_account (user_id, last_seq)`,
+	`create table if not exists audit_log (id text primary key, request_id text not null, client text not null default '', method text not null, path text not null, source_ip text not null, payload_hash text not null, status integer not null, result text not null check (result in ('success', 'failure')), error text, user_ids text not null default ',', transaction_ids text not null default ',', created_at text not null)`,
+	`create index if not exists audit_log_created_at_idx on audit_log (created_at)`,
+	`create index if not exists audit_log_client_idx on audit_log (client, created_at)`,
+}
+
+// Open открывает файл базы path, создавая его и схему при необходимости
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	params.Set("_busy_timeout", "10000")
+	params.Set("_foreign_keys", "1")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "FULL")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, xerrors.Errorf("Cannot open %s: %v", path, err)
+	}
+
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, xerrors.Errorf("Cannot create schema: %v", err)
+		}
+	}
+
+	return db, nil
+}
+
+// sqliteTx - транзакция SQLite. now - время ее начала, которое, как current_timestamp
+// в Postgres, записывается во все строки транзакции
+type sqliteTx struct {
+	tx  *sql.Tx
+	now time.Time
+}
+
+func (t *sqliteTx) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback()
+}
+
+func sqlTx(tx storage.Tx) *sqliteTx {
+	return tx.(*sqliteTx)
+}
+
+// now возвращает текущее время с точностью колонки TIMESTAMP в Postgres
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampFormat)
+}
+
+// scanRow читает строку, возвращая storage.ErrNoRows, если ее нет
+func scanRow(row *sql.Row, dest ...interface{}) error {
+	err := row.Scan(dest...)
+	if err == sql.ErrNoRows {
+		return storage.ErrNoRows
+	}
+
+	return err
+}
+
+// timeValue читает время, записанное timestamp
+type timeValue struct {
+	dest *time.Time
+}
+
+func (v timeValue) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return xerrors.Errorf("cannot scan %T into time", src)
+	}
+
+	t, err := time.Parse(timestampFormat, s)
+	if err != nil {
+		return err
+	}
+
+	*v.dest = t
+	return nil
+}
+
+// nullTimeValue читает время из колонки, допускающей null
+type nullTimeValue struct {
+	dest **time.Time
+}
+
+func (v nullTimeValue) Scan(src interface{}) error {
+	if src == nil {
+		*v.dest = nil
+		return nil
+	}
+
+	var t time.Time
+	if err := (timeValue{&t}).Scan(src); err != nil {
+		return err
+	}
+
+	*v.dest = &t
+	return nil
+}
+
+// textTimeValue читает время в текстовом виде, в котором его возвращает Postgres
+type textTimeValue struct {
+	dest *string
+}
+
+func (v textTimeValue) Scan(src interface{}) error {
+	var t time.Time
+	if err := (timeValue{&t}).Scan(src); err != nil {
+		return err
+	}
+
+	*v.dest = t.Format(textFormat)
+	return nil
+}
+
+// списки хранятся строкой ",a,b,", чтобы искать элемент через like '%,a,%'
+func joinList(items []string) string {
+	return "," + strings.Join(items, ",") + ","
+}
+
+func listPattern(item string) string {
+	return "%," + item + ",%"
+}
+
+type listValue struct {
+	dest *[]string
+}
+
+func (v listValue) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return xerrors.Errorf("cannot scan %T into list", src)
+	}
+
+	*v.dest = make([]string, 0)
+	for _, item := range strings.Split(strings.Trim(s, ","), ",") {
+		if item != "" {
+			*v.dest = append(*v.dest, item)
+		}
+	}
+
+	return nil
+}
+
+// placeholders возвращает n параметров через запятую для условия in
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+type storageAPI struct {
+	balanceStorage      storage.BalanceStorageAPI
+	transactionStorage  storage.TransactionStorageAPI
+	webhookStorage      storage.WebhookStorageAPI
+	limitStorage        storage.LimitStorageAPI
+	accountStorage      storage.AccountStorageAPI
+	scheduledStorage    storage.ScheduledStorageAPI
+	escrowStorage       storage.EscrowStorageAPI
+	invoiceStorage      storage.InvoiceStorageAPI
+	moneyRequestStorage storage.MoneyRequestStorageAPI
+	ledgerStorage       storage.LedgerStorageAPI
+	auditStorage        storage.AuditStorageAPI
+	archiveStorage      storage.ArchiveStorageAPI
+	db                  *sql.DB
+}
+
+func (s *storageAPI) GetTransaction(ctx context.Context) (storage.Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteTx{tx: tx, now: now()}, nil
+}
+
+// GetReadStorage всегда читает из основного хранилища: реплик у него нет
+func (s *storageAPI) GetReadStorage(strong bool) (storage.ReadStorageAPI, bool) {
+	return storage.NewReadStorageAPI(s.balanceStorage, s.transactionStorage), false
+}
+
+func (s *storageAPI) GetBalanceStorage() storage.BalanceStorageAPI {
+	return s.balanceStorage
+}
+
+func (s *storageAPI) GetTransactionStorage() storage.TransactionStorageAPI {
+	return s.transactionStorage
+}
+
+func (s *storageAPI) GetWebhookStorage() storage.WebhookStorageAPI {
+	return s.webhookStorage
+}
+
+func (s *storageAPI) GetLimitStorage() storage.LimitStorageAPI {
+	return s.limitStorage
+}
+
+func (s *storageAPI) GetAccountStorage() storage.AccountStorageAPI {
+	return s.accountStorage
+}
+
+func (s *storageAPI) GetScheduledStorage() storage.ScheduledStorageAPI {
+	return s.scheduledStorage
+}
+
+func (s *storageAPI) GetEscrowStorage() storage.EscrowStorageAPI {
+	return s.escrowStorage
+}
+
+func (s *storageAPI) GetInvoiceStorage() storage.InvoiceStorageAPI {
+	return s.invoiceStorage
+}
+
+func (s *storageAPI) GetMoneyRequestStorage() storage.MoneyRequestStorageAPI {
+	return s.moneyRequestStorage
+}
+
+func (s *storageAPI) GetLedgerStorage() storage.LedgerStorageAPI {
+	return s.ledgerStorage
+}
+
+func (s *storageAPI) GetAuditStorage() storage.AuditStorageAPI {
+	return s.auditStorage
+}
+
+func (s *storageAPI) GetArchiveStorage() storage.ArchiveStorageAPI {
+	return s.archiveStorage
+}
+
+func NewStorageAPI(db *sql.DB, ctx context.Context) storage.StorageAPI {
+	return &storageAPI{
+		balanceStorage:      &balanceStorage{db: db, ctx: ctx},
+		transactionStorage:  &transactionStorage{db: db, ctx: ctx},
+		webhookStorage:      &webhookStorage{db: db, ctx: ctx},
+		limitStorage:        &limitStorage{db: db, ctx: ctx},
+		accountStorage:      &accountStorage{db: db, ctx: ctx},
+		scheduledStorage:    &scheduledStorage{db: db, ctx: ctx},
+		escrowStorage:       &escrowStorage{db: db, ctx: ctx},
+		invoiceStorage:      &invoiceStorage{db: db, ctx: ctx},
+		moneyRequestStorage: &moneyRequestStorage{db: db, ctx: ctx},
+		ledgerStorage:       &ledgerStorage{db: db, ctx: ctx},
+		auditStorage:        &auditStorage{db: db, ctx: ctx},
+		archiveStorage:      &archiveStorage{db: db, ctx: ctx},
+		db:                  db,
+	}
+}
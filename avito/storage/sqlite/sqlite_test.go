@@ -0,0 +1,28 @@
+package sqlite
+
+import (
+	"avito/storage"
+	"avito/storage/storagetest"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "balance-sqlite")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storagetest.Run(t, func(t *testing.T) storage.StorageAPI {
+		db, err := Open(filepath.Join(dir, t.Name()[len("TestConformance/"):]+".db"))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+
+		return NewStorageAPI(db, context.Background())
+	})
+}
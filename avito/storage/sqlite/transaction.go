@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type transactionStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (t *transactionStorage) GetTransactions(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error) {
+	rows, err := t.db.QueryContext(t.ctx, "select id, entry_id, user_id, change_balance, operation, client, comment, created_at from \"transaction\" where user_id=? order by created_at desc, change_balance asc limit ? offset ?;", userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.Transaction, 0)
+	for rows.Next() {
+		var transaction dto.Transaction
+		var money int64
+		err := rows.Scan(&transaction.Id, &transaction.EntryId, &transaction.UserID, &money, &transaction.Operation, &transaction.Client, &transaction.Comment, textTimeValue{&transaction.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		transaction.ChangeBalance = &dto.Money{IntPart: money / 100, FracPart: money % 100}
+
+		result = append(result, transaction)
+	}
+
+	return result, rows.Err()
+}
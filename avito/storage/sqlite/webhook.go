@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"avito/dto"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+)
+
+type webhookStorage struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+const deliveryColumns = "id, webhook_id, event, payload, status, attempts, response_code, last_error, created_at, updated_at"
+
+func (w *webhookStorage) CreateWebhook(url string, events []string, secret string) (*dto.Webhook, error) {
+	createdAt := now()
+	result := &dto.Webhook{Id: uuid.New(), URL: url, Events: events, Secret: secret, CreatedAt: createdAt.Format(textFormat)}
+	_, err := w.db.ExecContext(w.ctx, "insert into webhook (id, url, events, secret, created_at) values (?, ?, ?, ?, ?);", result.Id, url, joinList(events), secret, timestamp(createdAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (w *webhookStorage) GetWebhooks() ([]dto.Webhook, error) {
+	return w.queryWebhooks("select id, url, events, secret, created_at from webhook order by created_at;")
+}
+
+func (w *webhookStorage) GetWebhooksByEvent(event string) ([]dto.Webhook, error) {
+	return w.queryWebhooks("select id, url, events, secret, created_at from webhook where events like ?;", listPattern(event))
+}
+
+func (w *webhookStorage) GetWebhook(id uuid.UUID) (*dto.Webhook, error) {
+	var result dto.Webhook
+	err := scanRow(w.db.QueryRowContext(w.ctx, "select id, url, events, secret, created_at from webhook where id=?;", id),
+		&result.Id, &result.URL, listValue{&result.Events}, &result.Secret, textTimeValue{&result.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (w *webhookStorage) DeleteWebhook(id uuid.UUID) (int64, error) {
+	result, err := w.db.ExecContext(w.ctx, "delete from webhook where id=?;", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (w *webhookStorage) CreateDelivery(webhookID uuid.UUID, event string, payload string) (uuid.UUID, error) {
+	id := uuid.New()
+	createdAt := timestamp(now())
+	_, err := w.db.ExecContext(w.ctx, "insert into webhook_delivery (id, webhook_id, event, payload, created_at, updated_at) values (?, ?, ?, ?, ?, ?);", id, webhookID, event, payload, createdAt, createdAt)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (w *webhookStorage) UpdateDelivery(id uuid.UUID, status string, attempts int, responseCode *int, lastError *string) error {
+	_, err := w.db.ExecContext(w.ctx, "update webhook_delivery set status=?, attempts=?, response_code=?, last_error=?, updated_at=? where id=?;", status, attempts, responseCode, lastError, timestamp(now()), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *webhookStorage) GetDelivery(id uuid.UUID) (*dto.WebhookDelivery, error) {
+	var d dto.WebhookDelivery
+	err := scanRow(w.db.QueryRowContext(w.ctx, "select "+deliveryColumns+" from webhook_delivery where id=?;", id),
+		&d.Id, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError, textTimeValue{&d.CreatedAt}, textTimeValue{&d.UpdatedAt})
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// пустой status и nil webhookID означают отсутствие фильтра
+func (w *webhookStorage) GetDeliveries(webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error) {
+	rows, err := w.db.QueryContext(w.ctx, "select "+deliveryColumns+" from webhook_delivery "+
+		"where (?1 is null or webhook_id=?1) and (?2 = '' or status=?2) order by created_at desc limit ?3 offset ?4;", webhookID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.WebhookDelivery, 0)
+	for rows.Next() {
+		var d dto.WebhookDelivery
+		err := rows.Scan(&d.Id, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError, textTimeValue{&d.CreatedAt}, textTimeValue{&d.UpdatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+func (w *webhookStorage) queryWebhooks(query string, args ...interface{}) ([]dto.Webhook, error) {
+	rows, err := w.db.QueryContext(w.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.Webhook, 0)
+	for rows.Next() {
+		var webhook dto.Webhook
+		err := rows.Scan(&webhook.Id, &webhook.URL, listValue{&webhook.Events}, &webhook.Secret, textTimeValue{&webhook.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, webhook)
+	}
+
+	return result, rows.Err()
+}
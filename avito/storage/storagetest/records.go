@@ -0,0 +1,447 @@
+package storagetest
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func testWebhooks(t *testing.T, s storage.StorageAPI) {
+	webhooks := s.GetWebhookStorage()
+	webhook, err := webhooks.CreateWebhook("http://example.com/hook", []string{dto.EventCredit, dto.EventWithdraw}, "secret")
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	requireTextTime(t, webhook.CreatedAt)
+
+	found, err := webhooks.GetWebhook(webhook.Id)
+	if err != nil || found.URL != webhook.URL || len(found.Events) != 2 || found.Events[1] != dto.EventWithdraw || found.Secret != "secret" {
+		t.Fatalf("GetWebhook: %+v, %v", found, err)
+	}
+
+	subscribed := func(event string) bool {
+		list, err := webhooks.GetWebhooksByEvent(event)
+		if err != nil {
+			t.Fatalf("GetWebhooksByEvent: %v", err)
+		}
+		for _, w := range list {
+			if w.Id == webhook.Id {
+				return true
+			}
+		}
+		return false
+	}
+	if !subscribed(dto.EventWithdraw) || subscribed(dto.EventTransfer) {
+		t.Fatal("unexpected webhook subscriptions")
+	}
+
+	deliveryID, err := webhooks.CreateDelivery(webhook.Id, dto.EventWithdraw, `{"sum":1}`)
+	if err != nil {
+		t.Fatalf("CreateDelivery: %v", err)
+	}
+	delivery, err := webhooks.GetDelivery(deliveryID)
+	if err != nil || delivery.Status != dto.DeliveryPending || delivery.Attempts != 0 || delivery.ResponseCode != nil || delivery.Payload != `{"sum":1}` {
+		t.Fatalf("GetDelivery: %+v, %v", delivery, err)
+	}
+	requireTextTime(t, delivery.UpdatedAt)
+
+	responseCode := 200
+	if err := webhooks.UpdateDelivery(deliveryID, dto.DeliveryDelivered, 1, &responseCode, nil); err != nil {
+		t.Fatalf("UpdateDelivery: %v", err)
+	}
+	deliveries, err := webhooks.GetDeliveries(&webhook.Id, dto.DeliveryDelivered, 10, 0)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Attempts != 1 || *deliveries[0].ResponseCode != 200 {
+		t.Fatalf("GetDeliveries: %+v, %v", deliveries, err)
+	}
+	deliveries, err = webhooks.GetDeliveries(&webhook.Id, dto.DeliveryPending, 10, 0)
+	if err != nil || len(deliveries) != 0 {
+		t.Fatalf("GetDeliveries of pending: %+v, %v", deliveries, err)
+	}
+	deliveries, err = webhooks.GetDeliveries(nil, "", 1000000, 0)
+	if err != nil || len(deliveries) == 0 {
+		t.Fatalf("GetDeliveries without filter: %+v, %v", deliveries, err)
+	}
+
+	if deleted, err := webhooks.DeleteWebhook(webhook.Id); err != nil || deleted != 1 {
+		t.Fatalf("DeleteWebhook: %d, %v", deleted, err)
+	}
+	if deleted, err := webhooks.DeleteWebhook(webhook.Id); err != nil || deleted != 0 {
+		t.Fatalf("repeated DeleteWebhook: %d, %v", deleted, err)
+	}
+	_, err = webhooks.GetWebhook(webhook.Id)
+	requireNoRows(t, err)
+	// доставки удаляются вместе с вебхуком
+	_, err = webhooks.GetDelivery(deliveryID)
+	requireNoRows(t, err)
+}
+
+func testScheduled(t *testing.T, s storage.StorageAPI) {
+	scheduled := s.GetScheduledStorage()
+	userID, receiverID := uuid.New(), uuid.New()
+	runAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Microsecond)
+
+	id, err := scheduled.CreateScheduledOperation(storage.ScheduledOperation{Operation: dto.OperationTransfer, UserID: userID, ReceiverID: &receiverID, Sum: 500, Schedule: "@daily", NextRunAt: runAt, Client: client})
+	if err != nil {
+		t.Fatalf("CreateScheduledOperation: %v", err)
+	}
+
+	operation, err := scheduled.GetScheduledOperation(id)
+	if err != nil {
+		t.Fatalf("GetScheduledOperation: %v", err)
+	}
+	if operation.Status != dto.ScheduledActive || operation.Sum != 500 || *operation.ReceiverID != receiverID || !operation.NextRunAt.Equal(runAt) || !operation.NextAttemptAt.Equal(runAt) || operation.LastError != nil {
+		t.Fatalf("unexpected operation %+v", operation)
+	}
+
+	if _, err := scheduled.CreateScheduledOperation(storage.ScheduledOperation{Operation: dto.OperationWithdraw, UserID: userID, Sum: 0, NextRunAt: runAt}); err == nil {
+		t.Fatal("expected error for zero amount")
+	}
+
+	operations, err := scheduled.GetScheduledOperations(receiverID, 10, 0)
+	if err != nil || len(operations) != 1 || operations[0].Id != id {
+		t.Fatalf("GetScheduledOperations: %+v, %v", operations, err)
+	}
+
+	isDue := func() bool {
+		due, err := scheduled.GetDueScheduledOperations(time.Now().UTC(), 1000000)
+		if err != nil {
+			t.Fatalf("GetDueScheduledOperations: %v", err)
+		}
+		for _, o := range due {
+			if o.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !isDue() {
+		t.Fatal("operation is not due")
+	}
+
+	lastError := "not enough funds"
+	tx := begin(t, s)
+	saved, err := scheduled.SaveOccurrence(tx, id, runAt, dto.OccurrenceRetrying, 1, &lastError)
+	if err != nil || !saved {
+		t.Fatalf("SaveOccurrence: %v, %v", saved, err)
+	}
+	saved, err = scheduled.SaveOccurrence(tx, id, runAt, dto.OccurrenceSucceeded, 2, nil)
+	if err != nil || !saved {
+		t.Fatalf("SaveOccurrence: %v, %v", saved, err)
+	}
+	// успешное выполнение не перезаписывается
+	saved, err = scheduled.SaveOccurrence(tx, id, runAt, dto.OccurrenceRetrying, 3, &lastError)
+	if err != nil || saved {
+		t.Fatalf("SaveOccurrence of succeeded occurrence: %v, %v", saved, err)
+	}
+
+	operation.NextRunAt = runAt.Add(24 * time.Hour)
+	operation.NextAttemptAt = operation.NextRunAt
+	if updated, err := scheduled.UpdateScheduledOperation(tx, *operation); err != nil || !updated {
+		t.Fatalf("UpdateScheduledOperation: %v, %v", updated, err)
+	}
+	commit(t, tx)
+
+	occurrences, err := scheduled.GetOccurrences(id)
+	if err != nil || len(occurrences) != 1 || occurrences[0].Status != dto.OccurrenceSucceeded || occurrences[0].Attempts != 2 || occurrences[0].LastError != nil {
+		t.Fatalf("GetOccurrences: %+v, %v", occurrences, err)
+	}
+	requireTextTime(t, occurrences[0].ScheduledFor)
+	if isDue() {
+		t.Fatal("rescheduled operation is still due")
+	}
+
+	if cancelled, err := scheduled.CancelScheduledOperation(id); err != nil || cancelled != 1 {
+		t.Fatalf("CancelScheduledOperation: %d, %v", cancelled, err)
+	}
+	if cancelled, err := scheduled.CancelScheduledOperation(id); err != nil || cancelled != 0 {
+		t.Fatalf("repeated CancelScheduledOperation: %d, %v", cancelled, err)
+	}
+
+	// отмененная операция не обновляется
+	tx = begin(t, s)
+	if updated, err := scheduled.UpdateScheduledOperation(tx, *operation); err != nil || updated {
+		t.Fatalf("UpdateScheduledOperation of cancelled operation: %v, %v", updated, err)
+	}
+	commit(t, tx)
+
+	_, err = scheduled.GetScheduledOperation(uuid.New())
+	requireNoRows(t, err)
+}
+
+func testEscrow(t *testing.T, s storage.StorageAPI) {
+	escrows := s.GetEscrowStorage()
+	deal := storage.Escrow{DealID: "deal-" + uuid.New().String(), PayerID: uuid.New(), PayeeID: uuid.New(), Sum: 500, Status: dto.EscrowFunded}
+	expired := time.Now().UTC().Add(-time.Minute)
+
+	tx := begin(t, s)
+	id, err := escrows.CreateEscrow(tx, deal, expired)
+	if err != nil {
+		t.Fatalf("CreateEscrow: %v", err)
+	}
+	if err := escrows.WriteEscrowEvent(tx, id, dto.EscrowFunded, "order 1", client); err != nil {
+		t.Fatalf("WriteEscrowEvent: %v", err)
+	}
+	commit(t, tx)
+
+	tx = begin(t, s)
+	if _, err := escrows.CreateEscrow(tx, deal, expired); err == nil {
+		t.Fatal("expected error for duplicate deal id")
+	}
+	tx.Rollback(context.Background())
+
+	isExpired := func(dealID string) bool {
+		deals, err := escrows.GetExpiredEscrows(time.Now().UTC(), 1000000)
+		if err != nil {
+			t.Fatalf("GetExpiredEscrows: %v", err)
+		}
+		for _, d := range deals {
+			if d == dealID {
+				return true
+			}
+		}
+		return false
+	}
+	if !isExpired(deal.DealID) {
+		t.Fatal("funded escrow is not listed as expired")
+	}
+
+	tx = begin(t, s)
+	locked, err := escrows.LockEscrow(tx, deal.DealID)
+	if err != nil || locked.Id != id || locked.PayerID != deal.PayerID || locked.Sum != 500 || locked.Status != dto.EscrowFunded {
+		t.Fatalf("LockEscrow: %+v, %v", locked, err)
+	}
+	if err := escrows.UpdateEscrowStatus(tx, id, dto.EscrowReleased); err != nil {
+		t.Fatalf("UpdateEscrowStatus: %v", err)
+	}
+	if err := escrows.WriteEscrowEvent(tx, id, dto.EscrowReleased, "", client); err != nil {
+		t.Fatalf("WriteEscrowEvent: %v", err)
+	}
+	commit(t, tx)
+
+	found, err := escrows.GetEscrow(deal.DealID)
+	if err != nil || found.Status != dto.EscrowReleased || *found.Sum != money(500) || len(found.History) != 2 {
+		t.Fatalf("GetEscrow: %+v, %v", found, err)
+	}
+	if found.History[0].Status != dto.EscrowFunded || found.History[0].Comment != "order 1" || found.History[1].Status != dto.EscrowReleased {
+		t.Fatalf("unexpected escrow history %+v", found.History)
+	}
+	if isExpired(deal.DealID) {
+		t.Fatal("released escrow is listed as expired")
+	}
+
+	_, err = escrows.GetEscrow("deal-" + uuid.New().String())
+	requireNoRows(t, err)
+	tx = begin(t, s)
+	_, err = escrows.LockEscrow(tx, "deal-"+uuid.New().String())
+	tx.Rollback(context.Background())
+	requireNoRows(t, err)
+}
+
+func testInvoices(t *testing.T, s storage.StorageAPI) {
+	invoices := s.GetInvoiceStorage()
+	payerID := uuid.New()
+	dueAt := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+
+	create := func(invoice storage.Invoice) (uuid.UUID, error) {
+		tx := begin(t, s)
+		id, err := invoices.CreateInvoice(tx, invoice)
+		if err != nil {
+			tx.Rollback(context.Background())
+			return uuid.Nil, err
+		}
+		return id, tx.Commit(context.Background())
+	}
+
+	id, err := create(storage.Invoice{PayerID: payerID, Sum: 300, Description: "order", DueAt: dueAt, Client: client,
+		Items: []storage.InvoiceItem{{Description: "first", Quantity: 1, Price: 100}, {Description: "second", Quantity: 2, Price: 100}}})
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if _, err := create(storage.Invoice{PayerID: payerID, Sum: 0, DueAt: dueAt}); err == nil {
+		t.Fatal("expected error for zero amount")
+	}
+
+	invoice, err := invoices.GetInvoice(id)
+	if err != nil {
+		t.Fatalf("GetInvoice: %v", err)
+	}
+	if invoice.Status != dto.InvoiceOpen || invoice.Sum != 300 || !invoice.DueAt.Equal(dueAt) || invoice.PaidAt != nil || len(invoice.Items) != 2 || invoice.Items[1].Description != "second" || invoice.Items[1].Quantity != 2 {
+		t.Fatalf("unexpected invoice %+v", invoice)
+	}
+
+	tx := begin(t, s)
+	locked, err := invoices.LockInvoice(tx, id)
+	if err != nil || locked.Status != dto.InvoiceOpen || locked.PayerID != payerID {
+		t.Fatalf("LockInvoice: %+v, %v", locked, err)
+	}
+	if err := invoices.SetInvoiceStatus(tx, id, dto.InvoicePaid); err != nil {
+		t.Fatalf("SetInvoiceStatus: %v", err)
+	}
+	commit(t, tx)
+
+	invoice, err = invoices.GetInvoice(id)
+	if err != nil || invoice.Status != dto.InvoicePaid || invoice.PaidAt == nil {
+		t.Fatalf("unexpected paid invoice %+v, %v", invoice, err)
+	}
+	if cancelled, err := invoices.CancelInvoice(id); err != nil || cancelled != 0 {
+		t.Fatalf("CancelInvoice of paid invoice: %d, %v", cancelled, err)
+	}
+
+	openID, err := create(storage.Invoice{PayerID: payerID, Sum: 100, DueAt: dueAt})
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	overdueID, err := create(storage.Invoice{PayerID: payerID, Sum: 100, DueAt: time.Now().UTC().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+
+	if expired, err := invoices.ExpireInvoices(time.Now().UTC()); err != nil || expired < 1 {
+		t.Fatalf("ExpireInvoices: %d, %v", expired, err)
+	}
+	if cancelled, err := invoices.CancelInvoice(openID); err != nil || cancelled != 1 {
+		t.Fatalf("CancelInvoice: %d, %v", cancelled, err)
+	}
+
+	list, err := invoices.GetInvoices(payerID, "", 10, 0)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("GetInvoices: %+v, %v", list, err)
+	}
+	for _, expected := range []struct {
+		id     uuid.UUID
+		status string
+	}{{id, dto.InvoicePaid}, {openID, dto.InvoiceCancelled}, {overdueID, dto.InvoiceExpired}} {
+		list, err := invoices.GetInvoices(payerID, expected.status, 10, 0)
+		if err != nil || len(list) != 1 || list[0].Id != expected.id {
+			t.Fatalf("GetInvoices with status %s: %+v, %v", expected.status, list, err)
+		}
+	}
+
+	_, err = invoices.GetInvoice(uuid.New())
+	requireNoRows(t, err)
+}
+
+func testMoneyRequests(t *testing.T, s storage.StorageAPI) {
+	requests := s.GetMoneyRequestStorage()
+	requesterID, payerID := uuid.New(), uuid.New()
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+
+	id, err := requests.CreateMoneyRequest(storage.MoneyRequest{RequesterID: requesterID, PayerID: payerID, Sum: 200, Message: "dinner", ExpiresAt: expiresAt, Client: client})
+	if err != nil {
+		t.Fatalf("CreateMoneyRequest: %v", err)
+	}
+	if _, err := requests.CreateMoneyRequest(storage.MoneyRequest{RequesterID: payerID, PayerID: payerID, Sum: 200, ExpiresAt: expiresAt}); err == nil {
+		t.Fatal("expected error for request to oneself")
+	}
+
+	request, err := requests.GetMoneyRequest(id)
+	if err != nil || request.Status != dto.MoneyRequestPending || request.Message != "dinner" || !request.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("GetMoneyRequest: %+v, %v", request, err)
+	}
+
+	pending := func(userID uuid.UUID) []storage.MoneyRequest {
+		list, err := requests.GetPendingMoneyRequests(userID, time.Now().UTC(), 10, 0)
+		if err != nil {
+			t.Fatalf("GetPendingMoneyRequests: %v", err)
+		}
+		return list
+	}
+	if len(pending(requesterID)) != 1 || len(pending(payerID)) != 1 {
+		t.Fatal("request is not pending for both users")
+	}
+
+	tx := begin(t, s)
+	locked, err := requests.LockMoneyRequest(tx, id)
+	if err != nil || locked.Sum != 200 || locked.PayerID != payerID {
+		t.Fatalf("LockMoneyRequest: %+v, %v", locked, err)
+	}
+	if err := requests.SetMoneyRequestStatus(tx, id, dto.MoneyRequestAccepted); err != nil {
+		t.Fatalf("SetMoneyRequestStatus: %v", err)
+	}
+	commit(t, tx)
+
+	if declined, err := requests.DeclineMoneyRequest(id); err != nil || declined != 0 {
+		t.Fatalf("DeclineMoneyRequest of accepted request: %d, %v", declined, err)
+	}
+	if len(pending(payerID)) != 0 {
+		t.Fatal("accepted request is pending")
+	}
+
+	declineID, err := requests.CreateMoneyRequest(storage.MoneyRequest{RequesterID: requesterID, PayerID: payerID, Sum: 100, ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("CreateMoneyRequest: %v", err)
+	}
+	if declined, err := requests.DeclineMoneyRequest(declineID); err != nil || declined != 1 {
+		t.Fatalf("DeclineMoneyRequest: %d, %v", declined, err)
+	}
+
+	expiredID, err := requests.CreateMoneyRequest(storage.MoneyRequest{RequesterID: requesterID, PayerID: payerID, Sum: 100, ExpiresAt: time.Now().UTC().Add(-time.Minute)})
+	if err != nil {
+		t.Fatalf("CreateMoneyRequest: %v", err)
+	}
+	if len(pending(payerID)) != 0 {
+		t.Fatal("overdue request is pending")
+	}
+	if expired, err := requests.ExpireMoneyRequests(time.Now().UTC()); err != nil || expired < 1 {
+		t.Fatalf("ExpireMoneyRequests: %d, %v", expired, err)
+	}
+	if request, err := requests.GetMoneyRequest(expiredID); err != nil || request.Status != dto.MoneyRequestExpired {
+		t.Fatalf("unexpected expired request %+v, %v", request, err)
+	}
+
+	_, err = requests.GetMoneyRequest(uuid.New())
+	requireNoRows(t, err)
+}
+
+func testAudit(t *testing.T, s storage.StorageAPI) {
+	audit := s.GetAuditStorage()
+	userID, otherID := uuid.New(), uuid.New()
+	transactionID := uuid.New().String()
+	auditClient := "conformance-" + uuid.New().String()
+	message := "You have not enough funds to complete this operation"
+
+	err := audit.WriteAuditEntry(storage.AuditEntry{RequestID: "req-1", Client: auditClient, Method: "POST", Path: "/balance/credit", SourceIP: "127.0.0.1",
+		PayloadHash: "hash", Status: 200, Result: dto.AuditResultSuccess, UserIDs: []string{userID.String()}, TransactionIDs: []string{transactionID}})
+	if err != nil {
+		t.Fatalf("WriteAuditEntry: %v", err)
+	}
+	err = audit.WriteAuditEntry(storage.AuditEntry{RequestID: "req-2", Client: auditClient, Method: "POST", Path: "/balance/transfer", SourceIP: "127.0.0.1",
+		PayloadHash: "hash", Status: 400, Result: dto.AuditResultFailure, Error: &message, UserIDs: []string{userID.String(), otherID.String()}, TransactionIDs: []string{}})
+	if err != nil {
+		t.Fatalf("WriteAuditEntry: %v", err)
+	}
+	if err := audit.WriteAuditEntry(storage.AuditEntry{RequestID: "req-3", Client: auditClient, Method: "POST", Path: "/balance/credit", Status: 200, Result: "unknown"}); err == nil {
+		t.Fatal("expected error for unknown result")
+	}
+
+	entries, err := audit.GetAuditEntries(storage.AuditFilter{UserID: &userID}, 10, 0)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("GetAuditEntries by user: %+v, %v", entries, err)
+	}
+	failed, succeeded := entries[0], entries[1]
+	if failed.RequestID != "req-2" || failed.Error == nil || *failed.Error != message || len(failed.UserIDs) != 2 || len(failed.TransactionIDs) != 0 {
+		t.Fatalf("unexpected entry %+v", failed)
+	}
+	if succeeded.Status != 200 || succeeded.Error != nil || len(succeeded.TransactionIDs) != 1 || succeeded.TransactionIDs[0] != transactionID || succeeded.CreatedAt.IsZero() {
+		t.Fatalf("unexpected entry %+v", succeeded)
+	}
+
+	entries, err = audit.GetAuditEntries(storage.AuditFilter{UserID: &otherID, Client: auditClient}, 10, 0)
+	if err != nil || len(entries) != 1 || entries[0].RequestID != "req-2" {
+		t.Fatalf("GetAuditEntries by user and client: %+v, %v", entries, err)
+	}
+
+	from := time.Now().UTC().Add(time.Hour)
+	entries, err = audit.GetAuditEntries(storage.AuditFilter{Client: auditClient, From: &from}, 10, 0)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("GetAuditEntries from the future: %+v, %v", entries, err)
+	}
+	to := time.Now().UTC().Add(-time.Hour)
+	entries, err = audit.GetAuditEntries(storage.AuditFilter{Client: auditClient, To: &to}, 10, 0)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("GetAuditEntries before writes: %+v, %v", entries, err)
+	}
+}
@@ -0,0 +1,676 @@
+// Package storagetest - общие проверки реализаций storage.StorageAPI. Хранилища Postgres,
+// SQLite и хранилище в памяти должны проходить их одинаково
+package storagetest
+
+import (
+	"avito/dto"
+	"avito/storage"
+	"context"
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// так Postgres выводит значения TIMESTAMP в текстовом виде
+const textFormat = "2006-01-02 15:04:05.999999"
+
+const client = "conformance"
+
+// Run проверяет хранилища, которые создает open. Проверки не рассчитывают на пустую базу:
+// каждая создает свои счета и записи, поэтому их можно запускать на общей базе Postgres
+func Run(t *testing.T, open func(t *testing.T) storage.StorageAPI) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.StorageAPI)
+	}{
+		{"PostEntry", testPostEntry},
+		{"UnbalancedEntry", testUnbalancedEntry},
+		{"BalanceConstraint", testBalanceConstraint},
+		{"Isolation", testIsolation},
+		{"ConcurrentDebits", testConcurrentDebits},
+		{"SystemAccounts", testSystemAccounts},
+		{"Chain", testChain},
+		{"Reconciliation", testReconciliation},
+		{"AccountStatus", testAccountStatus},
+		{"Limits", testLimits},
+		{"Webhooks", testWebhooks},
+		{"Scheduled", testScheduled},
+		{"Escrow", testEscrow},
+		{"Invoices", testInvoices},
+		{"MoneyRequests", testMoneyRequests},
+		{"Audit", testAudit},
+		// выгружает в архив движения текущего месяца всех счетов, поэтому выполняется последней
+		{"Archive", testArchive},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, open(t))
+		})
+	}
+}
+
+// newSystemAccount создает служебный счет, с которого зачисляются средства в проверках
+func newSystemAccount(t *testing.T, s storage.StorageAPI) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	if err := s.GetBalanceStorage().EnsureSystemAccount(id, "conformance-"+id.String()); err != nil {
+		t.Fatalf("EnsureSystemAccount: %v", err)
+	}
+
+	return id
+}
+
+func begin(t *testing.T, s storage.StorageAPI) storage.Tx {
+	t.Helper()
+	tx, err := s.GetTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("GetTransaction: %v", err)
+	}
+
+	return tx
+}
+
+func commit(t *testing.T, tx storage.Tx) {
+	t.Helper()
+	if err := tx.Commit(context.Background()); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+// move возвращает проводку, переводящую sum копеек со счета from на счет to
+func move(from uuid.UUID, to uuid.UUID, sum int64, operation string) storage.JournalEntry {
+	return storage.JournalEntry{
+		Operation: operation,
+		Client:    client,
+		Comment:   operation,
+		Postings: []storage.Posting{
+			{UserID: from, Sum: -sum, Operation: operation},
+			{UserID: to, Sum: sum, Operation: operation},
+		},
+	}
+}
+
+// post записывает проводку в отдельной транзакции
+func post(t *testing.T, s storage.StorageAPI, entry storage.JournalEntry) (uuid.UUID, error) {
+	t.Helper()
+	tx := begin(t, s)
+	entryID, _, err := s.GetLedgerStorage().PostEntry(tx, entry)
+	if err != nil {
+		tx.Rollback(context.Background())
+		return uuid.Nil, err
+	}
+
+	return entryID, tx.Commit(context.Background())
+}
+
+func mustPost(t *testing.T, s storage.StorageAPI, entry storage.JournalEntry) uuid.UUID {
+	t.Helper()
+	entryID, err := post(t, s, entry)
+	if err != nil {
+		t.Fatalf("PostEntry %+v: %v", entry, err)
+	}
+
+	return entryID
+}
+
+func requireBalance(t *testing.T, s storage.StorageAPI, userID uuid.UUID, expected int64) {
+	t.Helper()
+	balance, err := s.GetBalanceStorage().GetBalance(userID)
+	if err != nil {
+		t.Fatalf("GetBalance %v: %v", userID, err)
+	}
+	if balance != expected {
+		t.Fatalf("expected balance of %v %d, got %d", userID, expected, balance)
+	}
+}
+
+func requireNoRows(t *testing.T, err error) {
+	t.Helper()
+	if err != storage.ErrNoRows {
+		t.Fatalf("expected storage.ErrNoRows, got %v", err)
+	}
+}
+
+func requireTextTime(t *testing.T, value string) {
+	t.Helper()
+	if _, err := time.Parse(textFormat, value); err != nil {
+		t.Fatalf("unexpected time format %q: %v", value, err)
+	}
+}
+
+func money(sum int64) dto.Money {
+	return dto.Money{IntPart: sum / 100, FracPart: sum % 100}
+}
+
+func testPostEntry(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+
+	creditID := mustPost(t, s, move(system, userID, 1000, dto.OperationCredit))
+	mustPost(t, s, move(userID, system, 300, dto.OperationWithdraw))
+
+	requireBalance(t, s, userID, 700)
+	// служебный счет может уходить в минус
+	requireBalance(t, s, system, -700)
+
+	if count, err := s.GetBalanceStorage().CountUsers(userID); err != nil || count != 1 {
+		t.Fatalf("CountUsers: %d, %v", count, err)
+	}
+	unknown := uuid.New()
+	if count, err := s.GetBalanceStorage().CountUsers(unknown); err != nil || count != 0 {
+		t.Fatalf("CountUsers of unknown account: %d, %v", count, err)
+	}
+	_, err := s.GetBalanceStorage().GetBalance(unknown)
+	requireNoRows(t, err)
+
+	transactions, err := s.GetTransactionStorage().GetTransactions(userID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if len(transactions) != 2 || *transactions[0].ChangeBalance != money(-300) || *transactions[1].ChangeBalance != money(1000) {
+		t.Fatalf("unexpected transactions %v", transactions)
+	}
+	if transactions[1].EntryId != creditID || transactions[1].Operation != dto.OperationCredit || transactions[1].Client != client || transactions[1].Comment != dto.OperationCredit {
+		t.Fatalf("unexpected transaction %v", transactions[1])
+	}
+	requireTextTime(t, transactions[0].CreatedAt)
+
+	transactions, err = s.GetTransactionStorage().GetTransactions(userID, 1, 1)
+	if err != nil || len(transactions) != 1 || transactions[0].EntryId != creditID {
+		t.Fatalf("unexpected second page %v, %v", transactions, err)
+	}
+
+	entry, err := s.GetLedgerStorage().GetJournalEntry(creditID)
+	if err != nil {
+		t.Fatalf("GetJournalEntry: %v", err)
+	}
+	if entry.Operation != dto.OperationCredit || entry.Client != client || len(entry.Postings) != 2 || entry.Postings[0].UserID != system || *entry.Postings[1].ChangeBalance != money(1000) {
+		t.Fatalf("unexpected journal entry %+v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339, entry.CreatedAt); err != nil {
+		t.Fatalf("unexpected created_at %q", entry.CreatedAt)
+	}
+
+	_, err = s.GetLedgerStorage().GetJournalEntry(uuid.New())
+	requireNoRows(t, err)
+
+	tx := begin(t, s)
+	defer tx.Rollback(context.Background())
+	total, postings, err := s.GetLedgerStorage().GetPostingsSum(tx, userID)
+	if err != nil || total != 700 || postings != 2 {
+		t.Fatalf("GetPostingsSum: %d, %d, %v", total, postings, err)
+	}
+}
+
+func testUnbalancedEntry(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+
+	entry := move(system, userID, 100, dto.OperationCredit)
+	entry.Postings[1].Sum = 99
+	if _, err := post(t, s, entry); err != storage.ErrUnbalancedEntry {
+		t.Fatalf("expected ErrUnbalancedEntry, got %v", err)
+	}
+
+	entry = move(system, userID, 0, dto.OperationCredit)
+	entry.Postings = entry.Postings[1:]
+	if _, err := post(t, s, entry); err != storage.ErrUnbalancedEntry {
+		t.Fatalf("expected ErrUnbalancedEntry for single posting, got %v", err)
+	}
+
+	_, err := s.GetBalanceStorage().GetBalance(userID)
+	requireNoRows(t, err)
+}
+
+func testBalanceConstraint(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	mustPost(t, s, move(system, userID, 100, dto.OperationCredit))
+
+	if _, err := post(t, s, move(userID, system, 150, dto.OperationWithdraw)); err == nil {
+		t.Fatal("expected error for negative balance")
+	}
+	requireBalance(t, s, userID, 100)
+
+	tx := begin(t, s)
+	if err := s.GetBalanceStorage().SetCreditLimit(tx, userID, 100); err != nil {
+		t.Fatalf("SetCreditLimit: %v", err)
+	}
+	commit(t, tx)
+
+	tx = begin(t, s)
+	if err := s.GetBalanceStorage().SetCreditLimit(tx, userID, -1); err == nil {
+		t.Fatal("expected error for negative credit limit")
+	}
+	tx.Rollback(context.Background())
+
+	mustPost(t, s, move(userID, system, 150, dto.OperationWithdraw))
+	requireBalance(t, s, userID, -50)
+	if _, err := post(t, s, move(userID, system, 51, dto.OperationWithdraw)); err == nil {
+		t.Fatal("expected error for balance below credit limit")
+	}
+
+	tx = begin(t, s)
+	creditLimit, err := s.GetBalanceStorage().GetCreditLimit(tx, userID)
+	tx.Rollback(context.Background())
+	if err != nil || creditLimit != 100 {
+		t.Fatalf("GetCreditLimit: %d, %v", creditLimit, err)
+	}
+
+	accounts, err := s.GetBalanceStorage().GetOverdraftAccounts(1000000, 0)
+	if err != nil {
+		t.Fatalf("GetOverdraftAccounts: %v", err)
+	}
+	found := false
+	for _, account := range accounts {
+		if account.UserId == system {
+			t.Fatal("system account is listed as overdraft")
+		}
+		if account.UserId == userID {
+			found = *account.Sum == money(-50) && *account.CreditLimit == money(100)
+		}
+	}
+	if !found {
+		t.Fatalf("overdraft of %v is not listed correctly in %v", userID, accounts)
+	}
+}
+
+func testIsolation(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+
+	tx := begin(t, s)
+	if _, _, err := s.GetLedgerStorage().PostEntry(tx, move(system, userID, 100, dto.OperationCredit)); err != nil {
+		t.Fatalf("PostEntry: %v", err)
+	}
+
+	// изменения видны только после commit
+	if count, err := s.GetBalanceStorage().CountUsers(userID); err != nil || count != 0 {
+		t.Fatalf("uncommitted account is visible: %d, %v", count, err)
+	}
+	tx.Rollback(context.Background())
+
+	_, err := s.GetBalanceStorage().GetBalance(userID)
+	requireNoRows(t, err)
+	transactions, err := s.GetTransactionStorage().GetTransactions(userID, 10, 0)
+	if err != nil || len(transactions) != 0 {
+		t.Fatalf("rolled back transactions are visible: %v, %v", transactions, err)
+	}
+
+	mustPost(t, s, move(system, userID, 100, dto.OperationCredit))
+	requireBalance(t, s, userID, 100)
+}
+
+// testConcurrentDebits проверяет, что LockBalance не дает параллельным транзакциям
+// списать больше остатка
+func testConcurrentDebits(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	mustPost(t, s, move(system, userID, 1000, dto.OperationCredit))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	errs := make([]error, 0)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := func() error {
+				tx, err := s.GetTransaction(context.Background())
+				if err != nil {
+					return err
+				}
+				defer tx.Rollback(context.Background())
+
+				balance, err := s.GetBalanceStorage().LockBalance(tx, userID)
+				if err != nil {
+					return err
+				}
+				if balance < 100 {
+					return nil
+				}
+
+				if _, _, err := s.GetLedgerStorage().PostEntry(tx, move(userID, system, 100, dto.OperationWithdraw)); err != nil {
+					return err
+				}
+				if err := tx.Commit(context.Background()); err != nil {
+					return err
+				}
+
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return nil
+			}()
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) != 0 || succeeded != 10 {
+		t.Fatalf("expected 10 debits without errors, got %d, %v", succeeded, errs)
+	}
+	requireBalance(t, s, userID, 0)
+}
+
+func testSystemAccounts(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	if err := s.GetBalanceStorage().EnsureSystemAccount(system, "conformance-"+system.String()); err != nil {
+		t.Fatalf("repeated EnsureSystemAccount: %v", err)
+	}
+
+	accounts, err := s.GetBalanceStorage().GetSystemAccounts()
+	if err != nil {
+		t.Fatalf("GetSystemAccounts: %v", err)
+	}
+	found := false
+	for _, account := range accounts {
+		if account.UserId == system {
+			found = account.Name == "conformance-"+system.String() && *account.Sum == money(0)
+		}
+	}
+	if !found {
+		t.Fatalf("system account %v is not listed correctly in %v", system, accounts)
+	}
+
+	usersBefore, totalBefore, err := s.GetBalanceStorage().GetTotals()
+	if err != nil {
+		t.Fatalf("GetTotals: %v", err)
+	}
+	mustPost(t, s, move(system, uuid.New(), 1000, dto.OperationCredit))
+	usersAfter, totalAfter, err := s.GetBalanceStorage().GetTotals()
+	if err != nil {
+		t.Fatalf("GetTotals: %v", err)
+	}
+	if usersAfter-usersBefore != 1000 || totalAfter != totalBefore {
+		t.Fatalf("unexpected totals %d/%d before and %d/%d after", usersBefore, totalBefore, usersAfter, totalAfter)
+	}
+}
+
+func testChain(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	for i := int64(1); i <= 3; i++ {
+		mustPost(t, s, move(system, userID, i*100, dto.OperationCredit))
+	}
+
+	links := make([]storage.ChainLink, 0)
+	err := s.GetLedgerStorage().WalkChain(&userID, func(link storage.ChainLink) error {
+		links = append(links, link)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkChain: %v", err)
+	}
+	if len(links) != 3 {
+		t.Fatalf("expected 3 links, got %+v", links)
+	}
+
+	prevHash := ""
+	for i, link := range links {
+		if link.Seq != int64(i+1) || link.PrevHash != prevHash || link.Sum != int64(i+1)*100 || link.ComputeHash() != link.Hash {
+			t.Fatalf("unexpected link %+v", link)
+		}
+		prevHash = link.Hash
+	}
+
+	systemLinks := 0
+	err = s.GetLedgerStorage().WalkChain(nil, func(link storage.ChainLink) error {
+		if link.UserID == system {
+			systemLinks++
+		}
+		return nil
+	})
+	if err != nil || systemLinks != 3 {
+		t.Fatalf("WalkChain of all accounts: %d links of system account, %v", systemLinks, err)
+	}
+}
+
+func testReconciliation(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	mustPost(t, s, move(system, userID, 300, dto.OperationCredit))
+
+	if count, err := s.GetLedgerStorage().CountAccounts(); err != nil || count < 2 {
+		t.Fatalf("CountAccounts: %d, %v", count, err)
+	}
+
+	findMismatch := func() *storage.BalanceMismatch {
+		mismatches, err := s.GetLedgerStorage().GetBalanceMismatches()
+		if err != nil {
+			t.Fatalf("GetBalanceMismatches: %v", err)
+		}
+		for _, mismatch := range mismatches {
+			if mismatch.UserID == userID {
+				return &mismatch
+			}
+		}
+		return nil
+	}
+
+	if mismatch := findMismatch(); mismatch != nil {
+		t.Fatalf("unexpected mismatch %+v", mismatch)
+	}
+
+	tx := begin(t, s)
+	if err := s.GetLedgerStorage().AdjustBalance(tx, userID, 300, 250, "conformance", client); err != nil {
+		t.Fatalf("AdjustBalance: %v", err)
+	}
+	commit(t, tx)
+
+	mismatch := findMismatch()
+	if mismatch == nil || mismatch.Balance != 250 || mismatch.PostingsSum != 300 || mismatch.Postings != 1 {
+		t.Fatalf("unexpected mismatch %+v", mismatch)
+	}
+
+	tx = begin(t, s)
+	if err := s.GetLedgerStorage().AdjustBalance(tx, userID, 250, 300, "conformance", client); err != nil {
+		t.Fatalf("AdjustBalance: %v", err)
+	}
+	commit(t, tx)
+
+	if mismatch := findMismatch(); mismatch != nil {
+		t.Fatalf("unexpected mismatch after correction %+v", mismatch)
+	}
+}
+
+func testArchive(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	for i := int64(1); i <= 2; i++ {
+		mustPost(t, s, move(system, userID, i*100, dto.OperationCredit))
+	}
+
+	archiveStorage := s.GetArchiveStorage()
+	if _, err := archiveStorage.EnsurePartitions(time.Now(), time.Now().AddDate(0, 2, 0)); err != nil {
+		t.Fatalf("EnsurePartitions: %v", err)
+	}
+
+	start := storage.MonthStart(time.Now())
+	periods, err := archiveStorage.GetArchivablePeriods(start.AddDate(0, 1, 0))
+	if err != nil || len(periods) == 0 || !periods[len(periods)-1].Equal(start) {
+		t.Fatalf("GetArchivablePeriods: %v, %v", periods, err)
+	}
+	if periods, err := archiveStorage.GetArchivablePeriods(start); err != nil || len(periods) != 0 && !periods[len(periods)-1].Before(start) {
+		t.Fatalf("GetArchivablePeriods before %v: %v, %v", start, periods, err)
+	}
+
+	archive := storage.TransactionArchive{ID: uuid.New(), PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0), File: "conformance.ndjson.gz", Checksum: "conformance"}
+	var accounts []storage.ArchivedAccount
+	err = archiveStorage.WalkPeriod(start, func(link storage.ChainLink) error {
+		archive.Postings++
+		if len(accounts) == 0 || accounts[len(accounts)-1].UserID != link.UserID {
+			accounts = append(accounts, storage.ArchivedAccount{UserID: link.UserID})
+		}
+		account := &accounts[len(accounts)-1]
+		account.Postings++
+		account.Total += link.Sum
+		account.LastSeq, account.LastHash = link.Seq, link.Hash
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkPeriod: %v", err)
+	}
+
+	// число движений не совпадает с выгруженным, месяц остается в базе
+	tx := begin(t, s)
+	changed := archive
+	changed.Postings++
+	if err := archiveStorage.ArchivePeriod(tx, changed, accounts); !xerrors.Is(err, storage.ErrArchiveChanged) {
+		tx.Rollback(context.Background())
+		t.Fatalf("expected storage.ErrArchiveChanged, got %v", err)
+	}
+	tx.Rollback(context.Background())
+
+	tx = begin(t, s)
+	if err := archiveStorage.ArchivePeriod(tx, archive, accounts); err != nil {
+		tx.Rollback(context.Background())
+		t.Fatalf("ArchivePeriod: %v", err)
+	}
+	commit(t, tx)
+
+	if transactions, err := s.GetTransactionStorage().GetTransactions(userID, 10, 0); err != nil || len(transactions) != 0 {
+		t.Fatalf("expected no transactions after archive, got %v, %v", transactions, err)
+	}
+
+	archives, err := archiveStorage.GetArchives(&userID)
+	if err != nil || len(archives) != 1 || archives[0].ID != archive.ID || archives[0].Postings != 2 || !archives[0].PeriodStart.Equal(start) || archives[0].File != archive.File {
+		t.Fatalf("GetArchives: %+v, %v", archives, err)
+	}
+
+	archived, err := archiveStorage.GetArchivedAccounts(&userID)
+	if err != nil || len(archived) != 1 || archived[0].Postings != 2 || archived[0].Total != 300 || archived[0].LastSeq != 2 || archived[0].LastHash == "" {
+		t.Fatalf("GetArchivedAccounts: %+v, %v", archived, err)
+	}
+
+	// сверка учитывает архивные движения
+	mismatches, err := s.GetLedgerStorage().GetBalanceMismatches()
+	if err != nil {
+		t.Fatalf("GetBalanceMismatches: %v", err)
+	}
+	for _, mismatch := range mismatches {
+		if mismatch.UserID == userID || mismatch.UserID == system {
+			t.Fatalf("unexpected mismatch %+v", mismatch)
+		}
+	}
+
+	// цепочка продолжается с последнего архивного звена
+	mustPost(t, s, move(system, userID, 50, dto.OperationCredit))
+	requireBalance(t, s, userID, 350)
+
+	links := make([]storage.ChainLink, 0)
+	err = s.GetLedgerStorage().WalkChain(&userID, func(link storage.ChainLink) error {
+		links = append(links, link)
+		return nil
+	})
+	if err != nil || len(links) != 1 || links[0].Seq != 3 || links[0].PrevHash != archived[0].LastHash || links[0].ComputeHash() != links[0].Hash {
+		t.Fatalf("WalkChain after archive: %+v, %v", links, err)
+	}
+
+	tx = begin(t, s)
+	total, postings, err := s.GetLedgerStorage().GetPostingsSum(tx, userID)
+	tx.Rollback(context.Background())
+	if err != nil || total != 350 || postings != 3 {
+		t.Fatalf("GetPostingsSum: %d, %d, %v", total, postings, err)
+	}
+}
+
+func testAccountStatus(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+	mustPost(t, s, move(system, userID, 100, dto.OperationCredit))
+
+	tx := begin(t, s)
+	status, err := s.GetAccountStorage().GetAccountStatus(tx, userID)
+	if err != nil || status != dto.AccountActive {
+		t.Fatalf("GetAccountStatus: %q, %v", status, err)
+	}
+	if err := s.GetAccountStorage().SetAccountStatus(tx, userID, dto.AccountFrozenDebits, "investigation"); err != nil {
+		t.Fatalf("SetAccountStatus: %v", err)
+	}
+	if err := s.GetAccountStorage().WriteStatusChange(tx, userID, dto.AccountActive, dto.AccountFrozenDebits, "investigation", client); err != nil {
+		t.Fatalf("WriteStatusChange: %v", err)
+	}
+	commit(t, tx)
+
+	account, err := s.GetAccountStorage().GetAccount(userID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if account.Status != dto.AccountFrozenDebits || account.Reason != "investigation" || len(account.History) != 1 {
+		t.Fatalf("unexpected account %+v", account)
+	}
+	change := account.History[0]
+	if change.OldStatus != dto.AccountActive || change.NewStatus != dto.AccountFrozenDebits || change.Client != client {
+		t.Fatalf("unexpected status change %+v", change)
+	}
+	requireTextTime(t, change.CreatedAt)
+
+	tx = begin(t, s)
+	if err := s.GetAccountStorage().SetAccountStatus(tx, userID, "suspended", ""); err == nil {
+		t.Fatal("expected error for unknown status")
+	}
+	tx.Rollback(context.Background())
+
+	tx = begin(t, s)
+	_, err = s.GetAccountStorage().GetAccountStatus(tx, uuid.New())
+	tx.Rollback(context.Background())
+	requireNoRows(t, err)
+
+	_, err = s.GetAccountStorage().GetAccount(uuid.New())
+	requireNoRows(t, err)
+}
+
+func testLimits(t *testing.T, s storage.StorageAPI) {
+	system := newSystemAccount(t, s)
+	userID := uuid.New()
+
+	limits, err := s.GetLimitStorage().GetUserLimits(userID)
+	if err != nil || limits.WithdrawDaily != nil || limits.TransfersPerDay != nil {
+		t.Fatalf("unexpected default limits %+v, %v", limits, err)
+	}
+
+	daily, perDay := int64(5000), 3
+	if err := s.GetLimitStorage().SetUserLimits(userID, storage.UserLimits{WithdrawDaily: &daily, TransfersPerDay: &perDay}); err != nil {
+		t.Fatalf("SetUserLimits: %v", err)
+	}
+	monthly := int64(50000)
+	if err := s.GetLimitStorage().SetUserLimits(userID, storage.UserLimits{WithdrawDaily: &daily, WithdrawMonthly: &monthly}); err != nil {
+		t.Fatalf("SetUserLimits: %v", err)
+	}
+
+	limits, err = s.GetLimitStorage().GetUserLimits(userID)
+	if err != nil || limits.WithdrawDaily == nil || *limits.WithdrawDaily != daily || limits.WithdrawMonthly == nil || *limits.WithdrawMonthly != monthly || limits.TransfersPerDay != nil {
+		t.Fatalf("unexpected limits %+v, %v", limits, err)
+	}
+
+	if deleted, err := s.GetLimitStorage().DeleteUserLimits(userID); err != nil || deleted != 1 {
+		t.Fatalf("DeleteUserLimits: %d, %v", deleted, err)
+	}
+	if deleted, err := s.GetLimitStorage().DeleteUserLimits(userID); err != nil || deleted != 0 {
+		t.Fatalf("repeated DeleteUserLimits: %d, %v", deleted, err)
+	}
+
+	mustPost(t, s, move(system, userID, 1000, dto.OperationCredit))
+	mustPost(t, s, move(userID, system, 300, dto.OperationWithdraw))
+	mustPost(t, s, move(userID, system, 200, dto.OperationWithdraw))
+	mustPost(t, s, move(userID, uuid.New(), 100, dto.OperationTransfer))
+
+	tx := begin(t, s)
+	defer tx.Rollback(context.Background())
+	for _, period := range []string{"day", "month"} {
+		sum, count, err := s.GetLimitStorage().GetOperationTotals(tx, userID, dto.OperationWithdraw, period)
+		if err != nil || sum != 500 || count != 2 {
+			t.Fatalf("GetOperationTotals for %s: %d, %d, %v", period, sum, count, err)
+		}
+	}
+}
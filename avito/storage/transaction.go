@@ -5,12 +5,10 @@ import (
 	"avito/dto"
 	"context"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4"
 )
 
 type TransactionStorageAPI interface {
 	GetTransactions(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error)
-	WriteTransaction(tx pgx.Tx, userID uuid.UUID, sum int64) error
 }
 
 type transactionStorage struct {
@@ -27,7 +25,7 @@ func NewTransactionStorageAPI(connDB *db.ConnDB, ctx context.Context) Transactio
 
 func (t *transactionStorage) GetTransactions(userID uuid.UUID, limit int, offset int) ([]dto.Transaction, error) {
 
-	rows, err := t.db.DB.Query(t.ctx, "select * from \"transaction\" where user_id=$1 order by created_at desc, change_balance asc limit $2 offset $3;", userID, limit, offset)
+	rows, err := t.db.DB.Query(t.ctx, "select id, entry_id, user_id, change_balance, operation, client, comment, created_at from \"transaction\" where user_id=$1 order by created_at desc, change_balance asc limit $2 offset $3;", userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +34,7 @@ func (t *transactionStorage) GetTransactions(userID uuid.UUID, limit int, offset
 	for rows.Next() {
 		var transaction dto.Transaction
 		var money int64
-		err := rows.Scan(&transaction.Id, &transaction.UserID, &money, &transaction.CreatedAt)
+		err := rows.Scan(&transaction.Id, &transaction.EntryId, &transaction.UserID, &money, &transaction.Operation, &transaction.Client, &transaction.Comment, &transaction.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -48,12 +46,3 @@ func (t *transactionStorage) GetTransactions(userID uuid.UUID, limit int, offset
 
 	return result, nil
 }
-
-func (t *transactionStorage) WriteTransaction(tx pgx.Tx, userID uuid.UUID, sum int64) error {
-	_, err := tx.Exec(t.ctx,"insert into \"transaction\" (user_id, change_balance) values ($1, $2);", userID, sum)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
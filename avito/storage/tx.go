@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
+// Tx - транзакция хранилища. Методы хранилищ, принимающие Tx, выполняются в ней,
+// их изменения видны остальным только после Commit
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// ErrNoRows возвращается, если запрошенной записи нет
+var ErrNoRows = pgx.ErrNoRows
+
+// pgTx возвращает транзакцию Postgres, созданную GetTransaction
+func pgTx(tx Tx) pgx.Tx {
+	return tx.(pgx.Tx)
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"avito/db"
+	"avito/dto"
+	"context"
+	"github.com/google/uuid"
+)
+
+type WebhookStorageAPI interface {
+	CreateWebhook(url string, events []string, secret string) (*dto.Webhook, error)
+	GetWebhooks() ([]dto.Webhook, error)
+	GetWebhooksByEvent(event string) ([]dto.Webhook, error)
+	GetWebhook(id uuid.UUID) (*dto.Webhook, error)
+	DeleteWebhook(id uuid.UUID) (int64, error)
+	CreateDelivery(webhookID uuid.UUID, event string, payload string) (uuid.UUID, error)
+	UpdateDelivery(id uuid.UUID, status string, attempts int, responseCode *int, lastError *string) error
+	GetDelivery(id uuid.UUID) (*dto.WebhookDelivery, error)
+	GetDeliveries(webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error)
+}
+
+type webhookStorage struct {
+	db  *db.ConnDB
+	ctx context.Context
+}
+
+func NewWebhookStorageAPI(connDB *db.ConnDB, ctx context.Context) WebhookStorageAPI {
+	return &webhookStorage{
+		db:  connDB,
+		ctx: ctx,
+	}
+}
+
+func (w *webhookStorage) CreateWebhook(url string, events []string, secret string) (*dto.Webhook, error) {
+	result := &dto.Webhook{URL: url, Events: events, Secret: secret}
+	err := w.db.DB.QueryRow(w.ctx, "insert into webhook (url, events, secret) values ($1, $2, $3) returning id, created_at;", url, events, secret).Scan(&result.Id, &result.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (w *webhookStorage) GetWebhooks() ([]dto.Webhook, error) {
+	return w.queryWebhooks("select id, url, events, secret, created_at from webhook order by created_at;")
+}
+
+func (w *webhookStorage) GetWebhooksByEvent(event string) ([]dto.Webhook, error) {
+	return w.queryWebhooks("select id, url, events, secret, created_at from webhook where $1 = any(events);", event)
+}
+
+func (w *webhookStorage) GetWebhook(id uuid.UUID) (*dto.Webhook, error) {
+	var result dto.Webhook
+	err := w.db.DB.QueryRow(w.ctx, "select id, url, events, secret, created_at from webhook where id=$1;", id).Scan(&result.Id, &result.URL, &result.Events, &result.Secret, &result.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (w *webhookStorage) DeleteWebhook(id uuid.UUID) (int64, error) {
+	tag, err := w.db.DB.Exec(w.ctx, "delete from webhook where id=$1;", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (w *webhookStorage) CreateDelivery(webhookID uuid.UUID, event string, payload string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := w.db.DB.QueryRow(w.ctx, "insert into webhook_delivery (webhook_id, event, payload) values ($1, $2, $3) returning id;", webhookID, event, payload).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+func (w *webhookStorage) UpdateDelivery(id uuid.UUID, status string, attempts int, responseCode *int, lastError *string) error {
+	_, err := w.db.DB.Exec(w.ctx, "update webhook_delivery set status=$2, attempts=$3, response_code=$4, last_error=$5, updated_at=current_timestamp where id=$1;", id, status, attempts, responseCode, lastError)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *webhookStorage) GetDelivery(id uuid.UUID) (*dto.WebhookDelivery, error) {
+	var d dto.WebhookDelivery
+	err := w.db.DB.QueryRow(w.ctx, "select id, webhook_id, event, payload, status, attempts, response_code, last_error, created_at, updated_at from webhook_delivery where id=$1;", id).
+		Scan(&d.Id, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// пустой status и nil webhookID означают отсутствие фильтра
+func (w *webhookStorage) GetDeliveries(webhookID *uuid.UUID, status string, limit int, offset int) ([]dto.WebhookDelivery, error) {
+	rows, err := w.db.DB.Query(w.ctx, "select id, webhook_id, event, payload, status, attempts, response_code, last_error, created_at, updated_at from webhook_delivery "+
+		"where ($1::uuid is null or webhook_id=$1) and ($2 = '' or status=$2) order by created_at desc limit $3 offset $4;", webhookID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.WebhookDelivery, 0)
+	for rows.Next() {
+		var d dto.WebhookDelivery
+		err := rows.Scan(&d.Id, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+func (w *webhookStorage) queryWebhooks(sql string, args ...interface{}) ([]dto.Webhook, error) {
+	rows, err := w.db.DB.Query(w.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]dto.Webhook, 0)
+	for rows.Next() {
+		var webhook dto.Webhook
+		err := rows.Scan(&webhook.Id, &webhook.URL, &webhook.Events, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, webhook)
+	}
+
+	return result, rows.Err()
+}
@@ -1,4 +1,92 @@
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
-CREATE TABLE IF NOT EXISTS balance (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, user_id UUID NOT NULL, amount BIGINT NOT NULL CHECK (amount >= 0), UNIQUE(user_id));
-CREATE TABLE IF NOT EXISTS "transaction" (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, user_id UUID REFERENCES balance(user_id) NOT NULL, change_balance BIGINT NOT NULL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
-CREATE INDEX balance_user_id_idx ON balance (user_id);
\ No newline at end of file
+CREATE TABLE IF NOT EXISTS balance (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, user_id UUID NOT NULL, amount BIGINT NOT NULL, credit_limit BIGINT NOT NULL DEFAULT 0 CHECK (credit_limit >= 0), status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen_debits', 'blocked', 'closed')), status_reason TEXT NOT NULL DEFAULT '', system_account TEXT UNIQUE, UNIQUE(user_id), CHECK (system_account IS NOT NULL OR amount >= -credit_limit));
+CREATE TABLE IF NOT EXISTS journal_entry (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, operation TEXT NOT NULL, client TEXT NOT NULL DEFAULT '', comment TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+-- движения секционированы по месяцам created_at: старые месяцы выгружаются в архив удалением целой секции.
+-- Ключи секционированной таблицы должны включать created_at, порядок seq внутри счета по-прежнему
+-- обеспечивает блокировка строки balance, а уникальность - таблица transaction_seq
+CREATE TABLE IF NOT EXISTS "transaction" (id UUID DEFAULT uuid_generate_v4() NOT NULL, entry_id UUID REFERENCES journal_entry(id) NOT NULL, user_id UUID REFERENCES balance(user_id) NOT NULL, change_balance BIGINT NOT NULL, operation TEXT NOT NULL CHECK (operation IN ('credit', 'withdraw', 'transfer', 'fee')), client TEXT NOT NULL DEFAULT '', comment TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, seq BIGINT NOT NULL, prev_hash TEXT NOT NULL, hash TEXT NOT NULL DEFAULT '', PRIMARY KEY (id, created_at), UNIQUE (user_id, seq, created_at)) PARTITION BY RANGE (created_at);
+-- движения месяцев, для которых секция еще не создана
+CREATE TABLE IF NOT EXISTS transaction_default PARTITION OF "transaction" DEFAULT;
+CREATE INDEX balance_user_id_idx ON balance (user_id);
+CREATE INDEX balance_overdraft_idx ON balance (amount) WHERE amount < 0;
+CREATE INDEX transaction_user_id_operation_idx ON "transaction" (user_id, operation, created_at);
+CREATE INDEX transaction_entry_id_idx ON "transaction" (entry_id);
+CREATE INDEX transaction_user_id_created_at_idx ON "transaction" (user_id, created_at);
+-- create_transaction_partition создает секцию месяца month, если ее нет. Движения этого месяца,
+-- попавшие в секцию по умолчанию, переносятся в новую секцию до ее подключения
+CREATE OR REPLACE FUNCTION create_transaction_partition(month DATE) RETURNS BOOLEAN AS $$
+DECLARE
+    name TEXT := 'transaction_' || to_char(month, 'YYYY_MM');
+    from_ts TIMESTAMP := date_trunc('month', month);
+    to_ts TIMESTAMP := date_trunc('month', month) + INTERVAL '1 month';
+BEGIN
+    -- секции создают все экземпляры сервиса
+    PERFORM pg_advisory_xact_lock(hashtext('create_transaction_partition'));
+    IF to_regclass(name) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+    -- до подключения секции вставка движения месяца попала бы в секцию по умолчанию уже после переноса
+    -- и ATTACH завершился бы ошибкой, поэтому вставки ждут до конца транзакции
+    LOCK TABLE "transaction" IN SHARE ROW EXCLUSIVE MODE;
+    EXECUTE format('CREATE TABLE %I (LIKE "transaction" INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', name);
+    EXECUTE format('WITH moved AS (DELETE FROM transaction_default WHERE created_at >= %L AND created_at < %L RETURNING *) INSERT INTO %I SELECT * FROM moved', from_ts, to_ts, name);
+    EXECUTE format('ALTER TABLE "transaction" ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)', name, from_ts, to_ts);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+SELECT create_transaction_partition((date_trunc('month', localtimestamp) + n * INTERVAL '1 month')::date) FROM generate_series(0, 2) n;
+-- сумма движений каждой проводки должна быть равна нулю, проверяется при commit
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF (SELECT sum(change_balance) FROM "transaction" WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE CONSTRAINT TRIGGER transaction_entry_balanced AFTER INSERT OR UPDATE ON "transaction" DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE check_journal_entry_balanced();
+-- уникальный ключ секционированной таблицы обязан включать created_at, поэтому номера движений счета
+-- дублируются в несекционированную таблицу, которая не очищается при архивировании
+CREATE TABLE IF NOT EXISTS transaction_seq (user_id UUID NOT NULL, seq BIGINT NOT NULL, PRIMARY KEY (user_id, seq));
+CREATE OR REPLACE FUNCTION register_transaction_seq() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO transaction_seq (user_id, seq) VALUES (NEW.user_id, NEW.seq);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER transaction_seq_unique AFTER INSERT ON "transaction" FOR EACH ROW EXECUTE PROCEDURE register_transaction_seq();
+CREATE TABLE IF NOT EXISTS webhook (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, url TEXT NOT NULL, events TEXT[] NOT NULL, secret TEXT NOT NULL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE TABLE IF NOT EXISTS webhook_delivery (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, webhook_id UUID REFERENCES webhook(id) ON DELETE CASCADE NOT NULL, event TEXT NOT NULL, payload TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')), attempts INT NOT NULL DEFAULT 0, response_code INT, last_error TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX webhook_delivery_webhook_id_idx ON webhook_delivery (webhook_id, created_at);
+CREATE INDEX webhook_delivery_status_idx ON webhook_delivery (status);
+CREATE TABLE IF NOT EXISTS user_limit (user_id UUID PRIMARY KEY, withdraw_daily BIGINT CHECK (withdraw_daily >= 0), withdraw_monthly BIGINT CHECK (withdraw_monthly >= 0), transfer_daily BIGINT CHECK (transfer_daily >= 0), transfer_monthly BIGINT CHECK (transfer_monthly >= 0), single_operation_max BIGINT CHECK (single_operation_max >= 0), transfers_per_day INT CHECK (transfers_per_day >= 0), updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE TABLE IF NOT EXISTS account_status_history (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, user_id UUID REFERENCES balance(user_id) NOT NULL, old_status TEXT NOT NULL, new_status TEXT NOT NULL, reason TEXT NOT NULL, client TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX account_status_history_user_id_idx ON account_status_history (user_id, created_at);
+CREATE TABLE IF NOT EXISTS scheduled_operation (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, operation TEXT NOT NULL CHECK (operation IN ('withdraw', 'transfer')), user_id UUID NOT NULL, receiver_id UUID, amount BIGINT NOT NULL CHECK (amount > 0), schedule TEXT NOT NULL DEFAULT '', next_run_at TIMESTAMP NOT NULL, next_attempt_at TIMESTAMP NOT NULL, attempts INT NOT NULL DEFAULT 0, status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled', 'failed')), last_error TEXT, client TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX scheduled_operation_due_idx ON scheduled_operation (next_attempt_at) WHERE status = 'active';
+CREATE INDEX scheduled_operation_user_id_idx ON scheduled_operation (user_id);
+CREATE INDEX scheduled_operation_receiver_id_idx ON scheduled_operation (receiver_id);
+CREATE TABLE IF NOT EXISTS scheduled_occurrence (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, operation_id UUID REFERENCES scheduled_operation(id) NOT NULL, scheduled_for TIMESTAMP NOT NULL, status TEXT NOT NULL CHECK (status IN ('succeeded', 'retrying', 'failed')), attempts INT NOT NULL, last_error TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, UNIQUE (operation_id, scheduled_for));
+CREATE TABLE IF NOT EXISTS escrow (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, deal_id TEXT NOT NULL, payer_id UUID NOT NULL, payee_id UUID NOT NULL, amount BIGINT NOT NULL CHECK (amount > 0), status TEXT NOT NULL CHECK (status IN ('funded', 'released', 'cancelled', 'expired')), expires_at TIMESTAMP NOT NULL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, UNIQUE(deal_id));
+CREATE INDEX escrow_expires_at_idx ON escrow (expires_at) WHERE status = 'funded';
+CREATE TABLE IF NOT EXISTS escrow_event (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, escrow_id UUID REFERENCES escrow(id) NOT NULL, status TEXT NOT NULL, comment TEXT NOT NULL DEFAULT '', client TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX escrow_event_escrow_id_idx ON escrow_event (escrow_id, created_at);
+CREATE TABLE IF NOT EXISTS invoice (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, payer_id UUID NOT NULL, amount BIGINT NOT NULL CHECK (amount > 0), description TEXT NOT NULL DEFAULT '', due_at TIMESTAMP NOT NULL, status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'paid', 'cancelled', 'expired')), client TEXT NOT NULL DEFAULT '', paid_at TIMESTAMP, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX invoice_payer_id_idx ON invoice (payer_id, created_at);
+CREATE INDEX invoice_due_at_idx ON invoice (due_at) WHERE status = 'open';
+CREATE TABLE IF NOT EXISTS invoice_item (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, invoice_id UUID REFERENCES invoice(id) ON DELETE CASCADE NOT NULL, position INT NOT NULL, description TEXT NOT NULL, quantity INT NOT NULL CHECK (quantity > 0), price BIGINT NOT NULL CHECK (price >= 0), UNIQUE(invoice_id, position));
+CREATE TABLE IF NOT EXISTS money_request (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, requester_id UUID NOT NULL, payer_id UUID NOT NULL, amount BIGINT NOT NULL CHECK (amount > 0), message TEXT NOT NULL DEFAULT '', status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'expired')), expires_at TIMESTAMP NOT NULL, client TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, CHECK (requester_id <> payer_id));
+CREATE INDEX money_request_requester_id_idx ON money_request (requester_id, created_at) WHERE status = 'pending';
+CREATE INDEX money_request_payer_id_idx ON money_request (payer_id, created_at) WHERE status = 'pending';
+CREATE INDEX money_request_expires_at_idx ON money_request (expires_at) WHERE status = 'pending';
+CREATE TABLE IF NOT EXISTS reconciliation_adjustment (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, user_id UUID REFERENCES balance(user_id) NOT NULL, old_amount BIGINT NOT NULL, new_amount BIGINT NOT NULL, reason TEXT NOT NULL, client TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX reconciliation_adjustment_user_id_idx ON reconciliation_adjustment (user_id, created_at);
+-- месяцы движений, выгруженные в файлы и удаленные из "transaction"
+CREATE TABLE IF NOT EXISTS transaction_archive (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, period_start TIMESTAMP NOT NULL, period_end TIMESTAMP NOT NULL, file TEXT NOT NULL, postings INT NOT NULL, checksum TEXT NOT NULL, archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+-- итоги архивных движений счета: по ним сверяются балансы и продолжается цепочка хешей
+CREATE TABLE IF NOT EXISTS transaction_archive_account (archive_id UUID REFERENCES transaction_archive(id) NOT NULL, user_id UUID REFERENCES balance(user_id) NOT NULL, postings INT NOT NULL, total BIGINT NOT NULL, last_seq BIGINT NOT NULL, last_hash TEXT NOT NULL, PRIMARY KEY (archive_id, user_id));
+CREATE INDEX transaction_archive_account_user_id_idx ON transaction_archive_account (user_id, last_seq);
+CREATE TABLE IF NOT EXISTS audit_log (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, request_id TEXT NOT NULL, client TEXT NOT NULL DEFAULT '', method TEXT NOT NULL, path TEXT NOT NULL, source_ip TEXT NOT NULL, payload_hash TEXT NOT NULL, status INT NOT NULL, result TEXT NOT NULL CHECK (result IN ('success', 'failure')), error TEXT, user_ids UUID[] NOT NULL DEFAULT '{}', transaction_ids UUID[] NOT NULL DEFAULT '{}', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_client_idx ON audit_log (client, created_at);
+CREATE INDEX audit_log_user_ids_idx ON audit_log USING GIN (user_ids);
@@ -0,0 +1,61 @@
+-- переводит базу, созданную до секционирования "transaction", на таблицу с секциями по месяцам.
+-- Выполняется один раз при остановленном сервисе: psql -U docker -d avito -f partition_transaction.sql
+BEGIN;
+ALTER TABLE "transaction" RENAME TO transaction_unpartitioned;
+ALTER TABLE transaction_unpartitioned RENAME CONSTRAINT transaction_pkey TO transaction_unpartitioned_pkey;
+ALTER TABLE transaction_unpartitioned RENAME CONSTRAINT transaction_user_id_seq_key TO transaction_unpartitioned_user_id_seq_key;
+ALTER INDEX transaction_user_id_operation_idx RENAME TO transaction_unpartitioned_user_id_operation_idx;
+ALTER INDEX transaction_entry_id_idx RENAME TO transaction_unpartitioned_entry_id_idx;
+DROP TRIGGER transaction_entry_balanced ON transaction_unpartitioned;
+
+CREATE TABLE "transaction" (id UUID DEFAULT uuid_generate_v4() NOT NULL, entry_id UUID REFERENCES journal_entry(id) NOT NULL, user_id UUID REFERENCES balance(user_id) NOT NULL, change_balance BIGINT NOT NULL, operation TEXT NOT NULL CHECK (operation IN ('credit', 'withdraw', 'transfer', 'fee')), client TEXT NOT NULL DEFAULT '', comment TEXT NOT NULL DEFAULT '', created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL, seq BIGINT NOT NULL, prev_hash TEXT NOT NULL, hash TEXT NOT NULL DEFAULT '', PRIMARY KEY (id, created_at), UNIQUE (user_id, seq, created_at)) PARTITION BY RANGE (created_at);
+CREATE TABLE transaction_default PARTITION OF "transaction" DEFAULT;
+CREATE INDEX transaction_user_id_operation_idx ON "transaction" (user_id, operation, created_at);
+CREATE INDEX transaction_entry_id_idx ON "transaction" (entry_id);
+CREATE INDEX transaction_user_id_created_at_idx ON "transaction" (user_id, created_at);
+CREATE CONSTRAINT TRIGGER transaction_entry_balanced AFTER INSERT OR UPDATE ON "transaction" DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE check_journal_entry_balanced();
+-- create_transaction_partition создает секцию месяца month, если ее нет. Движения этого месяца,
+-- попавшие в секцию по умолчанию, переносятся в новую секцию до ее подключения
+CREATE OR REPLACE FUNCTION create_transaction_partition(month DATE) RETURNS BOOLEAN AS $$
+DECLARE
+    name TEXT := 'transaction_' || to_char(month, 'YYYY_MM');
+    from_ts TIMESTAMP := date_trunc('month', month);
+    to_ts TIMESTAMP := date_trunc('month', month) + INTERVAL '1 month';
+BEGIN
+    -- секции создают все экземпляры сервиса
+    PERFORM pg_advisory_xact_lock(hashtext('create_transaction_partition'));
+    IF to_regclass(name) IS NOT NULL THEN
+        RETURN FALSE;
+    END IF;
+    -- до подключения секции вставка движения месяца попала бы в секцию по умолчанию уже после переноса
+    -- и ATTACH завершился бы ошибкой, поэтому вставки ждут до конца транзакции
+    LOCK TABLE "transaction" IN SHARE ROW EXCLUSIVE MODE;
+    EXECUTE format('CREATE TABLE %I (LIKE "transaction" INCLUDING DEFAULTS INCLUDING CONSTRAINTS)', name);
+    EXECUTE format('WITH moved AS (DELETE FROM transaction_default WHERE created_at >= %L AND created_at < %L RETURNING *) INSERT INTO %I SELECT * FROM moved', from_ts, to_ts, name);
+    EXECUTE format('ALTER TABLE "transaction" ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)', name, from_ts, to_ts);
+    RETURN TRUE;
+END;
+$$ LANGUAGE plpgsql;
+-- секции для месяцев существующих движений и двух следующих месяцев
+SELECT create_transaction_partition(month::date) FROM (SELECT DISTINCT date_trunc('month', created_at) AS month FROM transaction_unpartitioned) months;
+SELECT create_transaction_partition((date_trunc('month', localtimestamp) + n * INTERVAL '1 month')::date) FROM generate_series(0, 2) n;
+-- уникальный ключ секционированной таблицы обязан включать created_at, поэтому номера движений счета
+-- дублируются в несекционированную таблицу, которая не очищается при архивировании
+CREATE TABLE IF NOT EXISTS transaction_seq (user_id UUID NOT NULL, seq BIGINT NOT NULL, PRIMARY KEY (user_id, seq));
+CREATE OR REPLACE FUNCTION register_transaction_seq() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO transaction_seq (user_id, seq) VALUES (NEW.user_id, NEW.seq);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER transaction_seq_unique AFTER INSERT ON "transaction" FOR EACH ROW EXECUTE PROCEDURE register_transaction_seq();
+INSERT INTO "transaction" (id, entry_id, user_id, change_balance, operation, client, comment, created_at, seq, prev_hash, hash)
+    SELECT id, entry_id, user_id, change_balance, operation, client, comment, created_at, seq, prev_hash, hash FROM transaction_unpartitioned;
+DROP TABLE transaction_unpartitioned;
+
+-- месяцы движений, выгруженные в файлы и удаленные из "transaction"
+CREATE TABLE IF NOT EXISTS transaction_archive (id UUID DEFAULT uuid_generate_v4() PRIMARY KEY, period_start TIMESTAMP NOT NULL, period_end TIMESTAMP NOT NULL, file TEXT NOT NULL, postings INT NOT NULL, checksum TEXT NOT NULL, archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL);
+-- итоги архивных движений счета: по ним сверяются балансы и продолжается цепочка хешей
+CREATE TABLE IF NOT EXISTS transaction_archive_account (archive_id UUID REFERENCES transaction_archive(id) NOT NULL, user_id UUID REFERENCES balance(user_id) NOT NULL, postings INT NOT NULL, total BIGINT NOT NULL, last_seq BIGINT NOT NULL, last_hash TEXT NOT NULL, PRIMARY KEY (archive_id, user_id));
+CREATE INDEX transaction_archive_account_user_id_idx ON transaction_archive_account (user_id, last_seq);
+COMMIT;